@@ -0,0 +1,109 @@
+package workers
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const defaultAllocationSampleRate = 0.01
+
+// AllocationProfileOptions configures the sampling of the memory allocated and the GC pauses seen by jobs,
+// reported by class in stats. Reading the memory stats briefly stops the world, so only a fraction of the
+// jobs is sampled.
+type AllocationProfileOptions struct {
+	// Optional fraction of the jobs sampled, between 0 and 1, defaults to 0.01
+	SampleRate float64
+}
+
+// ClassAllocations sums what the sampled jobs of a class allocated and the GC they saw. Memory stats are
+// process-wide, so jobs running alongside the sampled ones are attributed to them too: compare classes
+// sampled under similar loads.
+type ClassAllocations struct {
+	Sampled      int64  `json:"sampled"`
+	AllocBytes   uint64 `json:"alloc_bytes"`
+	AllocObjects uint64 `json:"alloc_objects"`
+	// GC cycles which completed while sampled jobs ran, and their stop-the-world pauses
+	GCCycles uint64        `json:"gc_cycles"`
+	GCPause  time.Duration `json:"gc_pause"`
+}
+
+// AvgAllocBytes returns the bytes allocated by an average sampled job
+func (a ClassAllocations) AvgAllocBytes() uint64 {
+	if a.Sampled == 0 {
+		return 0
+	}
+	return a.AllocBytes / uint64(a.Sampled)
+}
+
+type allocationProfiler struct {
+	rate float64
+
+	lock    sync.Mutex
+	classes map[string]*ClassAllocations
+}
+
+func newAllocationProfiler(opts *AllocationProfileOptions) *allocationProfiler {
+	if opts == nil {
+		return nil
+	}
+	rate := opts.SampleRate
+	if rate <= 0 {
+		rate = defaultAllocationSampleRate
+	}
+	return &allocationProfiler{rate: rate, classes: map[string]*ClassAllocations{}}
+}
+
+func (p *allocationProfiler) record(class string, before, after *runtime.MemStats) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	allocations, ok := p.classes[class]
+	if !ok {
+		allocations = &ClassAllocations{}
+		p.classes[class] = allocations
+	}
+	allocations.Sampled++
+	allocations.AllocBytes += after.TotalAlloc - before.TotalAlloc
+	allocations.AllocObjects += after.Mallocs - before.Mallocs
+	allocations.GCCycles += uint64(after.NumGC - before.NumGC)
+	allocations.GCPause += time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+}
+
+func (p *allocationProfiler) snapshot() map[string]ClassAllocations {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	res := make(map[string]ClassAllocations, len(p.classes))
+	for class, allocations := range p.classes {
+		res[class] = *allocations
+	}
+	return res
+}
+
+// ClassAllocations returns what the sampled jobs of every class allocated, by class, or nil without an
+// AllocationProfile
+func (m *Manager) ClassAllocations() map[string]ClassAllocations {
+	return m.allocations.snapshot()
+}
+
+// allocationJobFunc samples the memory stats around a fraction of the jobs
+func allocationJobFunc(m *Manager, next JobFunc) JobFunc {
+	if m.allocations == nil {
+		return next
+	}
+	return func(message *Msg) error {
+		if rand.Float64() >= m.allocations.rate {
+			return next(message)
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		defer func() {
+			runtime.ReadMemStats(&after)
+			m.allocations.record(message.Class(), &before, &after)
+		}()
+		return next(message)
+	}
+}
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var allocationSink [][]byte
+
+func TestAllocationProfile(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.AllocationProfile = &AllocationProfileOptions{SampleRate: 1}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	job := mgr.buildJob("profiled", func(m *Msg) error {
+		if m.Class() == "Hungry" {
+			for i := 0; i < 100; i++ {
+				allocationSink = append(allocationSink, make([]byte, 1<<16))
+			}
+		}
+		return nil
+	}, []MiddlewareFunc{NopMiddleware})
+	for _, class := range []string{"Hungry", "Hungry", "Frugal"} {
+		message, _ := NewMsg(`{"jid":"1","class":"` + class + `","args":[]}`)
+		assert.NoError(t, job(message))
+	}
+	allocationSink = nil
+
+	allocations := mgr.ClassAllocations()
+	assert.Equal(t, int64(2), allocations["Hungry"].Sampled)
+	assert.True(t, allocations["Hungry"].AvgAllocBytes() >= 100<<16)
+	assert.Equal(t, int64(1), allocations["Frugal"].Sampled)
+	assert.True(t, allocations["Frugal"].AllocBytes < allocations["Hungry"].AllocBytes)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, allocations, stats.Allocations)
+
+	opts.AllocationProfile = nil
+	mgr, err = newManager(opts)
+	assert.NoError(t, err)
+	assert.Nil(t, mgr.ClassAllocations())
+}
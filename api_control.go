@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ControlAction is a change to a manager posted to the control endpoint
+type ControlAction struct {
+	// Action is one of "pause", "resume", "quiet" or "concurrency"
+	Action string `json:"action"`
+
+	Queue       string `json:"queue,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+}
+
+// Control returns the control state of a manager on GET, and applies a ControlAction on POST
+func (s *apiServer) Control(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	mgr, err := s.requestManager(req.URL.Query().Get("manager"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var action ControlAction
+		if err := json.NewDecoder(req.Body).Decode(&action); err != nil {
+			http.Error(w, "invalid action: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := mgr.Manage(s.requestContext(req), action.Action, action.Queue, func() error {
+			return applyControlAction(mgr, action)
+		}); err != nil {
+			manageError(w, err, controlErrorStatus(err))
+			return
+		}
+	default:
+		http.Error(w, "control state is read with GET and changed with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(mgr.ControlState())
+}
+
+// InFlight lists the jobs being processed by a manager, longest running first
+func (s *apiServer) InFlight(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	mgr, err := s.requestManager(req.URL.Query().Get("manager"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jobs := mgr.InFlight()
+	if jobs == nil {
+		jobs = []InFlightJob{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+var (
+	errUnknownControlAction = errors.New("unknown action")
+	errInvalidConcurrency   = errors.New("concurrency must be positive")
+)
+
+// applyControlAction applies action to mgr
+func applyControlAction(mgr *Manager, action ControlAction) error {
+	switch action.Action {
+	case "pause":
+		mgr.Pause()
+	case "resume":
+		mgr.Resume()
+	case "quiet":
+		mgr.Quiet()
+	case "concurrency":
+		if action.Concurrency <= 0 {
+			return errInvalidConcurrency
+		}
+		return mgr.SetConcurrency(action.Queue, action.Concurrency)
+	default:
+		return fmt.Errorf("%w %s", errUnknownControlAction, action.Action)
+	}
+	return nil
+}
+
+// controlErrorStatus is the status of the requests whose action failed with err
+func controlErrorStatus(err error) int {
+	if errors.Is(err, errUnknownControlAction) || errors.Is(err, errInvalidConcurrency) {
+		return http.StatusBadRequest
+	}
+	return http.StatusNotFound
+}
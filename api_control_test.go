@@ -0,0 +1,58 @@
+package workers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlAPI(t *testing.T) {
+	a := &apiServer{
+		logger: log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds),
+	}
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("someq", 2, func(m *Msg) error { return nil })
+	a.registerManager(mgr)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		a.Control(recorder, httptest.NewRequest("POST", "/control", strings.NewReader(body)))
+		return recorder
+	}
+
+	recorder := post(`{"action":"pause"}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var state ControlState
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &state))
+	assert.True(t, state.Paused)
+	assert.True(t, mgr.IsPaused())
+
+	recorder = post(`{"action":"concurrency","queue":"someq","concurrency":5}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 5, mgr.workers[0].getConcurrency())
+
+	assert.Equal(t, http.StatusNotFound, post(`{"action":"concurrency","queue":"otherq","concurrency":5}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"action":"concurrency","queue":"someq"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"action":"restart"}`).Code)
+
+	post(`{"action":"resume"}`)
+	recorder = httptest.NewRecorder()
+	a.Control(recorder, httptest.NewRequest("GET", "/control", nil))
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &state))
+	assert.False(t, state.Paused)
+	assert.Equal(t, map[string]int{"someq": 5}, state.Concurrency)
+
+	recorder = httptest.NewRecorder()
+	a.InFlight(recorder, httptest.NewRequest("GET", "/inflight", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "[]\n", recorder.Body.String())
+}
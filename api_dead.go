@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultDeadPageSize is the number of dead jobs returned without a limit query parameter
+const defaultDeadPageSize = 100
+
+// Dead returns the dead jobs of every manager, up to the limit query parameter from the offset one
+func (s *apiServer) Dead(w http.ResponseWriter, req *http.Request) {
+	offset, limit := int64(0), int64(defaultDeadPageSize)
+	if value := req.URL.Query().Get("offset"); value != "" {
+		var err error
+		if offset, err = strconv.ParseInt(value, 10, 64); err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := req.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.ParseInt(value, 10, 64); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.lock.Lock()
+	managers := make([]*Manager, 0, len(s.managers))
+	for _, m := range s.managers {
+		managers = append(managers, m)
+	}
+	s.lock.Unlock()
+
+	allDead := []Dead{}
+	for _, m := range managers {
+		d, err := m.GetDead(req.Context(), offset, limit)
+		if err != nil {
+			s.logger.Println("couldn't retrieve dead jobs for manager:", err)
+		} else {
+			allDead = append(allDead, d)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(allDead)
+}
+
+// Dead stores dead job information
+type Dead struct {
+	TotalDeadCount int64  `json:"total_dead_count"`
+	DeadJobs       []*Msg `json:"dead_jobs"`
+
+	// Class, args and notes of every dead job, in the same order
+	DeadJobsDisplay []JobDisplay `json:"dead_jobs_display,omitempty"`
+}
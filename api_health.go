@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Health returns the status of every running manager, with a 503 status unless all of them are healthy
+func (s *apiServer) Health(w http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	managers := make([]*Manager, 0, len(s.managers))
+	for _, m := range s.managers {
+		managers = append(managers, m)
+	}
+	s.lock.Unlock()
+
+	code := http.StatusOK
+	if len(managers) == 0 {
+		code = http.StatusServiceUnavailable
+	}
+	statuses := []ManagerStatus{}
+	for _, m := range managers {
+		status := m.Status()
+		if status.Error != "" {
+			code = http.StatusServiceUnavailable
+		}
+		statuses = append(statuses, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthAPI(t *testing.T) {
+	a := &apiServer{}
+
+	recorder := httptest.NewRecorder()
+	a.Health(recorder, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "[]\n", recorder.Body.String())
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	a.registerManager(mgr)
+
+	recorder = httptest.NewRecorder()
+	a.Health(recorder, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	mgr.lock.Lock()
+	mgr.running = true
+	mgr.lock.Unlock()
+	recorder = httptest.NewRecorder()
+	a.Health(recorder, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"running":true`)
+}
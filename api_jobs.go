@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Purge removes every job waiting in the queue given by the queue query parameter on POST. The manager
+// is given by its manager parameter unless a single manager is registered.
+func (s *apiServer) Purge(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if req.Method != http.MethodPost {
+		http.Error(w, "queues are purged with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	mgr, err := s.requestManager(req.URL.Query().Get("manager"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	queue := req.URL.Query().Get("queue")
+	if queue == "" {
+		http.Error(w, "purges require a queue", http.StatusBadRequest)
+		return
+	}
+
+	var purged int64
+	if err := mgr.Manage(s.requestContext(req), "purge", queue, func() (err error) {
+		purged, err = mgr.PurgeQueue(req.Context(), queue)
+		return err
+	}); err != nil {
+		s.logger.Println("couldn't purge queue:", err)
+		manageError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]int64{"purged": purged})
+}
+
+// Requeue moves the job given by the set and jid query parameters to its queue on POST. The manager is
+// given by its manager parameter unless a single manager is registered.
+func (s *apiServer) Requeue(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if req.Method != http.MethodPost {
+		http.Error(w, "jobs are requeued with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	mgr, err := s.requestManager(req.URL.Query().Get("manager"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	set, err := ParseJobSet(req.URL.Query().Get("set"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jid := req.URL.Query().Get("jid")
+	if jid == "" {
+		http.Error(w, "requeues require a jid", http.StatusBadRequest)
+		return
+	}
+
+	var requeued bool
+	if err := mgr.Manage(s.requestContext(req), "requeue", string(set)+"/"+jid, func() (err error) {
+		requeued, err = mgr.RequeueJob(req.Context(), set, jid)
+		return err
+	}); err != nil {
+		s.logger.Println("couldn't requeue job:", err)
+		manageError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !requeued {
+		http.Error(w, "no job "+jid+" in "+string(set), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]string{"requeued": jid})
+}
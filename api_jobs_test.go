@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobsAPI(t *testing.T) {
+	ctx := context.Background()
+	a := &apiServer{
+		logger: log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds),
+	}
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	var audited []AuditRecord
+	opts.Authorize = func(ctx context.Context, action ManagementAction) error {
+		if action.Actor != "ops" {
+			return errors.New("read only")
+		}
+		return nil
+	}
+	opts.AuditSink = func(record AuditRecord) { audited = append(audited, record) }
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	a.registerManager(mgr)
+
+	post := func(handler http.HandlerFunc, target, actor string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", target, nil)
+		req.Header.Set(ActorHeader, actor)
+		handler(recorder, req)
+		return recorder
+	}
+
+	mgr.Producer().Enqueue("mailers", "Mail", nil)
+	mgr.Producer().EnqueueIn("mailers", "Mail", 60, nil)
+	scheduled, _ := opts.client.ZRange(ctx, "prod:"+string(ScheduledJobs), 0, -1).Result()
+	message, _ := NewMsg(scheduled[0])
+
+	// the authorization policy applies to the API purges and requeues
+	assert.Equal(t, http.StatusForbidden, post(a.Purge, "/purge?queue=mailers", "intern").Code)
+	assert.Equal(t, int64(1), opts.client.LLen(ctx, "prod:queue:mailers").Val())
+
+	recorder := post(a.Purge, "/purge?queue=mailers", "ops")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "{\"purged\":1}\n", recorder.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, post(a.Requeue, "/requeue?set=schedule&jid=other", "ops").Code)
+	assert.Equal(t, http.StatusBadRequest, post(a.Requeue, "/requeue?set=queue&jid="+message.Jid(), "ops").Code)
+	recorder = post(a.Requeue, "/requeue?set=schedule&jid="+message.Jid(), "ops")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, int64(1), opts.client.LLen(ctx, "prod:queue:mailers").Val())
+
+	if assert.Len(t, audited, 4) {
+		assert.Equal(t, "intern", audited[0].Actor)
+		assert.True(t, audited[0].Denied)
+		assert.Equal(t, "purge", audited[1].Action)
+		assert.Equal(t, "requeue", audited[3].Action)
+		assert.Equal(t, "schedule/"+message.Jid(), audited[3].Target)
+	}
+
+	recorder = httptest.NewRecorder()
+	a.Purge(recorder, httptest.NewRequest("GET", "/purge?queue=mailers", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
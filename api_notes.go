@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Notes lists the notes of the job given by the jid query parameter on GET, adds a note on POST and
+// removes them all on DELETE. A note is posted as a JSON object with a text and an optional author.
+func (s *apiServer) Notes(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	mgr, err := s.requestManager(req.URL.Query().Get("manager"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jid := req.URL.Query().Get("jid")
+	if jid == "" {
+		http.Error(w, "notes require a jid", http.StatusBadRequest)
+		return
+	}
+
+	var reply interface{}
+	switch req.Method {
+	case http.MethodGet:
+		notes, err := mgr.JobNotes(req.Context(), jid)
+		if err != nil {
+			s.logger.Println("couldn't retrieve notes:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if notes == nil {
+			notes = []JobNote{}
+		}
+		reply = notes
+	case http.MethodPost:
+		var note JobNote
+		if err := json.NewDecoder(req.Body).Decode(&note); err != nil {
+			http.Error(w, "invalid note: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if note.Text == "" {
+			http.Error(w, "notes require a text", http.StatusBadRequest)
+			return
+		}
+		if err := mgr.Manage(s.requestContext(req), "add_note", jid, func() (err error) {
+			reply, err = mgr.AddJobNote(req.Context(), jid, note.Author, note.Text)
+			return err
+		}); err != nil {
+			s.logger.Println("couldn't add note:", err)
+			manageError(w, err, http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		if err := mgr.Manage(s.requestContext(req), "clear_notes", jid, func() error {
+			return mgr.ClearJobNotes(req.Context(), jid)
+		}); err != nil {
+			s.logger.Println("couldn't remove notes:", err)
+			manageError(w, err, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "notes are listed with GET, added with POST and removed with DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(reply)
+}
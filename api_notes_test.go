@@ -0,0 +1,104 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotesAPI(t *testing.T) {
+	a := &apiServer{
+		logger: log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds),
+	}
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	a.registerManager(&Manager{opts: opts, uuid: "mgr"})
+
+	recorder := httptest.NewRecorder()
+	a.Notes(recorder, httptest.NewRequest("GET", "/notes", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.Notes(recorder, httptest.NewRequest("POST", "/notes?jid=1", strings.NewReader(`{"author":"ops"}`)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	body := strings.NewReader(`{"author":"ops","text":"investigating, do not retry"}`)
+	a.Notes(recorder, httptest.NewRequest("POST", "/notes?jid=1&manager=mgr", body))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.Notes(recorder, httptest.NewRequest("GET", "/notes?jid=1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var notes []JobNote
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &notes))
+	assert.Len(t, notes, 1)
+	assert.Equal(t, "ops", notes[0].Author)
+	assert.Equal(t, "investigating, do not retry", notes[0].Text)
+
+	recorder = httptest.NewRecorder()
+	a.Notes(recorder, httptest.NewRequest("DELETE", "/notes?jid=1", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.Notes(recorder, httptest.NewRequest("GET", "/notes?jid=1", nil))
+	assert.Equal(t, "[]\n", recorder.Body.String())
+}
+
+func TestDeadAPI(t *testing.T) {
+	a := &apiServer{
+		logger: log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds),
+	}
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, uuid: "mgr"}
+	a.registerManager(mgr)
+
+	ctx := context.Background()
+	assert.NoError(t, opts.store.EnqueueDeadMessage(ctx, 1, `{"jid":"1","class":"Add","args":[1]}`))
+	_, err = mgr.AddJobNote(ctx, "1", "ops", "known issue")
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	a.Dead(recorder, httptest.NewRequest("GET", "/dead", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var dead []struct {
+		TotalDeadCount  int64        `json:"total_dead_count"`
+		DeadJobsDisplay []JobDisplay `json:"dead_jobs_display"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &dead))
+	assert.Len(t, dead, 1)
+	assert.Equal(t, int64(1), dead[0].TotalDeadCount)
+	assert.Equal(t, "known issue", dead[0].DeadJobsDisplay[0].Notes[0].Text)
+
+	// dead jobs are returned a page at a time
+	assert.NoError(t, opts.store.EnqueueDeadMessage(ctx, 2, `{"jid":"2","class":"Add","args":[2]}`))
+	assert.NoError(t, opts.store.EnqueueDeadMessage(ctx, 3, `{"jid":"3","class":"Add","args":[3]}`))
+	recorder = httptest.NewRecorder()
+	a.Dead(recorder, httptest.NewRequest("GET", "/dead?offset=1&limit=1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var page []struct {
+		TotalDeadCount int64 `json:"total_dead_count"`
+		DeadJobs       []struct {
+			Jid string `json:"jid"`
+		} `json:"dead_jobs"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+	assert.Len(t, page, 1)
+	assert.Equal(t, int64(3), page[0].TotalDeadCount)
+	if assert.Len(t, page[0].DeadJobs, 1) {
+		assert.Equal(t, "2", page[0].DeadJobs[0].Jid)
+	}
+
+	recorder = httptest.NewRecorder()
+	a.Dead(recorder, httptest.NewRequest("GET", "/dead?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
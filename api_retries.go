@@ -35,6 +35,16 @@ func (s *apiServer) Retries(w http.ResponseWriter, req *http.Request) {
 type Retries struct {
 	TotalRetryCount int64  `json:"total_retry_count"`
 	RetryJobs       []*Msg `json:"retry_jobs"`
+
+	// Class, args and notes of every retry job, in the same order
+	RetryJobsDisplay []JobDisplay `json:"retry_jobs_display,omitempty"`
+}
+
+// JobDisplay is the class and args of a job as Sidekiq's Web UI shows them, and the notes attached to it
+type JobDisplay struct {
+	Class string        `json:"display_class"`
+	Args  []interface{} `json:"display_args"`
+	Notes []JobNote     `json:"notes,omitempty"`
 }
 
 func parseURLQuery(req *http.Request) (uint64, int64, string, error) {
@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,9 @@ import (
 type APIOptions struct {
 	Logger *log.Logger
 	Mux    *http.ServeMux
+	// Optional actor of the management actions of a request, such as the user of an authenticated
+	// session. Defaults to the ActorHeader, the basic authentication user or the remote address.
+	Actor func(req *http.Request) string
 }
 
 type apiServer struct {
@@ -20,6 +24,7 @@ type apiServer struct {
 	managers map[string]*Manager
 	logger   *log.Logger
 	mux      *http.ServeMux
+	actor    func(req *http.Request) string
 }
 
 func (s *apiServer) registerManager(m *Manager) {
@@ -37,6 +42,26 @@ func (s *apiServer) deregisterManager(m *Manager) {
 	delete(s.managers, m.uuid)
 }
 
+// requestManager returns the manager with the given uuid, or the only registered manager when uuid is empty
+func (s *apiServer) requestManager(uuid string) (*Manager, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if uuid != "" {
+		if mgr, ok := s.managers[uuid]; ok {
+			return mgr, nil
+		}
+		return nil, errors.New("unknown manager " + uuid)
+	}
+	if len(s.managers) > 1 {
+		return nil, errors.New("a manager is required when several are registered")
+	}
+	for _, mgr := range s.managers {
+		return mgr, nil
+	}
+	return nil, errors.New("no manager is registered")
+}
+
 var globalHTTPServer *http.Server
 
 var globalAPIServer = &apiServer{
@@ -54,12 +79,24 @@ func ConfigureAPIServer(options APIOptions) {
 	if options.Mux != nil {
 		globalAPIServer.mux = options.Mux
 	}
+
+	if options.Actor != nil {
+		globalAPIServer.actor = options.Actor
+	}
 }
 
 // RegisterAPIEndpoints sets up API server endpoints
 func RegisterAPIEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc("/stats", globalAPIServer.Stats)
 	mux.HandleFunc("/retries", globalAPIServer.Retries)
+	mux.HandleFunc("/snapshot", globalAPIServer.Snapshot)
+	mux.HandleFunc("/purge", globalAPIServer.Purge)
+	mux.HandleFunc("/requeue", globalAPIServer.Requeue)
+	mux.HandleFunc("/dead", globalAPIServer.Dead)
+	mux.HandleFunc("/notes", globalAPIServer.Notes)
+	mux.HandleFunc("/control", globalAPIServer.Control)
+	mux.HandleFunc("/inflight", globalAPIServer.InFlight)
+	mux.HandleFunc("/health", globalAPIServer.Health)
 }
 
 // StartAPIServer starts the API server
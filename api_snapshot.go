@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Snapshot exports a queue or job set as JSON lines on GET, and imports such a snapshot on POST.
+// The target is given by the queue or set query parameter, and the manager by its manager parameter
+// unless a single manager is registered.
+func (s *apiServer) Snapshot(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	mgr, err := s.requestManager(req.URL.Query().Get("manager"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	queue := req.URL.Query().Get("queue")
+	var set JobSet
+	if queue == "" {
+		if set, err = ParseJobSet(req.URL.Query().Get("set")); err != nil {
+			http.Error(w, "snapshots require a queue or a set: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if queue != "" {
+			_, err = mgr.ExportQueue(req.Context(), w, queue)
+		} else {
+			_, err = mgr.ExportSet(req.Context(), w, set)
+		}
+		if err != nil {
+			s.logger.Println("couldn't export snapshot:", err)
+		}
+	case http.MethodPost:
+		var imported int
+		target := queue
+		if queue == "" {
+			target = string(set)
+		}
+		err = mgr.Manage(s.requestContext(req), "import", target, func() (err error) {
+			if queue != "" {
+				imported, err = mgr.ImportQueue(req.Context(), req.Body, queue)
+			} else {
+				imported, err = mgr.ImportSet(req.Context(), req.Body, set)
+			}
+			return err
+		})
+		if err != nil {
+			s.logger.Println("couldn't import snapshot:", err)
+			manageError(w, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]int{"imported": imported})
+	default:
+		http.Error(w, "snapshots are exported with GET and imported with POST", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotAPI(t *testing.T) {
+	a := &apiServer{
+		logger: log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds),
+	}
+
+	recorder := httptest.NewRecorder()
+	a.Snapshot(recorder, httptest.NewRequest("GET", "/snapshot?queue=snap", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, uuid: "mgr"}
+	a.registerManager(mgr)
+
+	recorder = httptest.NewRecorder()
+	a.Snapshot(recorder, httptest.NewRequest("GET", "/snapshot", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	body := strings.NewReader("{\"job\":{\"jid\":\"1\",\"class\":\"Add\",\"args\":[1]}}\n")
+	a.Snapshot(recorder, httptest.NewRequest("POST", "/snapshot?set=retry&manager=mgr", body))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "{\"imported\":1}\n", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	a.Snapshot(recorder, httptest.NewRequest("GET", "/snapshot?set=retry", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"job":{"jid":"1","class":"Add","args":[1]}`)
+
+	recorder = httptest.NewRecorder()
+	a.Snapshot(recorder, httptest.NewRequest("GET", "/snapshot?set=retry&manager=other", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
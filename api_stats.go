@@ -27,15 +27,42 @@ func (s *apiServer) Stats(w http.ResponseWriter, req *http.Request) {
 // Stats containts current stats for a manager
 type Stats struct {
 	Name       string                 `json:"manager_name"`
+	Tag        string                 `json:"tag"`
+	Labels     map[string]string      `json:"labels,omitempty"`
 	Processed  int64                  `json:"processed"`
 	Failed     int64                  `json:"failed"`
+	Duplicates int64                  `json:"duplicates"`
 	Jobs       map[string][]JobStatus `json:"jobs"`
 	Enqueued   map[string]int64       `json:"enqueued"`
 	RetryCount int64                  `json:"retry_count"`
+
+	// Failures by category, such as timeout or panic
+	FailureCategories map[string]int64 `json:"failure_categories"`
+
+	MiddlewareTimings map[string][]MiddlewareTiming `json:"middleware_timings"`
+
+	// Sample of the recent failures of every job class
+	RecentFailures map[string][]RecentFailure `json:"recent_failures"`
+
+	// Memory allocated and GC seen by the sampled jobs of every class
+	Allocations map[string]ClassAllocations `json:"allocations,omitempty"`
+
+	// How late scheduled and retried jobs were moved to their queue
+	SchedulerLag map[string]SchedulerLag `json:"scheduler_lag"`
+
+	// Documentation of the job classes, such as their owner
+	JobDocs map[string]JobDoc `json:"job_docs,omitempty"`
+
+	// Job classes registered with the dispatchers added by AddJobClasses
+	JobClasses []HandlerInfo `json:"job_classes,omitempty"`
 }
 
 // JobStatus contains the status and data for active jobs of a manager
 type JobStatus struct {
 	Message   *Msg  `json:"message"`
 	StartedAt int64 `json:"started_at"`
+
+	// Class and args as Sidekiq's Web UI shows them
+	DisplayClass string        `json:"display_class"`
+	DisplayArgs  []interface{} `json:"display_args"`
 }
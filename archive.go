@@ -0,0 +1,186 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultArchiveBatchSize     = 100
+	defaultArchiveFlushInterval = 5 * time.Second
+	defaultArchiveMaxArgsBytes  = 1024
+	defaultArchiveBufferSize    = 10000
+)
+
+// Outcomes of archived jobs
+const (
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRecord is the history of a job run, as archived
+type JobRecord struct {
+	Jid       string        `json:"jid"`
+	Class     string        `json:"class"`
+	Queue     string        `json:"queue"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Outcome   string        `json:"outcome"`
+	Error     string        `json:"error,omitempty"`
+	// JSON of the displayed args, redacted by Options.RedactArgs and truncated to MaxArgsBytes
+	Args string `json:"args"`
+}
+
+// ArchiveSink writes batches of job records to durable storage, such as files, object storage or an
+// analytics database
+type ArchiveSink interface {
+	Write(ctx context.Context, records []JobRecord) error
+}
+
+// ArchiveSinkFunc is an ArchiveSink written as a function
+type ArchiveSinkFunc func(ctx context.Context, records []JobRecord) error
+
+// Write calls f
+func (f ArchiveSinkFunc) Write(ctx context.Context, records []JobRecord) error {
+	return f(ctx, records)
+}
+
+type writerArchiveSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// NewWriterArchiveSink writes job records to w, such as a file, as JSON lines
+func NewWriterArchiveSink(w io.Writer) ArchiveSink {
+	return &writerArchiveSink{w: w}
+}
+
+func (s *writerArchiveSink) Write(ctx context.Context, records []JobRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	encoder := json.NewEncoder(s.w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveOptions configures the archival of the record of every job a manager runs
+type ArchiveOptions struct {
+	Sink ArchiveSink
+
+	// Optional number of records written at once, defaults to 100
+	BatchSize int
+	// Optional longest time a record waits for its batch to fill up, defaults to 5s
+	FlushInterval time.Duration
+	// Optional size the JSON args of records are truncated to, defaults to 1KB
+	MaxArgsBytes int
+	// Optional number of records waiting to be written, past which new records are dropped. Defaults to 10000.
+	BufferSize int
+}
+
+func (o ArchiveOptions) withDefaults() ArchiveOptions {
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultArchiveBatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = defaultArchiveFlushInterval
+	}
+	if o.MaxArgsBytes <= 0 {
+		o.MaxArgsBytes = defaultArchiveMaxArgsBytes
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultArchiveBufferSize
+	}
+	return o
+}
+
+type archiver struct {
+	opts    ArchiveOptions
+	records chan JobRecord
+}
+
+// newArchiver subscribes to the outcome of the jobs of m
+func newArchiver(m *Manager, opts ArchiveOptions) *archiver {
+	opts = opts.withDefaults()
+	a := &archiver{opts: opts, records: make(chan JobRecord, opts.BufferSize)}
+	record := func(e Event) {
+		a.add(m, e)
+	}
+	m.Subscribe(EventJobFinish, record)
+	m.Subscribe(EventJobFail, record)
+	return a
+}
+
+func (a *archiver) add(m *Manager, e Event) {
+	record := JobRecord{
+		Jid:       e.Message.Jid(),
+		Class:     e.Message.DisplayClass(),
+		Queue:     e.Queue,
+		StartedAt: e.Time.Add(-e.Elapsed),
+		Duration:  e.Elapsed,
+		Outcome:   JobSucceeded,
+	}
+	if e.Err != nil {
+		record.Outcome, record.Error = JobFailed, e.Err.Error()
+	}
+	if args, err := json.Marshal(m.displayArgs(e.Message)); err == nil {
+		if len(args) > a.opts.MaxArgsBytes {
+			args = args[:a.opts.MaxArgsBytes]
+		}
+		record.Args = string(args)
+	}
+
+	select {
+	case a.records <- record:
+	default:
+		m.logger.Println("ERR: archive buffer full, dropping the record of", record.Jid)
+	}
+}
+
+// run writes the records in batches until ctx is done, then writes the remaining ones
+func (a *archiver) run(ctx context.Context, m *Manager) {
+	ticker := time.NewTicker(a.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]JobRecord, 0, a.opts.BatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.opts.Sink.Write(ctx, batch); err != nil {
+			m.logger.Println("ERR: couldn't archive", len(batch), "job records:", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case record := <-a.records:
+			batch = append(batch, record)
+			if len(batch) >= a.opts.BatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			for {
+				select {
+				case record := <-a.records:
+					batch = append(batch, record)
+					if len(batch) >= a.opts.BatchSize {
+						flush(context.Background())
+					}
+				default:
+					flush(context.Background())
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	var batches [][]JobRecord
+	opts.Archive = &ArchiveOptions{
+		Sink: ArchiveSinkFunc(func(ctx context.Context, records []JobRecord) error {
+			lock.Lock()
+			defer lock.Unlock()
+			batches = append(batches, append([]JobRecord(nil), records...))
+			return nil
+		}),
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		MaxArgsBytes:  10,
+	}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	job := mgr.buildJob("archived", func(m *Msg) error {
+		if m.Jid() == "2" {
+			return errors.New("failed")
+		}
+		return nil
+	}, []MiddlewareFunc{NopMiddleware})
+	for _, jid := range []string{"1", "2", "3"} {
+		message, _ := NewMsg(`{"jid":"` + jid + `","class":"Report","args":["a long argument"]}`)
+		job(message)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		mgr.archiver.run(ctx, mgr)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(batches) == 1
+	}, time.Second, time.Millisecond)
+
+	// the last record is written on shutdown, without waiting for the interval
+	cancel()
+	<-done
+	if assert.Len(t, batches, 2) {
+		records := append(batches[0], batches[1]...)
+		assert.Equal(t, "1", records[0].Jid)
+		assert.Equal(t, "Report", records[0].Class)
+		assert.Equal(t, "archived", records[0].Queue)
+		assert.Equal(t, JobSucceeded, records[0].Outcome)
+		assert.Equal(t, `["a long a`, records[0].Args)
+		assert.Equal(t, JobFailed, records[1].Outcome)
+		assert.Equal(t, "failed", records[1].Error)
+		assert.Equal(t, "3", records[2].Jid)
+	}
+}
+
+func TestWriterArchiveSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterArchiveSink(&buf)
+	assert.NoError(t, sink.Write(context.Background(), []JobRecord{{Jid: "1"}, {Jid: "2"}}))
+
+	decoder := json.NewDecoder(&buf)
+	for _, jid := range []string{"1", "2"} {
+		var record JobRecord
+		assert.NoError(t, decoder.Decode(&record))
+		assert.Equal(t, jid, record.Jid)
+	}
+}
+
+func TestArchiveRequiresSink(t *testing.T) {
+	_, err := processOptions(Options{ServerAddr: testServerAddr, ProcessID: "1", Archive: &ArchiveOptions{}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ArgsTransformFunc rewrites the raw JSON args of a job before they are decoded into the handler's args struct
+type ArgsTransformFunc func(raw []byte) ([]byte, error)
+
+// RegisterArgsTransform adds transforms which run, in order, on the args of every job of class before decoding
+func (d *JobDispatcher) RegisterArgsTransform(class string, transforms ...ArgsTransformFunc) {
+	if d.transforms == nil {
+		d.transforms = map[string][]ArgsTransformFunc{}
+	}
+	d.transforms[class] = append(d.transforms[class], transforms...)
+}
+
+func applyArgsTransforms(raw []byte, transforms []ArgsTransformFunc) ([]byte, error) {
+	for _, transform := range transforms {
+		var err error
+		if raw, err = transform(raw); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// UnwrapArgsEnvelope replaces args wrapped in a legacy envelope, either {"<key>": [...]} or
+// [{"<key>": [...]}], with the wrapped value. Args without the envelope are left untouched.
+func UnwrapArgsEnvelope(key string) ArgsTransformFunc {
+	return func(raw []byte) ([]byte, error) {
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &envelope); err != nil {
+			var arr []map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &arr); err != nil || len(arr) != 1 {
+				return raw, nil
+			}
+			envelope = arr[0]
+		}
+
+		inner, ok := envelope[key]
+		if !ok {
+			return raw, nil
+		}
+		var innerArr []json.RawMessage
+		if err := json.Unmarshal(inner, &innerArr); err != nil {
+			// a single wrapped value becomes the only positional argument
+			return json.Marshal([]json.RawMessage{inner})
+		}
+		return inner, nil
+	}
+}
+
+// RenameArgsKeys renames the keys of every object in the args array, mapping old names to new ones
+func RenameArgsKeys(renames map[string]string) ArgsTransformFunc {
+	return func(raw []byte) ([]byte, error) {
+		var arr []json.RawMessage
+		if err := json.Unmarshal(raw, &arr); err != nil {
+			return nil, fmt.Errorf("args must be an array: %v", err)
+		}
+
+		for i, elem := range arr {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(elem, &obj); err != nil {
+				continue
+			}
+			for from, to := range renames {
+				if value, ok := obj[from]; ok {
+					delete(obj, from)
+					obj[to] = value
+				}
+			}
+			renamed, err := json.Marshal(obj)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = renamed
+		}
+		return json.Marshal(arr)
+	}
+}
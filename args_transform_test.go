@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type transformTestArgs struct {
+	Name  string
+	Count int
+}
+
+type transformTestHandler struct {
+	got *transformTestArgs
+}
+
+func (h *transformTestHandler) HandleJob(args interface{}) error {
+	h.got = args.(*transformTestArgs)
+	return nil
+}
+
+func transformTestMsg(t *testing.T, args interface{}) *Msg {
+	payload, err := json.Marshal(map[string]interface{}{"class": "Legacy", "jid": "jid", "args": args})
+	assert.NoError(t, err)
+	msg, err := NewMsg(string(payload))
+	assert.NoError(t, err)
+	return msg
+}
+
+func TestDispatchArgsTransforms(t *testing.T) {
+	d := NewJobDispatcher()
+	handler := &transformTestHandler{}
+	assert.NoError(t, d.RegisterHandler("Legacy", handler, &transformTestArgs{}))
+	d.RegisterArgsTransform("Legacy", UnwrapArgsEnvelope("payload"))
+
+	tests := []struct {
+		name string
+		args interface{}
+	}{
+		{name: "current", args: []interface{}{"a", 2}},
+		{name: "object envelope", args: map[string]interface{}{"payload": []interface{}{"a", 2}}},
+		{name: "array envelope", args: []interface{}{map[string]interface{}{"payload": []interface{}{"a", 2}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.got = nil
+			assert.NoError(t, d.Dispatch(transformTestMsg(t, tt.args)))
+			assert.Equal(t, &transformTestArgs{Name: "a", Count: 2}, handler.got)
+		})
+	}
+
+	d.RegisterArgsTransform("Legacy", func(raw []byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	assert.Error(t, d.Dispatch(transformTestMsg(t, []interface{}{"a", 2})))
+}
+
+func TestRenameArgsKeys(t *testing.T) {
+	rename := RenameArgsKeys(map[string]string{"user": "user_id"})
+
+	out, err := rename([]byte(`[{"user":1,"keep":true},2,null]`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"user_id":1,"keep":true},2,null]`, string(out))
+
+	_, err = rename([]byte(`{"user":1}`))
+	assert.Error(t, err)
+}
+
+func TestUnwrapArgsEnvelope(t *testing.T) {
+	unwrap := UnwrapArgsEnvelope("args")
+
+	out, err := unwrap([]byte(`{"args":{"id":1}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1}]`, string(out))
+
+	out, err = unwrap([]byte(`[1,2]`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[1,2]`, string(out))
+}
@@ -0,0 +1,127 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultAutoscaleTargetLatency = 10 * time.Second
+	defaultAutoscaleCheckInterval = 10 * time.Second
+)
+
+// AutoscaleOptions configures the autoscaler, which periodically adjusts the concurrency of every
+// queue to keep the latency of its oldest job under a target. Managers with an autoscaler don't burst.
+type AutoscaleOptions struct {
+	// Bounds of the concurrency of every queue, with per-queue overrides. A zero minimum is one
+	// runner, a zero maximum is the concurrency the queue was added with.
+	MinConcurrency      int
+	MaxConcurrency      int
+	QueueMinConcurrency map[string]int
+	QueueMaxConcurrency map[string]int
+
+	// Optional latency of the oldest job above which a queue scales up, defaults to 10 seconds.
+	// Queues scale down once their latency is under half the target and some runners are idle.
+	TargetLatency time.Duration
+	// Optional interval between latency checks, defaults to 10 seconds
+	CheckInterval time.Duration
+}
+
+func (o AutoscaleOptions) bounds(queue string, configured int) (int, int) {
+	min, max := o.MinConcurrency, o.MaxConcurrency
+	if queueMin, ok := o.QueueMinConcurrency[queue]; ok {
+		min = queueMin
+	}
+	if queueMax, ok := o.QueueMaxConcurrency[queue]; ok {
+		max = queueMax
+	}
+	if min <= 0 {
+		min = 1
+	}
+	if max <= 0 {
+		max = configured
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// autoscaledConcurrency returns the concurrency of a queue given its latency and busy runners
+func autoscaledConcurrency(current, busy, min, max int, latency, target time.Duration) int {
+	next := current
+	switch {
+	case latency > target:
+		step := current / 2
+		if step < 1 {
+			step = 1
+		}
+		next = current + step
+	case latency < target/2 && busy < current:
+		step := (current - busy) / 2
+		if step < 1 {
+			step = 1
+		}
+		next = current - step
+	}
+	if next < min {
+		next = min
+	}
+	if next > max {
+		next = max
+	}
+	return next
+}
+
+// autoscale adjusts the concurrency of every worker once
+func (m *Manager) autoscale(ctx context.Context, opts AutoscaleOptions, configured map[*worker]int) {
+	for w, concurrency := range configured {
+		latency, err := m.workerLatency(ctx, w)
+		if err != nil {
+			m.logger.Println("ERR: couldn't read the latency of", w.queue, ":", err)
+			continue
+		}
+		min, max := opts.bounds(w.queue, concurrency)
+		current := w.getConcurrency()
+		next := autoscaledConcurrency(current, len(w.inProgressMessages()), min, max, latency, opts.TargetLatency)
+		if next != current {
+			m.logger.Println("autoscaling", w.queue, "from", current, "to", next, "runners for a latency of", latency)
+			w.setConcurrency(next)
+		}
+	}
+}
+
+// runAutoscale adjusts the concurrency of every worker until ctx is done, then restores the
+// concurrency they were added with
+func (m *Manager) runAutoscale(ctx context.Context, opts AutoscaleOptions) {
+	if opts.TargetLatency <= 0 {
+		opts.TargetLatency = defaultAutoscaleTargetLatency
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultAutoscaleCheckInterval
+	}
+
+	m.lock.Lock()
+	configured := map[*worker]int{}
+	for _, w := range m.workers {
+		configured[w] = w.getConcurrency()
+	}
+	m.lock.Unlock()
+	defer func() {
+		for w, concurrency := range configured {
+			w.setConcurrency(concurrency)
+		}
+	}()
+
+	m.autoscale(ctx, opts, configured)
+	ticker := time.NewTicker(opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.autoscale(ctx, opts, configured)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoscaledConcurrency(t *testing.T) {
+	target := 10 * time.Second
+	assert.Equal(t, 6, autoscaledConcurrency(4, 4, 1, 10, 20*time.Second, target))
+	assert.Equal(t, 2, autoscaledConcurrency(1, 1, 1, 10, 20*time.Second, target))
+	assert.Equal(t, 10, autoscaledConcurrency(8, 8, 1, 10, 20*time.Second, target))
+	// between half the target and the target, the concurrency holds
+	assert.Equal(t, 4, autoscaledConcurrency(4, 1, 1, 10, 7*time.Second, target))
+	assert.Equal(t, 5, autoscaledConcurrency(8, 2, 1, 10, time.Second, target))
+	assert.Equal(t, 8, autoscaledConcurrency(8, 8, 1, 10, time.Second, target))
+	assert.Equal(t, 3, autoscaledConcurrency(4, 0, 3, 10, 0, target))
+	assert.Equal(t, 2, autoscaledConcurrency(1, 0, 2, 10, 0, target))
+}
+
+func TestAutoscaleOptionsBounds(t *testing.T) {
+	opts := AutoscaleOptions{MaxConcurrency: 20, QueueMinConcurrency: map[string]int{"critical": 4}}
+	min, max := opts.bounds("default", 5)
+	assert.Equal(t, 1, min)
+	assert.Equal(t, 20, max)
+	min, max = opts.bounds("critical", 5)
+	assert.Equal(t, 4, min)
+	assert.Equal(t, 20, max)
+
+	min, max = AutoscaleOptions{}.bounds("default", 5)
+	assert.Equal(t, 1, min)
+	assert.Equal(t, 5, max)
+}
+
+func TestManager_Autoscale(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("late", 2, func(m *Msg) error { return nil })
+	mgr.AddWorker("idle", 4, func(m *Msg) error { return nil })
+
+	old := nowToSecondsWithNanoPrecision() - 60
+	opts.client.LPush(ctx, "prod:queue:late", fmt.Sprintf(`{"jid":"1","enqueued_at":%f}`, old))
+
+	autoscale := AutoscaleOptions{MaxConcurrency: 8, TargetLatency: 10 * time.Second}
+	configured := map[*worker]int{mgr.workers[0]: 2, mgr.workers[1]: 4}
+	mgr.autoscale(ctx, autoscale, configured)
+	assert.Equal(t, 3, mgr.workers[0].getConcurrency())
+	assert.Equal(t, 2, mgr.workers[1].getConcurrency())
+
+	mgr.autoscale(ctx, autoscale, configured)
+	assert.Equal(t, 4, mgr.workers[0].getConcurrency())
+	assert.Equal(t, 1, mgr.workers[1].getConcurrency())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	mgr.runAutoscale(cancelled, autoscale)
+	assert.Equal(t, 4, mgr.workers[0].getConcurrency())
+	assert.Equal(t, 1, mgr.workers[1].getConcurrency())
+}
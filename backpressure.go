@@ -0,0 +1,222 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBackpressureInterval   = 5 * time.Second
+	defaultBackpressureDelay      = time.Second
+	defaultBackpressureCacheTTL   = time.Second
+	defaultBackpressureStaleAfter = time.Minute
+)
+
+// BackpressureOptions configures the backpressure states a manager publishes for the queues it works,
+// which producers with ProducerBackpressure consult before enqueuing
+type BackpressureOptions struct {
+	// Depth above which a queue is under backpressure, zero for no limit on the depth
+	MaxDepth int64
+	// Latency of the oldest job above which a queue is under backpressure, zero for no limit on the latency
+	MaxLatency time.Duration
+	// Optional interval between checks of the queues, defaults to 5s
+	Interval time.Duration
+}
+
+func (o BackpressureOptions) withDefaults() BackpressureOptions {
+	if o.Interval <= 0 {
+		o.Interval = defaultBackpressureInterval
+	}
+	return o
+}
+
+// BackpressureState is the state of a queue as last published by a manager working it
+type BackpressureState struct {
+	Queue    string        `json:"queue"`
+	Exceeded bool          `json:"exceeded"`
+	Depth    int64         `json:"depth"`
+	Latency  time.Duration `json:"latency"`
+	// Time of the check, in seconds since the epoch
+	UpdatedAt float64 `json:"updated_at"`
+}
+
+// Backpressure returns the last published state of every queue, by queue name
+func (m *Manager) Backpressure(ctx context.Context) (map[string]BackpressureState, error) {
+	return readBackpressure(ctx, m.opts)
+}
+
+func readBackpressure(ctx context.Context, opts Options) (map[string]BackpressureState, error) {
+	states, err := opts.store.GetBackpressure(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]BackpressureState, len(states))
+	for queue, encoded := range states {
+		var state BackpressureState
+		if err := json.Unmarshal([]byte(encoded), &state); err != nil {
+			continue
+		}
+		res[queue] = state
+	}
+	return res, nil
+}
+
+// PublishBackpressure checks the depth and latency of the queues of the manager's workers against
+// opts, and publishes their state
+func (m *Manager) PublishBackpressure(ctx context.Context, opts BackpressureOptions) error {
+	m.lock.Lock()
+	workers := m.workers
+	m.lock.Unlock()
+
+	published := map[string]bool{}
+	for _, w := range workers {
+		for _, queue := range w.sourceQueues() {
+			if published[queue] {
+				continue
+			}
+			published[queue] = true
+
+			depth, err := m.opts.store.QueueLength(ctx, queue)
+			if err != nil {
+				return err
+			}
+			latency, err := m.QueueLatency(ctx, queue)
+			if err != nil {
+				return err
+			}
+			state := BackpressureState{
+				Queue:     queue,
+				Exceeded:  (opts.MaxDepth > 0 && depth > opts.MaxDepth) || (opts.MaxLatency > 0 && latency > opts.MaxLatency),
+				Depth:     depth,
+				Latency:   latency,
+				UpdatedAt: nowToSecondsWithNanoPrecision(),
+			}
+			encoded, err := json.Marshal(state)
+			if err != nil {
+				return err
+			}
+			if err := m.opts.store.SetBackpressure(ctx, queue, string(encoded)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runBackpressure publishes the state of the manager's queues every interval until ctx is done
+func (m *Manager) runBackpressure(ctx context.Context, opts BackpressureOptions) {
+	opts = opts.withDefaults()
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.IsActive() {
+				continue
+			}
+			if err := m.PublishBackpressure(ctx, opts); err != nil && ctx.Err() == nil {
+				m.logger.Println("ERR: couldn't publish backpressure:", err)
+			}
+		}
+	}
+}
+
+// BackpressureAction is what a producer does with the jobs enqueued to a queue under backpressure
+type BackpressureAction int
+
+const (
+	// BackpressureSlow holds the enqueue for Delay before writing the job
+	BackpressureSlow BackpressureAction = iota
+	// BackpressureDefer schedules the job to run Delay later instead of now
+	BackpressureDefer
+)
+
+// ProducerBackpressureOptions configures how producers react to the backpressure states published by
+// managers with Backpressure. Only immediate enqueues are affected.
+type ProducerBackpressureOptions struct {
+	Action BackpressureAction
+	// Optional time enqueues are held or jobs deferred, defaults to a second
+	Delay time.Duration
+	// Optional lifetime of the cached states, defaults to a second
+	CacheTTL time.Duration
+	// Optional age past which a state is ignored, such as one left by a stopped manager, defaults to a minute
+	StaleAfter time.Duration
+}
+
+func (o ProducerBackpressureOptions) withDefaults() ProducerBackpressureOptions {
+	if o.Delay <= 0 {
+		o.Delay = defaultBackpressureDelay
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = defaultBackpressureCacheTTL
+	}
+	if o.StaleAfter <= 0 {
+		o.StaleAfter = defaultBackpressureStaleAfter
+	}
+	return o
+}
+
+// producerBackpressure caches the published states for CacheTTL
+type producerBackpressure struct {
+	opts ProducerBackpressureOptions
+
+	lock      sync.Mutex
+	states    map[string]BackpressureState
+	fetchedAt time.Time
+}
+
+func newProducerBackpressure(opts *ProducerBackpressureOptions) *producerBackpressure {
+	if opts == nil {
+		return nil
+	}
+	return &producerBackpressure{opts: opts.withDefaults()}
+}
+
+func (b *producerBackpressure) exceeded(ctx context.Context, p *Producer, queue string) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.states == nil || time.Since(b.fetchedAt) > b.opts.CacheTTL {
+		states, err := readBackpressure(ctx, p.opts)
+		if err != nil {
+			return false, err
+		}
+		b.states = states
+		b.fetchedAt = time.Now()
+	}
+	state, ok := b.states[queue]
+	if !ok || !state.Exceeded {
+		return false, nil
+	}
+	return secondsToDuration(nowToSecondsWithNanoPrecision()-state.UpdatedAt) < b.opts.StaleAfter, nil
+}
+
+// applyBackpressure holds or defers the immediate jobs of queues under backpressure. Jobs are enqueued
+// as usual when the states can't be read.
+func (p *Producer) applyBackpressure(ctx context.Context, queue string, opts *EnqueueOptions, now float64) error {
+	if p.backpressure == nil || now < opts.At {
+		return nil
+	}
+	exceeded, err := p.backpressure.exceeded(ctx, p, queue)
+	if err != nil {
+		p.opts.Logger.Println("ERR: couldn't read backpressure:", err)
+		return nil
+	}
+	if !exceeded {
+		return nil
+	}
+
+	if p.backpressure.opts.Action == BackpressureDefer {
+		opts.At = now + p.backpressure.opts.Delay.Seconds()
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(p.backpressure.opts.Delay):
+		return nil
+	}
+}
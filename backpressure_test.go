@@ -0,0 +1,74 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_PublishBackpressure(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("pressured", 1, func(m *Msg) error { return nil })
+	mgr.AddWorker("relaxed", 1, func(m *Msg) error { return nil })
+
+	p := mgr.Producer()
+	for i := 0; i < 3; i++ {
+		_, err := p.Enqueue("pressured", "Report", nil)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, mgr.PublishBackpressure(ctx, BackpressureOptions{MaxDepth: 2}))
+
+	states, err := mgr.Backpressure(ctx)
+	assert.NoError(t, err)
+	assert.True(t, states["pressured"].Exceeded)
+	assert.Equal(t, int64(3), states["pressured"].Depth)
+	assert.False(t, states["relaxed"].Exceeded)
+}
+
+func TestProducerBackpressure(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	state := func(queue string, exceeded bool, age time.Duration) {
+		encoded, _ := json.Marshal(BackpressureState{Queue: queue, Exceeded: exceeded, UpdatedAt: nowToSecondsWithNanoPrecision() - age.Seconds()})
+		assert.NoError(t, opts.store.SetBackpressure(ctx, queue, string(encoded)))
+	}
+	state("deferred", true, 0)
+	state("stale", true, 2*time.Minute)
+	state("fine", false, 0)
+
+	opts.ProducerBackpressure = &ProducerBackpressureOptions{Action: BackpressureDefer, Delay: time.Minute}
+	p := newProducer(opts)
+	for _, queue := range []string{"deferred", "stale", "fine"} {
+		_, err := p.Enqueue(queue, "Report", nil)
+		assert.NoError(t, err)
+	}
+	_, err = p.EnqueueBulk("deferred", "Report", [][]interface{}{{1}, {2}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(0), rc.LLen(ctx, "prod:queue:deferred").Val())
+	assert.Equal(t, int64(3), rc.ZCard(ctx, "prod:"+string(ScheduledJobs)).Val())
+	assert.Equal(t, int64(1), rc.LLen(ctx, "prod:queue:stale").Val())
+	assert.Equal(t, int64(1), rc.LLen(ctx, "prod:queue:fine").Val())
+
+	opts.ProducerBackpressure = &ProducerBackpressureOptions{Action: BackpressureSlow, Delay: 20 * time.Millisecond}
+	p = newProducer(opts)
+	start := time.Now()
+	_, err = p.Enqueue("deferred", "Report", nil)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+	assert.Equal(t, int64(1), rc.LLen(ctx, "prod:queue:deferred").Val())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = p.EnqueueWithContext(cancelled, "deferred", "Report", nil, EnqueueOptions{})
+	assert.Equal(t, context.Canceled, err)
+}
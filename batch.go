@@ -0,0 +1,249 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// batchTTL is how long batches are kept after their last job was added, like Sidekiq Pro does
+const batchTTL = 30 * 24 * time.Hour
+
+// BatchCallback is a job enqueued once a batch reaches a state. The job's args are the batch's
+// status and Options, like the arguments of Sidekiq Pro's on_complete and on_success.
+type BatchCallback struct {
+	Queue   string      `json:"queue"`
+	Class   string      `json:"class"`
+	Options interface{} `json:"options,omitempty"`
+}
+
+type batchCallbacks struct {
+	Complete []BatchCallback `json:"complete,omitempty"`
+	Success  []BatchCallback `json:"success,omitempty"`
+}
+
+// Batch is a group of jobs tracked together. Its complete callbacks run once every job ran at
+// least once, and its success callbacks once every job succeeded. Batches aren't Sidekiq Pro's:
+// jobs carry the batch ID in their "gw_bid" field rather than Pro's "bid", and batches are kept
+// under their own keys, so Ruby processes running Pro leave them alone.
+type Batch struct {
+	ID          string
+	Description string
+
+	producer  *Producer
+	callbacks batchCallbacks
+}
+
+// BatchStatus is the state of a batch
+type BatchStatus struct {
+	ID          string  `json:"bid"`
+	Description string  `json:"description,omitempty"`
+	Total       int64   `json:"total"`
+	Pending     int64   `json:"pending"`
+	Failures    int64   `json:"failures"`
+	CreatedAt   float64 `json:"created_at"`
+	Complete    bool    `json:"complete"`
+	Success     bool    `json:"success"`
+}
+
+// NewBatch creates a batch, which is written to Redis by Jobs
+func (p *Producer) NewBatch(description string) *Batch {
+	return &Batch{ID: generateJid(), Description: description, producer: p}
+}
+
+// OnComplete adds a callback run once every job of the batch ran, successfully or not
+func (b *Batch) OnComplete(callback BatchCallback) {
+	b.callbacks.Complete = append(b.callbacks.Complete, callback)
+}
+
+// OnSuccess adds a callback run once every job of the batch succeeded
+func (b *Batch) OnSuccess(callback BatchCallback) {
+	b.callbacks.Success = append(b.callbacks.Success, callback)
+}
+
+// Jobs creates the batch and calls define, which enqueues the batch's jobs with Enqueue. The callbacks
+// can't run before define returns, even if the jobs already enqueued are done. Calling Jobs again adds
+// jobs to an existing batch.
+func (b *Batch) Jobs(define func() error) error {
+	ctx := context.Background()
+	callbacks, err := json.Marshal(b.callbacks)
+	if err != nil {
+		return err
+	}
+
+	store := b.producer.opts.store
+	token := "open-" + generateJid()
+	fields := map[string]interface{}{
+		"description": b.Description,
+		"created_at":  nowToSecondsWithNanoPrecision(),
+		"callbacks":   string(callbacks),
+	}
+	if err := store.OpenBatch(ctx, b.ID, fields, token, batchTTL); err != nil {
+		return err
+	}
+
+	defineErr := define()
+
+	due, err := store.BatchJobDone(ctx, b.ID, token, true)
+	if err == nil {
+		err = b.producer.runBatchCallbacks(ctx, b.ID, due)
+	}
+	if defineErr != nil {
+		return defineErr
+	}
+	return err
+}
+
+// Enqueue enqueues a job of the batch for immediate processing
+func (b *Batch) Enqueue(queue, class string, args interface{}) (string, error) {
+	return b.EnqueueWithOptions(queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+}
+
+// EnqueueWithOptions enqueues a job of the batch with the given options
+func (b *Batch) EnqueueWithOptions(queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	opts.Bid = b.ID
+	return b.producer.EnqueueWithOptions(queue, class, args, opts)
+}
+
+// BatchStatus returns the state of a batch
+func (p *Producer) BatchStatus(bid string) (*BatchStatus, error) {
+	batch, err := p.opts.store.GetBatch(context.Background(), bid)
+	if err != nil {
+		return nil, err
+	}
+	return newBatchStatus(bid, batch.Fields, batch.Failed), nil
+}
+
+func newBatchStatus(bid string, fields map[string]string, failed int64) *BatchStatus {
+	status := &BatchStatus{
+		ID:          bid,
+		Description: fields["description"],
+		Failures:    failed,
+		Complete:    fields["complete_at"] != "",
+		Success:     fields["success_at"] != "",
+	}
+	status.Total, _ = strconv.ParseInt(fields["total"], 10, 64)
+	status.Pending, _ = strconv.ParseInt(fields["pending"], 10, 64)
+	status.CreatedAt, _ = strconv.ParseFloat(fields["created_at"], 64)
+	return status
+}
+
+// registerBatchJob counts a job in its batch before it is pushed, so the batch can't be done before the job ran
+func (p *Producer) registerBatchJob(ctx context.Context, job *EnqueueData) error {
+	if job.Bid == "" {
+		return nil
+	}
+	return p.opts.store.AddBatchJob(ctx, job.Bid, job.Jid, batchTTL)
+}
+
+// unregisterBatchJob takes a job which couldn't be pushed out of its batch's pending jobs
+func (p *Producer) unregisterBatchJob(ctx context.Context, job *EnqueueData) {
+	if job.Bid == "" {
+		return
+	}
+	due, err := p.opts.store.BatchJobDone(ctx, job.Bid, job.Jid, true)
+	if err == nil {
+		err = p.runBatchCallbacks(ctx, job.Bid, due)
+	}
+	if err != nil {
+		p.opts.Logger.Println("ERR: couldn't update batch", job.Bid, ":", err)
+	}
+}
+
+// runBatchCallbacks fires the given batch events, writing the jobs of their callbacks along with the
+// marker of the event, so each event fires once. An event whose callbacks couldn't be written stays due,
+// and fires with the next outcome recorded for the batch.
+func (p *Producer) runBatchCallbacks(ctx context.Context, bid string, events []string) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch, err := p.opts.store.GetBatch(ctx, bid)
+	if err != nil {
+		return err
+	}
+	var callbacks batchCallbacks
+	if raw := batch.Fields["callbacks"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &callbacks); err != nil {
+			return err
+		}
+	}
+
+	for _, event := range events {
+		now := nowToSecondsWithNanoPrecision()
+		status := newBatchStatus(bid, batch.Fields, batch.Failed)
+		due := callbacks.Complete
+		status.Complete = true
+		if event == "success" {
+			due = callbacks.Success
+			status.Success = true
+		}
+
+		var jobs []EnqueueData
+		var written []string
+		var messages []storage.QueuedMessage
+		collect := p.opts.ProducerMiddlewares.build(func(ctx context.Context, job *EnqueueData) error {
+			bytes, err := p.encode(*job)
+			if err != nil {
+				return err
+			}
+			message := storage.QueuedMessage{Queue: job.Queue, Message: string(bytes)}
+			if now < job.At {
+				message.At = job.At
+			}
+			jobs = append(jobs, *job)
+			written = append(written, message.Message)
+			messages = append(messages, message)
+			return nil
+		})
+		for _, callback := range due {
+			// each event of a batch is only due once, and the callbacks of batches may share their args
+			opts := EnqueueOptions{At: now, DedupeFor: -1}
+			data := p.newEnqueueData(callback.Queue, callback.Class, []interface{}{status, callback.Options}, opts, now)
+			if err := collect(ctx, &data); err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
+		err := p.retry.do(ctx, func() error {
+			_, err := p.opts.store.FireBatchEvent(ctx, bid, event, now, messages)
+			return err
+		})
+		p.recordWrite(jobs, written, time.Since(start), err)
+		if err != nil {
+			p.reportEnqueueFailure(ctx, jobs, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// batchJobFunc records the outcome of every job which belongs to a batch, and runs the callbacks it makes due
+func batchJobFunc(mgr *Manager, next JobFunc) JobFunc {
+	return func(message *Msg) (err error) {
+		bid, _ := message.Get("gw_bid").String()
+		if bid == "" {
+			return next(message)
+		}
+
+		succeeded := false
+		defer func() {
+			ctx := context.Background()
+			due, doneErr := mgr.opts.store.BatchJobDone(ctx, bid, message.Jid(), succeeded)
+			if doneErr == nil {
+				doneErr = mgr.Producer().runBatchCallbacks(ctx, bid, due)
+			}
+			if doneErr != nil {
+				mgr.logger.Println("ERR: couldn't update batch", bid, ":", doneErr)
+			}
+		}()
+
+		err = next(message)
+		succeeded = err == nil
+		return err
+	}
+}
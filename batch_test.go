@@ -0,0 +1,182 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+	p := newProducer(opts)
+
+	fail := false
+	job := NewMiddlewares().build("imports", mgr, batchJobFunc(mgr, func(m *Msg) error {
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	run := func() *Msg {
+		bytes, err := rc.RPop(ctx, "prod:queue:imports").Result()
+		assert.NoError(t, err)
+		msg, _ := NewMsg(bytes)
+		job(msg)
+		return msg
+	}
+	callbacks := func() []string {
+		messages, _ := rc.LRange(ctx, "prod:queue:callbacks", 0, -1).Result()
+		var classes []string
+		for _, m := range messages {
+			msg, _ := NewMsg(m)
+			classes = append(classes, msg.Class())
+		}
+		return classes
+	}
+
+	b := p.NewBatch("nightly import")
+	b.OnComplete(BatchCallback{Queue: "callbacks", Class: "ImportDone", Options: map[string]int{"user": 1}})
+	b.OnSuccess(BatchCallback{Queue: "callbacks", Class: "ImportSucceeded"})
+
+	err = b.Jobs(func() error {
+		if _, err := b.Enqueue("imports", "Import", []int{1}); err != nil {
+			return err
+		}
+		// the batch isn't done while jobs are still being added
+		run()
+		assert.Empty(t, callbacks())
+
+		_, err := b.Enqueue("imports", "Import", []int{2})
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, callbacks())
+
+	status, err := p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "nightly import", status.Description)
+	assert.Equal(t, int64(2), status.Total)
+	assert.Equal(t, int64(1), status.Pending)
+
+	// the batch is complete once every job ran, even if some failed
+	fail = true
+	failed := run()
+	assert.Equal(t, b.ID, failed.Get("gw_bid").MustString())
+	assert.Equal(t, []string{"ImportDone"}, callbacks())
+
+	done, _ := NewMsg(rc.LIndex(ctx, "prod:queue:callbacks", 0).Val())
+	assert.Equal(t, b.ID, done.Args().GetIndex(0).Get("bid").MustString())
+	assert.Equal(t, int64(1), done.Args().GetIndex(0).Get("failures").MustInt64())
+	assert.Equal(t, 1, done.Args().GetIndex(1).Get("user").MustInt())
+
+	// its retry succeeding makes it successful
+	fail = false
+	rc.LPush(ctx, "prod:queue:imports", failed.ToJson())
+	run()
+	assert.Equal(t, []string{"ImportSucceeded", "ImportDone"}, callbacks())
+
+	status, err = p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), status.Pending)
+	assert.Equal(t, int64(0), status.Failures)
+	assert.True(t, status.Complete)
+	assert.True(t, status.Success)
+
+	// callbacks run once, whatever the number of deliveries of a job
+	rc.LPush(ctx, "prod:queue:imports", failed.ToJson())
+	run()
+	assert.Len(t, callbacks(), 2)
+
+	_, err = p.BatchStatus("unknown")
+	assert.Equal(t, storage.NoBatch, err)
+}
+
+func TestBatchJobPanics(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+	p := newProducer(opts)
+
+	b := p.NewBatch("")
+	b.OnComplete(BatchCallback{Queue: "callbacks", Class: "Done"})
+	var jid string
+	assert.NoError(t, b.Jobs(func() error {
+		jid, err = b.Enqueue("imports", "Import", []int{1})
+		return err
+	}))
+
+	job := batchJobFunc(mgr, func(m *Msg) error {
+		panic("boom")
+	})
+	msg, _ := NewMsg(`{"jid":"` + jid + `","gw_bid":"` + b.ID + `","class":"Import","args":[1]}`)
+	assert.Panics(t, func() { job(msg) })
+
+	status, err := p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), status.Failures)
+	assert.True(t, status.Complete)
+	assert.False(t, status.Success)
+}
+
+// refusingBatchStore refuses to fire the first batch events
+type refusingBatchStore struct {
+	storage.Store
+	refusals int
+}
+
+func (s *refusingBatchStore) FireBatchEvent(ctx context.Context, bid string, event string, now float64, messages []storage.QueuedMessage) (bool, error) {
+	if s.refusals > 0 {
+		s.refusals--
+		return false, errRedisDown
+	}
+	return s.Store.FireBatchEvent(ctx, bid, event, now, messages)
+}
+
+func TestBatchCallbacksAfterFailedWrite(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	store := &refusingBatchStore{Store: opts.store}
+	opts.store = store
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+	p := newProducer(opts)
+
+	b := p.NewBatch("")
+	b.OnSuccess(BatchCallback{Queue: "callbacks", Class: "Done"})
+	var jid string
+	assert.NoError(t, b.Jobs(func() error {
+		jid, err = b.Enqueue("imports", "Import", []int{1})
+		return err
+	}))
+	// batches are kept apart from Sidekiq Pro's
+	assert.EqualValues(t, 1, rc.Exists(ctx, "prod:gw-batch-"+b.ID).Val())
+	assert.EqualValues(t, 0, rc.Exists(ctx, "prod:b-"+b.ID).Val())
+
+	// the event isn't marked as fired when its callbacks couldn't be written
+	store.refusals = 1
+	job := batchJobFunc(mgr, func(m *Msg) error { return nil })
+	msg, _ := NewMsg(`{"jid":"` + jid + `","gw_bid":"` + b.ID + `","class":"Import","args":[1]}`)
+	assert.NoError(t, job(msg))
+	assert.EqualValues(t, 0, rc.LLen(ctx, "prod:queue:callbacks").Val())
+	status, err := p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.False(t, status.Success)
+
+	// so it fires on the next delivery of a job of the batch
+	assert.NoError(t, job(msg))
+	assert.EqualValues(t, 1, rc.LLen(ctx, "prod:queue:callbacks").Val())
+	assert.NoError(t, job(msg))
+	assert.EqualValues(t, 1, rc.LLen(ctx, "prod:queue:callbacks").Val())
+	status, err = p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.True(t, status.Success)
+}
@@ -0,0 +1,165 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+const (
+	defaultBurstBacklogThreshold = 1000
+	defaultBurstTargetLatency    = 10 * time.Second
+	defaultBurstCheckInterval    = 5 * time.Second
+)
+
+// BurstOptions configures the cold-start burst mode: when the manager starts, queues with a large
+// backlog run at a higher concurrency until their latency drops below a target, then settle back to
+// the concurrency they were added with
+type BurstOptions struct {
+	// Optional queue length from which a queue bursts, defaults to 1000
+	BacklogThreshold int64
+
+	// Concurrency of bursting queues, with per-queue overrides in QueueMaxConcurrency. Queues whose
+	// ceiling isn't above their concurrency don't burst.
+	MaxConcurrency      int
+	QueueMaxConcurrency map[string]int
+
+	// Optional latency of the oldest job under which a queue settles, defaults to 10 seconds
+	TargetLatency time.Duration
+	// Optional interval between latency checks, defaults to 5 seconds
+	CheckInterval time.Duration
+}
+
+func (o BurstOptions) ceiling(queue string) int {
+	if ceiling, ok := o.QueueMaxConcurrency[queue]; ok {
+		return ceiling
+	}
+	return o.MaxConcurrency
+}
+
+// QueueLatency returns how long the next job to be fetched from queue has been waiting, or zero for
+// an empty queue
+func (m *Manager) QueueLatency(ctx context.Context, queue string) (time.Duration, error) {
+	message, err := m.opts.store.OldestMessage(ctx, queue)
+	if err == storage.NoMessage {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	msg, err := NewMsg(message)
+	if err != nil {
+		return 0, err
+	}
+	enqueuedAt, err := msg.Get("enqueued_at").Float64()
+	if err != nil {
+		// jobs without an enqueue time can't tell how long they waited
+		return 0, nil
+	}
+	return secondsToDuration(nowToSecondsWithNanoPrecision() - enqueuedAt), nil
+}
+
+// workerBacklog returns the number of jobs waiting in the queues of a worker
+func (m *Manager) workerBacklog(ctx context.Context, w *worker) (int64, error) {
+	var backlog int64
+	for _, queue := range w.sourceQueues() {
+		length, err := m.opts.store.QueueLength(ctx, queue)
+		if err != nil {
+			return 0, err
+		}
+		backlog += length
+	}
+	return backlog, nil
+}
+
+// workerLatency returns the highest latency of the queues of a worker
+func (m *Manager) workerLatency(ctx context.Context, w *worker) (time.Duration, error) {
+	var latency time.Duration
+	for _, queue := range w.sourceQueues() {
+		l, err := m.QueueLatency(ctx, queue)
+		if err != nil {
+			return 0, err
+		}
+		if l > latency {
+			latency = l
+		}
+	}
+	return latency, nil
+}
+
+// startBurst raises the concurrency of the queues with a large backlog, and returns the concurrency
+// each bursting worker settles back to
+func (m *Manager) startBurst(ctx context.Context, opts BurstOptions) map[*worker]int {
+	m.lock.Lock()
+	workers := m.workers
+	m.lock.Unlock()
+
+	bursting := map[*worker]int{}
+	for _, w := range workers {
+		normal, ceiling := w.getConcurrency(), opts.ceiling(w.queue)
+		if ceiling <= normal {
+			continue
+		}
+		backlog, err := m.workerBacklog(ctx, w)
+		if err != nil {
+			m.logger.Println("ERR: couldn't read the backlog of", w.queue, ":", err)
+			continue
+		}
+		if backlog < opts.BacklogThreshold {
+			continue
+		}
+		m.logger.Println("bursting", w.queue, "to", ceiling, "runners for a backlog of", backlog, "jobs")
+		w.setConcurrency(ceiling)
+		bursting[w] = normal
+	}
+	return bursting
+}
+
+// settleBurst restores the concurrency of the bursting queues whose latency dropped below the target
+func (m *Manager) settleBurst(ctx context.Context, opts BurstOptions, bursting map[*worker]int) {
+	for w, normal := range bursting {
+		latency, err := m.workerLatency(ctx, w)
+		if err != nil {
+			m.logger.Println("ERR: couldn't read the latency of", w.queue, ":", err)
+			continue
+		}
+		if latency < opts.TargetLatency {
+			m.logger.Println("settling", w.queue, "back to", normal, "runners")
+			w.setConcurrency(normal)
+			delete(bursting, w)
+		}
+	}
+}
+
+// runBurst bursts the queues with a large backlog until they settle or ctx is done
+func (m *Manager) runBurst(ctx context.Context, opts BurstOptions) {
+	if opts.BacklogThreshold <= 0 {
+		opts.BacklogThreshold = defaultBurstBacklogThreshold
+	}
+	if opts.TargetLatency <= 0 {
+		opts.TargetLatency = defaultBurstTargetLatency
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultBurstCheckInterval
+	}
+
+	bursting := m.startBurst(ctx, opts)
+	// a stopping manager restarts at its normal concurrency
+	defer func() {
+		for w, normal := range bursting {
+			w.setConcurrency(normal)
+		}
+	}()
+
+	ticker := time.NewTicker(opts.CheckInterval)
+	defer ticker.Stop()
+	for len(bursting) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.settleBurst(ctx, opts, bursting)
+		}
+	}
+}
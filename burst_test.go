@@ -0,0 +1,63 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_QueueLatency(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	latency, err := mgr.QueueLatency(ctx, "myqueue")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), latency)
+
+	now := nowToSecondsWithNanoPrecision()
+	opts.client.LPush(ctx, "prod:queue:myqueue", fmt.Sprintf(`{"jid":"1","enqueued_at":%f}`, now-30))
+	opts.client.LPush(ctx, "prod:queue:myqueue", fmt.Sprintf(`{"jid":"2","enqueued_at":%f}`, now))
+	latency, err = mgr.QueueLatency(ctx, "myqueue")
+	assert.NoError(t, err)
+	assert.True(t, latency >= 30*time.Second && latency < 31*time.Second)
+}
+
+func TestManager_Burst(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("backlog", 2, func(m *Msg) error { return nil })
+	mgr.AddWorker("quiet", 2, func(m *Msg) error { return nil })
+	mgr.AddWorker("capped", 8, func(m *Msg) error { return nil })
+
+	old := nowToSecondsWithNanoPrecision() - 60
+	for i := 0; i < 5; i++ {
+		opts.client.LPush(ctx, "prod:queue:backlog", fmt.Sprintf(`{"jid":"%d","enqueued_at":%f}`, i, old))
+		opts.client.LPush(ctx, "prod:queue:capped", fmt.Sprintf(`{"jid":"%d","enqueued_at":%f}`, i, old))
+	}
+	opts.client.LPush(ctx, "prod:queue:quiet", fmt.Sprintf(`{"jid":"1","enqueued_at":%f}`, old))
+
+	burst := BurstOptions{BacklogThreshold: 3, MaxConcurrency: 6, TargetLatency: 10 * time.Second}
+	bursting := mgr.startBurst(ctx, burst)
+	assert.Len(t, bursting, 1)
+	assert.Equal(t, 6, mgr.workers[0].getConcurrency())
+	assert.Equal(t, 2, mgr.workers[1].getConcurrency())
+	assert.Equal(t, 8, mgr.workers[2].getConcurrency())
+
+	// the backlog is still late
+	mgr.settleBurst(ctx, burst, bursting)
+	assert.Equal(t, 6, mgr.workers[0].getConcurrency())
+
+	opts.client.Del(ctx, "prod:queue:backlog")
+	mgr.settleBurst(ctx, burst, bursting)
+	assert.Empty(t, bursting)
+	assert.Equal(t, 2, mgr.workers[0].getConcurrency())
+}
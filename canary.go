@@ -0,0 +1,102 @@
+package workers
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// CanaryOptions configures the sampling of the jobs of a queue for a canary worker, such as a Go port
+// validated against the Ruby worker of the queue before cutover
+type CanaryOptions struct {
+	// Queue whose jobs are sampled
+	Queue string
+	// Percentage of the jobs of Queue sent to CanaryQueue(Queue), from 0 to 100. Jobs are sampled by
+	// JID, so the retries of a sampled job are sampled too.
+	Percent float64
+	// Whether sampled jobs are copied to the canary queue and still enqueued to Queue, instead of
+	// moved. Copies are flagged as shadow jobs, which shouldn't have side effects.
+	Shadow bool
+}
+
+// CanaryQueue returns the queue the canary worker of queue fetches from
+func CanaryQueue(queue string) string {
+	return queue + "_canary"
+}
+
+// CanaryMiddleware sends a sample of the jobs of a queue to its canary queue
+func CanaryMiddleware(opts CanaryOptions) ProducerMiddlewareFunc {
+	return func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			if job.Queue != opts.Queue || !canarySampled(job.Jid, opts.Percent) {
+				return next(ctx, job)
+			}
+			if !opts.Shadow {
+				job.Queue = CanaryQueue(opts.Queue)
+				return next(ctx, job)
+			}
+
+			if err := next(ctx, job); err != nil {
+				return err
+			}
+			shadow := *job
+			shadow.Queue = CanaryQueue(opts.Queue)
+			// the copy doesn't take the locks of the original, nor counts in its batch
+			shadow.UniqueFor, shadow.DedupeFor, shadow.Bid = 0, 0, ""
+			shadow.Extra = map[string]interface{}{}
+			for key, value := range job.Extra {
+				shadow.Extra[key] = value
+			}
+			shadow.Extra["shadow"] = true
+			return next(ctx, &shadow)
+		}
+	}
+}
+
+// canarySampled tells whether the job of jid is in the percent sampled
+func canarySampled(jid string, percent float64) bool {
+	h := fnv.New32a()
+	h.Write([]byte(jid))
+	return float64(h.Sum32()%10000) < percent*100
+}
+
+type shadowContextKey struct{}
+
+// IsShadow tells whether the job is a copy of a job which also runs on its own queue, whose side
+// effects should be suppressed
+func (m *Msg) IsShadow() bool {
+	shadow, _ := m.Get("shadow").Bool()
+	return shadow
+}
+
+// IsShadow tells whether ctx is the context of a shadow job run by a canary worker, see Msg.IsShadow
+func IsShadow(ctx context.Context) bool {
+	shadow, _ := ctx.Value(shadowContextKey{}).(bool)
+	return shadow
+}
+
+// CanaryResultFunc receives the outcome of every job run by a canary worker
+type CanaryResultFunc func(queue string, message *Msg, err error, elapsed time.Duration)
+
+// AddCanaryWorker adds a worker of the canary queue of queue, whose jobs are sampled by CanaryMiddleware.
+// The context of shadow jobs is flagged, see IsShadow. Outcomes are counted in the
+// "canary:<queue>:processed" and "canary:<queue>:failed" stats, and given to the optional onResult.
+func (m *Manager) AddCanaryWorker(queue string, concurrency int, onResult CanaryResultFunc, job JobFunc, mids ...MiddlewareFunc) {
+	m.AddWorker(CanaryQueue(queue), concurrency, func(message *Msg) error {
+		if message.IsShadow() {
+			message.ctx = context.WithValue(message.Context(), shadowContextKey{}, true)
+		}
+
+		start := time.Now()
+		err := job(message)
+		if err != nil {
+			incrementStats(m, "canary:"+queue+":failed")
+		} else {
+			incrementStats(m, "canary:"+queue+":processed")
+		}
+		if onResult != nil {
+			onResult(queue, message, err, time.Since(start))
+		}
+		return err
+	}, mids...)
+}
@@ -0,0 +1,88 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanarySampled(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		jid := fmt.Sprint("jid-", i)
+		if canarySampled(jid, 10) {
+			sampled++
+		}
+		assert.False(t, canarySampled(jid, 0))
+		assert.True(t, canarySampled(jid, 100))
+	}
+	assert.InDelta(t, 1000, sampled, 100)
+}
+
+func TestCanaryMiddleware(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	opts.ProducerMiddlewares = NewProducerMiddlewares(CanaryMiddleware(CanaryOptions{Queue: "ported", Percent: 100, Shadow: true}))
+	p := newProducer(opts)
+	_, err = p.EnqueueWithOptions("ported", "Port", []int{1}, EnqueueOptions{UniqueFor: time.Minute})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("other", "Port", []int{1})
+	assert.NoError(t, err)
+
+	original, _ := rc.LPop(ctx, "prod:queue:ported").Result()
+	message, _ := NewMsg(original)
+	assert.False(t, message.IsShadow())
+	copied, _ := rc.LPop(ctx, "prod:queue:ported_canary").Result()
+	shadow, _ := NewMsg(copied)
+	assert.True(t, shadow.IsShadow())
+	assert.Equal(t, message.Jid(), shadow.Jid())
+	assert.Equal(t, "ported_canary", shadow.Get("queue").MustString())
+	assert.Equal(t, int64(1), rc.LLen(ctx, "prod:queue:other").Val())
+
+	opts.ProducerMiddlewares = NewProducerMiddlewares(CanaryMiddleware(CanaryOptions{Queue: "ported", Percent: 100}))
+	p = newProducer(opts)
+	_, err = p.Enqueue("ported", "Port", []int{2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rc.LLen(ctx, "prod:queue:ported").Val())
+	moved, _ := rc.LPop(ctx, "prod:queue:ported_canary").Result()
+	message, _ = NewMsg(moved)
+	assert.False(t, message.IsShadow())
+}
+
+func TestManager_AddCanaryWorker(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	var results []error
+	mgr.AddCanaryWorker("ported", 1, func(queue string, message *Msg, err error, elapsed time.Duration) {
+		assert.Equal(t, "ported", queue)
+		results = append(results, err)
+	}, func(message *Msg) error {
+		if IsShadow(message.Context()) {
+			return nil
+		}
+		return errors.New("side effect")
+	}, NopMiddleware)
+	assert.Equal(t, CanaryQueue("ported"), mgr.workers[0].queue)
+
+	shadow, _ := NewMsg(`{"jid":"1","class":"Port","shadow":true}`)
+	assert.NoError(t, mgr.workers[0].handler(shadow))
+	live, _ := NewMsg(`{"jid":"2","class":"Port"}`)
+	assert.Error(t, mgr.workers[0].handler(live))
+
+	assert.Len(t, results, 2)
+	processed, _ := opts.client.Get(ctx, "prod:stat:canary:ported:processed").Int()
+	assert.Equal(t, 1, processed)
+	failed, _ := opts.client.Get(ctx, "prod:stat:canary:ported:failed").Int()
+	assert.Equal(t, 1, failed)
+}
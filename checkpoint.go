@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// checkpointTTL is how long the checkpoint of a job is kept after it was last saved, long enough
+// for Sidekiq's default 25 retries
+const checkpointTTL = 30 * 24 * time.Hour
+
+// ErrNoCheckpointStore is returned by checkpoint methods of jobs which aren't run by a manager
+var ErrNoCheckpointStore = errors.New("checkpoints are only available to jobs run by a manager")
+
+type jobCheckpoint struct {
+	store storage.Store
+	// set once the job saved or loaded a checkpoint, which is then removed when the job succeeds
+	used bool
+}
+
+// SaveCheckpoint persists the progress of a long job, such as the cursor of a backfill, as JSON.
+// When the job fails or its process dies, LoadCheckpoint hands it back to the next attempt. The
+// checkpoint is removed once the job succeeds.
+func (m *Msg) SaveCheckpoint(checkpoint interface{}) error {
+	if m.checkpoint == nil {
+		return ErrNoCheckpointStore
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	m.checkpoint.used = true
+	return m.checkpoint.store.SetCheckpoint(context.Background(), m.Jid(), string(data), checkpointTTL)
+}
+
+// LoadCheckpoint decodes the last checkpoint saved by an earlier attempt of the job into target, and
+// reports whether there was one
+func (m *Msg) LoadCheckpoint(target interface{}) (bool, error) {
+	if m.checkpoint == nil {
+		return false, ErrNoCheckpointStore
+	}
+	data, err := m.checkpoint.store.GetCheckpoint(context.Background(), m.Jid())
+	if err == storage.NoCheckpoint {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	m.checkpoint.used = true
+	return true, json.Unmarshal([]byte(data), target)
+}
+
+// checkpointJobFunc gives jobs access to their checkpoint, and removes it once they succeed
+func checkpointJobFunc(mgr *Manager, next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		message.checkpoint = &jobCheckpoint{store: mgr.opts.store}
+		if err := next(message); err != nil {
+			return err
+		}
+		if message.checkpoint.used {
+			if err := mgr.opts.store.DeleteCheckpoint(context.Background(), message.Jid()); err != nil {
+				mgr.logger.Println("ERR: couldn't remove checkpoint of", message.Jid(), ":", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+
+	type cursor struct {
+		LastID int `json:"last_id"`
+	}
+
+	var resumedFrom []int
+	attempt := 0
+	job := checkpointJobFunc(mgr, func(m *Msg) error {
+		var c cursor
+		found, err := m.LoadCheckpoint(&c)
+		assert.NoError(t, err)
+		assert.Equal(t, attempt > 0, found)
+		resumedFrom = append(resumedFrom, c.LastID)
+
+		attempt++
+		assert.NoError(t, m.SaveCheckpoint(cursor{LastID: c.LastID + 100}))
+		if attempt < 3 {
+			return errors.New("interrupted")
+		}
+		return nil
+	})
+
+	message, _ := NewMsg(`{"jid":"backfill","class":"Backfill","args":[]}`)
+	assert.Error(t, job(message))
+	message, _ = NewMsg(`{"jid":"backfill","class":"Backfill","args":[]}`)
+	assert.Error(t, job(message))
+	stored, err := opts.store.GetCheckpoint(ctx, "backfill")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"last_id":200}`, stored)
+
+	message, _ = NewMsg(`{"jid":"backfill","class":"Backfill","args":[]}`)
+	assert.NoError(t, job(message))
+	assert.Equal(t, []int{0, 100, 200}, resumedFrom)
+
+	// the checkpoint is removed once the job succeeded
+	_, err = opts.store.GetCheckpoint(ctx, "backfill")
+	assert.Equal(t, storage.NoCheckpoint, err)
+
+	message, _ = NewMsg(`{"jid":"other"}`)
+	assert.Equal(t, ErrNoCheckpointStore, message.SaveCheckpoint(1))
+	_, err = message.LoadCheckpoint(new(int))
+	assert.Equal(t, ErrNoCheckpointStore, err)
+}
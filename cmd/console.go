@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	workers "github.com/digitalocean/go-workers2"
+	"github.com/spf13/cobra"
+)
+
+var (
+	consoleRedis     string
+	consoleDatabase  int
+	consolePassword  string
+	consoleNamespace string
+	consoleManager   string
+)
+
+var consoleCmd = &cobra.Command{
+	Use:   "console",
+	Short: "inspect go-workers2 queues interactively",
+	Long: `Use the console command to list queues and peek at and grep their jobs straight from Redis,
+	and to requeue retries or scheduled jobs and purge queues through the API of the instance at the
+	specified host address and port number, which authorizes and audits them, like so:
+
+	gwctl console --redis 127.0.0.1:6379 --namespace prod --a 127.0.0.1 --p 8080`,
+	RunE: runConsole,
+}
+
+func init() {
+	consoleCmd.Flags().StringVar(&consoleRedis, "redis", "localhost:6379", "Address of the Redis server.")
+	consoleCmd.Flags().IntVar(&consoleDatabase, "db", 0, "Redis database.")
+	consoleCmd.Flags().StringVar(&consolePassword, "password", os.Getenv("REDIS_PASSWORD"), "Redis password, defaults to $REDIS_PASSWORD.")
+	consoleCmd.Flags().StringVar(&consoleNamespace, "namespace", "", "Namespace of the go-workers2 keys.")
+	consoleCmd.Flags().StringVar(&consoleManager, "manager", "", "Manager requeues and purges are made by, when the API serves several.")
+	rootCmd.AddCommand(consoleCmd)
+}
+
+const consoleHelp = `commands:
+  queues                        list the queues and their size
+  peek <queue> [count]          show the next jobs of a queue, 10 by default
+  grep <queue|set> <pattern>    show the jobs of a queue, or of the schedule, retry or dead set, matching a regexp
+  requeue <set> <jid>           move a job of the schedule, retry or dead set to its queue, through the API
+  purge <queue>                 remove every job waiting in a queue, through the API
+  help                          show this help
+  quit                          leave the console`
+
+func runConsole(cmd *cobra.Command, args []string) error {
+	mgr, err := workers.NewManager(workers.Options{
+		ServerAddr: consoleRedis,
+		Database:   consoleDatabase,
+		Password:   consolePassword,
+		Namespace:  consoleNamespace,
+		ProcessID:  "gwctl-console",
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(consoleHelp)
+	return runConsoleSession(workers.WithActor(context.Background(), actor), mgr, os.Stdin, os.Stdout)
+}
+
+// runConsoleSession runs the commands read from in until it's closed or the user quits
+func runConsoleSession(ctx context.Context, mgr *workers.Manager, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return nil
+		}
+		if err := runConsoleCommand(ctx, mgr, fields, out); err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+func runConsoleCommand(ctx context.Context, mgr *workers.Manager, fields []string, out io.Writer) error {
+	switch fields[0] {
+	case "help":
+		fmt.Fprintln(out, consoleHelp)
+
+	case "queues":
+		sizes, err := mgr.QueueSizes(ctx)
+		if err != nil {
+			return err
+		}
+		for _, size := range sizes {
+			fmt.Fprintf(out, "%-30s %d\n", size.Queue, size.Size)
+		}
+
+	case "peek":
+		if len(fields) < 2 {
+			return fmt.Errorf("usage: peek <queue> [count]")
+		}
+		count := 10
+		if len(fields) > 2 {
+			var err error
+			if count, err = strconv.Atoi(fields[2]); err != nil {
+				return err
+			}
+		}
+		jobs, err := mgr.PeekQueue(ctx, fields[1], count)
+		if err != nil {
+			return err
+		}
+		printConsoleJobs(out, jobs)
+
+	case "grep":
+		if len(fields) < 3 {
+			return fmt.Errorf("usage: grep <queue|set> <pattern>")
+		}
+		pattern, err := regexp.Compile(strings.Join(fields[2:], " "))
+		if err != nil {
+			return err
+		}
+		jobs, err := mgr.GrepJobs(ctx, fields[1], pattern)
+		if err != nil {
+			return err
+		}
+		printConsoleJobs(out, jobs)
+		fmt.Fprintln(out, len(jobs), "matching jobs")
+
+	case "requeue":
+		if len(fields) != 3 {
+			return fmt.Errorf("usage: requeue <set> <jid>")
+		}
+		if _, err := workers.ParseJobSet(fields[1]); err != nil {
+			return err
+		}
+		if err := consolePost(ctx, "/requeue", url.Values{"set": {fields[1]}, "jid": {fields[2]}}, nil); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "requeued", fields[2])
+
+	case "purge":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: purge <queue>")
+		}
+		var result struct {
+			Purged int64 `json:"purged"`
+		}
+		if err := consolePost(ctx, "/purge", url.Values{"queue": {fields[1]}}, &result); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "purged", result.Purged, "jobs from", fields[1])
+
+	default:
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	return nil
+}
+
+// consolePost makes a management action through the API of the instance, so that its authorization
+// policy and audit sink apply to it, and decodes the response into result unless it's nil
+func consolePost(ctx context.Context, path string, query url.Values, result interface{}) error {
+	if consoleManager != "" {
+		query.Set("manager", consoleManager)
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://"+hostAddress+":"+port+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(workers.ActorHeader, workers.ActorFromContext(ctx))
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s failed: %s", path[1:], strings.TrimSpace(string(body)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func printConsoleJobs(out io.Writer, jobs []*workers.Msg) {
+	for _, job := range jobs {
+		fmt.Fprintln(out, job.ToJson())
+	}
+}
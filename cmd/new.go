@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	newDir     string
+	newPackage string
+	newForce   bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "generate go-workers2 code",
+}
+
+var newWorkerCmd = &cobra.Command{
+	Use:   "worker <Class>",
+	Short: "generate a job handler for a Sidekiq class",
+	Long: `Use the new worker command to generate the typed args struct, handler,
+	dispatcher registration and a table-driven test for a job class, like so:
+
+	gwctl new worker Billing::InvoiceJob --dir ./jobs --package jobs`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNewWorker,
+}
+
+func init() {
+	newWorkerCmd.Flags().StringVar(&newDir, "dir", ".", "Directory the files are written to.")
+	newWorkerCmd.Flags().StringVar(&newPackage, "package", "", "Go package name of the generated files, defaults to the directory name.")
+	newWorkerCmd.Flags().BoolVar(&newForce, "force", false, "Overwrite existing files.")
+	newCmd.AddCommand(newWorkerCmd)
+	rootCmd.AddCommand(newCmd)
+}
+
+type workerTemplateData struct {
+	Package string
+	Class   string
+	Type    string
+}
+
+var (
+	classSeparator = regexp.MustCompile(`::|[^A-Za-z0-9_]+`)
+	// rubyConstant matches the names of Ruby classes, such as Billing::InvoiceJob
+	rubyConstant = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*(::[A-Z][A-Za-z0-9_]*)*$`)
+)
+
+// goTypeName converts a Sidekiq class name such as Billing::InvoiceJob into BillingInvoiceJob
+func goTypeName(class string) (string, error) {
+	if !rubyConstant.MatchString(class) {
+		return "", fmt.Errorf("%q isn't a Ruby class name", class)
+	}
+	var name strings.Builder
+	for _, part := range classSeparator.Split(class, -1) {
+		if part == "" {
+			continue
+		}
+		name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	typeName := name.String()
+	if typeName == "" || !unicode.IsLetter(rune(typeName[0])) {
+		return "", fmt.Errorf("%q can't be turned into a Go type name", class)
+	}
+	return typeName, nil
+}
+
+// snakeCase converts a Go type name such as BillingHTTPJob into the file name billing_http_job
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var out strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// a run of capitals is one word, whose last capital starts the next word when followed by a
+			// lower case letter, as in HTTPJob
+			if i > 0 && runes[i-1] != '_' && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				out.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func runNewWorker(cmd *cobra.Command, args []string) error {
+	class := args[0]
+	typeName, err := goTypeName(class)
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(newDir)
+	if err != nil {
+		return err
+	}
+	pkg := newPackage
+	if pkg == "" {
+		pkg = strings.ReplaceAll(filepath.Base(dir), "-", "_")
+	}
+
+	data := workerTemplateData{Package: pkg, Class: class, Type: typeName}
+	base := filepath.Join(dir, snakeCase(typeName))
+
+	files := []struct {
+		path string
+		tmpl *template.Template
+	}{
+		{path: base + ".go", tmpl: workerTemplate},
+		{path: base + "_test.go", tmpl: workerTestTemplate},
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if _, err := os.Stat(f.path); err == nil && !newForce {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", f.path)
+		}
+	}
+
+	for _, f := range files {
+		var src strings.Builder
+		if err := f.tmpl.Execute(&src, data); err != nil {
+			return err
+		}
+		formatted, err := format.Source([]byte(src.String()))
+		if err != nil {
+			return fmt.Errorf("generated invalid Go for %s: %v", f.path, err)
+		}
+		if err := ioutil.WriteFile(f.path, formatted, 0644); err != nil {
+			return err
+		}
+		fmt.Println("created", f.path)
+	}
+	return nil
+}
+
+var workerTemplate = template.Must(template.New("worker").Parse(`package {{.Package}}
+
+import (
+	"fmt"
+
+	workers "github.com/digitalocean/go-workers2"
+)
+
+// {{.Type}}Class is the Sidekiq class name handled by {{.Type}}Handler
+const {{.Type}}Class = {{printf "%q" .Class}}
+
+// {{.Type}}Args holds the positional arguments of a {{.Class}} job.
+// Exported fields are decoded from the args array in declaration order.
+type {{.Type}}Args struct {
+	// TODO: replace with the job's arguments
+	ID int
+}
+
+// {{.Type}}Handler processes {{.Class}} jobs
+type {{.Type}}Handler struct{}
+
+// HandleJob implements workers.JobHandler
+func (h *{{.Type}}Handler) HandleJob(args interface{}) error {
+	jobArgs, ok := args.(*{{.Type}}Args)
+	if !ok {
+		return fmt.Errorf("unexpected args type %T for %s", args, {{.Type}}Class)
+	}
+
+	// TODO: implement the job
+	_ = jobArgs
+	return nil
+}
+
+// Register{{.Type}} registers the {{.Class}} handler with the dispatcher
+func Register{{.Type}}(d *workers.JobDispatcher) error {
+	return d.RegisterHandler({{.Type}}Class, &{{.Type}}Handler{}, &{{.Type}}Args{})
+}
+`))
+
+var workerTestTemplate = template.Must(template.New("worker_test").Parse(`package {{.Package}}
+
+import (
+	"encoding/json"
+	"testing"
+
+	workers "github.com/digitalocean/go-workers2"
+)
+
+func Test{{.Type}}Handler(t *testing.T) {
+	d := workers.NewJobDispatcher()
+	if err := Register{{.Type}}(d); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []interface{}
+		wantErr bool
+	}{
+		{
+			name: "valid args",
+			args: []interface{}{1},
+		},
+		{
+			name:    "invalid args",
+			args:    []interface{}{"not a number"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := json.Marshal(map[string]interface{}{
+				"class": {{.Type}}Class,
+				"jid":   "test-jid",
+				"args":  tt.args,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := workers.NewMsg(string(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = d.Dispatch(msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Dispatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+`))
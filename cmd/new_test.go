@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "InvoiceJob", want: "invoice_job"},
+		{name: "BillingInvoiceJob", want: "billing_invoice_job"},
+		{name: "HTTPJob", want: "http_job"},
+		{name: "SendHTTPRequest", want: "send_http_request"},
+		{name: "ExportCSV", want: "export_csv"},
+		{name: "S3Upload", want: "s3_upload"},
+		{name: "Base64Encoder", want: "base64_encoder"},
+		{name: "Job2", want: "job2"},
+		{name: "V2APIJob", want: "v2_api_job"},
+		{name: "Legacy_Job", want: "legacy_job"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, snakeCase(tt.name))
+		})
+	}
+}
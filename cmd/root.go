@@ -10,6 +10,7 @@ import (
 var (
 	hostAddress string
 	port        string
+	actor       string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -39,4 +40,5 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().StringVar(&hostAddress, "a", "localhost", "Host address for a specific goworkers2 instance.")
 	rootCmd.PersistentFlags().StringVar(&port, "p", "8080", "Port number for a specific goworkers2 instance.")
+	rootCmd.PersistentFlags().StringVar(&actor, "actor", os.Getenv("USER"), "Actor the changes are recorded for, defaults to $USER.")
 }
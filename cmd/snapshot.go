@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+
+	workers "github.com/digitalocean/go-workers2"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotQueue   string
+	snapshotSet     string
+	snapshotManager string
+	snapshotFile    string
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "export and import go-workers2 queues and job sets",
+}
+
+var snapshotExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export a queue or job set to a JSON lines file",
+	Long: `Use the snapshot export command to save the jobs of a queue, or of the schedule, retry or
+	dead set, from a specified host address and port number, like so:
+
+	gwctl snapshot export --queue default --file default.jsonl --a 127.0.0.1 --p 8080`,
+	RunE: runSnapshotExport,
+}
+
+var snapshotImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import a JSON lines file into a queue or job set",
+	Long: `Use the snapshot import command to enqueue the jobs of a file written by snapshot export, like so:
+
+	gwctl snapshot import --set retry --file retries.jsonl --a 127.0.0.1 --p 8080`,
+	RunE: runSnapshotImport,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{snapshotExportCmd, snapshotImportCmd} {
+		c.Flags().StringVar(&snapshotQueue, "queue", "", "Queue to export or import.")
+		c.Flags().StringVar(&snapshotSet, "set", "", "Job set to export or import: schedule, retry or dead.")
+		c.Flags().StringVar(&snapshotManager, "manager", "", "Manager UUID, required when the instance runs several managers.")
+		c.Flags().StringVar(&snapshotFile, "file", "", "Snapshot file, defaults to stdout on export and stdin on import.")
+		snapshotCmd.AddCommand(c)
+	}
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+func snapshotAddress() (string, error) {
+	if (snapshotQueue == "") == (snapshotSet == "") {
+		return "", errors.New("snapshots require either --queue or --set")
+	}
+	query := url.Values{}
+	if snapshotQueue != "" {
+		query.Set("queue", snapshotQueue)
+	} else {
+		query.Set("set", snapshotSet)
+	}
+	if snapshotManager != "" {
+		query.Set("manager", snapshotManager)
+	}
+	return "http://" + hostAddress + ":" + port + "/snapshot?" + query.Encode(), nil
+}
+
+func runSnapshotExport(cmd *cobra.Command, args []string) error {
+	address, err := snapshotAddress()
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Get(address)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("export failed: %s", body)
+	}
+
+	var out io.Writer = os.Stdout
+	if snapshotFile != "" {
+		f, err := os.Create(snapshotFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+func runSnapshotImport(cmd *cobra.Command, args []string) error {
+	address, err := snapshotAddress()
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if snapshotFile != "" {
+		f, err := os.Open(snapshotFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	req, err := http.NewRequest(http.MethodPost, address, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set(workers.ActorHeader, actor)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("import failed: %s", body)
+	}
+	fmt.Printf("Body: %v\n", string(body))
+	return nil
+}
@@ -0,0 +1,203 @@
+package workers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// ErrInvalidSignature is returned for the jobs whose args don't match their signature, were encoded by
+// unknown codecs, or weren't authenticated by the codecs their queue requires
+var ErrInvalidSignature = storage.ErrInvalidSignature
+
+// Codec is a reversible transform of the encoded args of a job, such as compression or encryption
+type Codec = storage.Codec
+
+// Authenticator is implemented by the codecs which authenticate the args they decode, such as
+// HMACCodec and AESGCMCodec
+type Authenticator = storage.Authenticator
+
+// CodecChain is the ordered list of codecs applied to the args of the jobs of a queue, such as compress,
+// then encrypt, then sign. The store of the producers applies them in order after the queue's
+// Serializer. Encoded jobs have their args replaced by a single base64 string, and the names of the
+// codecs in their "codecs" field, which managers decode in the reverse order with the codecs of that
+// name. Jobs keep their encoding when they move to another queue, such as their retry queue, so managers
+// decode them with the codecs of any of their chains, preferring the ones of the job's current queue.
+// Codecs of the same name in several chains should decode the same args. Managers don't run the jobs of
+// a queue whose chain has an Authenticator unless that codec encoded them, nor the jobs encoded by codecs
+// they don't know.
+type CodecChain = storage.CodecChain
+
+type compressionCodec struct {
+	algorithm CompressionAlgorithm
+}
+
+// CompressionCodec compresses args with algorithm, defaulting to zlib. Unlike ArgsCompression, args are
+// compressed whatever their size.
+func CompressionCodec(algorithm CompressionAlgorithm) Codec {
+	if algorithm == "" {
+		algorithm = CompressionZlib
+	}
+	return compressionCodec{algorithm: algorithm}
+}
+
+func (c compressionCodec) Name() string { return string(c.algorithm) }
+
+func (c compressionCodec) Encode(data []byte) ([]byte, error) { return compress(c.algorithm, data) }
+
+func (c compressionCodec) Decode(data []byte) ([]byte, error) { return decompress(c.algorithm, data) }
+
+type aesGCMCodec struct {
+	aead cipher.AEAD
+}
+
+// AESGCMCodec encrypts args with AES-GCM under a 16, 24 or 32 byte key, prefixing them with their nonce
+func AESGCMCodec(key []byte) (Codec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aesGCMCodec{aead: aead}, nil
+}
+
+func (c aesGCMCodec) Name() string { return "aes-gcm" }
+
+func (c aesGCMCodec) Authenticates() bool { return true }
+
+func (c aesGCMCodec) Encode(data []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (c aesGCMCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted args too short")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, sealed, nil)
+}
+
+type hmacCodec struct {
+	key []byte
+}
+
+// HMACCodec signs args with HMAC-SHA256, appending the signature. Decoding args which don't match their
+// signature fails with ErrInvalidSignature.
+func HMACCodec(key []byte) Codec {
+	return hmacCodec{key: key}
+}
+
+func (c hmacCodec) Name() string { return "hmac-sha256" }
+
+func (c hmacCodec) Authenticates() bool { return true }
+
+func (c hmacCodec) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (c hmacCodec) Encode(data []byte) ([]byte, error) {
+	return append(append([]byte(nil), data...), c.sign(data)...), nil
+}
+
+func (c hmacCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) < sha256.Size {
+		return nil, ErrInvalidSignature
+	}
+	data, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(signature, c.sign(data)) {
+		return nil, ErrInvalidSignature
+	}
+	return data, nil
+}
+
+// encodeArgs applies the codecs of the job's queue to its args
+func (p *Producer) encodeArgs(data EnqueueData) (EnqueueData, error) {
+	args, err := json.Marshal(data.Args)
+	if err != nil {
+		return data, err
+	}
+	encoded, names, err := p.opts.store.EncodeArgs(data.Queue, args)
+	if err != nil || len(names) == 0 {
+		return data, err
+	}
+	data.Args = []interface{}{base64.StdEncoding.EncodeToString(encoded)}
+
+	extra := map[string]interface{}{}
+	for key, value := range data.Extra {
+		extra[key] = value
+	}
+	extra["codecs"] = names
+	data.Extra = extra
+	return data, nil
+}
+
+// decodedArgs returns the args of an encoded job of queue, decoded by the codecs named by the job
+func (m *Msg) decodedArgs(store storage.Store, queue string) (*simplejson.Json, error) {
+	names, err := m.Get("codecs").StringArray()
+	if err != nil || len(names) == 0 {
+		return nil, fmt.Errorf("%w: job %s has no valid codecs", ErrInvalidSignature, m.Jid())
+	}
+	encoded, err := m.Args().GetIndex(0).String()
+	if err != nil {
+		return nil, fmt.Errorf("%w: encoded job %s has no encoded args", ErrInvalidSignature, m.Jid())
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if data, err = store.DecodeArgs(queue, names, data); err != nil {
+		return nil, err
+	}
+	return simplejson.NewJson(data)
+}
+
+// codecJobFunc hands the handler the decoded args of the jobs of queue. The encoded args are put back once
+// it returns, so retries stay encoded. Jobs which can't be decoded fail without being retried, as do the
+// plain jobs of queues requiring authenticated args.
+func codecJobFunc(m *Manager, queue string, next JobFunc) JobFunc {
+	if len(m.opts.QueueCodecs) == 0 {
+		return next
+	}
+
+	return func(message *Msg) error {
+		if message.Get("codecs").Interface() == nil {
+			if _, err := m.opts.store.DecodeArgs(queue, nil, nil); err != nil {
+				return NonRetryable(err)
+			}
+			return next(message)
+		}
+		args, err := message.decodedArgs(m.opts.store, queue)
+		if err != nil {
+			return NonRetryable(err)
+		}
+
+		encodedArgs := message.Get("args")
+		names := message.Get("codecs")
+		message.Set("args", args.Interface())
+		message.Del("codecs")
+		defer func() {
+			message.Set("args", encodedArgs.Interface())
+			message.Set("codecs", names.Interface())
+		}()
+		return next(message)
+	}
+}
@@ -0,0 +1,155 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCodecChain(t *testing.T) CodecChain {
+	encryption, err := AESGCMCodec([]byte("0123456789abcdef"))
+	assert.NoError(t, err)
+	return CodecChain{CompressionCodec(CompressionGzip), encryption, HMACCodec([]byte("secret"))}
+}
+
+func TestCodecs(t *testing.T) {
+	data := []byte(`["some","args"]`)
+	for _, codec := range testCodecChain(t) {
+		encoded, err := codec.Encode(data)
+		assert.NoError(t, err)
+		assert.NotEqual(t, data, encoded)
+		decoded, err := codec.Decode(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, data, decoded, codec.Name())
+	}
+
+	signed, _ := HMACCodec([]byte("secret")).Encode(data)
+	_, err := HMACCodec([]byte("other")).Decode(signed)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	_, err = AESGCMCodec([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestQueueCodecs(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.QueueCodecs = map[string]CodecChain{"secure": testCodecChain(t)}
+	opts.store = newStore(opts)
+	// codecs replace the compression of the queues they're configured on
+	opts.ArgsCompression = &ArgsCompressionOptions{Threshold: 1}
+
+	p := newProducer(opts)
+	_, err = p.Enqueue("secure", "Secret", []string{"card", "4242"})
+	assert.NoError(t, err)
+	payload, _ := opts.client.LPop(ctx, "prod:queue:secure").Result()
+	assert.NotContains(t, payload, "4242")
+
+	message, _ := NewMsg(payload)
+	assert.Equal(t, []string{"gzip", "aes-gcm", "hmac-sha256"}, message.Get("codecs").MustStringArray())
+	assert.Nil(t, message.Get("compressed").Interface())
+
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	var args []string
+	job := mgr.buildJob("secure", func(m *Msg) error {
+		args = m.Args().MustStringArray()
+		return nil
+	}, []MiddlewareFunc{NopMiddleware})
+	assert.NoError(t, job(message))
+	assert.Equal(t, []string{"card", "4242"}, args)
+	// the args are encoded again once the job is done
+	assert.NotContains(t, message.ToJson(), "4242")
+
+	// tampered jobs aren't run, nor retried
+	tampered, _ := NewMsg(payload)
+	tampered.Set("args", []string{"AAAA"})
+	err = job(tampered)
+	assert.True(t, errors.Is(err, ErrNonRetryable))
+}
+
+func TestQueueCodecsEnforced(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	compression := CodecChain{CompressionCodec(CompressionGzip)}
+	opts.QueueCodecs = map[string]CodecChain{"secure": testCodecChain(t), "compressed": compression}
+	opts.store = newStore(opts)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	ran := 0
+	handler := func(m *Msg) error {
+		ran++
+		return nil
+	}
+	secure := mgr.buildJob("secure", handler, []MiddlewareFunc{NopMiddleware})
+	plain := mgr.buildJob("plain", handler, []MiddlewareFunc{NopMiddleware})
+
+	assertRejected := func(job JobFunc, payload string) {
+		message, _ := NewMsg(payload)
+		err := job(message)
+		assert.True(t, errors.Is(err, ErrNonRetryable), payload)
+		assert.True(t, errors.Is(err, ErrInvalidSignature), payload)
+	}
+
+	// jobs of the signed queue which aren't signed don't run
+	assertRejected(secure, `{"jid":"1","class":"Secret","args":["card","4242"]}`)
+
+	// nor do the jobs encoded by a chain without its authenticating codecs
+	_, err = newProducer(opts).Enqueue("compressed", "Secret", []string{"card", "4242"})
+	assert.NoError(t, err)
+	weaker, _ := opts.client.LPop(ctx, "prod:queue:compressed").Result()
+	assertRejected(secure, weaker)
+
+	// or the jobs encoded by unknown codecs
+	assertRejected(plain, `{"jid":"3","class":"Secret","args":["AAAA"],"codecs":["rot13"]}`)
+	assert.Equal(t, 0, ran)
+
+	// other jobs of queues without codecs run as they are
+	message, _ := NewMsg(`{"jid":"2","class":"Plain","args":["a"]}`)
+	assert.NoError(t, plain(message))
+	assert.Equal(t, 1, ran)
+}
+
+func TestQueueCodecsAcrossQueues(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.QueueCodecs = map[string]CodecChain{
+		"secure":     testCodecChain(t),
+		"compressed": {CompressionCodec(CompressionZlib)},
+	}
+	opts.store = newStore(opts)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	_, err = newProducer(opts).Enqueue("secure", "Secret", []string{"card", "4242"})
+	assert.NoError(t, err)
+	payload, _ := opts.client.LPop(ctx, "prod:queue:secure").Result()
+
+	// a job moved to another queue, such as its retry queue or a fallback queue, keeps its encoding
+	// and runs with the codecs it names
+	for _, queue := range []string{"retries", "compressed", "secure"} {
+		var args []string
+		job := mgr.buildJob(queue, func(m *Msg) error {
+			args = m.Args().MustStringArray()
+			return nil
+		}, []MiddlewareFunc{NopMiddleware})
+		message, _ := NewMsg(payload)
+		message.Set("queue", queue)
+		assert.NoError(t, job(message), queue)
+		assert.Equal(t, []string{"card", "4242"}, args, queue)
+	}
+
+	// compressed jobs moved to the signed queue aren't run
+	_, err = newProducer(opts).Enqueue("compressed", "Secret", []string{"card", "4242"})
+	assert.NoError(t, err)
+	compressed, _ := opts.client.LPop(ctx, "prod:queue:compressed").Result()
+	message, _ := NewMsg(compressed)
+	err = mgr.buildJob("secure", func(m *Msg) error { return nil }, []MiddlewareFunc{NopMiddleware})(message)
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
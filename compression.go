@@ -0,0 +1,161 @@
+package workers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/bitly/go-simplejson"
+)
+
+// CompressionAlgorithm names how compressed args were compressed
+type CompressionAlgorithm string
+
+const (
+	// CompressionZlib compresses args with zlib
+	CompressionZlib CompressionAlgorithm = "zlib"
+	// CompressionGzip compresses args with gzip
+	CompressionGzip CompressionAlgorithm = "gzip"
+)
+
+// defaultCompressionThreshold is the JSON size from which args are compressed
+const defaultCompressionThreshold = 16 * 1024
+
+// ArgsCompressionOptions configures the compression of large args on enqueue. Compressed jobs have
+// their args replaced by a single base64 string of the compressed JSON args, and the algorithm in
+// their "compressed" field, so a Ruby middleware can read and write them the same way. Managers
+// decompress such jobs whether or not compression is configured.
+type ArgsCompressionOptions struct {
+	// Optional algorithm, defaults to zlib
+	Algorithm CompressionAlgorithm
+
+	// Optional size of the JSON args from which they are compressed, defaults to 16KB
+	Threshold int
+}
+
+func compress(algorithm CompressionAlgorithm, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch algorithm {
+	case CompressionZlib, "":
+		w = zlib.NewWriter(&buf)
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(algorithm CompressionAlgorithm, data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch algorithm {
+	case CompressionZlib:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	case CompressionGzip:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// compressArgs compresses the job's args when they are larger than the configured threshold
+func (p *Producer) compressArgs(data EnqueueData) (EnqueueData, error) {
+	opts := p.opts.ArgsCompression
+	if opts == nil {
+		return data, nil
+	}
+
+	encoded, err := json.Marshal(data.Args)
+	if err != nil {
+		return data, err
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+	if len(encoded) < threshold {
+		return data, nil
+	}
+
+	algorithm := opts.Algorithm
+	if algorithm == "" {
+		algorithm = CompressionZlib
+	}
+	compressed, err := compress(algorithm, encoded)
+	if err != nil {
+		return data, err
+	}
+	data.Args = []interface{}{base64.StdEncoding.EncodeToString(compressed)}
+
+	extra := map[string]interface{}{}
+	for key, value := range data.Extra {
+		extra[key] = value
+	}
+	extra["compressed"] = string(algorithm)
+	data.Extra = extra
+	return data, nil
+}
+
+// decompressedArgs returns the decompressed args of a compressed job, or nil for other jobs
+func (m *Msg) decompressedArgs() (*simplejson.Json, error) {
+	algorithm, err := m.Get("compressed").String()
+	if err != nil || algorithm == "" {
+		return nil, nil
+	}
+
+	encoded, err := m.Args().GetIndex(0).String()
+	if err != nil {
+		return nil, fmt.Errorf("compressed job %s has no compressed args", m.Jid())
+	}
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decompress(CompressionAlgorithm(algorithm), compressed)
+	if err != nil {
+		return nil, err
+	}
+	return simplejson.NewJson(data)
+}
+
+// decompressionJobFunc hands the handler the decompressed args of compressed jobs. The compressed
+// args are put back once it returns, so retries keep the job compressed.
+func decompressionJobFunc(next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		args, err := message.decompressedArgs()
+		if err != nil {
+			return err
+		}
+		if args == nil {
+			return next(message)
+		}
+
+		compressedArgs := message.Get("args")
+		algorithm := message.Get("compressed")
+		message.Set("args", args.Interface())
+		message.Del("compressed")
+		defer func() {
+			message.Set("args", compressedArgs.Interface())
+			message.Set("compressed", algorithm.Interface())
+		}()
+		return next(message)
+	}
+}
@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducer_ArgsCompression(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	large := strings.Repeat("lorem ipsum ", 1000)
+	for _, algorithm := range []CompressionAlgorithm{CompressionZlib, CompressionGzip} {
+		opts.ArgsCompression = &ArgsCompressionOptions{Algorithm: algorithm, Threshold: 1024}
+		p := newProducer(opts)
+
+		_, err = p.Enqueue("compressed", "Index", []interface{}{large, 1})
+		assert.NoError(t, err)
+		bytes, _ := rc.RPop(ctx, "prod:queue:compressed").Result()
+		assert.Less(t, len(bytes), len(large))
+
+		msg, err := NewMsg(bytes)
+		assert.NoError(t, err)
+		assert.Equal(t, string(algorithm), msg.Get("compressed").MustString())
+		assert.Len(t, msg.Args().MustArray(), 1)
+		assert.Equal(t, large, msg.DisplayArgs()[0])
+
+		var args []interface{}
+		assert.EqualError(t, decompressionJobFunc(func(m *Msg) error {
+			assert.NoError(t, m.DecodeArgs(&args))
+			_, compressed := m.CheckGet("compressed")
+			assert.False(t, compressed)
+			return errors.New("retry me")
+		})(msg), "retry me")
+		assert.Equal(t, []interface{}{large, float64(1)}, args)
+
+		// the job is put back compressed, for retries
+		assert.Equal(t, string(algorithm), msg.Get("compressed").MustString())
+		assert.Len(t, msg.Args().MustArray(), 1)
+	}
+
+	// small args stay plain
+	_, err = newProducer(opts).Enqueue("compressed", "Index", []int{1})
+	assert.NoError(t, err)
+	bytes, _ := rc.RPop(ctx, "prod:queue:compressed").Result()
+	assert.Contains(t, bytes, `"args":[1]`)
+	assert.NotContains(t, bytes, `"compressed":`)
+
+	options := testOptionsWithNamespace("prod")
+	options.ArgsCompression = &ArgsCompressionOptions{Algorithm: "lz4"}
+	_, err = processOptions(options)
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	workers "github.com/digitalocean/go-workers2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	goQueue   = "conformance_go"
+	rubyQueue = "conformance_ruby"
+
+	flowTimeout = 30 * time.Second
+)
+
+// conformanceArgs covers the JSON types payloads carry between the languages
+var conformanceArgs = []interface{}{
+	"unicode ✓ and \"quotes\"",
+	42,
+	3.5,
+	true,
+	nil,
+	map[string]interface{}{"nested": []interface{}{1, "two", map[string]interface{}{}}},
+	[]interface{}{},
+}
+
+func redisAddr() string {
+	if addr := os.Getenv("CONFORMANCE_REDIS"); addr != "" {
+		return addr
+	}
+	return "localhost:6379"
+}
+
+// newManager returns a manager sharing Sidekiq's Redis and namespace, with the conformance queues emptied
+func newManager(t *testing.T) *workers.Manager {
+	mgr, err := workers.NewManager(workers.Options{
+		ServerAddr: redisAddr(),
+		ProcessID:  "conformance-" + t.Name(),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	client := mgr.Opts().Client()
+	assert.NoError(t, client.Del(context.Background(), "queue:"+goQueue, "queue:"+rubyQueue).Err())
+	return mgr
+}
+
+// run runs mgr until the test ends
+func run(t *testing.T, mgr *workers.Manager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		mgr.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// normalize returns v as decoded from its JSON encoding
+func normalize(t *testing.T, v interface{}) interface{} {
+	encoded, err := json.Marshal(v)
+	assert.NoError(t, err)
+	var res interface{}
+	assert.NoError(t, json.Unmarshal(encoded, &res))
+	return res
+}
+
+var errMissing = errors.New("missing")
+
+// agent sends a command to the Ruby agent and returns its reply
+func agent(t *testing.T, client *redis.Client, command map[string]interface{}) (map[string]interface{}, error) {
+	ctx := context.Background()
+	id := fmt.Sprint(time.Now().UnixNano())
+	command["id"] = id
+	encoded, err := json.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.LPush(ctx, "conformance:commands", encoded).Err(); err != nil {
+		return nil, err
+	}
+	reply, err := client.BRPop(ctx, flowTimeout, "conformance:replies:"+id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("no reply from the agent, is it running? %v", err)
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal([]byte(reply[1]), &res); err != nil {
+		return nil, err
+	}
+	if missing, _ := res["missing"].(bool); missing {
+		return nil, errMissing
+	}
+	if message, ok := res["error"]; ok {
+		return nil, fmt.Errorf("agent: %v", message)
+	}
+	return res, nil
+}
+
+// rubyJob returns the queue and payload of the job with jid as Sidekiq ran it
+func rubyJob(t *testing.T, client *redis.Client, jid string) (string, map[string]interface{}) {
+	deadline := time.Now().Add(flowTimeout)
+	for time.Now().Before(deadline) {
+		recorded, err := client.Get(context.Background(), "conformance:results:"+jid).Result()
+		if err == nil {
+			var res struct {
+				Queue string                 `json:"queue"`
+				Job   map[string]interface{} `json:"job"`
+			}
+			assert.NoError(t, json.Unmarshal([]byte(recorded), &res))
+			return res.Queue, res.Job
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Sidekiq didn't run job %s in %v, is it running?", jid, flowTimeout)
+	return "", nil
+}
+
+// goJob returns the job with jid once the Go worker received it
+func goJob(t *testing.T, received chan *workers.Msg, jid string) *workers.Msg {
+	timeout := time.After(flowTimeout)
+	for {
+		select {
+		case msg := <-received:
+			if msg.Jid() == jid {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("the Go worker didn't run job %s in %v", jid, flowTimeout)
+		}
+	}
+}
+
+func assertSidekiqPayload(t *testing.T, job map[string]interface{}, jid string) {
+	assert.Equal(t, jid, job["jid"])
+	assert.Equal(t, "ConformanceEchoJob", job["class"])
+	assert.Equal(t, normalize(t, conformanceArgs), job["args"])
+	assert.IsType(t, float64(0), job["created_at"])
+	assert.IsType(t, float64(0), job["enqueued_at"])
+}
+
+func TestGoEnqueueSidekiqRuns(t *testing.T) {
+	mgr := newManager(t)
+	client := mgr.Opts().Client()
+
+	jid, err := mgr.Producer().Enqueue(rubyQueue, "ConformanceEchoJob", conformanceArgs)
+	assert.NoError(t, err)
+
+	queue, job := rubyJob(t, client, jid)
+	assert.Equal(t, rubyQueue, queue)
+	assertSidekiqPayload(t, job, jid)
+}
+
+func TestGoScheduleSidekiqRuns(t *testing.T) {
+	mgr := newManager(t)
+	client := mgr.Opts().Client()
+
+	start := time.Now()
+	jid, err := mgr.Producer().EnqueueIn(rubyQueue, "ConformanceEchoJob", 2, conformanceArgs)
+	assert.NoError(t, err)
+
+	// the job is moved to its queue by Sidekiq's scheduler, no Go manager runs
+	_, job := rubyJob(t, client, jid)
+	assert.True(t, time.Since(start) >= 2*time.Second, "job ran early")
+	assertSidekiqPayload(t, job, jid)
+}
+
+func TestSidekiqEnqueueGoRuns(t *testing.T) {
+	mgr := newManager(t)
+	client := mgr.Opts().Client()
+	received := make(chan *workers.Msg, 10)
+	mgr.AddWorker(goQueue, 1, func(msg *workers.Msg) error {
+		received <- msg
+		return nil
+	})
+	run(t, mgr)
+
+	reply, err := agent(t, client, map[string]interface{}{
+		"action": "push",
+		"item":   map[string]interface{}{"class": "GoConformanceJob", "queue": goQueue, "args": conformanceArgs},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	jid := reply["jid"].(string)
+
+	msg := goJob(t, received, jid)
+	assert.Equal(t, "GoConformanceJob", msg.Class())
+	assert.Equal(t, goQueue, msg.Get("queue").MustString())
+	var args interface{}
+	raw, _ := msg.Args().MarshalJSON()
+	assert.NoError(t, json.Unmarshal(raw, &args))
+	assert.Equal(t, normalize(t, conformanceArgs), args)
+}
+
+func TestSidekiqScheduleGoRuns(t *testing.T) {
+	mgr := newManager(t)
+	client := mgr.Opts().Client()
+	received := make(chan *workers.Msg, 10)
+	mgr.AddWorker(goQueue, 1, func(msg *workers.Msg) error {
+		received <- msg
+		return nil
+	})
+	run(t, mgr)
+
+	start := time.Now()
+	reply, err := agent(t, client, map[string]interface{}{
+		"action": "push",
+		"item": map[string]interface{}{
+			"class": "GoConformanceJob",
+			"queue": goQueue,
+			"args":  conformanceArgs,
+			"at":    float64(start.Add(2*time.Second).UnixNano()) / float64(time.Second),
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	goJob(t, received, reply["jid"].(string))
+	assert.True(t, time.Since(start) >= 2*time.Second, "job ran early")
+}
+
+func TestGoRetrySidekiqRetriesNow(t *testing.T) {
+	mgr := newManager(t)
+	client := mgr.Opts().Client()
+	received := make(chan *workers.Msg, 10)
+	mgr.AddWorkerWithOptions(goQueue, workers.WorkerOptions{
+		Concurrency: 1,
+		// the retry waits for Sidekiq to retry it now
+		RetryBackoff: func(count int) time.Duration { return time.Hour },
+	}, func(msg *workers.Msg) error {
+		received <- msg
+		if _, err := msg.Get("retry_count").Int(); err != nil {
+			return errors.New("conformance failure")
+		}
+		return nil
+	})
+	run(t, mgr)
+
+	jid, err := mgr.Producer().EnqueueWithOptions(goQueue, "GoConformanceJob", conformanceArgs, workers.EnqueueOptions{Retry: true})
+	assert.NoError(t, err)
+	goJob(t, received, jid)
+
+	// the retry is written once the failed job returns
+	var reply map[string]interface{}
+	deadline := time.Now().Add(flowTimeout)
+	for {
+		reply, err = agent(t, client, map[string]interface{}{"action": "retry", "jid": jid})
+		if err != errMissing || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+	item := reply["item"].(map[string]interface{})
+	assert.Equal(t, "conformance failure", item["error_message"])
+	assert.Equal(t, float64(0), item["retry_count"])
+	assert.Equal(t, goQueue, item["queue"])
+	assert.Equal(t, normalize(t, conformanceArgs), item["args"])
+
+	retried := goJob(t, received, jid)
+	assert.Equal(t, 0, retried.Get("retry_count").MustInt())
+	assert.Equal(t, "GoConformanceJob", retried.Class())
+}
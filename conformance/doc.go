@@ -0,0 +1,10 @@
+// Package conformance holds the end-to-end checks that go-workers2 and a real Sidekiq process agree
+// on the jobs they exchange: enqueued, scheduled and retried jobs, in both directions.
+//
+// Start Redis, Sidekiq and its agent, then run the driver with the conformance build tag:
+//
+//	docker compose -f conformance/docker-compose.yml up -d --build
+//	go test -tags conformance -v ./conformance
+//
+// CONFORMANCE_REDIS sets the address of the Redis shared with Sidekiq, localhost:6379 by default.
+package conformance
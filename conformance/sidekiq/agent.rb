@@ -0,0 +1,33 @@
+require_relative 'config'
+
+# The agent runs the Ruby side of the flows the Go driver starts: it pops commands from
+# conformance:commands and pushes its reply onto conformance:replies:<id>.
+def handle(command)
+  case command['action']
+  when 'push'
+    { 'jid' => Sidekiq::Client.push(command['item']) }
+  when 'retry'
+    # retries the job now, like the Retry Now button of the Web UI
+    entry = Sidekiq::RetrySet.new.find_job(command['jid'])
+    return { 'error' => "no retry #{command['jid']}", 'missing' => true } unless entry
+
+    item = entry.item
+    entry.retry
+    { 'item' => item }
+  else
+    { 'error' => "unknown action #{command['action']}" }
+  end
+rescue StandardError => e
+  { 'error' => "#{e.class}: #{e.message}" }
+end
+
+puts 'conformance agent waiting for commands'
+loop do
+  _, payload = Sidekiq.redis { |conn| conn.brpop('conformance:commands', timeout: 0) }
+  command = JSON.parse(payload)
+  reply = handle(command)
+  Sidekiq.redis do |conn|
+    conn.lpush("conformance:replies:#{command['id']}", JSON.generate(reply))
+    conn.expire("conformance:replies:#{command['id']}", 300)
+  end
+end
@@ -0,0 +1,29 @@
+require 'sidekiq'
+require 'sidekiq/api'
+require 'json'
+
+REDIS_URL = ENV.fetch('REDIS_URL', 'redis://localhost:6379/0')
+
+# ConformanceRecorder records every job Sidekiq runs as it received it, for the Go driver to compare
+# with what it enqueued
+class ConformanceRecorder
+  def call(_worker, job, queue)
+    Sidekiq.redis do |conn|
+      conn.setex("conformance:results:#{job['jid']}", 300, JSON.generate('queue' => queue, 'job' => job))
+    end
+    yield
+  end
+end
+
+Sidekiq.configure_server do |config|
+  config.redis = { url: REDIS_URL }
+  # scheduled jobs of the conformance flows are due within seconds
+  config[:average_scheduled_poll_interval] = 1
+  config.server_middleware do |chain|
+    chain.add ConformanceRecorder
+  end
+end
+
+Sidekiq.configure_client do |config|
+  config.redis = { url: REDIS_URL }
+end
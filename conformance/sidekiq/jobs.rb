@@ -0,0 +1,10 @@
+require_relative 'config'
+
+# ConformanceEchoJob is the job Go enqueues for Sidekiq, whose payload ConformanceRecorder records
+class ConformanceEchoJob
+  include Sidekiq::Worker
+
+  sidekiq_options queue: 'conformance_ruby'
+
+  def perform(*_args); end
+end
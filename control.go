@@ -0,0 +1,121 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// Pause stops fetching new jobs until Resume is called. Jobs in flight keep running.
+func (m *Manager) Pause() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.paused = true
+	m.updateFetchersLocked()
+}
+
+// Resume fetches jobs again after Pause. It doesn't undo Quiet.
+func (m *Manager) Resume() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.paused = false
+	m.updateFetchersLocked()
+}
+
+// Quiet stops fetching new jobs for good, ahead of stopping the manager. Jobs in flight keep running.
+func (m *Manager) Quiet() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.quiet = true
+	m.updateFetchersLocked()
+}
+
+// IsPaused reports whether the manager was paused
+func (m *Manager) IsPaused() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.paused
+}
+
+// IsQuiet reports whether the manager was quieted
+func (m *Manager) IsQuiet() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.quiet
+}
+
+// fetchingLocked reports whether the manager's workers fetch new jobs. It must be called with the lock held.
+func (m *Manager) fetchingLocked() bool {
+	return m.active && !m.paused && !m.quiet
+}
+
+func (m *Manager) updateFetchersLocked() {
+	fetching := m.fetchingLocked()
+	for _, w := range m.workers {
+		w.runnersLock.Lock()
+		fetcher := w.fetcher
+		w.runnersLock.Unlock()
+		if fetcher != nil {
+			fetcher.SetActive(fetching)
+		}
+	}
+}
+
+// ControlState is what can be controlled of a running manager
+type ControlState struct {
+	Name   string `json:"manager_name"`
+	Active bool   `json:"active"`
+	Paused bool   `json:"paused"`
+	Quiet  bool   `json:"quiet"`
+	// Concurrency of every queue
+	Concurrency map[string]int `json:"concurrency"`
+}
+
+// ControlState returns whether the manager fetches jobs, and the concurrency of its queues
+func (m *Manager) ControlState() ControlState {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	state := ControlState{
+		Name:        m.opts.ManagerDisplayName,
+		Active:      m.active,
+		Paused:      m.paused,
+		Quiet:       m.quiet,
+		Concurrency: make(map[string]int, len(m.workers)),
+	}
+	for _, w := range m.workers {
+		state.Concurrency[w.queue] = w.getConcurrency()
+	}
+	return state
+}
+
+// handleRemoteSignals applies the signals sent to the manager through Redis, the way Sidekiq's Web UI
+// quiets and stops processes: TSTP quiets the manager, TERM stops it and TTIN logs the jobs in flight
+func (m *Manager) handleRemoteSignals(ctx context.Context, heartbeatID string) {
+	for {
+		signal, err := m.opts.store.PopSignal(ctx, heartbeatID)
+		if err == storage.NoMessage {
+			return
+		}
+		if err != nil {
+			m.logger.Println("ERR: couldn't read remote signals:", err)
+			return
+		}
+
+		switch signal {
+		case "TSTP":
+			m.logger.Println("quieting on remote signal")
+			m.Quiet()
+		case "TERM":
+			m.logger.Println("stopping on remote signal")
+			go m.Stop()
+			return
+		case "TTIN":
+			var dump bytes.Buffer
+			m.WriteInFlight(&dump)
+			m.logger.Print(dump.String())
+		default:
+			m.logger.Println("ignoring unknown remote signal", signal)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_PauseResumeQuiet(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("someq", 2, func(m *Msg) error { return nil })
+	fetcher := &dummyFetcher{}
+	mgr.workers[0].fetcher = fetcher
+	mgr.Active(false)
+	mgr.Active(true)
+	assert.True(t, fetcher.IsActive())
+
+	mgr.Pause()
+	assert.True(t, mgr.IsPaused())
+	assert.False(t, fetcher.IsActive())
+	// a change of active manager doesn't resume a paused one
+	mgr.Active(false)
+	mgr.Active(true)
+	assert.False(t, fetcher.IsActive())
+
+	mgr.Resume()
+	assert.False(t, mgr.IsPaused())
+	assert.True(t, fetcher.IsActive())
+
+	mgr.Quiet()
+	mgr.Resume()
+	assert.True(t, mgr.IsQuiet())
+	assert.False(t, fetcher.IsActive())
+
+	heartbeat, err := mgr.buildHeartbeat(mgr.startedAt, 0)
+	assert.NoError(t, err)
+	assert.True(t, heartbeat.Quiet)
+
+	assert.Equal(t, ControlState{Active: true, Quiet: true, Concurrency: map[string]int{"someq": 2}}, mgr.ControlState())
+}
+
+func TestManager_HandleRemoteSignals(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	signals := storage.GetSignalsKey("prod:", "host:1:abc")
+	opts.client.LPush(ctx, signals, "TTIN", "TSTP")
+	mgr.handleRemoteSignals(ctx, "host:1:abc")
+	assert.True(t, mgr.IsQuiet())
+	nb, _ := opts.client.LLen(ctx, signals).Result()
+	assert.Equal(t, int64(0), nb)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	mgr.running, mgr.cancel = true, cancel
+	opts.client.LPush(ctx, signals, "TERM")
+	mgr.handleRemoteSignals(ctx, "host:1:abc")
+	select {
+	case <-runCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("remote TERM didn't stop the manager")
+	}
+}
@@ -0,0 +1,181 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	cronPollInterval = time.Second
+
+	defaultCronMisfireThreshold = time.Minute
+
+	// maxCronCatchUp caps the missed ticks enqueued by MisfireCatchUp
+	maxCronCatchUp = 100
+)
+
+// MisfirePolicy is what a cron job does with the ticks missed while no manager was running
+type MisfirePolicy int
+
+const (
+	// MisfireRunOnce enqueues a single job for all the missed ticks
+	MisfireRunOnce MisfirePolicy = iota
+	// MisfireSkip drops missed ticks, only ticks reached on time enqueue a job
+	MisfireSkip
+	// MisfireCatchUp enqueues a job for every missed tick, up to 100
+	MisfireCatchUp
+)
+
+// CronJob is a job enqueued on a cron schedule
+type CronJob struct {
+	// Optional name identifying the cron job across processes, defaults to its class.
+	// Cron jobs with the same class and different args need different names.
+	Name string
+
+	Queue string
+	Spec  string
+	Class string
+	Args  interface{}
+
+	// Optional location the schedule is evaluated in, defaults to the local time zone
+	Location *time.Location
+
+	Misfire MisfirePolicy
+	// Optional delay after which a tick is missed for MisfireSkip, defaults to a minute
+	MisfireThreshold time.Duration
+}
+
+type cronEntry struct {
+	job      CronJob
+	schedule *CronSchedule
+	next     time.Time
+}
+
+// AddCronJob enqueues a job of the given class and args to queue on the cron schedule spec, such as
+// "0 2 * * *" for every night at 2am. Every manager sharing the namespace may register the same cron
+// jobs: each tick is enqueued by a single active manager.
+func (m *Manager) AddCronJob(queue, spec, class string, args interface{}) error {
+	return m.AddCronJobWithOptions(CronJob{Queue: queue, Spec: spec, Class: class, Args: args})
+}
+
+// AddCronJobWithOptions registers a cron job with the given options
+func (m *Manager) AddCronJobWithOptions(job CronJob) error {
+	if job.Queue == "" || job.Class == "" {
+		return errors.New("cron jobs require a queue and a class")
+	}
+	schedule, err := ParseCronSchedule(job.Spec)
+	if err != nil {
+		return err
+	}
+	if job.Name == "" {
+		job.Name = job.Class
+	}
+	if job.Location == nil {
+		job.Location = time.Local
+	}
+	if job.MisfireThreshold <= 0 {
+		job.MisfireThreshold = defaultCronMisfireThreshold
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, entry := range m.cronJobs {
+		if entry.job.Name == job.Name {
+			return errors.New("a cron job is already named " + job.Name)
+		}
+	}
+	m.cronJobs = append(m.cronJobs, &cronEntry{job: job, schedule: schedule})
+	return nil
+}
+
+// runCron enqueues the due ticks of the cron jobs until ctx is done
+func (m *Manager) runCron(ctx context.Context) {
+	m.lock.Lock()
+	entries := m.cronJobs
+	m.lock.Unlock()
+
+	ticks, err := m.opts.store.GetCronTicks(ctx)
+	if err != nil {
+		m.logger.Println("ERR: couldn't read the last cron ticks:", err)
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		from := now
+		// ticks missed since the last one enqueued are due right away
+		if last, ok := ticks[entry.job.Name]; ok && entry.job.Misfire != MisfireSkip && last < now.Unix() {
+			from = time.Unix(last, 0)
+		}
+		entry.next = entry.schedule.Next(from.In(entry.job.Location))
+	}
+
+	producer := m.Producer()
+	ticker := time.NewTicker(cronPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !m.IsActive() {
+				continue
+			}
+			for _, entry := range entries {
+				if !entry.next.IsZero() && !now.Before(entry.next) {
+					m.fireCron(ctx, producer, entry, now)
+				}
+			}
+		}
+	}
+}
+
+// fireCron claims the due ticks of a cron job, and enqueues them according to its misfire policy
+func (m *Manager) fireCron(ctx context.Context, producer *Producer, entry *cronEntry, now time.Time) {
+	var due []time.Time
+	for tick := entry.next; !tick.IsZero() && !tick.After(now); tick = entry.schedule.Next(tick) {
+		due = append(due, tick)
+		if len(due) > maxCronCatchUp {
+			due = due[1:]
+		}
+	}
+	latest := due[len(due)-1]
+
+	previous, claimed, err := m.opts.store.ClaimCronTick(ctx, entry.job.Name, latest.Unix())
+	if err != nil {
+		m.logger.Println("ERR: couldn't claim cron tick of", entry.job.Name, ":", err)
+		return
+	}
+	entry.next = entry.schedule.Next(latest)
+	if !claimed {
+		// another manager enqueued it
+		return
+	}
+
+	var enqueue []time.Time
+	switch entry.job.Misfire {
+	case MisfireSkip:
+		if now.Sub(latest) <= entry.job.MisfireThreshold {
+			enqueue = []time.Time{latest}
+		}
+	case MisfireCatchUp:
+		for _, tick := range due {
+			if tick.Unix() > previous {
+				enqueue = append(enqueue, tick)
+			}
+		}
+	default:
+		enqueue = []time.Time{latest}
+	}
+
+	for _, tick := range enqueue {
+		// caught up ticks share their class and args, and were already deduplicated by their claim
+		opts := EnqueueOptions{
+			At:        nowToSecondsWithNanoPrecision(),
+			Custom:    map[string]interface{}{"cron": entry.job.Name, "cron_at": tick.Unix()},
+			DedupeFor: -1,
+		}
+		if _, err := producer.EnqueueWithContext(ctx, entry.job.Queue, entry.job.Class, entry.job.Args, opts); err != nil {
+			m.logger.Println("ERR: couldn't enqueue cron job", entry.job.Name, ":", err)
+		}
+	}
+}
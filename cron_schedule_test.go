@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "x * * * *"} {
+		_, err := ParseCronSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+
+	at := func(s string) time.Time {
+		res, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		assert.NoError(t, err)
+		return res
+	}
+
+	tests := []struct {
+		spec string
+		from string
+		next string
+	}{
+		{"* * * * *", "2024-03-10 10:15", "2024-03-10 10:16"},
+		{"0 2 * * *", "2024-03-10 10:15", "2024-03-11 02:00"},
+		{"0 2 * * *", "2024-03-10 01:59", "2024-03-10 02:00"},
+		{"*/15 9-17 * * mon-fri", "2024-03-08 17:50", "2024-03-11 09:00"},
+		{"5/20 * * * *", "2024-03-10 10:26", "2024-03-10 10:45"},
+		{"0 0 1,15 * *", "2024-03-02 00:00", "2024-03-15 00:00"},
+		{"0 0 29 feb *", "2023-03-01 00:00", "2024-02-29 00:00"},
+		{"0 12 * * 7", "2024-03-10 12:00", "2024-03-17 12:00"},
+		// with both days restricted, either one matches
+		{"0 0 13 * fri", "2024-03-09 00:00", "2024-03-13 00:00"},
+		{"@hourly", "2024-03-10 10:15", "2024-03-10 11:00"},
+		{"@monthly", "2024-12-10 10:15", "2025-01-01 00:00"},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCronSchedule(test.spec)
+		assert.NoError(t, err, test.spec)
+		assert.Equal(t, at(test.next), schedule.Next(at(test.from)), test.spec)
+	}
+
+	never, err := ParseCronSchedule("0 0 30 feb *")
+	assert.NoError(t, err)
+	assert.True(t, never.Next(at("2024-01-01 00:00")).IsZero())
+}
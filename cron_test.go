@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_AddCronJob(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.AddCronJob("reports", "0 2 * * *", "NightlyReport", []int{1}))
+	assert.Error(t, mgr.AddCronJob("reports", "0 3 * * *", "NightlyReport", []int{2}))
+	assert.NoError(t, mgr.AddCronJobWithOptions(CronJob{Name: "late", Queue: "reports", Spec: "0 3 * * *", Class: "NightlyReport"}))
+	assert.Error(t, mgr.AddCronJob("reports", "0 2 * *", "Other", nil))
+	assert.Error(t, mgr.AddCronJob("", "0 2 * * *", "Other", nil))
+}
+
+func TestManager_FireCron(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	newEntry := func(mgr *Manager, policy MisfirePolicy) *cronEntry {
+		assert.NoError(t, mgr.AddCronJobWithOptions(CronJob{Queue: "cron", Spec: "*/10 * * * *", Class: "Report", Args: []int{1}, Location: time.UTC, Misfire: policy}))
+		return mgr.cronJobs[0]
+	}
+	mgr1, _ := newManager(opts)
+	mgr2, _ := newManager(opts)
+	entry1, entry2 := newEntry(mgr1, MisfireCatchUp), newEntry(mgr2, MisfireCatchUp)
+
+	start := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	entry1.next, entry2.next = start, start
+
+	// each tick is enqueued by a single manager
+	mgr1.fireCron(ctx, mgr1.Producer(), entry1, start.Add(time.Second))
+	mgr2.fireCron(ctx, mgr2.Producer(), entry2, start.Add(2*time.Second))
+	nb, _ := rc.LLen(ctx, "prod:queue:cron").Result()
+	assert.Equal(t, int64(1), nb)
+	assert.Equal(t, start.Add(10*time.Minute), entry1.next)
+	assert.Equal(t, start.Add(10*time.Minute), entry2.next)
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:cron").Result()
+	msg, _ := NewMsg(bytes)
+	assert.Equal(t, "Report", msg.Class())
+	assert.Equal(t, "Report", msg.Get("cron").MustString())
+	assert.Equal(t, start.Unix(), msg.Get("cron_at").MustInt64())
+
+	// catching up enqueues every missed tick
+	mgr1.fireCron(ctx, mgr1.Producer(), entry1, start.Add(35*time.Minute))
+	nb, _ = rc.LLen(ctx, "prod:queue:cron").Result()
+	assert.Equal(t, int64(3), nb)
+	assert.Equal(t, start.Add(40*time.Minute), entry1.next)
+	rc.Del(ctx, "prod:queue:cron")
+
+	// running once enqueues a single job for the missed ticks
+	entry2.job.Misfire = MisfireRunOnce
+	mgr2.fireCron(ctx, mgr2.Producer(), entry2, start.Add(65*time.Minute))
+	nb, _ = rc.LLen(ctx, "prod:queue:cron").Result()
+	assert.Equal(t, int64(1), nb)
+	rc.Del(ctx, "prod:queue:cron")
+
+	// skipping drops ticks reached too late
+	entry2.job.Misfire = MisfireSkip
+	entry2.job.MisfireThreshold = time.Minute
+	mgr2.fireCron(ctx, mgr2.Producer(), entry2, start.Add(75*time.Minute))
+	nb, _ = rc.LLen(ctx, "prod:queue:cron").Result()
+	assert.Equal(t, int64(0), nb)
+	mgr2.fireCron(ctx, mgr2.Producer(), entry2, start.Add(80*time.Minute+time.Second))
+	nb, _ = rc.LLen(ctx, "prod:queue:cron").Result()
+	assert.Equal(t, int64(1), nb)
+
+	ticks, err := opts.store.GetCronTicks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(80*time.Minute).Unix(), ticks["Report"])
+}
+
+func TestManager_FireCronCatchUpWithDedupWindow(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.DedupWindow = time.Hour
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	assert.NoError(t, mgr.AddCronJobWithOptions(CronJob{Queue: "cron", Spec: "*/10 * * * *", Class: "Report", Args: []int{1}, Location: time.UTC, Misfire: MisfireCatchUp}))
+	entry := mgr.cronJobs[0]
+	start := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	entry.next = start
+
+	// the caught up ticks share their class and args, and none is dropped as a duplicate
+	mgr.fireCron(ctx, mgr.Producer(), entry, start.Add(25*time.Minute))
+	nb, _ := opts.client.LLen(ctx, "prod:queue:cron").Result()
+	assert.Equal(t, int64(3), nb)
+
+	// while the callers' enqueues are still deduplicated
+	_, err = mgr.Producer().Enqueue("cron", "Report", []int{1})
+	assert.NoError(t, err)
+	_, err = mgr.Producer().Enqueue("cron", "Report", []int{1})
+	assert.Equal(t, ErrDuplicateJob, err)
+}
+
+func TestManager_RunCron(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	assert.NoError(t, mgr.AddCronJobWithOptions(CronJob{Queue: "cron", Spec: "0 * * * *", Class: "Hourly", Location: time.UTC}))
+
+	// the tick missed while no manager ran is enqueued once on start
+	_, _, err = opts.store.ClaimCronTick(ctx, "Hourly", time.Now().Add(-3*time.Hour).Unix())
+	assert.NoError(t, err)
+
+	cronCtx, cancel := context.WithCancel(ctx)
+	done := make(chan bool)
+	go func() {
+		mgr.runCron(cronCtx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		nb, _ := opts.client.LLen(ctx, "prod:queue:cron").Result()
+		return nb == 1
+	}, 3*time.Second, 50*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,133 @@
+package workers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+const (
+	defaultDeadLetterRetention = 180 * 24 * time.Hour
+	defaultDeadLetterMaxJobs   = 10000
+)
+
+// DeadLetterOptions configures the dead-letter queue of a queue
+type DeadLetterOptions struct {
+	// Optional time dead-lettered jobs are kept, defaults to 180 days
+	Retention time.Duration
+	// Optional number of jobs kept, the oldest ones being dropped first, defaults to 10000
+	MaxJobs int64
+}
+
+// DeadLetterConsumerFunc handles a job drained from a dead-letter queue. Jobs it fails to handle are
+// put back in the dead-letter queue.
+type DeadLetterConsumerFunc func(message *Msg) error
+
+type deadLetterConsumer struct {
+	queue    string
+	consumer DeadLetterConsumerFunc
+}
+
+// deadLetter moves a job of queue to the queue's dead-letter queue if it has one, or else to the
+// shared dead set
+func (m *Manager) deadLetter(ctx context.Context, queue string, message *Msg) error {
+	now := nowToSecondsWithNanoPrecision()
+	opts, ok := m.opts.DeadLetterQueues[queue]
+	if !ok {
+		return m.opts.store.EnqueueDeadMessage(ctx, now, message.ToJson())
+	}
+
+	if opts.Retention <= 0 {
+		opts.Retention = defaultDeadLetterRetention
+	}
+	if opts.MaxJobs <= 0 {
+		opts.MaxJobs = defaultDeadLetterMaxJobs
+	}
+	return m.opts.store.EnqueueDeadLetterMessage(ctx, queue, now, message.ToJson(), opts.Retention, opts.MaxJobs)
+}
+
+// hasDeadLetterQueue reports whether the namespaced queue has a dead-letter queue, and returns its name
+func (m *Manager) hasDeadLetterQueue(namespacedQueue string) (string, bool) {
+	queue := strings.TrimPrefix(namespacedQueue, m.opts.Namespace)
+	_, ok := m.opts.DeadLetterQueues[queue]
+	return queue, ok
+}
+
+// DeadLetterJobs returns the jobs in the dead-letter queue of queue, oldest first
+func (m *Manager) DeadLetterJobs(ctx context.Context, queue string) ([]*Msg, error) {
+	messages, err := m.opts.store.ListSetMessages(ctx, storage.DeadLetterKey(queue))
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Msg
+	for _, message := range messages {
+		msg, err := NewMsg(message.Message)
+		if err != nil {
+			m.logger.Println("ERR: skipping invalid dead-lettered job:", err)
+			continue
+		}
+		jobs = append(jobs, msg)
+	}
+	return jobs, nil
+}
+
+// DrainDeadLetterQueue hands the jobs of the dead-letter queue of queue to consumer, oldest first, and
+// returns how many it handled. Draining stops at the first job consumer fails to handle.
+func (m *Manager) DrainDeadLetterQueue(ctx context.Context, queue string, consumer DeadLetterConsumerFunc) (int, error) {
+	drained := 0
+	for ctx.Err() == nil {
+		message, err := m.opts.store.DequeueDeadLetterMessage(ctx, queue)
+		if err == storage.NoMessage {
+			return drained, nil
+		}
+		if err != nil {
+			return drained, err
+		}
+		msg, err := NewMsg(message)
+		if err != nil {
+			m.logger.Println("ERR: dropping invalid dead-lettered job:", err)
+			continue
+		}
+		m.opts.renameClass(msg)
+
+		if err := consumer(msg); err != nil {
+			if err := m.deadLetter(ctx, queue, msg); err != nil {
+				m.logger.Println("ERR: couldn't put back dead-lettered job", msg.Jid(), ":", err)
+			}
+			return drained, err
+		}
+		drained++
+	}
+	return drained, ctx.Err()
+}
+
+// AddDeadLetterConsumer drains the dead-letter queue of queue to consumer every poll interval while
+// the manager runs
+func (m *Manager) AddDeadLetterConsumer(queue string, consumer DeadLetterConsumerFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.deadLetterConsumers = append(m.deadLetterConsumers, deadLetterConsumer{queue: queue, consumer: consumer})
+}
+
+func (m *Manager) runDeadLetterConsumers(ctx context.Context) {
+	m.lock.Lock()
+	consumers := m.deadLetterConsumers
+	m.lock.Unlock()
+
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		for _, c := range consumers {
+			if _, err := m.DrainDeadLetterQueue(ctx, c.queue, c.consumer); err != nil && ctx.Err() == nil {
+				m.logger.Println("ERR: draining the dead-letter queue of", c.queue, ":", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryExhaustedToDeadLetterQueue(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.DeadLetterQueues = map[string]DeadLetterOptions{"billing": {}}
+	mgr := &Manager{opts: opts}
+
+	message, _ := NewMsg(`{"class":"Charge","jid":"1","retry":true,"retry_count":25}`)
+	wares.build("prod:billing", mgr, panickingFunc)(message)
+	assert.True(t, message.ack)
+
+	// queues without a dead-letter queue keep the exhausted jobs nowhere
+	message, _ = NewMsg(`{"class":"Report","jid":"2","retry":true,"retry_count":25}`)
+	wares.build("prod:reports", mgr, panickingFunc)(message)
+
+	jobs, err := mgr.DeadLetterJobs(ctx, "billing")
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "1", jobs[0].Jid())
+	assert.Equal(t, errorText, jobs[0].Get("error_message").MustString())
+
+	count, _ := opts.client.ZCard(ctx, "prod:"+storage.DeadKey).Result()
+	assert.Equal(t, int64(0), count)
+	jobs, err = mgr.DeadLetterJobs(ctx, "reports")
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestDeadLetterRetention(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	now := nowToSecondsWithNanoPrecision()
+	for i, at := range []float64{now - 7200, now - 10, now - 5, now} {
+		message := `{"jid":"` + string(rune('a'+i)) + `"}`
+		assert.NoError(t, opts.store.EnqueueDeadLetterMessage(ctx, "billing", at, message, time.Hour, 2))
+	}
+
+	messages, err := opts.store.ListSetMessages(ctx, storage.DeadLetterKey("billing"))
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.ScoredMessage{{Score: now - 5, Message: `{"jid":"c"}`}, {Score: now, Message: `{"jid":"d"}`}}, messages)
+}
+
+func TestDrainDeadLetterQueue(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.DeadLetterQueues = map[string]DeadLetterOptions{"billing": {}}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	for _, jid := range []string{"1", "2", "3"} {
+		message, _ := NewMsg(`{"jid":"` + jid + `"}`)
+		assert.NoError(t, mgr.deadLetter(ctx, "billing", message))
+	}
+
+	var drained []string
+	n, err := mgr.DrainDeadLetterQueue(ctx, "billing", func(message *Msg) error {
+		if message.Jid() == "2" {
+			return errors.New("still broken")
+		}
+		drained = append(drained, message.Jid())
+		return nil
+	})
+	assert.EqualError(t, err, "still broken")
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"1"}, drained)
+
+	jobs, err := mgr.DeadLetterJobs(ctx, "billing")
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	// registered consumers drain while the manager runs
+	mgr.AddDeadLetterConsumer("billing", func(message *Msg) error {
+		drained = append(drained, message.Jid())
+		return nil
+	})
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan bool)
+	go func() {
+		mgr.runDeadLetterConsumers(runCtx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		jobs, _ := mgr.DeadLetterJobs(ctx, "billing")
+		return len(jobs) == 0
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, drained)
+}
@@ -4,10 +4,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/bitly/go-simplejson"
 )
 
+// sidekiqArgsField is a struct field holding one positional argument
+type sidekiqArgsField struct {
+	index int
+	// key is the name encoding/json uses for the field
+	key string
+}
+
+// sidekiqArgsFields lists the fields mapped to positional arguments: exported fields in
+// declaration order, leaving out the ones tagged json:"-"
+func sidekiqArgsFields(t reflect.Type) []sidekiqArgsField {
+	var fields []sidekiqArgsField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// Skip unexported fields
+		if !field.IsExported() {
+			continue
+		}
+
+		key := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			name := strings.Split(tag, ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name != "" {
+				key = name
+			}
+		}
+		fields = append(fields, sidekiqArgsField{index: i, key: key})
+	}
+	return fields
+}
+
 // DecodeSidekiqArgs decodes a SimpleJSON array into a struct's public fields in order
 func DecodeSidekiqArgs(args *simplejson.Json, target interface{}) error {
 	v := reflect.ValueOf(target)
@@ -26,24 +60,13 @@ func DecodeSidekiqArgs(args *simplejson.Json, target interface{}) error {
 		return fmt.Errorf("failed to decode JSON array: %v", err)
 	}
 
-	// Create a map of field names to values
+	// Create a map of field keys to values
 	values := make(map[string]interface{})
-	currentIdx := 0
-
-	t := v.Type()
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		// Skip unexported fields
-		if !field.IsExported() {
-			continue
-		}
-
-		if currentIdx >= len(arr) {
+	for i, field := range sidekiqArgsFields(v.Type()) {
+		if i >= len(arr) {
 			break
 		}
-
-		values[field.Name] = arr[currentIdx]
-		currentIdx++
+		values[field.key] = arr[i]
 	}
 
 	// Marshal the map back to JSON
@@ -59,3 +82,35 @@ func DecodeSidekiqArgs(args *simplejson.Json, target interface{}) error {
 
 	return nil
 }
+
+// DecodeSidekiqKwargs decodes the single hash argument of a job, such as the opts of a Ruby
+// perform(opts = {}), into a struct's public fields by key. Jobs without args decode to the zero struct.
+func DecodeSidekiqKwargs(args *simplejson.Json, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a non-nil pointer to a struct")
+	}
+
+	arr, err := args.Array()
+	if err != nil {
+		return fmt.Errorf("failed to decode JSON array: %v", err)
+	}
+	if len(arr) > 1 {
+		return fmt.Errorf("expected a single hash argument, got %d arguments", len(arr))
+	}
+	if len(arr) == 0 || arr[0] == nil {
+		return nil
+	}
+	if _, ok := arr[0].(map[string]interface{}); !ok {
+		return fmt.Errorf("expected a hash argument, got %T", arr[0])
+	}
+
+	jsonBytes, err := json.Marshal(arr[0])
+	if err != nil {
+		return fmt.Errorf("failed to marshal intermediate JSON: %v", err)
+	}
+	if err := json.Unmarshal(jsonBytes, target); err != nil {
+		return fmt.Errorf("failed to unmarshal into target struct: %v", err)
+	}
+	return nil
+}
@@ -412,6 +412,23 @@ func TestDecodeSidekiqArgs(t *testing.T) {
 				assert.Equal(t, e.Struct.EmptyOmitted, a.Struct.EmptyOmitted, "empty field should match")
 			},
 		},
+		{
+			name:    "top-level json tags",
+			jsonStr: `["renamed", "second"]`,
+			target: &struct {
+				Renamed string `json:"renamed"`
+				Ignored string `json:"-"`
+				Second  string `json:",omitempty"`
+			}{},
+			expected: &struct {
+				Renamed string `json:"renamed"`
+				Ignored string `json:"-"`
+				Second  string `json:",omitempty"`
+			}{
+				Renamed: "renamed",
+				Second:  "second",
+			},
+		},
 		{
 			name:    "null values",
 			jsonStr: `[null, null, null, null, null]`,
@@ -480,3 +497,32 @@ func TestDecodeSidekiqArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeSidekiqKwargs(t *testing.T) {
+	type options struct {
+		Name    string            `json:"name"`
+		Count   int               `json:"count"`
+		Tags    []string          `json:"tags"`
+		Headers map[string]string `json:"headers"`
+		Skipped string            `json:"-"`
+	}
+
+	args, _ := simplejson.NewJson([]byte(`[{"count":3,"name":"report","tags":["a","b"],"headers":{"h":"v"},"Skipped":"x"}]`))
+	var got options
+	assert.NoError(t, DecodeSidekiqKwargs(args, &got))
+	assert.Equal(t, options{Name: "report", Count: 3, Tags: []string{"a", "b"}, Headers: map[string]string{"h": "v"}}, got)
+
+	for _, raw := range []string{`[]`, `[null]`} {
+		args, _ = simplejson.NewJson([]byte(raw))
+		got = options{}
+		assert.NoError(t, DecodeSidekiqKwargs(args, &got), raw)
+		assert.Equal(t, options{}, got, raw)
+	}
+
+	for _, raw := range []string{`[{"count":"three"}]`, `["report"]`, `[{}, {}]`, `{"count":3}`} {
+		args, _ = simplejson.NewJson([]byte(raw))
+		assert.Error(t, DecodeSidekiqKwargs(args, &got), raw)
+	}
+	args, _ = simplejson.NewJson([]byte(`[{}]`))
+	assert.Error(t, DecodeSidekiqKwargs(args, got))
+}
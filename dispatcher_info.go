@@ -0,0 +1,118 @@
+package workers
+
+import "sort"
+
+// HandlerInfo describes a job class, or a class pattern, registered with a JobDispatcher
+type HandlerInfo struct {
+	Class string `json:"class"`
+	// Whether Class is a pattern registered with RegisterPattern
+	Pattern  bool        `json:"pattern,omitempty"`
+	ArgsType string      `json:"args_type"`
+	Args     []JobArgDoc `json:"args,omitempty"`
+	// Former class names renamed to the class by Options.ClassAliases, as added by Manager.AddJobClasses
+	Aliases []string `json:"aliases,omitempty"`
+	// Versions of the class routed to it by RouteClassVersion
+	RoutedVersions []string `json:"routed_versions,omitempty"`
+	// Middlewares the class was registered with, outermost first
+	Middlewares []string `json:"middlewares,omitempty"`
+	// Number of args transforms registered with RegisterArgsTransform
+	ArgsTransforms int `json:"args_transforms,omitempty"`
+	// Whether the args are a single hash, set by RegisterKeywordArgs
+	KeywordArgs bool `json:"keyword_args,omitempty"`
+}
+
+// Classes returns the classes registered with the dispatcher, by name, followed by its class patterns in
+// registration order
+func (d *JobDispatcher) Classes() []HandlerInfo {
+	var res []HandlerInfo
+	for class, registered := range d.handlers {
+		info := HandlerInfo{
+			Class:          class,
+			ArgsType:       registered.argsType.Elem().String(),
+			Args:           argsDocs(registered.argsType.Elem()),
+			Middlewares:    registered.middlewares,
+			ArgsTransforms: len(d.transforms[class]),
+			KeywordArgs:    d.keywordArgs[class],
+		}
+		for version, route := range d.versionRoutes {
+			if route.class == class {
+				info.RoutedVersions = append(info.RoutedVersions, version)
+			}
+		}
+		sort.Strings(info.RoutedVersions)
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Class < res[j].Class })
+	for _, handler := range d.patterns.list() {
+		res = append(res, HandlerInfo{
+			Class:    handler.pattern,
+			Pattern:  true,
+			ArgsType: handler.argsType.Elem().String(),
+			Args:     argsDocs(handler.argsType.Elem()),
+		})
+		info := &res[len(res)-1]
+		for _, mid := range handler.mids {
+			info.Middlewares = append(info.Middlewares, funcName(mid))
+		}
+	}
+	return res
+}
+
+// Handles tells whether the jobs of class reach a handler, directly or through a version route
+func (d *JobDispatcher) Handles(class string) bool {
+	if route, ok := d.versionRoutes[class]; ok {
+		class = route.class
+	}
+	if _, ok := d.handlers[class]; ok {
+		return true
+	}
+	for _, handler := range d.patterns.list() {
+		if handler.match(class) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnhandledClasses returns the classes, such as the ones Ruby enqueues, whose jobs reach no handler
+func (d *JobDispatcher) UnhandledClasses(classes []string) []string {
+	var res []string
+	for _, class := range classes {
+		if !d.Handles(class) {
+			res = append(res, class)
+		}
+	}
+	return res
+}
+
+// AddJobClasses adds the classes registered with a dispatcher to the manager's stats, along with their
+// former names listed in Options.ClassAliases
+func (m *Manager) AddJobClasses(classes []HandlerInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.jobClasses == nil {
+		m.jobClasses = map[string]HandlerInfo{}
+	}
+	for _, info := range classes {
+		info.Aliases = nil
+		for alias, current := range m.opts.ClassAliases {
+			if current == info.Class {
+				info.Aliases = append(info.Aliases, alias)
+			}
+		}
+		sort.Strings(info.Aliases)
+		m.jobClasses[info.Class] = info
+	}
+}
+
+// JobClasses returns the classes added to the manager, by name
+func (m *Manager) JobClasses() []HandlerInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := make([]HandlerInfo, 0, len(m.jobClasses))
+	for _, info := range m.jobClasses {
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Class < res[j].Class })
+	return res
+}
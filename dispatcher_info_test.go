@@ -0,0 +1,57 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type dispatcherInfoTestArgs struct {
+	Email string
+	Count int
+}
+
+func TestJobDispatcher_Classes(t *testing.T) {
+	d := NewJobDispatcher()
+	assert.NoError(t, Register(d, "Mailer.v2", func(ctx context.Context, args dispatcherInfoTestArgs) error {
+		return nil
+	}, WithTimeout(time.Minute)))
+	assert.NoError(t, d.RegisterHandler("Report", &aliasTestHandler{}, &aliasTestArgs{}))
+	d.RouteClassVersion("Mailer", 1, 2)
+	d.RegisterArgsTransform("Report", UnwrapArgsEnvelope("args"))
+
+	classes := d.Classes()
+	if assert.Len(t, classes, 2) {
+		assert.Equal(t, "Mailer.v2", classes[0].Class)
+		assert.Equal(t, "workers.dispatcherInfoTestArgs", classes[0].ArgsType)
+		assert.Len(t, classes[0].Args, 2)
+		assert.Equal(t, []string{"Mailer.v1"}, classes[0].RoutedVersions)
+		if assert.Len(t, classes[0].Middlewares, 1) {
+			assert.Contains(t, classes[0].Middlewares[0], "WithTimeout")
+		}
+		assert.Equal(t, "Report", classes[1].Class)
+		assert.Equal(t, 1, classes[1].ArgsTransforms)
+	}
+
+	assert.Equal(t, []string{"Mailer.v3", "Unknown"}, d.UnhandledClasses([]string{"Mailer.v1", "Mailer.v2", "Mailer.v3", "Report", "Unknown"}))
+}
+
+func TestManager_JobClasses(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ClassAliases = map[string]string{"Legacy::Report": "Report", "Legacy::Mailer": "Mailer"}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	d := NewJobDispatcher()
+	assert.NoError(t, d.RegisterHandler("Report", &aliasTestHandler{}, &aliasTestArgs{}))
+	mgr.AddJobClasses(d.Classes())
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	if assert.Len(t, stats.JobClasses, 1) {
+		assert.Equal(t, "Report", stats.JobClasses[0].Class)
+		assert.Equal(t, []string{"Legacy::Report"}, stats.JobClasses[0].Aliases)
+	}
+}
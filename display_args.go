@@ -0,0 +1,161 @@
+package workers
+
+import (
+	"strings"
+)
+
+// wrapper classes of jobs enqueued through ActiveJob
+var activeJobWrappers = map[string]bool{
+	"ActiveJob::QueueAdapters::SidekiqAdapter::JobWrapper": true,
+	"Sidekiq::ActiveJob::Wrapper":                          true,
+}
+
+// RedactArgsFunc returns the args of a job as they should be displayed
+type RedactArgsFunc func(class string, args []interface{}) []interface{}
+
+// RedactedValue replaces the values hidden by RedactArgKeys
+const RedactedValue = "[FILTERED]"
+
+// RedactArgKeys hides the values of the given keys, at any depth of a job's args
+func RedactArgKeys(keys ...string) RedactArgsFunc {
+	redacted := map[string]bool{}
+	for _, key := range keys {
+		redacted[key] = true
+	}
+
+	var redact func(value interface{}) interface{}
+	redact = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case []interface{}:
+			res := make([]interface{}, len(v))
+			for i, item := range v {
+				res[i] = redact(item)
+			}
+			return res
+		case map[string]interface{}:
+			res := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				if redacted[key] {
+					res[key] = RedactedValue
+				} else {
+					res[key] = redact(item)
+				}
+			}
+			return res
+		}
+		return value
+	}
+
+	return func(class string, args []interface{}) []interface{} {
+		return redact(args).([]interface{})
+	}
+}
+
+// DisplayClass returns the class shown for the job, like Sidekiq's Web UI shows it: the wrapped
+// class of ActiveJob jobs, or the mailer and method of ActionMailer deliveries
+func (m *Msg) DisplayClass() string {
+	class := m.Class()
+	if !activeJobWrappers[class] {
+		return class
+	}
+
+	job := m.Args().GetIndex(0)
+	wrapped, err := m.Get("wrapped").String()
+	if err != nil {
+		if wrapped, err = job.Get("job_class").String(); err != nil {
+			return class
+		}
+	}
+
+	switch wrapped {
+	case "ActionMailer::DeliveryJob", "ActionMailer::MailDeliveryJob":
+		arguments, _ := job.Get("arguments").Array()
+		if len(arguments) >= 2 {
+			mailer, _ := arguments[0].(string)
+			method, _ := arguments[1].(string)
+			return mailer + "#" + method
+		}
+	}
+	return wrapped
+}
+
+// DisplayArgs returns the args shown for the job, like Sidekiq's Web UI shows them: the arguments
+// of ActiveJob jobs without ActiveJob's serialization markers, and encrypted args hidden
+func (m *Msg) DisplayArgs() []interface{} {
+	args, _ := m.Args().Array()
+	if decompressed, err := m.decompressedArgs(); err == nil && decompressed != nil {
+		args, _ = decompressed.Array()
+	}
+
+	if activeJobWrappers[m.Class()] {
+		job := m.Args().GetIndex(0)
+		wrapped, err := m.Get("wrapped").String()
+		if err != nil {
+			wrapped, _ = job.Get("job_class").String()
+		}
+		arguments, _ := job.Get("arguments").Array()
+		args = deserializeActiveJobArgument(arguments).([]interface{})
+
+		switch wrapped {
+		case "ActionMailer::DeliveryJob", "ActionMailer::MailDeliveryJob":
+			// drop the mailer, method and delivery method
+			args = dropArgs(args, 3)
+		case "ActionMailer::Parameterized::DeliveryJob":
+			args = dropArgs(args, 3)
+			if len(args) > 0 {
+				if params, ok := args[0].(map[string]interface{}); ok {
+					args = []interface{}{params["params"], params["args"]}
+				}
+			}
+		}
+	} else if m.Get("encrypt").MustBool() && len(args) > 0 {
+		// Sidekiq Enterprise encrypts the last argument
+		args = append(append([]interface{}{}, args[:len(args)-1]...), "[encrypted data]")
+	}
+
+	if args == nil {
+		args = []interface{}{}
+	}
+	return args
+}
+
+func dropArgs(args []interface{}, n int) []interface{} {
+	if len(args) <= n {
+		return []interface{}{}
+	}
+	return args[n:]
+}
+
+// deserializeActiveJobArgument replaces serialized GlobalIDs with their URI and drops ActiveJob's "_aj_" keys
+func deserializeActiveJobArgument(argument interface{}) interface{} {
+	switch v := argument.(type) {
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = deserializeActiveJobArgument(item)
+		}
+		return res
+	case map[string]interface{}:
+		if gid, ok := v["_aj_globalid"]; ok && len(v) == 1 {
+			return gid
+		}
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if strings.HasPrefix(key, "_aj_") {
+				continue
+			}
+			res[key] = deserializeActiveJobArgument(item)
+		}
+		return res
+	}
+	return argument
+}
+
+// displayArgs returns the args of a job as the manager shows them
+func (m *Manager) displayArgs(message *Msg) []interface{} {
+	args := message.DisplayArgs()
+	if m.opts.RedactArgs != nil {
+		args = m.opts.RedactArgs(message.DisplayClass(), args)
+	}
+	return args
+}
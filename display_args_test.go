@@ -0,0 +1,85 @@
+package workers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgDisplayArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		job   string
+		class string
+		args  string
+	}{
+		{
+			name:  "plain job",
+			job:   `{"class":"Add","args":[1,{"a":2}]}`,
+			class: "Add",
+			args:  `[1,{"a":2}]`,
+		},
+		{
+			name: "active job",
+			job: `{"class":"ActiveJob::QueueAdapters::SidekiqAdapter::JobWrapper","wrapped":"ImportJob","args":[{"job_class":"ImportJob",` +
+				`"arguments":[{"_aj_globalid":"gid://app/User/1"},{"id":2,"_aj_symbol_keys":["id"]}]}]}`,
+			class: "ImportJob",
+			args:  `["gid://app/User/1",{"id":2}]`,
+		},
+		{
+			name:  "active job without wrapped",
+			job:   `{"class":"Sidekiq::ActiveJob::Wrapper","args":[{"job_class":"ImportJob","arguments":[]}]}`,
+			class: "ImportJob",
+			args:  `[]`,
+		},
+		{
+			name: "mailer delivery",
+			job: `{"class":"Sidekiq::ActiveJob::Wrapper","wrapped":"ActionMailer::MailDeliveryJob","args":[{"job_class":"ActionMailer::MailDeliveryJob",` +
+				`"arguments":["UserMailer","welcome","deliver_now",{"args":[{"_aj_globalid":"gid://app/User/1"}],"_aj_ruby2_keywords":["args"]}]}]}`,
+			class: "UserMailer#welcome",
+			args:  `[{"args":["gid://app/User/1"]}]`,
+		},
+		{
+			name: "parameterized mailer delivery",
+			job: `{"class":"Sidekiq::ActiveJob::Wrapper","wrapped":"ActionMailer::Parameterized::DeliveryJob","args":[{"job_class":"ActionMailer::Parameterized::DeliveryJob",` +
+				`"arguments":["UserMailer","welcome","deliver_now",{"params":{"id":1},"args":[2]}]}]}`,
+			class: "ActionMailer::Parameterized::DeliveryJob",
+			args:  `[{"id":1},[2]]`,
+		},
+		{
+			name:  "encrypted job",
+			job:   `{"class":"Charge","encrypt":true,"args":[1,"secret"]}`,
+			class: "Charge",
+			args:  `[1,"[encrypted data]"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewMsg(tt.job)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.class, msg.DisplayClass())
+
+			display, err := NewMsg(`{}`)
+			assert.NoError(t, err)
+			display.Set("args", msg.DisplayArgs())
+			assert.Equal(t, tt.args, display.Args().ToJson())
+		})
+	}
+}
+
+func TestManagerDisplayArgsRedaction(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.RedactArgs = RedactArgKeys("password", "token")
+	mgr := &Manager{opts: opts}
+
+	msg, _ := NewMsg(`{"class":"Login","args":["bob",{"password":"hunter2","nested":[{"token":"abc","id":1}]}]}`)
+	assert.Equal(t, []interface{}{"bob", map[string]interface{}{
+		"password": RedactedValue,
+		"nested":   []interface{}{map[string]interface{}{"token": RedactedValue, "id": msg.Args().GetIndex(1).Get("nested").GetIndex(0).Get("id").Interface()}},
+	}}, mgr.displayArgs(msg))
+
+	// the job itself is untouched
+	assert.Equal(t, "hunter2", msg.Args().GetIndex(1).Get("password").MustString())
+}
@@ -0,0 +1,28 @@
+package workers
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EncodeSidekiqArgs encodes a struct's public fields in order into a Sidekiq args array,
+// the inverse of DecodeSidekiqArgs. Fields tagged json:"-" are left out.
+func EncodeSidekiqArgs(src interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(src)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("source must be a non-nil pointer to a struct")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("source must be a struct or a pointer to a struct, got %T", src)
+	}
+
+	fields := sidekiqArgsFields(v.Type())
+	args := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		args = append(args, v.Field(field.index).Interface())
+	}
+	return args, nil
+}
@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeSidekiqArgs(t *testing.T) {
+	type Inner struct {
+		Name string `json:"name"`
+	}
+
+	type Args struct {
+		ID      int
+		Renamed string `json:"renamed"`
+		Ignored string `json:"-"`
+		skipped string
+		Tags    []string
+		Inner   Inner
+		Ptr     *Inner
+	}
+
+	src := Args{
+		ID:      1,
+		Renamed: "r",
+		Ignored: "i",
+		skipped: "s",
+		Tags:    []string{"a"},
+		Inner:   Inner{Name: "n"},
+	}
+
+	tests := []struct {
+		name        string
+		src         interface{}
+		expected    string
+		expectError bool
+	}{
+		{name: "struct", src: src, expected: `[1,"r",["a"],{"name":"n"},null]`},
+		{name: "pointer to struct", src: &src, expected: `[1,"r",["a"],{"name":"n"},null]`},
+		{name: "empty struct", src: struct{}{}, expected: `[]`},
+		{name: "nil pointer", src: (*Args)(nil), expectError: true},
+		{name: "non-struct", src: []int{1}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := EncodeSidekiqArgs(tt.src)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			bytes, err := json.Marshal(args)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(bytes))
+		})
+	}
+}
+
+func TestEncodeDecodeSidekiqArgsRoundTrip(t *testing.T) {
+	type Args struct {
+		ID      int64   `json:"id"`
+		Ignored string  `json:"-"`
+		Amount  float64 `json:"amount,omitempty"`
+		Labels  map[string]string
+	}
+
+	src := Args{ID: 7, Ignored: "x", Labels: map[string]string{"k": "v"}}
+	args, err := EncodeSidekiqArgs(src)
+	assert.NoError(t, err)
+	bytes, err := json.Marshal(args)
+	assert.NoError(t, err)
+
+	msg, err := NewMsg(`{"args":` + string(bytes) + `}`)
+	assert.NoError(t, err)
+	var dst Args
+	assert.NoError(t, DecodeSidekiqArgs(msg.Args().Json, &dst))
+	assert.Equal(t, Args{ID: 7, Labels: map[string]string{"k": "v"}}, dst)
+}
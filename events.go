@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType is the kind of an Event
+type EventType string
+
+const (
+	// EventFetchError is emitted for every Redis error of a fetch
+	EventFetchError EventType = "fetch_error"
+	// EventWorkerStart and EventWorkerStop are emitted when the worker of a queue starts and stops fetching
+	EventWorkerStart EventType = "worker_start"
+	EventWorkerStop  EventType = "worker_stop"
+	// EventJobStart is emitted before the handler of every job, then EventJobFinish or EventJobFail once it returns
+	EventJobStart  EventType = "job_start"
+	EventJobFinish EventType = "job_finish"
+	EventJobFail   EventType = "job_fail"
+	// EventRetryScheduled is emitted for every failed job written to the retry set
+	EventRetryScheduled EventType = "retry_scheduled"
+	// EventHeartbeat is emitted after every heartbeat written
+	EventHeartbeat EventType = "heartbeat"
+)
+
+// Event is something a manager did, see Subscribe
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Queue of the event, empty for heartbeats
+	Queue string
+	// Job of the job and retry events
+	Message *Msg
+	// Error of fetch errors and failed jobs
+	Err error
+	// Run time of finished and failed jobs
+	Elapsed time.Duration
+	// Time a scheduled retry is due
+	RetryAt time.Time
+}
+
+// Subscribe calls fn with every event of type t. Subscribers are called synchronously from the goroutine
+// emitting the event, such as the runner of a job, and must return quickly.
+func (m *Manager) Subscribe(t EventType, fn func(Event)) {
+	m.eventsLock.Lock()
+	defer m.eventsLock.Unlock()
+	if m.subscribers == nil {
+		m.subscribers = map[EventType][]func(Event){}
+	}
+	m.subscribers[t] = append(m.subscribers[t], fn)
+}
+
+// hasSubscribers tells whether events of type t have subscribers, so emitting them can be skipped
+func (m *Manager) hasSubscribers(t EventType) bool {
+	m.eventsLock.RLock()
+	defer m.eventsLock.RUnlock()
+	return len(m.subscribers[t]) > 0
+}
+
+func (m *Manager) emit(event Event) {
+	m.eventsLock.RLock()
+	subscribers := m.subscribers[event.Type]
+	m.eventsLock.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// eventsJobFunc emits the start and outcome of every job the middlewares let run, panics included
+func eventsJobFunc(mgr *Manager, queue string, next JobFunc) JobFunc {
+	return func(message *Msg) (err error) {
+		if !mgr.hasSubscribers(EventJobStart) && !mgr.hasSubscribers(EventJobFinish) && !mgr.hasSubscribers(EventJobFail) {
+			return next(message)
+		}
+
+		start := time.Now()
+		mgr.emit(Event{Type: EventJobStart, Time: start, Queue: queue, Message: message})
+		defer func() {
+			event := Event{Type: EventJobFinish, Queue: queue, Message: message, Elapsed: time.Since(start)}
+			e := recover()
+			if e != nil {
+				err = fmt.Errorf("%v", e)
+			}
+			if err != nil {
+				event.Type, event.Err = EventJobFail, err
+			}
+			mgr.emit(event)
+			if e != nil {
+				panic(e)
+			}
+		}()
+		return next(message)
+	}
+}
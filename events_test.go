@@ -0,0 +1,117 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_SubscribeJobEvents(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	var events []Event
+	record := func(e Event) { events = append(events, e) }
+	for _, eventType := range []EventType{EventJobStart, EventJobFinish, EventJobFail, EventRetryScheduled} {
+		mgr.Subscribe(eventType, record)
+	}
+
+	cause := errors.New("failed")
+	job := mgr.buildJob("events", func(m *Msg) error {
+		switch m.Jid() {
+		case "failed":
+			return cause
+		case "panicked":
+			panic("boom")
+		}
+		return nil
+	}, nil)
+
+	message, _ := NewMsg(`{"jid":"done","class":"Any"}`)
+	assert.NoError(t, job(message))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, EventJobStart, events[0].Type)
+		assert.Equal(t, "events", events[0].Queue)
+		assert.Equal(t, message, events[0].Message)
+		assert.Equal(t, EventJobFinish, events[1].Type)
+		assert.NoError(t, events[1].Err)
+	}
+
+	events = nil
+	message, _ = NewMsg(`{"jid":"failed","class":"Any","retry":true}`)
+	job(message)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, EventJobFail, events[1].Type)
+		assert.Equal(t, cause, events[1].Err)
+		assert.Equal(t, EventRetryScheduled, events[2].Type)
+		assert.Equal(t, "events", events[2].Queue)
+		assert.True(t, events[2].RetryAt.After(time.Now()))
+	}
+
+	events = nil
+	message, _ = NewMsg(`{"jid":"panicked","class":"Any"}`)
+	job(message)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, EventJobFail, events[1].Type)
+		assert.EqualError(t, events[1].Err, "boom")
+	}
+}
+
+func TestManager_SubscribeFetchErrors(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	var events []Event
+	mgr.Subscribe(EventFetchError, func(e Event) { events = append(events, e) })
+
+	cause := errors.New("connection refused")
+	mgr.reportError("events", PhaseFetch, cause)
+	mgr.reportError("events", PhaseAcknowledge, cause)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "events", events[0].Queue)
+		assert.Equal(t, cause, events[0].Err)
+		assert.False(t, events[0].Time.IsZero())
+	}
+}
+
+func TestManager_SubscribeWorkerEvents(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("worker_events", 1, func(m *Msg) error { return nil })
+
+	var lock sync.Mutex
+	var events []EventType
+	record := func(e Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "worker_events", e.Queue)
+		events = append(events, e.Type)
+	}
+	mgr.Subscribe(EventWorkerStart, record)
+	mgr.Subscribe(EventWorkerStop, record)
+
+	stopped := make(chan bool)
+	go func() {
+		mgr.Run(context.Background())
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(events) == 1
+	}, time.Second, time.Millisecond)
+	mgr.Stop()
+	<-stopped
+
+	assert.Equal(t, []EventType{EventWorkerStart, EventWorkerStop}, events)
+}
@@ -0,0 +1,77 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Categories of job failures counted in stats
+const (
+	FailureTimeout      = "timeout"
+	FailurePanic        = "panic"
+	FailureNonRetryable = "non_retryable"
+	FailureDependency   = "dependency"
+	FailureError        = "error"
+)
+
+// ErrNonRetryable is matched by the errors wrapped by NonRetryable
+var ErrNonRetryable = errors.New("non-retryable")
+
+type nonRetryableError struct {
+	err error
+}
+
+func (e nonRetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e nonRetryableError) Unwrap() error {
+	return e.err
+}
+
+func (e nonRetryableError) Is(target error) bool {
+	return target == ErrNonRetryable
+}
+
+// NonRetryable marks the error of a job which would fail again, such as for invalid args: the job isn't
+// retried, and the failure is counted as non_retryable
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return nonRetryableError{err: err}
+}
+
+// FailureClassifierFunc returns the category of a job failure, such as FailureDependency for the errors of
+// a downstream service, or an empty category to leave it to the built-in classification
+type FailureClassifierFunc func(message *Msg, err error) string
+
+// classifyFailure returns the category of the failure of a job: the one of Options.FailureClassifier,
+// else timeout, panic, non_retryable or error
+func (m *Manager) classifyFailure(message *Msg, err error, panicked bool) string {
+	if m.opts.FailureClassifier != nil {
+		if category := m.opts.FailureClassifier(message, err); category != "" {
+			return category
+		}
+	}
+	switch {
+	case errors.Is(err, ErrJobTimeout), errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	case panicked:
+		return FailurePanic
+	case errors.Is(err, ErrNonRetryable):
+		return FailureNonRetryable
+	}
+	return FailureError
+}
+
+// recordFailure counts the failure of a job in stats and keeps it in the sample of recent failures
+func (m *Manager) recordFailure(queue string, message *Msg, err error, panicked bool) {
+	incrementStats(m, "failed")
+	category := m.classifyFailure(message, err, panicked)
+	if err := m.opts.store.IncrementFailureCategory(context.Background(), category); err != nil {
+		m.logger.Println("couldn't save stats:", err)
+	}
+	m.failures.record(queue, message, err, time.Now())
+}
@@ -0,0 +1,73 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var errUpstream = errors.New("upstream unavailable")
+
+func TestFailureCategoryStats(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.FailureClassifier = func(message *Msg, err error) string {
+		if errors.Is(err, errUpstream) {
+			return FailureDependency
+		}
+		return ""
+	}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	failures := []func() error{
+		func() error { return fmt.Errorf("%w after 1s: context deadline exceeded", ErrJobTimeout) },
+		func() error { panic("nil map") },
+		func() error { return NonRetryable(errors.New("invalid args")) },
+		func() error { return fmt.Errorf("charging: %w", errUpstream) },
+		func() error { return fmt.Errorf("charging: %w", errUpstream) },
+		func() error { return errors.New("index out of range") },
+	}
+	for _, fail := range failures {
+		message, _ := NewMsg(`{"jid":"1","class":"Charge"}`)
+		NewMiddlewares(StatsMiddleware).build("prod:billing", mgr, func(m *Msg) error {
+			return fail()
+		})(message)
+	}
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), stats.Failed)
+	assert.Equal(t, map[string]int64{
+		FailureTimeout:      1,
+		FailurePanic:        1,
+		FailureNonRetryable: 1,
+		FailureDependency:   2,
+		FailureError:        1,
+	}, stats.FailureCategories)
+}
+
+func TestRetryMiddlewareSkipsNonRetryable(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	cause := errors.New("invalid args")
+	message, _ := NewMsg(`{"jid":"1","class":"Charge","retry":true}`)
+	err = NewMiddlewares(RetryMiddleware).build("prod:billing", mgr, func(m *Msg) error {
+		return NonRetryable(cause)
+	})(message)
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(err, ErrNonRetryable))
+	assert.Equal(t, "invalid args", err.Error())
+
+	retries, _ := opts.client.ZCard(ctx, "prod:"+storage.RetryKey).Result()
+	assert.Equal(t, int64(0), retries)
+	assert.Nil(t, NonRetryable(nil))
+}
@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureSampleSize     = 10
+	defaultFailureSampleHalfLife = 10 * time.Minute
+
+	// maxFailureMessageLength truncates the error messages kept in the sample
+	maxFailureMessageLength = 512
+)
+
+// FailureSampleOptions configures the sample of recent failures a manager keeps per job class
+type FailureSampleOptions struct {
+	// Optional number of failures kept per class, defaults to 10
+	Size int
+
+	// Optional time after which a failure is half as likely to stay in the sample as a new one,
+	// defaults to 10 minutes
+	HalfLife time.Duration
+}
+
+// RecentFailure is a failure kept in the sample of recent failures
+type RecentFailure struct {
+	Jid      string    `json:"jid"`
+	Queue    string    `json:"queue"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+type sampledFailure struct {
+	RecentFailure
+	// logarithm of the forward-decay priority of the failure
+	priority float64
+}
+
+// failureSampler keeps a bounded, exponentially decaying sample of the failures of every class:
+// each failure gets a random priority weighted by how recent it is, and the highest priorities
+// are kept, so the sample favors recent failures without forgetting a burst from a while ago.
+type failureSampler struct {
+	size   int
+	lambda float64
+
+	lock    sync.Mutex
+	classes map[string][]sampledFailure
+}
+
+func newFailureSampler(opts *FailureSampleOptions) *failureSampler {
+	size, halfLife := defaultFailureSampleSize, defaultFailureSampleHalfLife
+	if opts != nil {
+		if opts.Size > 0 {
+			size = opts.Size
+		}
+		if opts.HalfLife > 0 {
+			halfLife = opts.HalfLife
+		}
+	}
+	return &failureSampler{
+		size:    size,
+		lambda:  math.Ln2 / halfLife.Seconds(),
+		classes: map[string][]sampledFailure{},
+	}
+}
+
+func (s *failureSampler) record(queue string, message *Msg, err error, at time.Time) {
+	if s == nil {
+		return
+	}
+
+	text := err.Error()
+	if len(text) > maxFailureMessageLength {
+		text = text[:maxFailureMessageLength]
+	}
+	failure := sampledFailure{
+		RecentFailure: RecentFailure{Jid: message.Jid(), Queue: queue, Error: text, FailedAt: at},
+		priority:      s.lambda*float64(at.UnixNano())/1e9 - math.Log(1-rand.Float64()),
+	}
+
+	class := message.Class()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sample := s.classes[class]
+	if len(sample) < s.size {
+		s.classes[class] = append(sample, failure)
+		return
+	}
+	lowest := 0
+	for i := range sample {
+		if sample[i].priority < sample[lowest].priority {
+			lowest = i
+		}
+	}
+	if failure.priority > sample[lowest].priority {
+		sample[lowest] = failure
+	}
+}
+
+// snapshot returns the sampled failures of every class, most recent first
+func (s *failureSampler) snapshot() map[string][]RecentFailure {
+	res := map[string][]RecentFailure{}
+	if s == nil {
+		return res
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for class, sample := range s.classes {
+		failures := make([]RecentFailure, len(sample))
+		for i := range sample {
+			failures[i] = sample[i].RecentFailure
+		}
+		sort.Slice(failures, func(i, j int) bool {
+			return failures[i].FailedAt.After(failures[j].FailedAt)
+		})
+		res[class] = failures
+	}
+	return res
+}
+
+// RecentFailures returns a sample of the recent failures of every job class, most recent first
+func (m *Manager) RecentFailures() map[string][]RecentFailure {
+	return m.failures.snapshot()
+}
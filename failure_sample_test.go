@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureSampler(t *testing.T) {
+	s := newFailureSampler(&FailureSampleOptions{Size: 5, HalfLife: time.Minute})
+	start := time.Now()
+
+	// a burst of old failures, then a few failures an hour later
+	for i := 0; i < 100; i++ {
+		msg, _ := NewMsg(fmt.Sprintf(`{"jid":"old%d","class":"Charge"}`, i))
+		s.record("billing", msg, errors.New("card declined"), start.Add(time.Duration(i)*time.Millisecond))
+	}
+	for i := 0; i < 5; i++ {
+		msg, _ := NewMsg(fmt.Sprintf(`{"jid":"new%d","class":"Charge"}`, i))
+		s.record("billing", msg, errors.New("gateway timeout"), start.Add(time.Hour+time.Duration(i)*time.Second))
+	}
+	msg, _ := NewMsg(`{"jid":"other","class":"Notify"}`)
+	s.record("mail", msg, errors.New(strings.Repeat("x", 1000)), start)
+
+	failures := s.snapshot()
+	assert.Len(t, failures, 2)
+	assert.Len(t, failures["Charge"], 5)
+	assert.Equal(t, "new4", failures["Charge"][0].Jid)
+	for _, f := range failures["Charge"] {
+		assert.Equal(t, "gateway timeout", f.Error)
+		assert.Equal(t, "billing", f.Queue)
+	}
+	assert.Len(t, failures["Notify"][0].Error, maxFailureMessageLength)
+}
+
+func TestStatsMiddleware_RecentFailures(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	message, _ := NewMsg(`{"jid":"2","class":"Charge","retry":true}`)
+	NewMiddlewares(StatsMiddleware).build("billing", mgr, func(m *Msg) error {
+		return errors.New("card declined")
+	})(message)
+	NewMiddlewares(StatsMiddleware).build("billing", mgr, func(m *Msg) error {
+		panic("nil gateway")
+	})(message)
+
+	failures := mgr.RecentFailures()["Charge"]
+	assert.Len(t, failures, 2)
+	assert.ElementsMatch(t, []string{"card declined", "nil gateway"}, []string{failures[0].Error, failures[1].Error})
+	assert.Equal(t, "2", failures[0].Jid)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Len(t, stats.RecentFailures["Charge"], 2)
+}
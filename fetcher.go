@@ -11,6 +11,13 @@ import (
 	"github.com/digitalocean/go-workers2/storage"
 )
 
+const (
+	// delayedPromotionInterval bounds how often a fetcher moves the due delayed messages of its queues
+	delayedPromotionInterval = 100 * time.Millisecond
+	// delayedPromotionBatch is how many due delayed messages of a queue are moved at once
+	delayedPromotionBatch = 100
+)
+
 // Fetcher is an interface for managing work messages
 type Fetcher interface {
 	Queue() string
@@ -25,12 +32,22 @@ type Fetcher interface {
 	Closed() bool
 }
 
+// fetchErrorFunc receives the errors of a fetcher with the queue and phase they happened in
+type fetchErrorFunc func(queue, phase string, err error)
+
 type simpleFetcher struct {
 	store     storage.Store
 	processID string
 	queue     string
 	lock      sync.Mutex
 	isActive  bool
+	// last time Redis answered a fetch, with a message or without
+	lastFetch time.Time
+	onError   fetchErrorFunc
+	// acknowledgements Redis refused, nil unless the manager has a WriteBuffer
+	writes *writeBuffer
+	// last time the due delayed messages were moved to the queue
+	lastPromotion time.Time
 
 	ready    chan bool
 	messages chan *Msg
@@ -70,6 +87,10 @@ func (f *simpleFetcher) processOldMessages() {
 	messages := f.inprogressMessages()
 
 	for _, message := range messages {
+		if f.writes.has(f.InProgressQueue(), message) {
+			// already done, waiting for Redis to take its acknowledgement
+			continue
+		}
 		<-f.Ready()
 		f.sendMessage(message)
 	}
@@ -112,18 +133,39 @@ func (f *simpleFetcher) Fetch() {
 }
 
 func (f *simpleFetcher) tryFetchMessage() {
+	f.promoteDelayedMessages(f.queue)
 	message, err := f.store.DequeueMessage(context.Background(), f.queue, f.InProgressQueue(), 1*time.Second)
+	if err == nil || err == storage.NoMessage {
+		f.recordFetch()
+	}
 	if err != nil {
 		// If redis returns null, the queue is empty.
 		// Just ignore empty queue errors; print all other errors.
 		if err != storage.NoMessage {
 			f.logger.Println("ERR: ", f.queue, err)
+			f.reportError(f.queue, PhaseFetch, err)
 		}
 	} else {
 		f.sendMessage(message)
 	}
 }
 
+// promoteDelayedMessages moves the due delayed messages of queues ahead of their other messages, at most
+// every delayedPromotionInterval
+func (f *simpleFetcher) promoteDelayedMessages(queues ...string) {
+	if time.Since(f.lastPromotion) < delayedPromotionInterval {
+		return
+	}
+	f.lastPromotion = time.Now()
+	now := timeToSecondsWithNanoPrecision(f.lastPromotion)
+	for _, queue := range queues {
+		if _, err := f.store.PromoteDelayedMessages(context.Background(), queue, now, delayedPromotionBatch); err != nil {
+			f.logger.Println("ERR: couldn't move the due delayed jobs of", queue, ":", err)
+			f.reportError(queue, PhaseFetch, err)
+		}
+	}
+}
+
 func (f *simpleFetcher) sendMessage(message string) {
 	msg, err := NewMsg(message)
 
@@ -136,7 +178,37 @@ func (f *simpleFetcher) sendMessage(message string) {
 }
 
 func (f *simpleFetcher) Acknowledge(message *Msg) {
-	f.store.AcknowledgeMessage(context.Background(), f.InProgressQueue(), message.OriginalJson())
+	f.acknowledge(f.queue, f.InProgressQueue(), message)
+}
+
+// acknowledge removes message from inProgressQueue, after writing its pending retry. Writes Redis
+// refuses are buffered when the fetcher has a write buffer.
+func (f *simpleFetcher) acknowledge(queue, inProgressQueue string, message *Msg) {
+	write := &bufferedWrite{
+		inProgressQueue: inProgressQueue,
+		message:         message.OriginalJson(),
+		retryAt:         message.pendingRetryAt,
+		retry:           message.pendingRetry,
+	}
+	err := write.apply(context.Background(), f.store)
+	if err == nil {
+		return
+	}
+	f.logger.Println("ERR: couldn't acknowledge", message.Jid(), "of", queue, ":", err)
+	f.reportError(queue, PhaseAcknowledge, err)
+	if f.writes == nil {
+		return
+	}
+	if !f.writes.add(write) {
+		f.logger.Println("ERR: couldn't buffer the acknowledgement of", message.Jid(), "of", queue, ", it stays in progress")
+		f.reportError(queue, PhaseAcknowledge, ErrWriteBufferFull)
+	}
+}
+
+func (f *simpleFetcher) reportError(queue, phase string, err error) {
+	if f.onError != nil {
+		f.onError(queue, phase, err)
+	}
 }
 
 func (f *simpleFetcher) Messages() chan *Msg {
@@ -155,6 +227,19 @@ func (f *simpleFetcher) IsActive() bool {
 	return f.isActive
 }
 
+func (f *simpleFetcher) recordFetch() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.lastFetch = time.Now()
+}
+
+// lastFetched returns the last time Redis answered a fetch, zero before the first one
+func (f *simpleFetcher) lastFetched() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.lastFetch
+}
+
 func (f *simpleFetcher) Ready() chan bool {
 	return f.ready
 }
@@ -0,0 +1,203 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// weightedQueue is a queue fetched by a worker of several queues
+type weightedQueue struct {
+	name   string
+	weight int
+}
+
+// sortWeightedQueues orders queues by descending weight, then name
+func sortWeightedQueues(queues map[string]int) []weightedQueue {
+	var res []weightedQueue
+	for name, weight := range queues {
+		if weight <= 0 {
+			weight = 1
+		}
+		res = append(res, weightedQueue{name: name, weight: weight})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].weight != res[j].weight {
+			return res[i].weight > res[j].weight
+		}
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+// poolName is the name a worker of several queues is known by, such as in SetConcurrency
+func poolName(queues []weightedQueue) string {
+	names := make([]string, len(queues))
+	for i, q := range queues {
+		names[i] = q.name
+	}
+	return strings.Join(names, ",")
+}
+
+// multiQueueFetcher fetches the messages of several queues, each with its own in-progress queue.
+// Strict fetchers always try the queues by descending weight, the others in a random order
+// favoring the heavier queues.
+type multiQueueFetcher struct {
+	*simpleFetcher
+	queues []weightedQueue
+	strict bool
+}
+
+var _ Fetcher = &multiQueueFetcher{}
+
+func newMultiQueueFetcher(queues []weightedQueue, strict bool, opts Options, isActive bool) *multiQueueFetcher {
+	return &multiQueueFetcher{
+		simpleFetcher: newSimpleFetcher(poolName(queues), opts, isActive),
+		queues:        queues,
+		strict:        strict,
+	}
+}
+
+func (f *multiQueueFetcher) Fetch() {
+	for !f.IsActive() {
+		select {
+		case <-f.stop:
+			close(f.closed)
+			close(f.exit)
+			return
+		}
+	}
+	f.processOldMessages()
+
+	go func() {
+		for {
+			// f.Close() has been called
+			if f.Closed() {
+				break
+			}
+			<-f.Ready()
+			if f.IsActive() {
+				f.tryFetchMessage()
+			}
+		}
+	}()
+
+	<-f.stop
+	// Stop the redis-polling goroutine
+	close(f.closed)
+	// Signal to Close() that the fetcher has stopped
+	close(f.exit)
+}
+
+func (f *multiQueueFetcher) processOldMessages() {
+	for _, q := range f.queues {
+		messages, err := f.store.ListMessages(context.Background(), f.inProgressQueueOf(q.name))
+		if err != nil {
+			f.logger.Println("ERR: ", err)
+		}
+		for _, message := range messages {
+			if f.writes.has(f.inProgressQueueOf(q.name), message) {
+				continue
+			}
+			<-f.Ready()
+			f.sendMessage(q.name, message)
+		}
+	}
+}
+
+// tryFetchMessage takes the first message of the queues in fetch order, waiting on the first queue
+// when all of them are empty
+func (f *multiQueueFetcher) tryFetchMessage() {
+	ctx := context.Background()
+	order := f.fetchOrder()
+	f.promoteDelayedMessages(order...)
+	for _, queue := range order {
+		message, err := f.store.DequeueMessageNow(ctx, queue, f.inProgressQueueOf(queue))
+		if err == nil {
+			f.recordFetch()
+			f.sendMessage(queue, message)
+			return
+		}
+		if err != storage.NoMessage {
+			f.logger.Println("ERR: ", queue, err)
+			f.reportError(queue, PhaseFetch, err)
+		}
+	}
+
+	message, err := f.store.DequeueMessage(ctx, order[0], f.inProgressQueueOf(order[0]), 1*time.Second)
+	if err == nil || err == storage.NoMessage {
+		f.recordFetch()
+	} else {
+		f.reportError(order[0], PhaseFetch, err)
+	}
+	if err == nil {
+		f.sendMessage(order[0], message)
+	}
+}
+
+// fetchOrder returns the queues in the order they're tried by the next fetch
+func (f *multiQueueFetcher) fetchOrder() []string {
+	order := make([]string, 0, len(f.queues))
+	if f.strict {
+		for _, q := range f.queues {
+			order = append(order, q.name)
+		}
+		return order
+	}
+
+	remaining := append([]weightedQueue(nil), f.queues...)
+	for len(remaining) > 0 {
+		total := 0
+		for _, q := range remaining {
+			total += q.weight
+		}
+		pick := rand.Intn(total)
+		for i, q := range remaining {
+			if pick < q.weight {
+				order = append(order, q.name)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			pick -= q.weight
+		}
+	}
+	return order
+}
+
+func (f *multiQueueFetcher) sendMessage(queue, message string) {
+	msg, err := NewMsg(message)
+	if err != nil {
+		f.logger.Println("ERR: Couldn't create message from", message, ":", err)
+		return
+	}
+	msg.fetchedFrom = queue
+
+	f.Messages() <- msg
+}
+
+func (f *multiQueueFetcher) Acknowledge(message *Msg) {
+	f.acknowledge(message.fetchedFrom, f.inProgressQueueOf(message.fetchedFrom), message)
+}
+
+// InProgressQueue returns the in-progress queue of the heaviest queue, see inProgressQueues for the others
+func (f *multiQueueFetcher) InProgressQueue() string {
+	return f.inProgressQueueOf(f.queues[0].name)
+}
+
+// inProgressQueues returns the in-progress queue of every fetched queue
+func (f *multiQueueFetcher) inProgressQueues() map[string]string {
+	res := map[string]string{}
+	for _, q := range f.queues {
+		res[q.name] = f.inProgressQueueOf(q.name)
+	}
+	return res
+}
+
+func (f *multiQueueFetcher) inProgressQueueOf(queue string) string {
+	return fmt.Sprint(queue, ":", f.processID, ":inprogress")
+}
@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortWeightedQueues(t *testing.T) {
+	queues := sortWeightedQueues(map[string]int{"low": 1, "critical": 5, "default": 3, "bulk": 0})
+	assert.Equal(t, []weightedQueue{{"critical", 5}, {"default", 3}, {"bulk", 1}, {"low", 1}}, queues)
+	assert.Equal(t, "critical,default,bulk,low", poolName(queues))
+}
+
+func TestMultiQueueFetcherOrder(t *testing.T) {
+	opts, err := SetupDefaultTestOptions()
+	assert.NoError(t, err)
+	queues := sortWeightedQueues(map[string]int{"critical": 3, "low": 1})
+
+	strict := newMultiQueueFetcher(queues, true, opts, true)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, []string{"critical", "low"}, strict.fetchOrder())
+	}
+
+	weighted := newMultiQueueFetcher(queues, false, opts, true)
+	first := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		order := weighted.fetchOrder()
+		assert.ElementsMatch(t, []string{"critical", "low"}, order)
+		first[order[0]]++
+	}
+	// critical comes first about three times as often as low
+	assert.InDelta(t, 3000, first["critical"], 200)
+}
+
+func TestMultiQueueFetcherFetch(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptions()
+	assert.NoError(t, err)
+	rc := opts.client
+
+	fetch := newMultiQueueFetcher(sortWeightedQueues(map[string]int{"critical": 5, "low": 1}), true, opts, true)
+	go fetch.Fetch()
+	defer fetch.Close()
+
+	rc.LPush(ctx, "queue:low", `{"jid":"1"}`)
+	rc.LPush(ctx, "queue:critical", `{"jid":"2"}`)
+
+	fetch.Ready() <- true
+	msg := <-fetch.Messages()
+	assert.Equal(t, "2", msg.Jid())
+	assert.Equal(t, "critical", msg.fetchedFrom)
+
+	fetch.Ready() <- true
+	msg = <-fetch.Messages()
+	assert.Equal(t, "1", msg.Jid())
+	assert.Equal(t, "low", msg.fetchedFrom)
+
+	inProgress, err := rc.LRange(ctx, "queue:low:1:inprogress", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"jid":"1"}`}, inProgress)
+
+	fetch.Acknowledge(msg)
+	length, err := rc.LLen(ctx, "queue:low:1:inprogress").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+	length, err = rc.LLen(ctx, "queue:critical:1:inprogress").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+}
@@ -3,6 +3,7 @@ package workers
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -162,3 +163,35 @@ func TestRetryInprogressMessages(t *testing.T) {
 
 	fetch.Close()
 }
+
+func TestFetchDelayedMessagesOnceDue(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptions()
+	assert.NoError(t, err)
+	p := newProducer(opts)
+
+	now := nowToSecondsWithNanoPrecision()
+	later, err := p.EnqueueWithOptions("fetchDelayed", "Later", nil, EnqueueOptions{NotBefore: now + 0.3})
+	assert.NoError(t, err)
+	sooner, err := p.EnqueueWithOptions("fetchDelayed", "Sooner", nil, EnqueueOptions{NotBefore: now + 0.2})
+	assert.NoError(t, err)
+	length, err := opts.store.QueueLength(ctx, "fetchDelayed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+
+	_, err = p.EnqueueWithOptions("fetchDelayed", "Now", nil, EnqueueOptions{})
+	assert.NoError(t, err)
+
+	fetch := buildFetch("fetchDelayed", opts)
+	defer fetch.Close()
+	fetch.Ready() <- true
+	assert.Equal(t, "Now", (<-fetch.Messages()).Class())
+
+	time.Sleep(400 * time.Millisecond)
+	// due delayed jobs are fetched by not-before time
+	for _, jid := range []string{sooner, later} {
+		fetch.Ready() <- true
+		assert.Equal(t, jid, (<-fetch.Messages()).Jid())
+	}
+}
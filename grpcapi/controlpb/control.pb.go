@@ -0,0 +1,795 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListManagersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListManagersRequest) Reset() {
+	*x = ListManagersRequest{}
+	mi := &file_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListManagersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListManagersRequest) ProtoMessage() {}
+
+func (x *ListManagersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListManagersRequest.ProtoReflect.Descriptor instead.
+func (*ListManagersRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{0}
+}
+
+type ListManagersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Managers      []*ControlState        `protobuf:"bytes,1,rep,name=managers,proto3" json:"managers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListManagersResponse) Reset() {
+	*x = ListManagersResponse{}
+	mi := &file_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListManagersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListManagersResponse) ProtoMessage() {}
+
+func (x *ListManagersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListManagersResponse.ProtoReflect.Descriptor instead.
+func (*ListManagersResponse) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListManagersResponse) GetManagers() []*ControlState {
+	if x != nil {
+		return x.Managers
+	}
+	return nil
+}
+
+// ManagerRequest names the manager acted on by its process ID. It can be left empty when the server has
+// a single manager.
+type ManagerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Manager       string                 `protobuf:"bytes,1,opt,name=manager,proto3" json:"manager,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ManagerRequest) Reset() {
+	*x = ManagerRequest{}
+	mi := &file_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ManagerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ManagerRequest) ProtoMessage() {}
+
+func (x *ManagerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ManagerRequest.ProtoReflect.Descriptor instead.
+func (*ManagerRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ManagerRequest) GetManager() string {
+	if x != nil {
+		return x.Manager
+	}
+	return ""
+}
+
+type SetConcurrencyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Manager       string                 `protobuf:"bytes,1,opt,name=manager,proto3" json:"manager,omitempty"`
+	Queue         string                 `protobuf:"bytes,2,opt,name=queue,proto3" json:"queue,omitempty"`
+	Concurrency   int32                  `protobuf:"varint,3,opt,name=concurrency,proto3" json:"concurrency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetConcurrencyRequest) Reset() {
+	*x = SetConcurrencyRequest{}
+	mi := &file_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetConcurrencyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetConcurrencyRequest) ProtoMessage() {}
+
+func (x *SetConcurrencyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetConcurrencyRequest.ProtoReflect.Descriptor instead.
+func (*SetConcurrencyRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SetConcurrencyRequest) GetManager() string {
+	if x != nil {
+		return x.Manager
+	}
+	return ""
+}
+
+func (x *SetConcurrencyRequest) GetQueue() string {
+	if x != nil {
+		return x.Queue
+	}
+	return ""
+}
+
+func (x *SetConcurrencyRequest) GetConcurrency() int32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Manager string                 `protobuf:"bytes,1,opt,name=manager,proto3" json:"manager,omitempty"`
+	// Milliseconds between statuses, defaults to a second
+	IntervalMs    int64 `protobuf:"varint,2,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WatchRequest) GetManager() string {
+	if x != nil {
+		return x.Manager
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetIntervalMs() int64 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+type ControlState struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Manager string                 `protobuf:"bytes,1,opt,name=manager,proto3" json:"manager,omitempty"`
+	Name    string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Active  bool                   `protobuf:"varint,3,opt,name=active,proto3" json:"active,omitempty"`
+	Paused  bool                   `protobuf:"varint,4,opt,name=paused,proto3" json:"paused,omitempty"`
+	Quiet   bool                   `protobuf:"varint,5,opt,name=quiet,proto3" json:"quiet,omitempty"`
+	// Concurrency of every queue
+	Concurrency   map[string]int32 `protobuf:"bytes,6,rep,name=concurrency,proto3" json:"concurrency,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ControlState) Reset() {
+	*x = ControlState{}
+	mi := &file_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ControlState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ControlState) ProtoMessage() {}
+
+func (x *ControlState) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ControlState.ProtoReflect.Descriptor instead.
+func (*ControlState) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ControlState) GetManager() string {
+	if x != nil {
+		return x.Manager
+	}
+	return ""
+}
+
+func (x *ControlState) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ControlState) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *ControlState) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *ControlState) GetQuiet() bool {
+	if x != nil {
+		return x.Quiet
+	}
+	return false
+}
+
+func (x *ControlState) GetConcurrency() map[string]int32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return nil
+}
+
+type Stats struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Manager    string                 `protobuf:"bytes,1,opt,name=manager,proto3" json:"manager,omitempty"`
+	Name       string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Processed  int64                  `protobuf:"varint,3,opt,name=processed,proto3" json:"processed,omitempty"`
+	Failed     int64                  `protobuf:"varint,4,opt,name=failed,proto3" json:"failed,omitempty"`
+	Duplicates int64                  `protobuf:"varint,5,opt,name=duplicates,proto3" json:"duplicates,omitempty"`
+	RetryCount int64                  `protobuf:"varint,6,opt,name=retry_count,json=retryCount,proto3" json:"retry_count,omitempty"`
+	Enqueued   map[string]int64       `protobuf:"bytes,7,rep,name=enqueued,proto3" json:"enqueued,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Failures by category, such as timeout or panic
+	FailureCategories map[string]int64 `protobuf:"bytes,8,rep,name=failure_categories,json=failureCategories,proto3" json:"failure_categories,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Stats) GetManager() string {
+	if x != nil {
+		return x.Manager
+	}
+	return ""
+}
+
+func (x *Stats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Stats) GetProcessed() int64 {
+	if x != nil {
+		return x.Processed
+	}
+	return 0
+}
+
+func (x *Stats) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *Stats) GetDuplicates() int64 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+func (x *Stats) GetRetryCount() int64 {
+	if x != nil {
+		return x.RetryCount
+	}
+	return 0
+}
+
+func (x *Stats) GetEnqueued() map[string]int64 {
+	if x != nil {
+		return x.Enqueued
+	}
+	return nil
+}
+
+func (x *Stats) GetFailureCategories() map[string]int64 {
+	if x != nil {
+		return x.FailureCategories
+	}
+	return nil
+}
+
+type InFlightJob struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Queue         string                 `protobuf:"bytes,1,opt,name=queue,proto3" json:"queue,omitempty"`
+	Class         string                 `protobuf:"bytes,2,opt,name=class,proto3" json:"class,omitempty"`
+	Jid           string                 `protobuf:"bytes,3,opt,name=jid,proto3" json:"jid,omitempty"`
+	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	Slot          int32                  `protobuf:"varint,5,opt,name=slot,proto3" json:"slot,omitempty"`
+	Tid           string                 `protobuf:"bytes,6,opt,name=tid,proto3" json:"tid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InFlightJob) Reset() {
+	*x = InFlightJob{}
+	mi := &file_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InFlightJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InFlightJob) ProtoMessage() {}
+
+func (x *InFlightJob) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InFlightJob.ProtoReflect.Descriptor instead.
+func (*InFlightJob) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *InFlightJob) GetQueue() string {
+	if x != nil {
+		return x.Queue
+	}
+	return ""
+}
+
+func (x *InFlightJob) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *InFlightJob) GetJid() string {
+	if x != nil {
+		return x.Jid
+	}
+	return ""
+}
+
+func (x *InFlightJob) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *InFlightJob) GetSlot() int32 {
+	if x != nil {
+		return x.Slot
+	}
+	return 0
+}
+
+func (x *InFlightJob) GetTid() string {
+	if x != nil {
+		return x.Tid
+	}
+	return ""
+}
+
+type ListInFlightResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*InFlightJob         `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListInFlightResponse) Reset() {
+	*x = ListInFlightResponse{}
+	mi := &file_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListInFlightResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListInFlightResponse) ProtoMessage() {}
+
+func (x *ListInFlightResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListInFlightResponse.ProtoReflect.Descriptor instead.
+func (*ListInFlightResponse) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListInFlightResponse) GetJobs() []*InFlightJob {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type Status struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         *ControlState          `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Stats         *Stats                 `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	InFlight      []*InFlightJob         `protobuf:"bytes,3,rep,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Status) GetState() *ControlState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *Status) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *Status) GetInFlight() []*InFlightJob {
+	if x != nil {
+		return x.InFlight
+	}
+	return nil
+}
+
+var File_control_proto protoreflect.FileDescriptor
+
+const file_control_proto_rawDesc = "" +
+	"\n" +
+	"\rcontrol.proto\x12\x14goworkers.control.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x15\n" +
+	"\x13ListManagersRequest\"V\n" +
+	"\x14ListManagersResponse\x12>\n" +
+	"\bmanagers\x18\x01 \x03(\v2\".goworkers.control.v1.ControlStateR\bmanagers\"*\n" +
+	"\x0eManagerRequest\x12\x18\n" +
+	"\amanager\x18\x01 \x01(\tR\amanager\"i\n" +
+	"\x15SetConcurrencyRequest\x12\x18\n" +
+	"\amanager\x18\x01 \x01(\tR\amanager\x12\x14\n" +
+	"\x05queue\x18\x02 \x01(\tR\x05queue\x12 \n" +
+	"\vconcurrency\x18\x03 \x01(\x05R\vconcurrency\"I\n" +
+	"\fWatchRequest\x12\x18\n" +
+	"\amanager\x18\x01 \x01(\tR\amanager\x12\x1f\n" +
+	"\vinterval_ms\x18\x02 \x01(\x03R\n" +
+	"intervalMs\"\x99\x02\n" +
+	"\fControlState\x12\x18\n" +
+	"\amanager\x18\x01 \x01(\tR\amanager\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06active\x18\x03 \x01(\bR\x06active\x12\x16\n" +
+	"\x06paused\x18\x04 \x01(\bR\x06paused\x12\x14\n" +
+	"\x05quiet\x18\x05 \x01(\bR\x05quiet\x12U\n" +
+	"\vconcurrency\x18\x06 \x03(\v23.goworkers.control.v1.ControlState.ConcurrencyEntryR\vconcurrency\x1a>\n" +
+	"\x10ConcurrencyEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\"\xd9\x03\n" +
+	"\x05Stats\x12\x18\n" +
+	"\amanager\x18\x01 \x01(\tR\amanager\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1c\n" +
+	"\tprocessed\x18\x03 \x01(\x03R\tprocessed\x12\x16\n" +
+	"\x06failed\x18\x04 \x01(\x03R\x06failed\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\x05 \x01(\x03R\n" +
+	"duplicates\x12\x1f\n" +
+	"\vretry_count\x18\x06 \x01(\x03R\n" +
+	"retryCount\x12E\n" +
+	"\benqueued\x18\a \x03(\v2).goworkers.control.v1.Stats.EnqueuedEntryR\benqueued\x12a\n" +
+	"\x12failure_categories\x18\b \x03(\v22.goworkers.control.v1.Stats.FailureCategoriesEntryR\x11failureCategories\x1a;\n" +
+	"\rEnqueuedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1aD\n" +
+	"\x16FailureCategoriesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xac\x01\n" +
+	"\vInFlightJob\x12\x14\n" +
+	"\x05queue\x18\x01 \x01(\tR\x05queue\x12\x14\n" +
+	"\x05class\x18\x02 \x01(\tR\x05class\x12\x10\n" +
+	"\x03jid\x18\x03 \x01(\tR\x03jid\x129\n" +
+	"\n" +
+	"started_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12\x12\n" +
+	"\x04slot\x18\x05 \x01(\x05R\x04slot\x12\x10\n" +
+	"\x03tid\x18\x06 \x01(\tR\x03tid\"M\n" +
+	"\x14ListInFlightResponse\x125\n" +
+	"\x04jobs\x18\x01 \x03(\v2!.goworkers.control.v1.InFlightJobR\x04jobs\"\xb5\x01\n" +
+	"\x06Status\x128\n" +
+	"\x05state\x18\x01 \x01(\v2\".goworkers.control.v1.ControlStateR\x05state\x121\n" +
+	"\x05stats\x18\x02 \x01(\v2\x1b.goworkers.control.v1.StatsR\x05stats\x12>\n" +
+	"\tin_flight\x18\x03 \x03(\v2!.goworkers.control.v1.InFlightJobR\binFlight2\xa1\x06\n" +
+	"\aControl\x12e\n" +
+	"\fListManagers\x12).goworkers.control.v1.ListManagersRequest\x1a*.goworkers.control.v1.ListManagersResponse\x12T\n" +
+	"\bGetState\x12$.goworkers.control.v1.ManagerRequest\x1a\".goworkers.control.v1.ControlState\x12Q\n" +
+	"\x05Pause\x12$.goworkers.control.v1.ManagerRequest\x1a\".goworkers.control.v1.ControlState\x12R\n" +
+	"\x06Resume\x12$.goworkers.control.v1.ManagerRequest\x1a\".goworkers.control.v1.ControlState\x12Q\n" +
+	"\x05Quiet\x12$.goworkers.control.v1.ManagerRequest\x1a\".goworkers.control.v1.ControlState\x12a\n" +
+	"\x0eSetConcurrency\x12+.goworkers.control.v1.SetConcurrencyRequest\x1a\".goworkers.control.v1.ControlState\x12M\n" +
+	"\bGetStats\x12$.goworkers.control.v1.ManagerRequest\x1a\x1b.goworkers.control.v1.Stats\x12`\n" +
+	"\fListInFlight\x12$.goworkers.control.v1.ManagerRequest\x1a*.goworkers.control.v1.ListInFlightResponse\x12K\n" +
+	"\x05Watch\x12\".goworkers.control.v1.WatchRequest\x1a\x1c.goworkers.control.v1.Status0\x01B7Z5github.com/digitalocean/go-workers2/grpcapi/controlpbb\x06proto3"
+
+var (
+	file_control_proto_rawDescOnce sync.Once
+	file_control_proto_rawDescData []byte
+)
+
+func file_control_proto_rawDescGZIP() []byte {
+	file_control_proto_rawDescOnce.Do(func() {
+		file_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)))
+	})
+	return file_control_proto_rawDescData
+}
+
+var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_control_proto_goTypes = []any{
+	(*ListManagersRequest)(nil),   // 0: goworkers.control.v1.ListManagersRequest
+	(*ListManagersResponse)(nil),  // 1: goworkers.control.v1.ListManagersResponse
+	(*ManagerRequest)(nil),        // 2: goworkers.control.v1.ManagerRequest
+	(*SetConcurrencyRequest)(nil), // 3: goworkers.control.v1.SetConcurrencyRequest
+	(*WatchRequest)(nil),          // 4: goworkers.control.v1.WatchRequest
+	(*ControlState)(nil),          // 5: goworkers.control.v1.ControlState
+	(*Stats)(nil),                 // 6: goworkers.control.v1.Stats
+	(*InFlightJob)(nil),           // 7: goworkers.control.v1.InFlightJob
+	(*ListInFlightResponse)(nil),  // 8: goworkers.control.v1.ListInFlightResponse
+	(*Status)(nil),                // 9: goworkers.control.v1.Status
+	nil,                           // 10: goworkers.control.v1.ControlState.ConcurrencyEntry
+	nil,                           // 11: goworkers.control.v1.Stats.EnqueuedEntry
+	nil,                           // 12: goworkers.control.v1.Stats.FailureCategoriesEntry
+	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
+}
+var file_control_proto_depIdxs = []int32{
+	5,  // 0: goworkers.control.v1.ListManagersResponse.managers:type_name -> goworkers.control.v1.ControlState
+	10, // 1: goworkers.control.v1.ControlState.concurrency:type_name -> goworkers.control.v1.ControlState.ConcurrencyEntry
+	11, // 2: goworkers.control.v1.Stats.enqueued:type_name -> goworkers.control.v1.Stats.EnqueuedEntry
+	12, // 3: goworkers.control.v1.Stats.failure_categories:type_name -> goworkers.control.v1.Stats.FailureCategoriesEntry
+	13, // 4: goworkers.control.v1.InFlightJob.started_at:type_name -> google.protobuf.Timestamp
+	7,  // 5: goworkers.control.v1.ListInFlightResponse.jobs:type_name -> goworkers.control.v1.InFlightJob
+	5,  // 6: goworkers.control.v1.Status.state:type_name -> goworkers.control.v1.ControlState
+	6,  // 7: goworkers.control.v1.Status.stats:type_name -> goworkers.control.v1.Stats
+	7,  // 8: goworkers.control.v1.Status.in_flight:type_name -> goworkers.control.v1.InFlightJob
+	0,  // 9: goworkers.control.v1.Control.ListManagers:input_type -> goworkers.control.v1.ListManagersRequest
+	2,  // 10: goworkers.control.v1.Control.GetState:input_type -> goworkers.control.v1.ManagerRequest
+	2,  // 11: goworkers.control.v1.Control.Pause:input_type -> goworkers.control.v1.ManagerRequest
+	2,  // 12: goworkers.control.v1.Control.Resume:input_type -> goworkers.control.v1.ManagerRequest
+	2,  // 13: goworkers.control.v1.Control.Quiet:input_type -> goworkers.control.v1.ManagerRequest
+	3,  // 14: goworkers.control.v1.Control.SetConcurrency:input_type -> goworkers.control.v1.SetConcurrencyRequest
+	2,  // 15: goworkers.control.v1.Control.GetStats:input_type -> goworkers.control.v1.ManagerRequest
+	2,  // 16: goworkers.control.v1.Control.ListInFlight:input_type -> goworkers.control.v1.ManagerRequest
+	4,  // 17: goworkers.control.v1.Control.Watch:input_type -> goworkers.control.v1.WatchRequest
+	1,  // 18: goworkers.control.v1.Control.ListManagers:output_type -> goworkers.control.v1.ListManagersResponse
+	5,  // 19: goworkers.control.v1.Control.GetState:output_type -> goworkers.control.v1.ControlState
+	5,  // 20: goworkers.control.v1.Control.Pause:output_type -> goworkers.control.v1.ControlState
+	5,  // 21: goworkers.control.v1.Control.Resume:output_type -> goworkers.control.v1.ControlState
+	5,  // 22: goworkers.control.v1.Control.Quiet:output_type -> goworkers.control.v1.ControlState
+	5,  // 23: goworkers.control.v1.Control.SetConcurrency:output_type -> goworkers.control.v1.ControlState
+	6,  // 24: goworkers.control.v1.Control.GetStats:output_type -> goworkers.control.v1.Stats
+	8,  // 25: goworkers.control.v1.Control.ListInFlight:output_type -> goworkers.control.v1.ListInFlightResponse
+	9,  // 26: goworkers.control.v1.Control.Watch:output_type -> goworkers.control.v1.Status
+	18, // [18:27] is the sub-list for method output_type
+	9,  // [9:18] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_control_proto_init() }
+func file_control_proto_init() {
+	if File_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_control_proto_goTypes,
+		DependencyIndexes: file_control_proto_depIdxs,
+		MessageInfos:      file_control_proto_msgTypes,
+	}.Build()
+	File_control_proto = out.File
+	file_control_proto_goTypes = nil
+	file_control_proto_depIdxs = nil
+}
@@ -0,0 +1,291 @@
+// Package grpcapi serves the control of go-workers2 managers over gRPC: pausing, resuming and quieting
+// them, setting the concurrency of their queues, and reading their stats and jobs in flight, once or as a
+// stream. It's a module of its own so that the core module doesn't depend on gRPC.
+//
+//	server := grpcapi.NewServer(grpcapi.Options{})
+//	server.Register(manager)
+//	grpcServer := grpc.NewServer()
+//	controlpb.RegisterControlServer(grpcServer, server)
+//	grpcServer.Serve(listener)
+//
+// Mutating calls go through Manager.Manage, so they're authorized and audited as the HTTP ones.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	workers "github.com/digitalocean/go-workers2"
+	"github.com/digitalocean/go-workers2/grpcapi/controlpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ActorMetadata is the metadata key the server reads the actor of management actions from by default
+const ActorMetadata = "x-go-workers-actor"
+
+const defaultWatchInterval = time.Second
+
+// Options configures a Server
+type Options struct {
+	// Optional actor of the management actions of a call, such as the subject of its client certificate.
+	// Defaults to the ActorMetadata of the call or its peer address, which callers can claim freely.
+	Actor func(ctx context.Context) string
+}
+
+// Server implements controlpb.ControlServer for the managers registered with it, by process ID
+type Server struct {
+	controlpb.UnimplementedControlServer
+
+	lock     sync.Mutex
+	managers map[string]*workers.Manager
+	actor    func(ctx context.Context) string
+}
+
+// NewServer returns a server without managers
+func NewServer(opts Options) *Server {
+	actor := opts.Actor
+	if actor == nil {
+		actor = callActor
+	}
+	return &Server{managers: map[string]*workers.Manager{}, actor: actor}
+}
+
+// Register makes mgr controllable through the server, under its process ID
+func (s *Server) Register(mgr *workers.Manager) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.managers[mgr.Opts().ProcessID] = mgr
+}
+
+// Deregister removes mgr from the server
+func (s *Server) Deregister(mgr *workers.Manager) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.managers, mgr.Opts().ProcessID)
+}
+
+// manager returns the manager with the given process ID, or the only registered manager when id is empty
+func (s *Server) manager(id string) (*workers.Manager, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if id != "" {
+		if mgr, ok := s.managers[id]; ok {
+			return mgr, nil
+		}
+		return nil, status.Errorf(codes.NotFound, "unknown manager %s", id)
+	}
+	if len(s.managers) > 1 {
+		return nil, status.Error(codes.InvalidArgument, "a manager is required when several are registered")
+	}
+	for _, mgr := range s.managers {
+		return mgr, nil
+	}
+	return nil, status.Error(codes.FailedPrecondition, "no manager is registered")
+}
+
+// callActor is the actor of a call when Options.Actor isn't set: its ActorMetadata, or its peer address
+func callActor(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actors := md.Get(ActorMetadata); len(actors) > 0 && actors[0] != "" {
+			return actors[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// manage applies action to the manager named by id once it was authorized, and returns its control state
+func (s *Server) manage(ctx context.Context, id, action, target string, apply func(mgr *workers.Manager) error) (*controlpb.ControlState, error) {
+	mgr, err := s.manager(id)
+	if err != nil {
+		return nil, err
+	}
+	err = mgr.Manage(workers.WithActor(ctx, s.actor(ctx)), action, target, func() error {
+		return apply(mgr)
+	})
+	if err != nil {
+		return nil, manageError(err)
+	}
+	return controlState(mgr), nil
+}
+
+// manageError is the status of a management action failing with err
+func manageError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, workers.ErrManagementDenied) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.NotFound, err.Error())
+}
+
+// ListManagers returns the control state of the registered managers, by process ID
+func (s *Server) ListManagers(ctx context.Context, req *controlpb.ListManagersRequest) (*controlpb.ListManagersResponse, error) {
+	s.lock.Lock()
+	ids := make([]string, 0, len(s.managers))
+	for id := range s.managers {
+		ids = append(ids, id)
+	}
+	managers := make([]*workers.Manager, 0, len(ids))
+	sort.Strings(ids)
+	for _, id := range ids {
+		managers = append(managers, s.managers[id])
+	}
+	s.lock.Unlock()
+
+	res := &controlpb.ListManagersResponse{}
+	for _, mgr := range managers {
+		res.Managers = append(res.Managers, controlState(mgr))
+	}
+	return res, nil
+}
+
+// GetState returns the control state of a manager
+func (s *Server) GetState(ctx context.Context, req *controlpb.ManagerRequest) (*controlpb.ControlState, error) {
+	mgr, err := s.manager(req.GetManager())
+	if err != nil {
+		return nil, err
+	}
+	return controlState(mgr), nil
+}
+
+// Pause stops a manager fetching jobs
+func (s *Server) Pause(ctx context.Context, req *controlpb.ManagerRequest) (*controlpb.ControlState, error) {
+	return s.manage(ctx, req.GetManager(), "pause", "", func(mgr *workers.Manager) error {
+		mgr.Pause()
+		return nil
+	})
+}
+
+// Resume fetches jobs again after Pause
+func (s *Server) Resume(ctx context.Context, req *controlpb.ManagerRequest) (*controlpb.ControlState, error) {
+	return s.manage(ctx, req.GetManager(), "resume", "", func(mgr *workers.Manager) error {
+		mgr.Resume()
+		return nil
+	})
+}
+
+// Quiet stops a manager fetching jobs for good, ahead of stopping it
+func (s *Server) Quiet(ctx context.Context, req *controlpb.ManagerRequest) (*controlpb.ControlState, error) {
+	return s.manage(ctx, req.GetManager(), "quiet", "", func(mgr *workers.Manager) error {
+		mgr.Quiet()
+		return nil
+	})
+}
+
+// SetConcurrency sets the number of runners of a queue
+func (s *Server) SetConcurrency(ctx context.Context, req *controlpb.SetConcurrencyRequest) (*controlpb.ControlState, error) {
+	return s.manage(ctx, req.GetManager(), "concurrency", req.GetQueue(), func(mgr *workers.Manager) error {
+		if req.GetConcurrency() <= 0 {
+			return status.Error(codes.InvalidArgument, "concurrency must be positive")
+		}
+		return mgr.SetConcurrency(req.GetQueue(), int(req.GetConcurrency()))
+	})
+}
+
+// GetStats returns the stats of a manager
+func (s *Server) GetStats(ctx context.Context, req *controlpb.ManagerRequest) (*controlpb.Stats, error) {
+	mgr, err := s.manager(req.GetManager())
+	if err != nil {
+		return nil, err
+	}
+	return managerStats(mgr)
+}
+
+// ListInFlight lists the jobs being processed by a manager, longest running first
+func (s *Server) ListInFlight(ctx context.Context, req *controlpb.ManagerRequest) (*controlpb.ListInFlightResponse, error) {
+	mgr, err := s.manager(req.GetManager())
+	if err != nil {
+		return nil, err
+	}
+	return &controlpb.ListInFlightResponse{Jobs: inFlight(mgr)}, nil
+}
+
+// Watch sends the status of a manager at every interval, from the call on, until the call is done
+func (s *Server) Watch(req *controlpb.WatchRequest, stream controlpb.Control_WatchServer) error {
+	mgr, err := s.manager(req.GetManager())
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(req.GetIntervalMs()) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats, err := managerStats(mgr)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&controlpb.Status{State: controlState(mgr), Stats: stats, InFlight: inFlight(mgr)}); err != nil {
+			return err
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func controlState(mgr *workers.Manager) *controlpb.ControlState {
+	state := mgr.ControlState()
+	res := &controlpb.ControlState{
+		Manager:     mgr.Opts().ProcessID,
+		Name:        state.Name,
+		Active:      state.Active,
+		Paused:      state.Paused,
+		Quiet:       state.Quiet,
+		Concurrency: make(map[string]int32, len(state.Concurrency)),
+	}
+	for queue, concurrency := range state.Concurrency {
+		res.Concurrency[queue] = int32(concurrency)
+	}
+	return res
+}
+
+func managerStats(mgr *workers.Manager) (*controlpb.Stats, error) {
+	stats, err := mgr.GetStats()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &controlpb.Stats{
+		Manager:           mgr.Opts().ProcessID,
+		Name:              stats.Name,
+		Processed:         stats.Processed,
+		Failed:            stats.Failed,
+		Duplicates:        stats.Duplicates,
+		RetryCount:        stats.RetryCount,
+		Enqueued:          stats.Enqueued,
+		FailureCategories: stats.FailureCategories,
+	}, nil
+}
+
+func inFlight(mgr *workers.Manager) []*controlpb.InFlightJob {
+	var jobs []*controlpb.InFlightJob
+	for _, job := range mgr.InFlight() {
+		jobs = append(jobs, &controlpb.InFlightJob{
+			Queue:     job.Queue,
+			Class:     job.Class,
+			Jid:       job.Jid,
+			StartedAt: timestamppb.New(job.StartedAt),
+			Slot:      int32(job.Slot),
+			Tid:       job.Tid,
+		})
+	}
+	return jobs
+}
@@ -0,0 +1,211 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	workers "github.com/digitalocean/go-workers2"
+	"github.com/digitalocean/go-workers2/grpcapi/controlpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestManager(t *testing.T, opts workers.Options) *workers.Manager {
+	opts.ServerAddr = "localhost:6379"
+	opts.Database = 14
+	opts.Namespace = "grpcapi"
+	if opts.ProcessID == "" {
+		opts.ProcessID = "grpc-1"
+	}
+	mgr, err := workers.NewManager(opts)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, mgr.GetRedisClient().FlushDB(context.Background()).Err())
+	return mgr
+}
+
+// newTestClient serves server in memory and returns a client of it
+func newTestClient(t *testing.T, server *Server) controlpb.ControlClient {
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	controlpb.RegisterControlServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	return controlpb.NewControlClient(conn)
+}
+
+func TestControl(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, workers.Options{})
+	mgr.AddWorker("someq", 2, func(m *workers.Msg) error { return nil })
+	server := NewServer(Options{})
+	server.Register(mgr)
+	client := newTestClient(t, server)
+
+	state, err := client.Pause(ctx, &controlpb.ManagerRequest{})
+	assert.NoError(t, err)
+	assert.True(t, state.Paused)
+	assert.Equal(t, "grpc-1", state.Manager)
+	assert.True(t, mgr.IsPaused())
+
+	state, err = client.SetConcurrency(ctx, &controlpb.SetConcurrencyRequest{Queue: "someq", Concurrency: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int32{"someq": 5}, state.Concurrency)
+
+	_, err = client.SetConcurrency(ctx, &controlpb.SetConcurrencyRequest{Queue: "otherq", Concurrency: 5})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.SetConcurrency(ctx, &controlpb.SetConcurrencyRequest{Queue: "someq"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetState(ctx, &controlpb.ManagerRequest{Manager: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Resume(ctx, &controlpb.ManagerRequest{Manager: "grpc-1"})
+	assert.NoError(t, err)
+	state, err = client.GetState(ctx, &controlpb.ManagerRequest{})
+	assert.NoError(t, err)
+	assert.False(t, state.Paused)
+
+	_, err = client.Quiet(ctx, &controlpb.ManagerRequest{})
+	assert.NoError(t, err)
+	assert.True(t, mgr.IsQuiet())
+
+	managers, err := client.ListManagers(ctx, &controlpb.ListManagersRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, managers.Managers, 1) {
+		assert.True(t, managers.Managers[0].Quiet)
+	}
+}
+
+func TestControlAuthorization(t *testing.T) {
+	var records []workers.AuditRecord
+	mgr := newTestManager(t, workers.Options{
+		Authorize: func(ctx context.Context, action workers.ManagementAction) error {
+			if action.Action == "concurrency" {
+				return errors.New("read only")
+			}
+			return nil
+		},
+		AuditSink: func(record workers.AuditRecord) { records = append(records, record) },
+	})
+	mgr.AddWorker("someq", 2, func(m *workers.Msg) error { return nil })
+	server := NewServer(Options{})
+	server.Register(mgr)
+	client := newTestClient(t, server)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), ActorMetadata, "orchestrator")
+	_, err := client.SetConcurrency(ctx, &controlpb.SetConcurrencyRequest{Queue: "someq", Concurrency: 5})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.Pause(ctx, &controlpb.ManagerRequest{})
+	assert.NoError(t, err)
+
+	if assert.Len(t, records, 2) {
+		assert.True(t, records[0].Denied)
+		assert.Equal(t, "orchestrator", records[0].Actor)
+		assert.Equal(t, "someq", records[0].Target)
+		assert.Equal(t, "pause", records[1].Action)
+		assert.False(t, records[1].Denied)
+	}
+	assert.Equal(t, 2, mgr.ControlState().Concurrency["someq"])
+}
+
+func TestSeveralManagers(t *testing.T) {
+	first := newTestManager(t, workers.Options{ProcessID: "first"})
+	second := newTestManager(t, workers.Options{ProcessID: "second"})
+	server := NewServer(Options{})
+	server.Register(first)
+	server.Register(second)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	_, err := client.Pause(ctx, &controlpb.ManagerRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Pause(ctx, &controlpb.ManagerRequest{Manager: "second"})
+	assert.NoError(t, err)
+	assert.False(t, first.IsPaused())
+	assert.True(t, second.IsPaused())
+
+	managers, err := client.ListManagers(ctx, &controlpb.ListManagersRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, managers.Managers, 2) {
+		assert.Equal(t, "first", managers.Managers[0].Manager)
+		assert.Equal(t, "second", managers.Managers[1].Manager)
+	}
+
+	server.Deregister(first)
+	state, err := client.GetState(ctx, &controlpb.ManagerRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "second", state.Manager)
+}
+
+func TestInFlightAndWatch(t *testing.T) {
+	mgr := newTestManager(t, workers.Options{})
+	started := make(chan bool)
+	release := make(chan bool)
+	mgr.AddWorker("someq", 1, func(m *workers.Msg) error {
+		close(started)
+		<-release
+		return nil
+	})
+	server := NewServer(Options{})
+	server.Register(mgr)
+	client := newTestClient(t, server)
+
+	runCtx, stop := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		mgr.Run(runCtx)
+		close(done)
+	}()
+	defer func() {
+		close(release)
+		stop()
+		<-done
+	}()
+
+	jid, err := mgr.Producer().Enqueue("someq", "Slow", nil)
+	assert.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job didn't start")
+	}
+
+	jobs, err := client.ListInFlight(context.Background(), &controlpb.ManagerRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, jobs.Jobs, 1) {
+		assert.Equal(t, jid, jobs.Jobs[0].Jid)
+		assert.Equal(t, "Slow", jobs.Jobs[0].Class)
+		assert.Equal(t, "someq", jobs.Jobs[0].Queue)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &controlpb.WatchRequest{IntervalMs: 10})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		st, err := stream.Recv()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, st.State.Active)
+		assert.Len(t, st.InFlight, 1)
+		assert.Contains(t, st.Stats.Enqueued, "grpcapi:someq")
+	}
+}
@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// healthRedisTimeout bounds the Redis round trip of health checks
+const healthRedisTimeout = 5 * time.Second
+
+// staleFetchAge is how long a worker with idle runners can go without Redis answering a fetch
+// before its manager is reported unhealthy
+const staleFetchAge = time.Minute
+
+// ErrManagerNotRunning is returned by Healthy for managers which aren't running
+var ErrManagerNotRunning = errors.New("manager isn't running")
+
+// ManagerStatus describes the health of a manager, such as for liveness and readiness probes
+type ManagerStatus struct {
+	Name    string `json:"manager_name"`
+	Running bool   `json:"running"`
+	// Whether the manager fetches new jobs, false while inactive, paused or quiet
+	Fetching bool `json:"fetching"`
+
+	// Round trip of a Redis command, or the error it returned
+	RedisLatency time.Duration `json:"redis_latency"`
+	RedisError   string        `json:"redis_error,omitempty"`
+
+	// Last time Redis answered a fetch of every worker's queue, zero before the first one
+	LastFetch map[string]time.Time `json:"last_fetch"`
+	InFlight  int                  `json:"in_flight"`
+
+	// Acknowledgements waiting for Redis to take writes again, see Options.WriteBuffer
+	BufferedWrites int `json:"buffered_writes"`
+
+	// Time since the last heartbeat was written, zero without heartbeat
+	HeartbeatAge time.Duration `json:"heartbeat_age"`
+
+	// Why the manager is unhealthy, empty when it's healthy
+	Error string `json:"error,omitempty"`
+}
+
+// Status returns the health of the manager
+func (m *Manager) Status() ManagerStatus {
+	status, _ := m.status()
+	return status
+}
+
+// Healthy returns why the manager is unhealthy: it isn't running, Redis doesn't answer, its heartbeat is
+// late, or a worker with idle runners stopped fetching. It returns nil for healthy managers.
+func (m *Manager) Healthy() error {
+	_, err := m.status()
+	return err
+}
+
+func (m *Manager) status() (ManagerStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthRedisTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, redisErr := m.opts.store.GetTime(ctx)
+
+	now := time.Now()
+	m.lock.Lock()
+	status := ManagerStatus{
+		Name:         m.opts.ManagerDisplayName,
+		Running:      m.running,
+		Fetching:     m.running && m.fetchingLocked(),
+		RedisLatency: now.Sub(start),
+		LastFetch:    map[string]time.Time{},
+
+		BufferedWrites: m.writes.len(),
+	}
+	startedAt, lastHeartbeat := m.startedAt, m.lastHeartbeat
+	workers := m.workers
+	m.lock.Unlock()
+
+	var unhealthy error
+	fail := func(err error) {
+		if unhealthy == nil {
+			unhealthy = err
+			status.Error = err.Error()
+		}
+	}
+
+	if !status.Running {
+		fail(ErrManagerNotRunning)
+	}
+	if redisErr != nil {
+		status.RedisError = redisErr.Error()
+		fail(fmt.Errorf("redis: %w", redisErr))
+	}
+
+	if m.opts.Heartbeat != nil && status.Running {
+		if lastHeartbeat.IsZero() {
+			lastHeartbeat = startedAt
+		} else {
+			status.HeartbeatAge = now.Sub(lastHeartbeat)
+		}
+		if now.Sub(lastHeartbeat) > m.opts.Heartbeat.HeartbeatTTL {
+			fail(fmt.Errorf("no heartbeat written for %v", now.Sub(lastHeartbeat).Round(time.Second)))
+		}
+	}
+
+	for _, w := range workers {
+		inFlight := len(w.inProgressMessages())
+		status.InFlight += inFlight
+
+		w.runnersLock.Lock()
+		fetcher, ok := w.fetcher.(interface{ lastFetched() time.Time })
+		w.runnersLock.Unlock()
+		if !ok {
+			continue
+		}
+		lastFetch := fetcher.lastFetched()
+		status.LastFetch[w.queue] = lastFetch
+
+		if !status.Fetching || inFlight >= w.getConcurrency() {
+			continue
+		}
+		if lastFetch.IsZero() {
+			lastFetch = startedAt
+		}
+		if now.Sub(lastFetch) > staleFetchAge {
+			fail(fmt.Errorf("%s wasn't fetched for %v", w.queue, now.Sub(lastFetch).Round(time.Second)))
+		}
+	}
+
+	return status, unhealthy
+}
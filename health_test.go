@@ -0,0 +1,84 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerHealthy(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("someq", 2, func(m *Msg) error { return nil })
+
+	assert.Equal(t, ErrManagerNotRunning, mgr.Healthy())
+	assert.False(t, mgr.Status().Running)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(context.Background())
+		wg.Done()
+	}()
+	defer func() {
+		mgr.Stop()
+		wg.Wait()
+	}()
+
+	assert.Eventually(t, func() bool {
+		return !mgr.Status().LastFetch["someq"].IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, mgr.Healthy())
+
+	status := mgr.Status()
+	assert.True(t, status.Running)
+	assert.True(t, status.Fetching)
+	assert.Empty(t, status.RedisError)
+	assert.Equal(t, 0, status.InFlight)
+
+}
+
+func TestManagerHealthyStaleFetch(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("someq", 2, func(m *Msg) error { return nil })
+
+	fetcher := newSimpleFetcher("someq", opts, true)
+	fetcher.lastFetch = time.Now().Add(-2 * staleFetchAge)
+	mgr.workers[0].fetcher = fetcher
+	mgr.running = true
+	mgr.startedAt = time.Now().Add(-time.Hour)
+
+	// a worker with idle runners which stopped fetching is unhealthy, unless its manager is paused
+	assert.Error(t, mgr.Healthy())
+	assert.Contains(t, mgr.Status().Error, "someq wasn't fetched")
+
+	mgr.Pause()
+	assert.NoError(t, mgr.Healthy())
+}
+
+func TestManagerHealthyHeartbeat(t *testing.T) {
+	opts, err := processOptions(SetupDefaultTestOptionsWithHeartbeat("prod", "1"))
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	mgr.lock.Lock()
+	mgr.running = true
+	mgr.startedAt = time.Now().Add(-2 * opts.Heartbeat.HeartbeatTTL)
+	mgr.lock.Unlock()
+	assert.Error(t, mgr.Healthy())
+
+	mgr.lock.Lock()
+	mgr.lastHeartbeat = time.Now().Add(-time.Second)
+	mgr.lock.Unlock()
+	assert.NoError(t, mgr.Healthy())
+	assert.InDelta(t, time.Second, mgr.Status().HeartbeatAge, float64(100*time.Millisecond))
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,7 +16,7 @@ import (
 type HeartbeatInfo struct {
 	Hostname    string   `json:"hostname"`
 	StartedAt   int64    `json:"started_at"`
-	Pid         int      `json:"Pid"`
+	Pid         int      `json:"pid"`
 	Tag         string   `json:"tag"`
 	Concurrency int      `json:"concurrency"`
 	Queues      []string `json:"queues"`
@@ -23,11 +24,12 @@ type HeartbeatInfo struct {
 	Identity    string   `json:"identity"`
 }
 
+// HeartbeatWorkerMsgWrapper is a job in flight as Sidekiq's Web UI reads it from the process' work hash
 type HeartbeatWorkerMsgWrapper struct {
-	Queue   string `json:"Queue"`
+	Queue   string `json:"queue"`
 	Payload string `json:"payload"`
 	RunAt   int64  `json:"run_at"`
-	Tid     string `json:"Tid"`
+	Tid     string `json:"tid"`
 }
 
 type HeartbeatWorkerMsg struct {
@@ -61,23 +63,31 @@ func (m *Manager) buildHeartbeat(heartbeatTime time.Time, ttl time.Duration) (*s
 	var workerHeartbeats []storage.WorkerHeartbeat
 
 	for _, w := range m.workers {
-		queues = append(queues, w.queue)
-		concurrency += w.concurrency // add up all concurrency here because it can be specified on a per-worker basis.
+		sources := w.sourceQueues()
+		queues = append(queues, sources...)
+		concurrency += w.getConcurrency() // add up all concurrency here because it can be specified on a per-worker basis.
 		busy += len(w.inProgressMessages())
 
 		w.runnersLock.Lock()
 		for _, r := range w.runners {
-			workerHeartbeat := storage.WorkerHeartbeat{
-				Pid:             pid,
-				Tid:             r.tid,
-				Queue:           w.queue,
-				InProgressQueue: w.inProgressQueue,
+			for _, queue := range sources {
+				workerHeartbeat := storage.WorkerHeartbeat{
+					Pid:             pid,
+					Tid:             r.tid,
+					Queue:           queue,
+					InProgressQueue: w.queueInProgressQueue(queue),
+				}
+				workerHeartbeats = append(workerHeartbeats, workerHeartbeat)
 			}
-			workerHeartbeats = append(workerHeartbeats, workerHeartbeat)
 		}
 		w.runnersLock.Unlock()
 	}
 
+	work, err := m.heartbeatWork()
+	if err != nil {
+		return nil, err
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, err
@@ -87,11 +97,7 @@ func (m *Manager) buildHeartbeat(heartbeatTime time.Time, ttl time.Duration) (*s
 		hostname = hostname + ":" + m.opts.ManagerDisplayName
 	}
 
-	tag := "default"
-
-	if m.opts.Namespace != "" {
-		tag = strings.ReplaceAll(m.opts.Namespace, ":", "")
-	}
+	tag, labels := m.processTag(), m.processLabels()
 
 	heartbeatID, err := m.getHeartbeatID()
 	if err != nil {
@@ -105,7 +111,7 @@ func (m *Manager) buildHeartbeat(heartbeatTime time.Time, ttl time.Duration) (*s
 		Tag:         tag,
 		Concurrency: concurrency,
 		Queues:      queues,
-		Labels:      []string{},
+		Labels:      labels,
 		Identity:    heartbeatID,
 	}
 	heartbeatInfoJson, err := json.Marshal(heartbeatInfo)
@@ -117,22 +123,98 @@ func (m *Manager) buildHeartbeat(heartbeatTime time.Time, ttl time.Duration) (*s
 	heartbeat := &storage.Heartbeat{
 		Identity:         heartbeatID,
 		Beat:             heartbeatTime.UTC().Unix(),
-		Quiet:            false,
+		Quiet:            m.IsQuiet() || m.IsPaused(),
 		Busy:             busy,
 		RSS:              0, // rss is not currently supported
 		Info:             string(heartbeatInfoJson),
 		Pid:              pid,
 		ActiveManager:    m.IsActive(),
 		WorkerHeartbeats: workerHeartbeats,
+		Work:             work,
 		Ttl:              ttl,
 	}
 	if m.opts.Heartbeat != nil && m.opts.Heartbeat.PrioritizedManager != nil {
 		heartbeat.ManagerPriority = m.opts.Heartbeat.PrioritizedManager.ManagerPriority
 	}
+	if m.opts.Heartbeat != nil && m.opts.Heartbeat.BeatFields != nil {
+		heartbeat.Extra = m.heartbeatExtraFields(m.opts.Heartbeat.BeatFields(m))
+	}
 
 	return heartbeat, nil
 }
 
+// processTag returns the tag of the process: Options.Tag, or its namespace without the environment
+func (m *Manager) processTag() string {
+	if m.opts.Tag != "" {
+		return m.opts.Tag
+	}
+	namespace := m.opts.Namespace
+	if m.opts.Environment != "" {
+		namespace = strings.TrimPrefix(namespace, m.opts.Environment+":")
+	}
+	if namespace == "" {
+		return "default"
+	}
+	return strings.ReplaceAll(namespace, ":", "")
+}
+
+// processLabels returns the labels of the process shown by Sidekiq's Web UI: its environment, then
+// Options.Labels as key=value sorted by key
+func (m *Manager) processLabels() []string {
+	labels := []string{}
+	if m.opts.Environment != "" {
+		labels = append(labels, m.opts.Environment)
+	}
+	keys := make([]string, 0, len(m.opts.Labels))
+	for key := range m.opts.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		labels = append(labels, key+"="+m.opts.Labels[key])
+	}
+	return labels
+}
+
+// heartbeatWork returns the jobs in flight by runner thread ID, the way Sidekiq's Busy tab lists them
+func (m *Manager) heartbeatWork() (map[string]string, error) {
+	work := map[string]string{}
+	for _, job := range m.InFlight() {
+		wrapper, err := json.Marshal(HeartbeatWorkerMsgWrapper{
+			Queue:   job.Queue,
+			Payload: job.Message.ToJson(),
+			RunAt:   job.StartedAt.Unix(),
+			Tid:     job.Tid,
+		})
+		if err != nil {
+			return nil, err
+		}
+		work[job.Tid] = string(wrapper)
+	}
+	return work, nil
+}
+
+func (m *Manager) heartbeatExtraFields(fields map[string]string) map[string]string {
+	extra := map[string]string{}
+	for field, value := range fields {
+		if isHeartbeatField(field) {
+			m.logger.Println("ERR: ignoring custom heartbeat field that would overwrite", field)
+			continue
+		}
+		extra[field] = value
+	}
+	return extra
+}
+
+func isHeartbeatField(field string) bool {
+	for _, f := range storage.HeartbeatFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Manager) getHeartbeatID() (string, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -1,12 +1,14 @@
 package workers
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/digitalocean/go-workers2/storage"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,6 +45,43 @@ func TestBuildHeartbeat(t *testing.T) {
 	assert.Equal(t, false, heartbeat.Quiet)
 }
 
+func TestBuildHeartbeatEnvironment(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.Environment = "staging"
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+
+	heartbeat, err := mgr.buildHeartbeat(time.Now().UTC(), time.Second)
+	assert.NoError(t, err)
+
+	info := &HeartbeatInfo{}
+	assert.NoError(t, json.Unmarshal([]byte(heartbeat.Info), info))
+	assert.Equal(t, "prod", info.Tag)
+	assert.Equal(t, []string{"staging"}, info.Labels)
+}
+
+func TestBuildHeartbeatTagAndLabels(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.Environment = "staging"
+	opts.Tag = "billing"
+	opts.Labels = map[string]string{"team": "payments", "binary": "invoicer"}
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+
+	heartbeat, err := mgr.buildHeartbeat(time.Now().UTC(), time.Second)
+	assert.NoError(t, err)
+
+	info := &HeartbeatInfo{}
+	assert.NoError(t, json.Unmarshal([]byte(heartbeat.Info), info))
+	assert.Equal(t, "billing", info.Tag)
+	assert.Equal(t, []string{"staging", "binary=invoicer", "team=payments"}, info.Labels)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, "billing", stats.Tag)
+	assert.Equal(t, opts.Labels, stats.Labels)
+}
+
 func TestBuildHeartbeatWorkerMessage(t *testing.T) {
 	namespace := "prod"
 	opts := testOptionsWithNamespace(namespace)
@@ -78,3 +117,96 @@ func TestBuildHeartbeatWorkerMessage(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+func TestSendHeartbeatCustomFields(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := "prod"
+	opts := SetupDefaultTestOptionsWithHeartbeat(namespace, "1")
+	opts.Heartbeat.BeatFields = func(manager *Manager) map[string]string {
+		return map[string]string{
+			"deploy": "abc123",
+			"busy":   "99",
+		}
+	}
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+
+	heartbeat, err := mgr.sendHeartbeat(time.Now())
+	assert.NoError(t, err)
+
+	managerKey := storage.GetManagerKey(mgr.opts.Namespace, heartbeat.Identity)
+	deploy, err := mgr.opts.client.HGet(ctx, managerKey, "deploy").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", deploy)
+
+	// custom fields can't overwrite the standard ones
+	busy, err := mgr.opts.client.HGet(ctx, managerKey, "busy").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "0", busy)
+
+	// the hash outlives the process until another manager requeues its jobs
+	ttl, err := mgr.opts.client.TTL(ctx, managerKey).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+}
+
+func TestSendHeartbeatWork(t *testing.T) {
+	ctx := context.Background()
+
+	opts := SetupDefaultTestOptionsWithHeartbeat("prod", "1")
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	mgr.AddWorker("somequeue", 1, func(m *Msg) error {
+		return nil
+	})
+
+	msg, _ := NewMsg(`{"class":"MyWorker","jid":"jid-123"}`)
+	tr := newTaskRunner(mgr.logger, nil)
+	tr.currentMsg, tr.currentStart = msg, time.Unix(1700000000, 0)
+	mgr.workers[0].runners = []*taskRunner{tr}
+
+	heartbeat, err := mgr.sendHeartbeat(time.Now())
+	assert.NoError(t, err)
+
+	info := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(heartbeat.Info), &info))
+	assert.NotNil(t, info["pid"])
+
+	managerKey := storage.GetManagerKey(mgr.opts.Namespace, heartbeat.Identity)
+	quiet, _ := mgr.opts.client.HGet(ctx, managerKey, "quiet").Result()
+	assert.Equal(t, "false", quiet)
+
+	work, err := mgr.opts.client.HGetAll(ctx, storage.GetWorkersKey(managerKey)).Result()
+	assert.NoError(t, err)
+	assert.Len(t, work, 1)
+	var job HeartbeatWorkerMsgWrapper
+	assert.NoError(t, json.Unmarshal([]byte(work[tr.tid]), &job))
+	assert.Equal(t, HeartbeatWorkerMsgWrapper{Queue: "somequeue", Payload: msg.ToJson(), RunAt: 1700000000, Tid: tr.tid}, job)
+
+	// finished jobs leave the work hash on the next beat
+	tr.currentMsg = nil
+	_, err = mgr.sendHeartbeat(time.Now())
+	assert.NoError(t, err)
+	nb, _ := mgr.opts.client.Exists(ctx, storage.GetWorkersKey(managerKey)).Result()
+	assert.Equal(t, int64(0), nb)
+}
+
+func TestManagerRemovesHeartbeatOnShutdown(t *testing.T) {
+	ctx := context.Background()
+
+	opts := SetupDefaultTestOptionsWithHeartbeat("prod", "1")
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+
+	heartbeat, err := mgr.sendHeartbeat(time.Now())
+	assert.NoError(t, err)
+	processes, _ := mgr.opts.client.SMembers(ctx, storage.GetProcessesKey(mgr.opts.Namespace)).Result()
+	assert.Contains(t, processes, heartbeat.Identity)
+
+	mgr.removeHeartbeat()
+	processes, _ = mgr.opts.client.SMembers(ctx, storage.GetProcessesKey(mgr.opts.Namespace)).Result()
+	assert.NotContains(t, processes, heartbeat.Identity)
+	nb, _ := mgr.opts.client.Exists(ctx, storage.GetManagerKey(mgr.opts.Namespace, heartbeat.Identity)).Result()
+	assert.Equal(t, int64(0), nb)
+}
@@ -0,0 +1,78 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+)
+
+// InFlightJob is a job being processed by a manager
+type InFlightJob struct {
+	Queue     string    `json:"queue"`
+	Class     string    `json:"class"`
+	Jid       string    `json:"jid"`
+	StartedAt time.Time `json:"started_at"`
+
+	// Slot is the index of the runner processing the job among its queue's runners, and Tid
+	// the runner's thread ID as reported in heartbeats
+	Slot int    `json:"slot"`
+	Tid  string `json:"tid"`
+
+	Message *Msg `json:"-"`
+}
+
+// InFlight returns the jobs currently being processed by the manager, longest running first
+func (m *Manager) InFlight() []InFlightJob {
+	m.lock.Lock()
+	workers := m.workers
+	m.lock.Unlock()
+
+	var jobs []InFlightJob
+	for _, w := range workers {
+		jobs = append(jobs, w.inFlightJobs()...)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+	})
+	return jobs
+}
+
+// WriteInFlight writes the jobs currently being processed by the manager to w, one per line
+func (m *Manager) WriteInFlight(w io.Writer) error {
+	jobs := m.InFlight()
+	now := time.Now()
+	if _, err := fmt.Fprintf(w, "%d jobs in flight\n", len(jobs)); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		_, err := fmt.Fprintf(w, "%s[%d] tid=%s %s jid=%s running for %v\n",
+			job.Queue, job.Slot, job.Tid, job.Class, job.Jid, now.Sub(job.StartedAt).Round(time.Millisecond))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpInFlightOnSignal logs the in-flight jobs every time the manager receives sig, until ctx is done
+func (m *Manager) dumpInFlightOnSignal(ctx context.Context, sig os.Signal) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-signals:
+			var dump bytes.Buffer
+			m.WriteInFlight(&dump)
+			m.logger.Print(dump.String())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
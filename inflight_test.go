@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_InFlight(t *testing.T) {
+	opts := testOptionsWithNamespace("inflighttest")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	prod, err := NewProducer(opts)
+	assert.NoError(t, err)
+
+	started := make(chan string)
+	release := make(chan bool)
+	mgr.AddWorker("inflight_queue", 2, func(m *Msg) error {
+		started <- m.Jid()
+		<-release
+		return nil
+	}, NopMiddleware)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(context.Background())
+		wg.Done()
+	}()
+
+	assert.Empty(t, mgr.InFlight())
+
+	before := time.Now()
+	jid1, err := prod.Enqueue("inflight_queue", "First", []int{1})
+	assert.NoError(t, err)
+	assert.Equal(t, jid1, <-started)
+	jid2, err := prod.Enqueue("inflight_queue", "Second", []int{2})
+	assert.NoError(t, err)
+	assert.Equal(t, jid2, <-started)
+
+	jobs := mgr.InFlight()
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, jid1, jobs[0].Jid)
+	assert.Equal(t, "First", jobs[0].Class)
+	assert.Equal(t, "inflight_queue", jobs[0].Queue)
+	assert.False(t, jobs[0].StartedAt.Before(before))
+	assert.Equal(t, jid2, jobs[1].Jid)
+	assert.NotEqual(t, jobs[0].Slot, jobs[1].Slot)
+	assert.NotEmpty(t, jobs[1].Tid)
+
+	var dump bytes.Buffer
+	assert.NoError(t, mgr.WriteInFlight(&dump))
+	assert.Contains(t, dump.String(), "2 jobs in flight")
+	assert.Contains(t, dump.String(), "First jid="+jid1)
+
+	release <- true
+	release <- true
+	assert.Eventually(t, func() bool { return len(mgr.InFlight()) == 0 }, 2*time.Second, 10*time.Millisecond)
+
+	mgr.Stop()
+	wg.Wait()
+}
@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// QueueSize is the number of jobs waiting in a queue
+type QueueSize struct {
+	Queue string
+	Size  int64
+}
+
+// QueueSizes returns the size of every known queue, by name
+func (m *Manager) QueueSizes(ctx context.Context) ([]QueueSize, error) {
+	queues, err := m.opts.store.ListQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(queues)
+
+	sizes := make([]QueueSize, len(queues))
+	for i, queue := range queues {
+		size, err := m.opts.store.QueueLength(ctx, queue)
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = QueueSize{Queue: queue, Size: size}
+	}
+	return sizes, nil
+}
+
+// PeekQueue returns up to count jobs of a queue without taking them, next to be fetched first
+func (m *Manager) PeekQueue(ctx context.Context, queue string, count int) ([]*Msg, error) {
+	messages, err := m.opts.store.ListMessages(ctx, queue)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Msg
+	// jobs are fetched from the end of the list
+	for i := len(messages) - 1; i >= 0 && len(res) < count; i-- {
+		message, err := NewMsg(messages[i])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, message)
+	}
+	return res, nil
+}
+
+// GrepJobs returns the jobs of a queue, or of the job set named by source such as "retry", whose payload
+// matches pattern
+func (m *Manager) GrepJobs(ctx context.Context, source string, pattern *regexp.Regexp) ([]*Msg, error) {
+	var payloads []string
+	if set, err := ParseJobSet(source); err == nil {
+		messages, err := m.opts.store.ListSetMessages(ctx, string(set))
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			payloads = append(payloads, message.Message)
+		}
+	} else {
+		messages, err := m.opts.store.ListMessages(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(messages) - 1; i >= 0; i-- {
+			payloads = append(payloads, messages[i])
+		}
+	}
+
+	var res []*Msg
+	for _, payload := range payloads {
+		if !pattern.MatchString(payload) {
+			continue
+		}
+		message, err := NewMsg(payload)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, message)
+	}
+	return res, nil
+}
+
+// RequeueJob moves the job of a job set with the given JID to its queue, for immediate processing. It
+// returns false when the set has no such job.
+func (m *Manager) RequeueJob(ctx context.Context, set JobSet, jid string) (bool, error) {
+	messages, err := m.opts.store.ListSetMessages(ctx, string(set))
+	if err != nil {
+		return false, err
+	}
+	for _, scored := range messages {
+		message, err := NewMsg(scored.Message)
+		if err != nil || message.Jid() != jid {
+			continue
+		}
+		queue, err := message.Get("queue").String()
+		if err != nil || queue == "" {
+			return false, fmt.Errorf("job %s has no queue", jid)
+		}
+		// retries carry the namespaced queue
+		queue = strings.TrimPrefix(queue, m.opts.Namespace)
+
+		// whoever removes the job from the set requeues it, so it isn't requeued twice
+		removed, err := m.opts.store.RemoveSetMessage(ctx, string(set), scored.Message)
+		if err != nil || !removed {
+			return false, err
+		}
+		if err := m.opts.store.EnqueueMessageNow(ctx, queue, m.opts.renamePayload(scored.Message)); err != nil {
+			if restoreErr := m.opts.store.AddSetMessages(ctx, string(set), []storage.ScoredMessage{scored}); restoreErr != nil {
+				m.logger.Println("ERR: couldn't put job", jid, "back in", set, ":", restoreErr)
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// PurgeQueue removes every job waiting in a queue, returning how many were removed. Jobs being processed
+// and the ones scheduled or retried into the queue later are left alone.
+func (m *Manager) PurgeQueue(ctx context.Context, queue string) (int64, error) {
+	return m.opts.store.PurgeQueue(ctx, queue)
+}
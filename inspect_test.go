@@ -0,0 +1,90 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_InspectQueues(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	p := mgr.Producer()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.org"} {
+		_, err := p.Enqueue("mailers", "Mail", []string{email})
+		assert.NoError(t, err)
+	}
+	_, err = p.Enqueue("reports", "Report", nil)
+	assert.NoError(t, err)
+
+	sizes, err := mgr.QueueSizes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []QueueSize{{Queue: "mailers", Size: 3}, {Queue: "reports", Size: 1}}, sizes)
+
+	jobs, err := mgr.PeekQueue(ctx, "mailers", 2)
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, "a@example.com", jobs[0].Args().GetIndex(0).MustString())
+		assert.Equal(t, "b@example.com", jobs[1].Args().GetIndex(0).MustString())
+	}
+	assert.Equal(t, int64(3), opts.client.LLen(ctx, "prod:queue:mailers").Val())
+
+	jobs, err = mgr.GrepJobs(ctx, "mailers", regexp.MustCompile(`example\.com`))
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+
+	purged, err := mgr.PurgeQueue(ctx, "mailers")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	assert.Equal(t, int64(0), opts.client.LLen(ctx, "prod:queue:mailers").Val())
+	assert.Equal(t, int64(1), opts.client.LLen(ctx, "prod:queue:reports").Val())
+}
+
+func TestManager_RequeueJob(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	// the job fails into the retry set
+	_, err = mgr.Producer().EnqueueWithOptions("mailers", "Mail", []string{"a@example.com"}, EnqueueOptions{Retry: true})
+	assert.NoError(t, err)
+	payload, _ := opts.client.LPop(ctx, "prod:queue:mailers").Result()
+	message, _ := NewMsg(payload)
+	job := mgr.buildJob("mailers", func(m *Msg) error {
+		return errors.New("smtp down")
+	}, []MiddlewareFunc{RetryMiddleware})
+	assert.NoError(t, job(message))
+
+	jobs, err := mgr.GrepJobs(ctx, "retry", regexp.MustCompile(`"error_message":"smtp down"`))
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+
+	requeued, err := mgr.RequeueJob(ctx, RetryJobs, "other")
+	assert.NoError(t, err)
+	assert.False(t, requeued)
+
+	requeued, err = mgr.RequeueJob(ctx, RetryJobs, message.Jid())
+	assert.NoError(t, err)
+	assert.True(t, requeued)
+	assert.Equal(t, int64(0), opts.client.ZCard(ctx, "prod:"+string(RetryJobs)).Val())
+	jobs, err = mgr.PeekQueue(ctx, "mailers", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, message.Jid(), jobs[0].Jid())
+	}
+	assert.Equal(t, int64(0), opts.client.Exists(ctx, "prod:queue:prod:mailers").Val())
+
+	// a job is only requeued once
+	requeued, err = mgr.RequeueJob(ctx, RetryJobs, message.Jid())
+	assert.NoError(t, err)
+	assert.False(t, requeued)
+}
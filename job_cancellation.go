@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// jobCancellationRetryInterval is how long a manager waits before subscribing to cancellations again
+const jobCancellationRetryInterval = 5 * time.Second
+
+// jobCancellations holds the cancel funcs of the contexts of the jobs running in a manager
+type jobCancellations struct {
+	lock sync.Mutex
+	jobs map[string]map[*Msg]context.CancelFunc
+}
+
+func (c *jobCancellations) add(message *Msg, cancel context.CancelFunc) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.jobs == nil {
+		c.jobs = map[string]map[*Msg]context.CancelFunc{}
+	}
+	if c.jobs[message.Jid()] == nil {
+		c.jobs[message.Jid()] = map[*Msg]context.CancelFunc{}
+	}
+	c.jobs[message.Jid()][message] = cancel
+}
+
+func (c *jobCancellations) remove(message *Msg) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.jobs[message.Jid()], message)
+	if len(c.jobs[message.Jid()]) == 0 {
+		delete(c.jobs, message.Jid())
+	}
+}
+
+// cancel cancels the contexts of the running copies of a job, and reports whether there were any
+func (c *jobCancellations) cancel(jid string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, cancel := range c.jobs[jid] {
+		cancel()
+	}
+	return len(c.jobs[jid]) > 0
+}
+
+// CancelJob cancels the context of the job jid wherever it runs, in every manager sharing the Redis
+// namespace. Handlers should return once Msg.Context is done; the job is then acknowledged without
+// being retried, and counted in the "cancelled" stat. Jobs which aren't running are left alone.
+func (m *Manager) CancelJob(jid string) error {
+	return m.opts.store.PublishCancellation(context.Background(), jid)
+}
+
+// runJobCancellations cancels the running jobs other managers ask to, until ctx is done
+func (m *Manager) runJobCancellations(ctx context.Context) {
+	for ctx.Err() == nil {
+		jids, err := m.opts.store.SubscribeCancellations(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				m.logger.Println("ERR: couldn't subscribe to job cancellations:", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(jobCancellationRetryInterval):
+			}
+			continue
+		}
+		for jid := range jids {
+			if m.cancellations.cancel(jid) {
+				m.logger.Println("cancelling job", jid)
+			}
+		}
+	}
+}
+
+// cancellationJobFunc runs jobs with a context cancelled by CancelJob. Cancelled jobs succeed, so
+// they aren't retried whatever they return.
+func cancellationJobFunc(m *Manager, next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		ctx, cancel := context.WithCancel(message.Context())
+		defer cancel()
+		message.ctx = ctx
+		// set under the lock of the cancellations, read once the job is removed from them
+		cancelled := false
+		m.cancellations.add(message, func() {
+			cancelled = true
+			cancel()
+		})
+
+		err := next(message)
+		m.cancellations.remove(message)
+		if cancelled {
+			m.logger.Println("cancelled job", message.Jid())
+			incrementStats(m, "cancelled")
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,66 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_CancelJob(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	worker, err := newManager(opts)
+	assert.NoError(t, err)
+	// the job is cancelled from another manager, such as the one behind a UI
+	ui, err := newManager(opts)
+	assert.NoError(t, err)
+
+	subscribed, stop := context.WithCancel(ctx)
+	defer stop()
+	go worker.runJobCancellations(subscribed)
+
+	started := make(chan bool)
+	job := worker.buildJob("exports", func(m *Msg) error {
+		started <- true
+		<-m.Context().Done()
+		return m.Context().Err()
+	}, nil)
+
+	done := make(chan error)
+	go func() {
+		message, _ := NewMsg(`{"jid":"export-1","class":"Export","retry":true}`)
+		done <- job(message)
+	}()
+	<-started
+
+	// other jobs aren't affected
+	assert.NoError(t, ui.CancelJob("export-2"))
+	select {
+	case <-done:
+		t.Fatal("job cancelled by the cancellation of another job")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the subscription may not be ready yet
+	var cancelErr error
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, ui.CancelJob("export-1"))
+		select {
+		case cancelErr = <-done:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, cancelErr)
+
+	retries, _ := opts.client.ZCard(ctx, "prod:"+storage.RetryKey).Result()
+	assert.Equal(t, int64(0), retries)
+	cancelled, _ := opts.client.Get(ctx, "prod:stat:cancelled").Int()
+	assert.Equal(t, 1, cancelled)
+	assert.Empty(t, worker.cancellations.jobs)
+}
@@ -1,8 +1,10 @@
 package workers
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // JobHandler interface defines the contract for job handlers
@@ -10,62 +12,313 @@ type JobHandler interface {
 	HandleJob(args interface{}) error
 }
 
+// ContextJobHandler is implemented by the handlers which take the context of the job, carrying its
+// JobContext, over HandleJob
+type ContextJobHandler interface {
+	HandleJobContext(ctx context.Context, args interface{}) error
+}
+
+// JobContext describes the job a dispatched handler runs, such as to log its JID or key idempotency on it
+type JobContext struct {
+	Jid string
+	// Class the job was dispatched to, once class aliases are resolved
+	Class      string
+	Queue      string
+	RetryCount int
+	// Zero for jobs without an enqueue time
+	EnqueuedAt time.Time
+	Msg        *Msg
+}
+
+type jobContextKey struct{}
+
+// GetJobContext returns the JobContext of the job a JobDispatcher handler was given ctx for
+func GetJobContext(ctx context.Context) (*JobContext, bool) {
+	jc, ok := ctx.Value(jobContextKey{}).(*JobContext)
+	return jc, ok
+}
+
+func newJobContext(msg *Msg, class string) *JobContext {
+	jc := &JobContext{Jid: msg.Jid(), Class: class, Msg: msg}
+	jc.Queue, _ = msg.Get("queue").String()
+	jc.RetryCount, _ = msg.Get("retry_count").Int()
+	if enqueuedAt, err := msg.Get("enqueued_at").Float64(); err == nil {
+		jc.EnqueuedAt = time.Unix(0, 0).Add(secondsToDuration(enqueuedAt))
+	}
+	return jc
+}
+
+// UnknownClassPolicy is what a JobDispatcher without a default handler does with the jobs of classes
+// without a handler, such as a class shipped by Ruby before Go is deployed
+type UnknownClassPolicy int
+
+const (
+	// UnknownClassError fails the job, which is retried as usual
+	UnknownClassError UnknownClassPolicy = iota
+	// UnknownClassPark parks the job in the ParkedQueue of its class, until Manager.EnableClass moves
+	// it back once a handler is deployed
+	UnknownClassPark
+	// UnknownClassDrop logs and drops the job
+	UnknownClassDrop
+)
+
+// HandlerMiddlewareFunc wraps the dispatch of the jobs of a class to its handler, such as in a DB transaction
+type HandlerMiddlewareFunc func(class string, next JobFunc) JobFunc
+
+// WithTimeout cancels the context of the jobs of a class running past timeout, and fails them with
+// ErrJobTimeout, so they are retried as usual. Handlers ignoring their context are abandoned to finish in
+// the background, freeing their worker's slot.
+func WithTimeout(timeout time.Duration) HandlerMiddlewareFunc {
+	return func(class string, next JobFunc) JobFunc {
+		if timeout <= 0 {
+			return next
+		}
+		return func(msg *Msg) error {
+			parent := msg.ctx
+			ctx, cancel := context.WithTimeout(msg.Context(), timeout)
+			defer cancel()
+			msg.ctx = ctx
+
+			done := make(chan error, 1)
+			go func() {
+				defer func() {
+					if e := recover(); e != nil {
+						done <- fmt.Errorf("%v", e)
+					}
+				}()
+				done <- next(msg)
+			}()
+
+			select {
+			case err := <-done:
+				msg.ctx = parent
+				if err != nil && ctx.Err() == context.DeadlineExceeded {
+					return fmt.Errorf("%w after %v: %v", ErrJobTimeout, timeout, err)
+				}
+				return err
+			case <-ctx.Done():
+				// the handler may still use msg, so its context is left as is
+				if ctx.Err() != context.DeadlineExceeded {
+					return ctx.Err()
+				}
+				Logger.Println("abandoned handler of job", msg.Jid(), "of class", class, "after", timeout)
+				return fmt.Errorf("%w after %v: %v", ErrJobTimeout, timeout, ctx.Err())
+			}
+		}
+	}
+}
+
+// registeredHandler is the handler of a class, along with the type of its args
+type registeredHandler struct {
+	handler  JobHandler
+	argsType reflect.Type
+	// dispatch of the jobs of the class through its middlewares
+	run         JobFunc
+	middlewares []string
+}
+
 // JobDispatcher manages job handlers and routes messages to them
 type JobDispatcher struct {
-	handlers map[string]struct {
-		handler  JobHandler
-		argsType reflect.Type
-	}
+	handlers   map[string]*registeredHandler
+	transforms map[string][]ArgsTransformFunc
+	docs       map[string]JobDoc
+	// classes whose args are a single hash, decoded by key
+	keywordArgs map[string]bool
+
+	versionRoutes map[string]versionRoute
+	patterns      patternHandlers
+
+	defaultHandler     JobFunc
+	unknownClassPolicy UnknownClassPolicy
 }
 
 // NewJobDispatcher creates a new JobDispatcher instance
 func NewJobDispatcher() *JobDispatcher {
 	return &JobDispatcher{
-		handlers: make(map[string]struct {
-			handler  JobHandler
-			argsType reflect.Type
-		}),
+		handlers: make(map[string]*registeredHandler),
 	}
 }
 
-// RegisterHandler registers a handler for a specific job class
-func (d *JobDispatcher) RegisterHandler(class string, handler JobHandler, argsType interface{}) error {
+// RegisterHandler registers a handler for a specific job class, run through the given middlewares, the
+// first one outermost
+func (d *JobDispatcher) RegisterHandler(class string, handler JobHandler, argsType interface{}, mids ...HandlerMiddlewareFunc) error {
+	t, err := handlerArgsType(argsType)
+	if err != nil {
+		return err
+	}
+	d.handlers[class] = d.newRegisteredHandler(class, handler, t, mids)
+	return nil
+}
+
+func handlerArgsType(argsType interface{}) (reflect.Type, error) {
 	t := reflect.TypeOf(argsType)
-	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("argsType must be a pointer to a struct")
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("argsType must be a pointer to a struct")
 	}
+	return t, nil
+}
 
-	d.handlers[class] = struct {
-		handler  JobHandler
-		argsType reflect.Type
-	}{
-		handler:  handler,
-		argsType: t,
+func (d *JobDispatcher) newRegisteredHandler(class string, handler JobHandler, argsType reflect.Type, mids []HandlerMiddlewareFunc) *registeredHandler {
+	registered := &registeredHandler{handler: handler, argsType: argsType}
+	for _, mid := range mids {
+		registered.middlewares = append(registered.middlewares, funcName(mid))
 	}
-	return nil
+	registered.run = func(msg *Msg) error {
+		return d.handle(msg, class, registered)
+	}
+	for i := len(mids) - 1; i >= 0; i-- {
+		registered.run = mids[i](class, registered.run)
+	}
+	return registered
+}
+
+// funcHandler adapts a function taking a pointer to its args struct to JobHandler
+type funcHandler struct {
+	fn reflect.Value
+}
+
+func (h funcHandler) HandleJob(args interface{}) error {
+	err, _ := h.fn.Call([]reflect.Value{reflect.ValueOf(args)})[0].Interface().(error)
+	return err
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterFunc registers fn, a func(args *MyArgs) error, as the handler of class. The args struct is
+// inferred from the signature of fn.
+func (d *JobDispatcher) RegisterFunc(class string, fn interface{}, mids ...HandlerMiddlewareFunc) error {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 1 || t.Out(0) != errorType {
+		return fmt.Errorf("fn must be a func(args *T) error")
+	}
+	return d.RegisterHandler(class, funcHandler{fn: reflect.ValueOf(fn)}, reflect.Zero(t.In(0)).Interface(), mids...)
+}
+
+// typedHandler adapts a function taking its args struct by value to JobHandler
+type typedHandler[T any] struct {
+	fn func(ctx context.Context, args T) error
+}
+
+func (h typedHandler[T]) HandleJob(args interface{}) error {
+	return h.HandleJobContext(context.Background(), args)
+}
+
+func (h typedHandler[T]) HandleJobContext(ctx context.Context, args interface{}) error {
+	return h.fn(ctx, *args.(*T))
+}
+
+// Register registers fn as the handler of class, getting the context of the job, carrying its JobContext,
+// and its args decoded into T, a struct, so the type of the args is checked at compile time
+func Register[T any](d *JobDispatcher, class string, fn func(ctx context.Context, args T) error, mids ...HandlerMiddlewareFunc) error {
+	if fn == nil {
+		return fmt.Errorf("fn must not be nil")
+	}
+	return d.RegisterHandler(class, typedHandler[T]{fn: fn}, new(T), mids...)
+}
+
+// RegisterKeywordArgs decodes the args of the jobs of class, such as a Ruby perform(opts = {}), from a
+// single hash matched by key to the fields of the args struct, instead of positionally
+func (d *JobDispatcher) RegisterKeywordArgs(class string) {
+	if d.keywordArgs == nil {
+		d.keywordArgs = map[string]bool{}
+	}
+	d.keywordArgs[class] = true
+}
+
+// SetDefaultHandler runs fn for the jobs of classes without a handler, instead of applying the
+// UnknownClassPolicy
+func (d *JobDispatcher) SetDefaultHandler(fn JobFunc) {
+	d.defaultHandler = fn
+}
+
+// SetUnknownClassPolicy sets what is done with the jobs of classes without a handler when there's no
+// default handler, UnknownClassError by default
+func (d *JobDispatcher) SetUnknownClassPolicy(policy UnknownClassPolicy) {
+	d.unknownClassPolicy = policy
+}
+
+// dispatchUnknown handles a job of a class without a handler
+func (d *JobDispatcher) dispatchUnknown(msg *Msg, class string) error {
+	if d.defaultHandler != nil {
+		return d.defaultHandler(msg)
+	}
+	switch d.unknownClassPolicy {
+	case UnknownClassPark:
+		p, err := msg.Producer()
+		if err != nil {
+			return err
+		}
+		if err := p.opts.store.EnqueueMessageNow(msg.Context(), ParkedQueue(class), msg.ToJson()); err != nil {
+			return err
+		}
+		Logger.Println("parked job", msg.Jid(), "of class without a handler:", class)
+		return nil
+	case UnknownClassDrop:
+		Logger.Println("dropped job", msg.Jid(), "of class without a handler:", class)
+		return nil
+	}
+	return fmt.Errorf("no handler registered for job class: %s", class)
 }
 
 // Dispatch routes a message to its registered handler
 func (d *JobDispatcher) Dispatch(msg *Msg) error {
+	if route, ok := d.versionRoutes[msg.Class()]; ok {
+		routed, err := d.routeVersion(msg, route)
+		if err != nil {
+			return err
+		}
+		msg = routed
+	}
+
 	class := msg.Class()
 	handlerInfo, ok := d.handlers[class]
 	if !ok {
-		return fmt.Errorf("no handler registered for job class: %s", class)
+		handlerInfo, ok = d.patterns.match(d, class)
+	}
+	if !ok {
+		return d.dispatchUnknown(msg, class)
 	}
+	return handlerInfo.run(msg)
+}
 
+// handle decodes the args of a message and calls the handler of its class
+func (d *JobDispatcher) handle(msg *Msg, class string, handlerInfo *registeredHandler) error {
 	args := msg.Args()
 	if args == nil {
 		return fmt.Errorf("no arguments received for job class: %s", class)
 	}
 
+	if transforms := d.transforms[class]; len(transforms) > 0 {
+		raw, err := args.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("failed to encode job args for class %s: %v", class, err)
+		}
+		if raw, err = applyArgsTransforms(raw, transforms); err != nil {
+			return fmt.Errorf("failed to transform job args for class %s: %v", class, err)
+		}
+		parsed, err := newData(string(raw))
+		if err != nil {
+			return fmt.Errorf("failed to parse transformed job args for class %s: %v", class, err)
+		}
+		args = &Args{parsed}
+	}
+
 	// Create a new instance of the args struct
 	argsValue := reflect.New(handlerInfo.argsType.Elem())
 	argsInterface := argsValue.Interface()
 	// Decode the arguments
-	if err := DecodeSidekiqArgs(args.Json, argsInterface); err != nil {
+	decode := DecodeSidekiqArgs
+	if d.keywordArgs[class] {
+		decode = DecodeSidekiqKwargs
+	}
+	if err := decode(args.Json, argsInterface); err != nil {
 		return fmt.Errorf("failed to decode job args for class %s: %v", class, err)
 	}
 
 	// Call the handler
+	if handler, ok := handlerInfo.handler.(ContextJobHandler); ok {
+		ctx := context.WithValue(msg.Context(), jobContextKey{}, newJobContext(msg, class))
+		return handler.HandleJobContext(ctx, argsInterface)
+	}
 	return handlerInfo.handler.HandleJob(argsInterface)
 }
@@ -0,0 +1,264 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type aliasTestArgs struct {
+	ID int
+}
+
+type aliasTestHandler struct {
+	calls int
+}
+
+func (h *aliasTestHandler) HandleJob(args interface{}) error {
+	h.calls++
+	return nil
+}
+
+func TestDispatchClassAlias(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ClassAliases = map[string]string{"Legacy::Mailer": "Mailer"}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	d := NewJobDispatcher()
+	handler := &aliasTestHandler{}
+	assert.NoError(t, d.RegisterHandler("Mailer", handler, &aliasTestArgs{}))
+
+	// the middlewares see the current class already
+	var classes []string
+	seen := func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		return func(message *Msg) error {
+			classes = append(classes, message.Class())
+			return next(message)
+		}
+	}
+	job := mgr.buildJob("mail", d.Dispatch, []MiddlewareFunc{seen})
+	for _, class := range []string{"Mailer", "Legacy::Mailer"} {
+		msg, err := NewMsg(`{"class":"` + class + `","jid":"1","args":[1]}`)
+		assert.NoError(t, err)
+		assert.NoError(t, job(msg))
+	}
+	assert.Equal(t, 2, handler.calls)
+	assert.Equal(t, []string{"Mailer", "Mailer"}, classes)
+
+	msg, _ := NewMsg(`{"class":"Unknown","jid":"1","args":[1]}`)
+	assert.Error(t, job(msg))
+}
+
+func TestRegisterFunc(t *testing.T) {
+	d := NewJobDispatcher()
+	var got *aliasTestArgs
+	assert.NoError(t, d.RegisterFunc("Mailer", func(args *aliasTestArgs) error {
+		got = args
+		return nil
+	}))
+	failure := errors.New("failed")
+	assert.NoError(t, d.RegisterFunc("Failing", func(args *aliasTestArgs) error {
+		return failure
+	}))
+
+	msg, _ := NewMsg(`{"class":"Mailer","jid":"1","args":[7]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	assert.Equal(t, &aliasTestArgs{ID: 7}, got)
+
+	msg, _ = NewMsg(`{"class":"Failing","jid":"1","args":[7]}`)
+	assert.Equal(t, failure, d.Dispatch(msg))
+
+	assert.Error(t, d.RegisterFunc("Bad", func(args aliasTestArgs) error { return nil }))
+	assert.Error(t, d.RegisterFunc("Bad", func(args *aliasTestArgs) {}))
+	assert.Error(t, d.RegisterFunc("Bad", nil))
+}
+
+type registerTestKey struct{}
+
+func TestRegister(t *testing.T) {
+	d := NewJobDispatcher()
+	var got aliasTestArgs
+	var value interface{}
+	assert.NoError(t, Register(d, "Mailer", func(ctx context.Context, args aliasTestArgs) error {
+		got = args
+		value = ctx.Value(registerTestKey{})
+		return nil
+	}))
+
+	msg, _ := NewMsg(`{"class":"Mailer","jid":"1","args":[7]}`)
+	msg.ctx = context.WithValue(context.Background(), registerTestKey{}, "job")
+	assert.NoError(t, d.Dispatch(msg))
+	assert.Equal(t, aliasTestArgs{ID: 7}, got)
+	assert.Equal(t, "job", value)
+
+	assert.Error(t, Register(d, "Bad", func(ctx context.Context, args *aliasTestArgs) error { return nil }))
+	assert.Error(t, Register[aliasTestArgs](d, "Bad", nil))
+}
+
+type jobContextTestHandler struct {
+	jc *JobContext
+}
+
+func (h *jobContextTestHandler) HandleJob(args interface{}) error {
+	return errors.New("HandleJobContext should be called")
+}
+
+func (h *jobContextTestHandler) HandleJobContext(ctx context.Context, args interface{}) error {
+	h.jc, _ = GetJobContext(ctx)
+	return nil
+}
+
+func TestDispatchJobContext(t *testing.T) {
+	d := NewJobDispatcher()
+	handler := &jobContextTestHandler{}
+	assert.NoError(t, d.RegisterHandler("Mailer", handler, &aliasTestArgs{}))
+
+	msg, _ := NewMsg(`{"class":"Mailer","jid":"1","queue":"mail","retry_count":2,"enqueued_at":1700000000.5,"args":[1]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	if assert.NotNil(t, handler.jc) {
+		assert.Equal(t, "1", handler.jc.Jid)
+		assert.Equal(t, "Mailer", handler.jc.Class)
+		assert.Equal(t, "mail", handler.jc.Queue)
+		assert.Equal(t, 2, handler.jc.RetryCount)
+		assert.Equal(t, time.Unix(1700000000, 500000000).UnixNano(), handler.jc.EnqueuedAt.UnixNano())
+		assert.Equal(t, msg, handler.jc.Msg)
+	}
+
+	var jid string
+	assert.NoError(t, Register(d, "Typed", func(ctx context.Context, args aliasTestArgs) error {
+		jc, _ := GetJobContext(ctx)
+		jid = jc.Jid
+		return nil
+	}))
+	msg, _ = NewMsg(`{"class":"Typed","jid":"2","args":[1]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	assert.Equal(t, "2", jid)
+}
+
+func TestDispatchUnknownClass(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	d := NewJobDispatcher()
+	msg, _ := NewMsg(`{"class":"Shipped","jid":"1","queue":"mail","args":[1]}`)
+	assert.EqualError(t, d.Dispatch(msg), "no handler registered for job class: Shipped")
+
+	d.SetUnknownClassPolicy(UnknownClassDrop)
+	assert.NoError(t, d.Dispatch(msg))
+
+	d.SetUnknownClassPolicy(UnknownClassPark)
+	assert.Equal(t, ErrNoJobProducer, d.Dispatch(msg))
+	msg.producer = newProducer(opts)
+	assert.NoError(t, d.Dispatch(msg))
+	parked, _ := opts.client.LRange(ctx, "prod:queue:"+ParkedQueue("Shipped"), 0, -1).Result()
+	if assert.Len(t, parked, 1) {
+		message, _ := NewMsg(parked[0])
+		assert.Equal(t, "1", message.Jid())
+		assert.Equal(t, "mail", message.Get("queue").MustString())
+	}
+
+	var fallback string
+	d.SetDefaultHandler(func(m *Msg) error {
+		fallback = m.Class()
+		return nil
+	})
+	assert.NoError(t, d.Dispatch(msg))
+	assert.Equal(t, "Shipped", fallback)
+}
+
+func TestDispatchHandlerMiddlewares(t *testing.T) {
+	d := NewJobDispatcher()
+	var calls []string
+	trace := func(name string) HandlerMiddlewareFunc {
+		return func(class string, next JobFunc) JobFunc {
+			return func(msg *Msg) error {
+				calls = append(calls, name+":"+class)
+				return next(msg)
+			}
+		}
+	}
+	refuse := func(class string, next JobFunc) JobFunc {
+		return func(msg *Msg) error {
+			return errors.New("refused")
+		}
+	}
+	assert.NoError(t, d.RegisterFunc("Mailer", func(args *aliasTestArgs) error {
+		calls = append(calls, "handler")
+		return nil
+	}, trace("outer"), trace("inner")))
+	assert.NoError(t, Register(d, "Refused", func(ctx context.Context, args aliasTestArgs) error {
+		calls = append(calls, "refused handler")
+		return nil
+	}, refuse))
+	assert.NoError(t, d.RegisterHandler("Plain", &aliasTestHandler{}, &aliasTestArgs{}))
+
+	msg, _ := NewMsg(`{"class":"Mailer","jid":"1","args":[1]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	msg, _ = NewMsg(`{"class":"Refused","jid":"2","args":[1]}`)
+	assert.EqualError(t, d.Dispatch(msg), "refused")
+	msg, _ = NewMsg(`{"class":"Plain","jid":"3","args":[1]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	assert.Equal(t, []string{"outer:Mailer", "inner:Mailer", "handler"}, calls)
+}
+
+func TestDispatchWithTimeout(t *testing.T) {
+	d := NewJobDispatcher()
+	release := make(chan bool)
+	defer close(release)
+	assert.NoError(t, Register(d, "Runaway", func(ctx context.Context, args aliasTestArgs) error {
+		<-release
+		return nil
+	}, WithTimeout(10*time.Millisecond)))
+	assert.NoError(t, Register(d, "Cooperative", func(ctx context.Context, args aliasTestArgs) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(10*time.Millisecond)))
+	assert.NoError(t, Register(d, "Quick", func(ctx context.Context, args aliasTestArgs) error {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return nil
+	}, WithTimeout(time.Minute)))
+
+	for _, class := range []string{"Runaway", "Cooperative"} {
+		msg, _ := NewMsg(`{"class":"` + class + `","jid":"1","args":[1]}`)
+		err := d.Dispatch(msg)
+		assert.True(t, errors.Is(err, ErrJobTimeout), class)
+	}
+	msg, _ := NewMsg(`{"class":"Quick","jid":"1","args":[1]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	_, ok := msg.Context().Deadline()
+	assert.False(t, ok)
+}
+
+func TestDispatchKeywordArgs(t *testing.T) {
+	type options struct {
+		UserID int    `json:"user_id"`
+		Email  string `json:"email"`
+		Notify bool   `json:"notify"`
+	}
+	d := NewJobDispatcher()
+	var got []options
+	assert.NoError(t, Register(d, "Welcome", func(ctx context.Context, args options) error {
+		got = append(got, args)
+		return nil
+	}))
+	d.RegisterKeywordArgs("Welcome")
+
+	msg, _ := NewMsg(`{"class":"Welcome","jid":"1","args":[{"email":"a@example.com","user_id":7,"extra":1}]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	msg, _ = NewMsg(`{"class":"Welcome","jid":"2","args":[]}`)
+	assert.NoError(t, d.Dispatch(msg))
+	assert.Equal(t, []options{{UserID: 7, Email: "a@example.com"}, {}}, got)
+
+	msg, _ = NewMsg(`{"class":"Welcome","jid":"3","args":[7,"a@example.com"]}`)
+	assert.Error(t, d.Dispatch(msg))
+	msg, _ = NewMsg(`{"class":"Welcome","jid":"4","args":["a@example.com"]}`)
+	assert.Error(t, d.Dispatch(msg))
+	assert.True(t, d.Classes()[0].KeywordArgs)
+}
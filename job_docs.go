@@ -0,0 +1,74 @@
+package workers
+
+import "reflect"
+
+// JobDoc documents a job class for the operators looking at its jobs
+type JobDoc struct {
+	Description string `json:"description,omitempty"`
+	// Team owning the job class
+	Owner string `json:"owner,omitempty"`
+	// Service level the job class is held to, such as "processed within 5 minutes"
+	SLO string `json:"slo,omitempty"`
+
+	// Positional args of the job, defaults to the fields of the args struct its handler was registered with
+	Args []JobArgDoc `json:"args,omitempty"`
+}
+
+// JobArgDoc documents a positional argument of a job
+type JobArgDoc struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// RegisterJobDoc documents the job class handled by the dispatcher
+func (d *JobDispatcher) RegisterJobDoc(class string, doc JobDoc) {
+	if d.docs == nil {
+		d.docs = map[string]JobDoc{}
+	}
+	d.docs[class] = doc
+}
+
+// JobDocs returns the documentation of the job classes registered with RegisterJobDoc
+func (d *JobDispatcher) JobDocs() map[string]JobDoc {
+	docs := make(map[string]JobDoc, len(d.docs))
+	for class, doc := range d.docs {
+		if handlerInfo, ok := d.handlers[class]; ok && len(doc.Args) == 0 {
+			doc.Args = argsDocs(handlerInfo.argsType.Elem())
+		}
+		docs[class] = doc
+	}
+	return docs
+}
+
+func argsDocs(t reflect.Type) []JobArgDoc {
+	var args []JobArgDoc
+	for _, field := range sidekiqArgsFields(t) {
+		args = append(args, JobArgDoc{Name: field.key, Type: t.Field(field.index).Type.String()})
+	}
+	return args
+}
+
+// AddJobDocs adds documentation of job classes to the manager's stats, typically the docs registered
+// with its dispatcher
+func (m *Manager) AddJobDocs(docs map[string]JobDoc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.jobDocs == nil {
+		m.jobDocs = map[string]JobDoc{}
+	}
+	for class, doc := range docs {
+		m.jobDocs[class] = doc
+	}
+}
+
+// JobDocs returns the documentation of the job classes added to the manager
+func (m *Manager) JobDocs() map[string]JobDoc {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	docs := make(map[string]JobDoc, len(m.jobDocs))
+	for class, doc := range m.jobDocs {
+		docs[class] = doc
+	}
+	return docs
+}
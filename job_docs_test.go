@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type docsTestArgs struct {
+	AccountID int `json:"account_id"`
+	Plan      string
+	Internal  bool `json:"-"`
+}
+
+func TestJobDispatcher_JobDocs(t *testing.T) {
+	d := NewJobDispatcher()
+	assert.NoError(t, d.RegisterHandler("Billing", &aliasTestHandler{}, &docsTestArgs{}))
+	d.RegisterJobDoc("Billing", JobDoc{Description: "Charges an account", Owner: "payments", SLO: "processed within 5 minutes"})
+	d.RegisterJobDoc("Report", JobDoc{Owner: "data", Args: []JobArgDoc{{Name: "day", Type: "string", Description: "YYYY-MM-DD"}}})
+
+	docs := d.JobDocs()
+	assert.Equal(t, JobDoc{
+		Description: "Charges an account",
+		Owner:       "payments",
+		SLO:         "processed within 5 minutes",
+		Args:        []JobArgDoc{{Name: "account_id", Type: "int"}, {Name: "Plan", Type: "string"}},
+	}, docs["Billing"])
+	assert.Equal(t, "YYYY-MM-DD", docs["Report"].Args[0].Description)
+}
+
+func TestManager_JobDocs(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Empty(t, stats.JobDocs)
+
+	mgr.AddJobDocs(map[string]JobDoc{"Billing": {Owner: "payments"}})
+	mgr.AddJobDocs(map[string]JobDoc{"Report": {Owner: "data"}})
+	stats, err = mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]JobDoc{"Billing": {Owner: "payments"}, "Report": {Owner: "data"}}, stats.JobDocs)
+}
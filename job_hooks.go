@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+)
+
+// BeforeJobFunc runs before every job fetched by a manager, whether or not middleware lets it run,
+// such as to open a request-scoped DB session. The context it returns is the job's Msg.Context.
+type BeforeJobFunc func(ctx context.Context, queue string, message *Msg) context.Context
+
+// AfterJobFunc runs after every job a BeforeJobFunc ran for, even when it failed or panicked, with the
+// job's context and error, such as to close a request-scoped DB session
+type AfterJobFunc func(ctx context.Context, queue string, message *Msg, err error)
+
+// AddBeforeJobHooks adds functions to be executed before every job
+func (m *Manager) AddBeforeJobHooks(hooks ...BeforeJobFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.beforeJobHooks = append(m.beforeJobHooks, hooks...)
+}
+
+// AddAfterJobHooks adds functions to be executed after every job, in the reverse order they were added
+func (m *Manager) AddAfterJobHooks(hooks ...AfterJobFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.afterJobHooks = append(m.afterJobHooks, hooks...)
+}
+
+// jobHooksJobFunc runs the job hooks of the manager around the whole pipeline of a job
+func jobHooksJobFunc(mgr *Manager, queue string, next JobFunc) JobFunc {
+	return func(message *Msg) (err error) {
+		mgr.lock.Lock()
+		before, after := mgr.beforeJobHooks, mgr.afterJobHooks
+		mgr.lock.Unlock()
+		if len(before) == 0 && len(after) == 0 {
+			return next(message)
+		}
+
+		ctx := message.Context()
+		for _, hook := range before {
+			ctx = hook(ctx, queue, message)
+		}
+		message.ctx = ctx
+		defer func() {
+			if e := recover(); e != nil {
+				// the hooks see the panic as the job's error, then it carries on to the runner
+				for i := len(after) - 1; i >= 0; i-- {
+					after[i](ctx, queue, message, fmt.Errorf("%v", e))
+				}
+				panic(e)
+			}
+			for i := len(after) - 1; i >= 0; i-- {
+				after[i](ctx, queue, message, err)
+			}
+		}()
+		return next(message)
+	}
+}
@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionKey struct{}
+
+func TestJobHooks(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ClassFilter = &ClassFilterOptions{Deny: []string{"Refused"}}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	var calls []string
+	mgr.AddBeforeJobHooks(func(ctx context.Context, queue string, message *Msg) context.Context {
+		calls = append(calls, "open "+queue+" "+message.Jid())
+		return context.WithValue(ctx, sessionKey{}, "session")
+	}, func(ctx context.Context, queue string, message *Msg) context.Context {
+		calls = append(calls, "second")
+		return ctx
+	})
+	mgr.AddAfterJobHooks(func(ctx context.Context, queue string, message *Msg, err error) {
+		calls = append(calls, "close "+ctx.Value(sessionKey{}).(string)+" "+errString(err))
+	}, func(ctx context.Context, queue string, message *Msg, err error) {
+		calls = append(calls, "first")
+	})
+
+	mgr.AddWorker("sessions", 1, func(m *Msg) error {
+		calls = append(calls, "run "+m.Context().Value(sessionKey{}).(string))
+		if m.Class() == "Panicking" {
+			panic("boom")
+		}
+		return errors.New("failed")
+	}, NopMiddleware)
+	handler := mgr.workers[0].handler
+
+	msg, _ := NewMsg(`{"jid":"1","class":"Any"}`)
+	assert.Error(t, handler(msg))
+	assert.Equal(t, []string{"open sessions 1", "second", "run session", "first", "close session failed"}, calls)
+
+	// hooks run for jobs the middleware refuses
+	calls = nil
+	msg, _ = NewMsg(`{"jid":"2","class":"Refused"}`)
+	assert.NoError(t, handler(msg))
+	assert.Equal(t, []string{"open sessions 2", "second", "first", "close session "}, calls)
+
+	calls = nil
+	msg, _ = NewMsg(`{"jid":"3","class":"Panicking"}`)
+	assert.Panics(t, func() { handler(msg) })
+	assert.Equal(t, []string{"open sessions 3", "second", "run session", "first", "close session boom"}, calls)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
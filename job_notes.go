@@ -0,0 +1,121 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// jobNotesTTL is how long notes are kept after the last one was added, like Sidekiq keeps dead jobs
+const jobNotesTTL = 180 * 24 * time.Hour
+
+// JobNote is a note attached to a retry or dead job by an operator, such as "investigating, do not retry"
+type JobNote struct {
+	Author    string  `json:"author,omitempty"`
+	Text      string  `json:"text"`
+	CreatedAt float64 `json:"created_at"`
+}
+
+// AddJobNote attaches a note to the job with the given jid. Notes are kept apart from the job's
+// payload, so they don't change the entry of the job in its set, and they follow the job when it
+// moves from the retry set to the dead set.
+func (m *Manager) AddJobNote(ctx context.Context, jid, author, text string) (JobNote, error) {
+	if jid == "" {
+		return JobNote{}, errors.New("notes require a jid")
+	}
+	if text == "" {
+		return JobNote{}, errors.New("notes require a text")
+	}
+
+	note := JobNote{Author: author, Text: text, CreatedAt: nowToSecondsWithNanoPrecision()}
+	data, err := json.Marshal(note)
+	if err != nil {
+		return JobNote{}, err
+	}
+	return note, m.opts.store.AddJobNote(ctx, jid, string(data), jobNotesTTL)
+}
+
+// JobNotes returns the notes attached to the job with the given jid, oldest first
+func (m *Manager) JobNotes(ctx context.Context, jid string) ([]JobNote, error) {
+	notes, err := m.jobNotes(ctx, []string{jid})
+	if err != nil {
+		return nil, err
+	}
+	return notes[jid], nil
+}
+
+// ClearJobNotes removes the notes attached to the job with the given jid
+func (m *Manager) ClearJobNotes(ctx context.Context, jid string) error {
+	return m.opts.store.RemoveJobNotes(ctx, jid)
+}
+
+// GetDead returns up to limit dead jobs of the manager from offset, oldest first, and how many there are
+func (m *Manager) GetDead(ctx context.Context, offset, limit int64) (Dead, error) {
+	total, err := m.opts.store.CountSetMessages(ctx, storage.DeadKey)
+	if err != nil {
+		return Dead{}, err
+	}
+	var messages []storage.ScoredMessage
+	if limit > 0 {
+		if messages, err = m.opts.store.ListSetMessagesRange(ctx, storage.DeadKey, offset, offset+limit-1); err != nil {
+			return Dead{}, err
+		}
+	}
+
+	dead := Dead{TotalDeadCount: total}
+	for _, message := range messages {
+		deadJob, err := NewMsg(message.Message)
+		if err != nil {
+			return Dead{}, err
+		}
+		dead.DeadJobs = append(dead.DeadJobs, deadJob)
+	}
+	if dead.DeadJobsDisplay, err = m.displayJobs(ctx, dead.DeadJobs); err != nil {
+		return Dead{}, err
+	}
+	return dead, nil
+}
+
+// displayJobs returns the class, args and notes of jobs as the manager shows them
+func (m *Manager) displayJobs(ctx context.Context, jobs []*Msg) ([]JobDisplay, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	jids := make([]string, len(jobs))
+	for i, job := range jobs {
+		jids[i] = job.Jid()
+	}
+	notes, err := m.jobNotes(ctx, jids)
+	if err != nil {
+		return nil, err
+	}
+
+	display := make([]JobDisplay, len(jobs))
+	for i, job := range jobs {
+		display[i] = JobDisplay{Class: job.DisplayClass(), Args: m.displayArgs(job), Notes: notes[job.Jid()]}
+	}
+	return display, nil
+}
+
+func (m *Manager) jobNotes(ctx context.Context, jids []string) (map[string][]JobNote, error) {
+	stored, err := m.opts.store.GetJobNotes(ctx, jids)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make(map[string][]JobNote, len(stored))
+	for jid, raw := range stored {
+		for _, data := range raw {
+			var note JobNote
+			if err := json.Unmarshal([]byte(data), &note); err != nil {
+				return nil, err
+			}
+			notes[jid] = append(notes[jid], note)
+		}
+	}
+	return notes, nil
+}
@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_JobNotes(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+
+	_, err = mgr.AddJobNote(ctx, "", "ops", "investigating")
+	assert.Error(t, err)
+	_, err = mgr.AddJobNote(ctx, "1", "ops", "")
+	assert.Error(t, err)
+
+	note, err := mgr.AddJobNote(ctx, "1", "ops", "investigating, do not retry")
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", note.Author)
+	assert.NotZero(t, note.CreatedAt)
+	_, err = mgr.AddJobNote(ctx, "1", "", "fixed upstream")
+	assert.NoError(t, err)
+
+	notes, err := mgr.JobNotes(ctx, "1")
+	assert.NoError(t, err)
+	assert.Len(t, notes, 2)
+	assert.Equal(t, "investigating, do not retry", notes[0].Text)
+	assert.Equal(t, "fixed upstream", notes[1].Text)
+
+	notes, err = mgr.JobNotes(ctx, "2")
+	assert.NoError(t, err)
+	assert.Empty(t, notes)
+
+	assert.NoError(t, mgr.ClearJobNotes(ctx, "1"))
+	notes, err = mgr.JobNotes(ctx, "1")
+	assert.NoError(t, err)
+	assert.Empty(t, notes)
+}
+
+func TestManager_JobNotesInListings(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, 1, `{"jid":"r1","class":"Add","args":[1]}`))
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, 2, `{"jid":"r2","class":"Add","args":[2]}`))
+	assert.NoError(t, opts.store.EnqueueDeadMessage(ctx, 1, `{"jid":"d1","class":"Add","args":[3]}`))
+
+	_, err = mgr.AddJobNote(ctx, "r2", "ops", "investigating, do not retry")
+	assert.NoError(t, err)
+	_, err = mgr.AddJobNote(ctx, "d1", "ops", "known issue")
+	assert.NoError(t, err)
+
+	retries, err := mgr.GetRetries(0, 10, "")
+	assert.NoError(t, err)
+	assert.Len(t, retries.RetryJobsDisplay, 2)
+	assert.Empty(t, retries.RetryJobsDisplay[0].Notes)
+	assert.Len(t, retries.RetryJobsDisplay[1].Notes, 1)
+	assert.Equal(t, "investigating, do not retry", retries.RetryJobsDisplay[1].Notes[0].Text)
+
+	dead, err := mgr.GetDead(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dead.TotalDeadCount)
+	assert.Equal(t, "d1", dead.DeadJobs[0].Jid())
+	assert.Equal(t, "Add", dead.DeadJobsDisplay[0].Class)
+	assert.Equal(t, "known issue", dead.DeadJobsDisplay[0].Notes[0].Text)
+}
@@ -0,0 +1,100 @@
+package workers
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// patternHandler is the handler of the classes matching a pattern
+type patternHandler struct {
+	pattern  string
+	match    func(class string) bool
+	handler  JobHandler
+	argsType reflect.Type
+	mids     []HandlerMiddlewareFunc
+}
+
+// patternHandlers are the pattern handlers of a dispatcher, with the handlers of the classes they matched
+type patternHandlers struct {
+	lock     sync.Mutex
+	handlers []*patternHandler
+	matched  map[string]*registeredHandler
+}
+
+// RegisterPattern registers a handler for the classes matching pattern, such as every class of a Ruby
+// namespace with Billing::*. Patterns are globs as matched by path.Match, or regular expressions between
+// slashes, such as /^Billing::(Invoice|Refund)Job$/. Classes with their own handler are dispatched to it,
+// others to the first pattern registered they match. The middlewares and args transforms of the job are
+// the ones of its class.
+func (d *JobDispatcher) RegisterPattern(pattern string, handler JobHandler, argsType interface{}, mids ...HandlerMiddlewareFunc) error {
+	t, err := handlerArgsType(argsType)
+	if err != nil {
+		return err
+	}
+	match, err := classMatcher(pattern)
+	if err != nil {
+		return err
+	}
+
+	d.patterns.lock.Lock()
+	defer d.patterns.lock.Unlock()
+	d.patterns.handlers = append(d.patterns.handlers, &patternHandler{
+		pattern:  pattern,
+		match:    match,
+		handler:  handler,
+		argsType: t,
+		mids:     mids,
+	})
+	// classes matched earlier may match the new pattern first
+	d.patterns.matched = nil
+	return nil
+}
+
+func classMatcher(pattern string) (func(class string) bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid class pattern %s: %v", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid class pattern %s: %v", pattern, err)
+	}
+	return func(class string) bool {
+		matched, _ := path.Match(pattern, class)
+		return matched
+	}, nil
+}
+
+// match returns the handler of class built from the first pattern it matches
+func (p *patternHandlers) match(d *JobDispatcher, class string) (*registeredHandler, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if registered, ok := p.matched[class]; ok {
+		return registered, true
+	}
+	for _, handler := range p.handlers {
+		if !handler.match(class) {
+			continue
+		}
+		if p.matched == nil {
+			p.matched = map[string]*registeredHandler{}
+		}
+		registered := d.newRegisteredHandler(class, handler.handler, handler.argsType, handler.mids)
+		p.matched[class] = registered
+		return registered, true
+	}
+	return nil, false
+}
+
+// list returns the patterns, in registration order, along with their handlers
+func (p *patternHandlers) list() []*patternHandler {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]*patternHandler(nil), p.handlers...)
+}
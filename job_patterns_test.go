@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type patternTestHandler struct {
+	classes []string
+	ids     []int
+}
+
+func (h *patternTestHandler) HandleJobContext(ctx context.Context, args interface{}) error {
+	jc, _ := GetJobContext(ctx)
+	h.classes = append(h.classes, jc.Class)
+	h.ids = append(h.ids, args.(*aliasTestArgs).ID)
+	return nil
+}
+
+func (h *patternTestHandler) HandleJob(args interface{}) error {
+	return h.HandleJobContext(context.Background(), args)
+}
+
+func TestRegisterPattern(t *testing.T) {
+	d := NewJobDispatcher()
+	billing := &patternTestHandler{}
+	refunds := &patternTestHandler{}
+	exact := &aliasTestHandler{}
+	var wrapped []string
+	mid := func(class string, next JobFunc) JobFunc {
+		return func(msg *Msg) error {
+			wrapped = append(wrapped, class)
+			return next(msg)
+		}
+	}
+	assert.NoError(t, d.RegisterPattern("/^Billing::Refund(s::.*)?Job$/", refunds, &aliasTestArgs{}))
+	assert.NoError(t, d.RegisterPattern("Billing::*", billing, &aliasTestArgs{}, mid))
+	assert.NoError(t, d.RegisterHandler("Billing::ExactJob", exact, &aliasTestArgs{}))
+
+	for i, class := range []string{"Billing::InvoiceJob", "Billing::Taxes::ReportJob", "Billing::RefundJob", "Billing::ExactJob", "Billing::InvoiceJob", "Billing::InvoiceJob"} {
+		msg, _ := NewMsg(fmt.Sprintf(`{"class":"%s","jid":"1","args":[%d]}`, class, i))
+		assert.NoError(t, d.Dispatch(msg), class)
+	}
+	assert.Equal(t, []string{"Billing::InvoiceJob", "Billing::Taxes::ReportJob", "Billing::InvoiceJob", "Billing::InvoiceJob"}, billing.classes)
+	assert.Equal(t, []int{0, 1, 4, 5}, billing.ids)
+	assert.Equal(t, billing.classes, wrapped)
+	assert.Equal(t, []string{"Billing::RefundJob"}, refunds.classes)
+	assert.Equal(t, 1, exact.calls)
+
+	msg, _ := NewMsg(`{"class":"Shipping::LabelJob","jid":"1","args":[1]}`)
+	assert.Error(t, d.Dispatch(msg))
+	assert.True(t, d.Handles("Billing::Anything"))
+	assert.Equal(t, []string{"Shipping::LabelJob"}, d.UnhandledClasses([]string{"Billing::RefundJob", "Shipping::LabelJob"}))
+
+	classes := d.Classes()
+	if assert.Len(t, classes, 3) {
+		assert.Equal(t, "Billing::ExactJob", classes[0].Class)
+		assert.False(t, classes[0].Pattern)
+		assert.Equal(t, "/^Billing::Refund(s::.*)?Job$/", classes[1].Class)
+		assert.True(t, classes[1].Pattern)
+		assert.Equal(t, "Billing::*", classes[2].Class)
+		assert.Len(t, classes[2].Middlewares, 1)
+	}
+
+	assert.Error(t, d.RegisterPattern("Billing::[", billing, &aliasTestArgs{}))
+	assert.Error(t, d.RegisterPattern("/Billing::(/", billing, &aliasTestArgs{}))
+	assert.Error(t, d.RegisterPattern("Billing::*", billing, aliasTestArgs{}))
+}
@@ -0,0 +1,80 @@
+package workers
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoJobProducer is returned by Msg.Producer for jobs which aren't run by a manager
+var ErrNoJobProducer = errors.New("job producers are only available to jobs run by a manager")
+
+// defaultPropagatedFields are the payload fields children copy from their parent, unless
+// Options.PropagatedFields is set
+var defaultPropagatedFields = []string{"tenant", "trace_id", "traceparent"}
+
+// Producer returns a producer of the children of the job, making fan-out job trees traceable: children
+// record the JID of the job as their parent_jid and the JID of the first job of the tree as their root_jid.
+// They join the batch of the job, unless they're given one, and copy its propagated fields.
+func (m *Msg) Producer() (*Producer, error) {
+	if m.producer == nil {
+		return nil, ErrNoJobProducer
+	}
+	return m.producer, nil
+}
+
+// childOf returns a copy of the producer tagging the jobs it enqueues as children of parent
+func (p *Producer) childOf(parent *Msg, fields []string) *Producer {
+	child := *p
+	child.opts.ProducerMiddlewares = p.opts.ProducerMiddlewares.Prepend(childJobMiddleware(parent, fields))
+	return &child
+}
+
+// childJobMiddleware copies the lineage, batch and propagated fields of parent to a job, leaving
+// the fields the job already has alone
+func childJobMiddleware(parent *Msg, fields []string) ProducerMiddlewareFunc {
+	return func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			if job.Extra == nil {
+				job.Extra = map[string]interface{}{}
+			}
+			set := func(key string, value interface{}) {
+				if _, ok := job.Custom[key]; ok {
+					return
+				}
+				if _, ok := job.Extra[key]; !ok {
+					job.Extra[key] = value
+				}
+			}
+
+			set("parent_jid", parent.Jid())
+			root, err := parent.Get("root_jid").String()
+			if err != nil || root == "" {
+				root = parent.Jid()
+			}
+			set("root_jid", root)
+
+			if job.Bid == "" {
+				job.Bid, _ = parent.Get("gw_bid").String()
+			}
+			for _, field := range fields {
+				if value := parent.Get(field).Interface(); value != nil {
+					set(field, value)
+				}
+			}
+			return next(ctx, job)
+		}
+	}
+}
+
+// jobProducerJobFunc gives jobs a producer of their children
+func jobProducerJobFunc(mgr *Manager, next JobFunc) JobFunc {
+	producer := mgr.Producer()
+	fields := mgr.opts.PropagatedFields
+	if fields == nil {
+		fields = defaultPropagatedFields
+	}
+	return func(message *Msg) error {
+		message.producer = producer.childOf(message, fields)
+		return next(message)
+	}
+}
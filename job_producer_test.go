@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgProducer(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	job := jobProducerJobFunc(mgr, func(m *Msg) error {
+		p, err := m.Producer()
+		if err != nil {
+			return err
+		}
+		if _, err = p.Enqueue("children", "Child", nil); err != nil {
+			return err
+		}
+		_, err = p.EnqueueWithOptions("children", "Child", nil, EnqueueOptions{
+			Custom: map[string]interface{}{"tenant": "other"},
+		})
+		return err
+	})
+
+	parent, _ := NewMsg(`{"jid":"parent","root_jid":"root","gw_bid":"b1","tenant":"acme","trace_id":"t1","class":"Parent"}`)
+	assert.NoError(t, job(parent))
+
+	messages, err := opts.client.LRange(ctx, "prod:queue:children", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	child, _ := NewMsg(messages[1])
+	for field, value := range map[string]string{
+		"parent_jid": "parent", "root_jid": "root", "gw_bid": "b1", "tenant": "acme", "trace_id": "t1",
+	} {
+		got, _ := child.Get(field).String()
+		assert.Equal(t, value, got, field)
+	}
+
+	child, _ = NewMsg(messages[0])
+	tenant, _ := child.Get("tenant").String()
+	assert.Equal(t, "other", tenant)
+}
+
+func TestMsgProducerRootJid(t *testing.T) {
+	parent, _ := NewMsg(`{"jid":"parent"}`)
+	job := &EnqueueData{Jid: "child"}
+	next := func(ctx context.Context, job *EnqueueData) error { return nil }
+
+	err := childJobMiddleware(parent, nil)(next)(context.Background(), job)
+	assert.NoError(t, err)
+	assert.Equal(t, "parent", job.Extra["parent_jid"])
+	assert.Equal(t, "parent", job.Extra["root_jid"])
+	assert.Empty(t, job.Bid)
+}
+
+func TestMsgProducerOutsideManager(t *testing.T) {
+	msg, _ := NewMsg(`{"jid":"1"}`)
+	_, err := msg.Producer()
+	assert.Equal(t, ErrNoJobProducer, err)
+}
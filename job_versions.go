@@ -0,0 +1,79 @@
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionedClass returns the name of a version of a job class, such as SendEmailJob.v2. Version 0 is the
+// unversioned class.
+func VersionedClass(class string, version int) string {
+	if version <= 0 {
+		return class
+	}
+	return class + ".v" + strconv.Itoa(version)
+}
+
+// ParseClassVersion splits a class name built by VersionedClass into the class and its version, 0 for
+// unversioned classes
+func ParseClassVersion(versioned string) (string, int) {
+	i := strings.LastIndex(versioned, ".v")
+	if i <= 0 {
+		return versioned, 0
+	}
+	version, err := strconv.Atoi(versioned[i+2:])
+	if err != nil || version <= 0 || strconv.Itoa(version) != versioned[i+2:] {
+		return versioned, 0
+	}
+	return versioned[:i], version
+}
+
+// EnqueueVersion enqueues new work for immediate processing by the given version of class
+func (p *Producer) EnqueueVersion(queue, class string, version int, args interface{}) (string, error) {
+	return p.Enqueue(queue, VersionedClass(class, version), args)
+}
+
+// versionRoute sends the jobs of a version of a class to the handler of another version
+type versionRoute struct {
+	class      string
+	transforms []ArgsTransformFunc
+}
+
+// RouteClassVersion dispatches the jobs of version from of class to the handler of version to, upgrading
+// their args with transforms first, so payloads of a retired version keep working. The handler and its
+// middlewares see the job as a job of version to, while retries keep the job as it was enqueued.
+func (d *JobDispatcher) RouteClassVersion(class string, from, to int, transforms ...ArgsTransformFunc) {
+	if d.versionRoutes == nil {
+		d.versionRoutes = map[string]versionRoute{}
+	}
+	d.versionRoutes[VersionedClass(class, from)] = versionRoute{class: VersionedClass(class, to), transforms: transforms}
+}
+
+// routeVersion returns a copy of msg upgraded to the version its class is routed to
+func (d *JobDispatcher) routeVersion(msg *Msg, route versionRoute) (*Msg, error) {
+	parsed, err := newData(msg.ToJson())
+	if err != nil {
+		return nil, err
+	}
+	routed := *msg
+	routed.data = parsed
+	routed.Set("class", route.class)
+
+	if len(route.transforms) > 0 {
+		raw, err := routed.Args().MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode job args for class %s: %v", msg.Class(), err)
+		}
+		if raw, err = applyArgsTransforms(raw, route.transforms); err != nil {
+			return nil, fmt.Errorf("failed to upgrade job args of class %s to %s: %v", msg.Class(), route.class, err)
+		}
+		var args interface{}
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, fmt.Errorf("failed to parse upgraded job args of class %s: %v", msg.Class(), err)
+		}
+		routed.Set("args", args)
+	}
+	return &routed, nil
+}
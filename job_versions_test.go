@@ -0,0 +1,77 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClassVersion(t *testing.T) {
+	assert.Equal(t, "SendEmailJob.v2", VersionedClass("SendEmailJob", 2))
+	assert.Equal(t, "SendEmailJob", VersionedClass("SendEmailJob", 0))
+
+	for versioned, expected := range map[string]struct {
+		class   string
+		version int
+	}{
+		"SendEmailJob.v2":    {"SendEmailJob", 2},
+		"SendEmailJob.v12":   {"SendEmailJob", 12},
+		"SendEmailJob":       {"SendEmailJob", 0},
+		"SendEmailJob.v":     {"SendEmailJob.v", 0},
+		"SendEmailJob.v02":   {"SendEmailJob.v02", 0},
+		"SendEmailJob.vault": {"SendEmailJob.vault", 0},
+		".v2":                {".v2", 0},
+	} {
+		class, version := ParseClassVersion(versioned)
+		assert.Equal(t, expected.class, class, versioned)
+		assert.Equal(t, expected.version, version, versioned)
+	}
+}
+
+type versionTestArgs struct {
+	Email string
+	Name  string
+}
+
+func TestRouteClassVersion(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	p := newProducer(opts)
+	_, err = p.EnqueueVersion("mail", "SendEmailJob", 2, []string{"a@example.com"})
+	assert.NoError(t, err)
+	payload, _ := opts.client.RPop(ctx, "prod:queue:mail").Result()
+	msg, _ := NewMsg(payload)
+	assert.Equal(t, "SendEmailJob.v2", msg.Class())
+
+	d := NewJobDispatcher()
+	var got []versionTestArgs
+	var classes []string
+	assert.NoError(t, Register(d, VersionedClass("SendEmailJob", 3), func(ctx context.Context, args versionTestArgs) error {
+		got = append(got, args)
+		jc, _ := GetJobContext(ctx)
+		classes = append(classes, jc.Class)
+		return nil
+	}))
+	// version 3 added the name of the recipient
+	d.RouteClassVersion("SendEmailJob", 2, 3, func(raw []byte) ([]byte, error) {
+		var args []interface{}
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, err
+		}
+		return json.Marshal(append(args, "customer"))
+	})
+
+	assert.NoError(t, d.Dispatch(msg))
+	current, _ := NewMsg(`{"class":"SendEmailJob.v3","jid":"2","args":["b@example.com","B"]}`)
+	assert.NoError(t, d.Dispatch(current))
+	assert.Equal(t, []versionTestArgs{{Email: "a@example.com", Name: "customer"}, {Email: "b@example.com", Name: "B"}}, got)
+	assert.Equal(t, []string{"SendEmailJob.v3", "SendEmailJob.v3"}, classes)
+
+	// the routed job itself is left as it was enqueued
+	assert.Equal(t, "SendEmailJob.v2", msg.Class())
+	assert.Len(t, msg.Args().MustArray(), 1)
+}
@@ -0,0 +1,158 @@
+package workers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKillSwitchCacheTTL     = 5 * time.Second
+	defaultKillSwitchRequeueDelay = time.Minute
+)
+
+// KillSwitchOptions configures how a manager holds back the jobs of the classes disabled across the fleet
+// by DisableClass, such as a buggy class during an incident. Held back jobs are counted in the "disabled" stat.
+type KillSwitchOptions struct {
+	// Optional lifetime of the cached set of disabled classes, defaults to 5s
+	CacheTTL time.Duration
+
+	// Whether the jobs of disabled classes are parked in their ParkedQueue until EnableClass moves them
+	// back, instead of scheduled to be checked again after RequeueDelay
+	Park bool
+	// Optional delay before a held back job is checked again, defaults to a minute
+	RequeueDelay time.Duration
+}
+
+func (o KillSwitchOptions) withDefaults() KillSwitchOptions {
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = defaultKillSwitchCacheTTL
+	}
+	if o.RequeueDelay <= 0 {
+		o.RequeueDelay = defaultKillSwitchRequeueDelay
+	}
+	return o
+}
+
+// ParkedQueue returns the queue the jobs of a disabled class are parked in, which no worker fetches
+func ParkedQueue(class string) string {
+	return "parked:" + class
+}
+
+// killSwitch caches the disabled classes for CacheTTL
+type killSwitch struct {
+	lock      sync.Mutex
+	disabled  map[string]bool
+	fetchedAt time.Time
+}
+
+func (k *killSwitch) isDisabled(ctx context.Context, mgr *Manager, ttl time.Duration, class string) (bool, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.disabled == nil || time.Since(k.fetchedAt) > ttl {
+		classes, err := mgr.opts.store.GetDisabledClasses(ctx)
+		if err != nil {
+			return false, err
+		}
+		k.disabled = map[string]bool{}
+		for _, c := range classes {
+			k.disabled[c] = true
+		}
+		k.fetchedAt = time.Now()
+	}
+	return k.disabled[class], nil
+}
+
+func (k *killSwitch) invalidate() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.disabled = nil
+}
+
+// DisableClass stops every manager with a KillSwitch from running the jobs of class, once their cache
+// of the disabled classes expires
+func (m *Manager) DisableClass(ctx context.Context, class string) error {
+	if err := m.opts.store.DisableClass(ctx, class); err != nil {
+		return err
+	}
+	m.killSwitch.invalidate()
+	return nil
+}
+
+// EnableClass lets the jobs of class run again, moving its parked jobs back to their queue. It returns
+// the number of jobs moved.
+func (m *Manager) EnableClass(ctx context.Context, class string) (int, error) {
+	if err := m.opts.store.EnableClass(ctx, class); err != nil {
+		return 0, err
+	}
+	m.killSwitch.invalidate()
+
+	parked, err := m.opts.store.ListMessages(ctx, ParkedQueue(class))
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	// the oldest parked jobs are at the end
+	for i := len(parked) - 1; i >= 0; i-- {
+		message, err := NewMsg(parked[i])
+		if err != nil {
+			m.logger.Println("ERR: couldn't read parked job of", class, ":", err)
+			continue
+		}
+		// retried jobs carry the namespaced queue
+		queue, _ := message.Get("queue").String()
+		queue = strings.TrimPrefix(queue, m.opts.Namespace)
+		// the job is written to its queue before it leaves the parked queue, so it isn't lost
+		if err := m.opts.store.EnqueueMessageNow(ctx, queue, m.opts.renamePayload(parked[i])); err != nil {
+			return moved, err
+		}
+		if err := m.opts.store.AcknowledgeMessage(ctx, ParkedQueue(class), parked[i]); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// DisabledClasses returns the classes disabled by DisableClass
+func (m *Manager) DisabledClasses(ctx context.Context) ([]string, error) {
+	return m.opts.store.GetDisabledClasses(ctx)
+}
+
+// killSwitchMiddleware parks or schedules again the jobs of disabled classes. Jobs run when the disabled
+// classes can't be read.
+func killSwitchMiddleware(opts KillSwitchOptions) MiddlewareFunc {
+	opts = opts.withDefaults()
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		queue = strings.TrimPrefix(queue, mgr.opts.Namespace)
+		return func(message *Msg) error {
+			ctx := context.Background()
+			disabled, err := mgr.killSwitch.isDisabled(ctx, mgr, opts.CacheTTL, message.Class())
+			if err != nil {
+				mgr.logger.Println("ERR: couldn't read the disabled classes:", err)
+				return next(message)
+			}
+			if !disabled {
+				return next(message)
+			}
+
+			if opts.Park {
+				if q, _ := message.Get("queue").String(); q == "" {
+					message.Set("queue", queue)
+				}
+				err = mgr.opts.store.EnqueueMessageNow(ctx, ParkedQueue(message.Class()), message.ToJson())
+			} else {
+				at := timeToSecondsWithNanoPrecision(time.Now().Add(opts.RequeueDelay))
+				err = mgr.opts.store.EnqueueScheduledMessage(ctx, at, message.ToJson())
+			}
+			if err != nil {
+				// keep the job in the in-progress queue rather than losing it
+				message.ack = false
+				return err
+			}
+			incrementStats(mgr, "disabled")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKillSwitchPark(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.KillSwitch = &KillSwitchOptions{Park: true}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	ran := 0
+	job := mgr.buildJob("switched", func(m *Msg) error {
+		ran++
+		return nil
+	}, []MiddlewareFunc{NopMiddleware})
+
+	message, _ := NewMsg(`{"jid":"1","class":"Buggy","queue":"switched"}`)
+	assert.NoError(t, job(message))
+	assert.Equal(t, 1, ran)
+
+	// another manager disables the class, this one sees it once its cache expires
+	other, err := newManager(opts)
+	assert.NoError(t, err)
+	assert.NoError(t, other.DisableClass(ctx, "Buggy"))
+	classes, err := mgr.DisabledClasses(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Buggy"}, classes)
+	mgr.killSwitch.invalidate()
+
+	// a retried job, carrying its namespaced queue
+	message, _ = NewMsg(`{"jid":"2","class":"Buggy","queue":"prod:switched","retry_count":0}`)
+	assert.NoError(t, job(message))
+	fine, _ := NewMsg(`{"jid":"3","class":"Fine","queue":"switched"}`)
+	assert.NoError(t, job(fine))
+	assert.Equal(t, 2, ran)
+	assert.True(t, message.ack)
+	assert.Equal(t, int64(1), opts.client.LLen(ctx, "prod:queue:"+ParkedQueue("Buggy")).Val())
+	held, _ := opts.client.Get(ctx, "prod:stat:disabled").Int()
+	assert.Equal(t, 1, held)
+
+	moved, err := mgr.EnableClass(ctx, "Buggy")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, int64(0), opts.client.LLen(ctx, "prod:queue:"+ParkedQueue("Buggy")).Val())
+	requeued, _ := opts.client.LPop(ctx, "prod:queue:switched").Result()
+	message, _ = NewMsg(requeued)
+	assert.Equal(t, "2", message.Jid())
+
+	assert.NoError(t, job(message))
+	assert.Equal(t, 3, ran)
+}
+
+func TestKillSwitchRequeue(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.KillSwitch = &KillSwitchOptions{RequeueDelay: time.Hour}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	assert.NoError(t, mgr.DisableClass(ctx, "Buggy"))
+
+	job := mgr.buildJob("switched", func(m *Msg) error {
+		t.Fatal("disabled job ran")
+		return nil
+	}, []MiddlewareFunc{NopMiddleware})
+	message, _ := NewMsg(`{"jid":"1","class":"Buggy"}`)
+	assert.NoError(t, job(message))
+
+	scheduled, _ := opts.client.ZRangeWithScores(ctx, "prod:"+storage.ScheduledJobsKey, 0, -1).Result()
+	if assert.Len(t, scheduled, 1) {
+		assert.InDelta(t, float64(time.Now().Add(time.Hour).Unix()), scheduled[0].Score, 5)
+	}
+}
@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultLockJanitorInterval = time.Minute
+	defaultLockJanitorGrace    = time.Minute
+)
+
+// LockJanitorOptions configures the release of the unique and dedupe locks left behind by producers
+// which died between taking the lock of a job and writing it, which would otherwise keep identical
+// jobs out until the lock expires
+type LockJanitorOptions struct {
+	// Optional interval between sweeps, defaults to a minute
+	Interval time.Duration
+	// Optional time a producer has to write the job of a lock before it is deemed dead, defaults to a minute
+	Grace time.Duration
+}
+
+func (o LockJanitorOptions) withDefaults() LockJanitorOptions {
+	if o.Interval <= 0 {
+		o.Interval = defaultLockJanitorInterval
+	}
+	if o.Grace <= 0 {
+		o.Grace = defaultLockJanitorGrace
+	}
+	return o
+}
+
+// ReleaseStaleLocks frees the locks whose job wasn't written within grace of taking them, and returns
+// their digests. Released locks are counted in the "stale_locks" stat.
+func (m *Manager) ReleaseStaleLocks(ctx context.Context, grace time.Duration) ([]string, error) {
+	digests, err := m.opts.store.ReleaseStaleUniqueLocks(ctx, time.Now().Add(-grace))
+	if err != nil {
+		return nil, err
+	}
+	for _, digest := range digests {
+		m.logger.Println("released stale lock", digest)
+		incrementStats(m, "stale_locks")
+	}
+	return digests, nil
+}
+
+// runLockJanitor releases stale locks every interval until ctx is done
+func (m *Manager) runLockJanitor(ctx context.Context, opts LockJanitorOptions) {
+	opts = opts.withDefaults()
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.IsActive() {
+				continue
+			}
+			if _, err := m.ReleaseStaleLocks(ctx, opts.Grace); err != nil && ctx.Err() == nil {
+				m.logger.Println("ERR: couldn't release stale locks:", err)
+			}
+		}
+	}
+}
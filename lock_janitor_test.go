@@ -0,0 +1,58 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_ReleaseStaleLocks(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	rc := opts.client
+	unique := EnqueueOptions{UniqueFor: 10 * time.Minute}
+
+	// the lock of a written job is confirmed and kept
+	_, err = mgr.Producer().EnqueueWithOptions("janitor", "Add", []int{1}, unique)
+	assert.NoError(t, err)
+
+	// a producer died between locking a job and writing it
+	dead, err := uniqueDigest(&EnqueueData{Queue: "janitor", Class: "Add", Args: []int{2}})
+	assert.NoError(t, err)
+	acquired, err := opts.store.AcquireUniqueJobLock(ctx, dead, "deadjid", 10*time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	_, err = mgr.Producer().EnqueueWithOptions("janitor", "Add", []int{2}, unique)
+	assert.Equal(t, ErrDuplicateJob, err)
+
+	// locks within their grace period may still be written
+	released, err := mgr.ReleaseStaleLocks(ctx, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, released)
+
+	rc.ZAdd(ctx, "prod:unique-pending", &redis.Z{Member: dead, Score: float64(time.Now().Add(-2 * time.Minute).Unix())})
+	released, err = mgr.ReleaseStaleLocks(ctx, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{dead}, released)
+	assert.Zero(t, rc.Exists(ctx, "prod:"+dead+":LOCKED").Val())
+	assert.Zero(t, rc.ZScore(ctx, "prod:uniquejobs:digests", dead).Val())
+
+	stats, err := rc.Get(ctx, "prod:stat:stale_locks").Int()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats)
+
+	_, err = mgr.Producer().EnqueueWithOptions("janitor", "Add", []int{2}, unique)
+	assert.NoError(t, err)
+	_, err = mgr.Producer().EnqueueWithOptions("janitor", "Add", []int{1}, unique)
+	assert.Equal(t, ErrDuplicateJob, err)
+
+	pending, err := rc.ZCard(ctx, "prod:unique-pending").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+}
@@ -0,0 +1,127 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ErrManagementDenied is returned for the management actions Options.Authorize denied
+var ErrManagementDenied = errors.New("management action denied")
+
+// ManagementAction is a mutating operation of the API or of gwctl on a manager, such as a queue purge
+type ManagementAction struct {
+	// Actor is who asked for the action, as set on its context by WithActor
+	Actor string `json:"actor"`
+	// Action is one of "pause", "resume", "quiet", "concurrency", "import", "add_note", "clear_notes",
+	// "requeue" or "purge"
+	Action string `json:"action"`
+	// Target is the queue, job set or job acted on, empty for actions on the whole manager
+	Target string `json:"target,omitempty"`
+}
+
+// AuthorizeFunc allows a management action by returning nil, and denies it with an error
+type AuthorizeFunc func(ctx context.Context, action ManagementAction) error
+
+// AuditRecord is a management action as it was authorized and applied
+type AuditRecord struct {
+	ManagementAction
+	Manager string    `json:"manager_name"`
+	At      time.Time `json:"at"`
+	// Whether Options.Authorize denied the action, which then wasn't applied
+	Denied bool `json:"denied"`
+	// Error of the denial or of the action, empty when it succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSinkFunc records the management actions of a manager, such as to a log or an audit service
+type AuditSinkFunc func(record AuditRecord)
+
+// LogAuditSink records management actions to logger
+func LogAuditSink(logger *log.Logger) AuditSinkFunc {
+	return func(record AuditRecord) {
+		outcome := "applied"
+		if record.Denied {
+			outcome = "denied"
+		} else if record.Error != "" {
+			outcome = "failed"
+		}
+		logger.Printf("audit: %s %s %s of %q by %q %s", record.At.Format(time.RFC3339), outcome, record.Action, record.Target, record.Actor, record.Error)
+	}
+}
+
+type actorContextKey struct{}
+
+// WithActor returns a context attributing the management actions run with it to actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor set on ctx by WithActor, empty if none was
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// Manage runs apply once Options.Authorize allowed the action on target by the actor of ctx, and records
+// the outcome to Options.AuditSink. Denied actions return an error wrapping ErrManagementDenied.
+func (m *Manager) Manage(ctx context.Context, action, target string, apply func() error) error {
+	record := AuditRecord{
+		ManagementAction: ManagementAction{Actor: ActorFromContext(ctx), Action: action, Target: target},
+		Manager:          m.opts.ManagerDisplayName,
+		At:               time.Now(),
+	}
+
+	var err error
+	if m.opts.Authorize != nil {
+		if err = m.opts.Authorize(ctx, record.ManagementAction); err != nil {
+			record.Denied = true
+			err = fmt.Errorf("%w: %v", ErrManagementDenied, err)
+		}
+	}
+	if err == nil {
+		err = apply()
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if m.opts.AuditSink != nil {
+		m.opts.AuditSink(record)
+	}
+	return err
+}
+
+// ActorHeader is the request header the API reads the actor of management actions from by default
+const ActorHeader = "X-Go-Workers-Actor"
+
+// requestActor is the actor of an API request when APIOptions.Actor isn't set: the ActorHeader, the user of
+// basic authentication or the remote address, in that order, which callers can claim freely
+func requestActor(req *http.Request) string {
+	if actor := req.Header.Get(ActorHeader); actor != "" {
+		return actor
+	}
+	if user, _, ok := req.BasicAuth(); ok && user != "" {
+		return user
+	}
+	return req.RemoteAddr
+}
+
+// requestContext returns the context of an API request, carrying its actor
+func (s *apiServer) requestContext(req *http.Request) context.Context {
+	actor := s.actor
+	if actor == nil {
+		actor = requestActor
+	}
+	return WithActor(req.Context(), actor(req))
+}
+
+// manageError writes the error of a management action, forbidden when it was denied
+func manageError(w http.ResponseWriter, err error, status int) {
+	if errors.Is(err, ErrManagementDenied) {
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,112 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Manage(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	var records []AuditRecord
+	opts.ManagerDisplayName = "billing"
+	opts.Authorize = func(ctx context.Context, action ManagementAction) error {
+		if action.Action == "purge" && action.Actor != "admin" {
+			return errors.New("purges are for admins")
+		}
+		return nil
+	}
+	opts.AuditSink = func(record AuditRecord) { records = append(records, record) }
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	applied := 0
+	apply := func() error {
+		applied++
+		return nil
+	}
+	ctx := WithActor(context.Background(), "alice")
+	err = mgr.Manage(ctx, "purge", "mailers", apply)
+	assert.True(t, errors.Is(err, ErrManagementDenied))
+	assert.Equal(t, 0, applied)
+	assert.NoError(t, mgr.Manage(WithActor(ctx, "admin"), "purge", "mailers", apply))
+	assert.Equal(t, 1, applied)
+	failure := errors.New("no such job")
+	assert.Equal(t, failure, mgr.Manage(ctx, "requeue", "dead/1", func() error { return failure }))
+
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, ManagementAction{Actor: "alice", Action: "purge", Target: "mailers"}, records[0].ManagementAction)
+		assert.True(t, records[0].Denied)
+		assert.Contains(t, records[0].Error, "purges are for admins")
+		assert.Equal(t, "billing", records[0].Manager)
+		assert.False(t, records[0].At.IsZero())
+
+		assert.Equal(t, "admin", records[1].Actor)
+		assert.False(t, records[1].Denied)
+		assert.Empty(t, records[1].Error)
+
+		assert.False(t, records[2].Denied)
+		assert.Equal(t, "no such job", records[2].Error)
+	}
+}
+
+func TestManagementAPIAuthorization(t *testing.T) {
+	a := &apiServer{
+		logger: log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds),
+	}
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	var records []AuditRecord
+	opts.Authorize = func(ctx context.Context, action ManagementAction) error {
+		if action.Actor != "ops" {
+			return errors.New("read-only")
+		}
+		return nil
+	}
+	opts.AuditSink = func(record AuditRecord) { records = append(records, record) }
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	a.registerManager(mgr)
+
+	post := func(actor, body string) int {
+		req := httptest.NewRequest("POST", "/control", strings.NewReader(body))
+		if actor != "" {
+			req.Header.Set(ActorHeader, actor)
+		}
+		recorder := httptest.NewRecorder()
+		a.Control(recorder, req)
+		return recorder.Code
+	}
+	assert.Equal(t, http.StatusForbidden, post("", `{"action":"pause"}`))
+	assert.False(t, mgr.IsPaused())
+	assert.Equal(t, http.StatusOK, post("ops", `{"action":"pause"}`))
+	assert.True(t, mgr.IsPaused())
+	assert.Equal(t, http.StatusBadRequest, post("ops", `{"action":"restart"}`))
+
+	req := httptest.NewRequest("POST", "/notes?jid=1", strings.NewReader(`{"text":"looking"}`))
+	req.SetBasicAuth("bob", "secret")
+	recorder := httptest.NewRecorder()
+	a.Notes(recorder, req)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	if assert.Len(t, records, 4) {
+		assert.Equal(t, "192.0.2.1:1234", records[0].Actor)
+		assert.True(t, records[0].Denied)
+		assert.Equal(t, ManagementAction{Actor: "ops", Action: "pause"}, records[1].ManagementAction)
+		assert.Equal(t, "restart", records[2].Action)
+		assert.Equal(t, "unknown action restart", records[2].Error)
+		assert.Equal(t, ManagementAction{Actor: "bob", Action: "add_note", Target: "1"}, records[3].ManagementAction)
+	}
+
+	a.actor = func(req *http.Request) string { return "ops" }
+	assert.Equal(t, http.StatusOK, post("", `{"action":"resume"}`))
+	assert.False(t, mgr.IsPaused())
+}
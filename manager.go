@@ -25,18 +25,65 @@ type Manager struct {
 	signal           chan os.Signal
 	running          bool
 	active           bool
+	paused           bool
+	quiet            bool
 	logger           *log.Logger
 	startedAt        time.Time
 	processNonce     string
 	heartbeatChannel chan bool
+	// startWorker starts the workers added while the manager runs, nil otherwise
+	startWorker      func(w *worker)
+	lastHeartbeat    time.Time
+	cancel           context.CancelFunc
+	drainStartedAt   time.Time
+	shutdownReport   *ShutdownReport
+	middlewareTimers map[string]middlewareTimers
+	failures         *failureSampler
+	allocations      *allocationProfiler
+	schedulerLag     *schedulerLag
+	cronJobs         []*cronEntry
+	jobDocs          map[string]JobDoc
+	jobClasses       map[string]HandlerInfo
+
+	deadLetterConsumers []deadLetterConsumer
+	migrations          []*queueMigration
 
 	beforeStartHooks       []func()
 	duringDrainHooks       []func()
 	afterActiveChangeHooks []AfterActiveChangeFunc
+	afterShutdownHooks     []AfterShutdownFunc
+	beforeJobHooks         []BeforeJobFunc
+	afterJobHooks          []AfterJobFunc
 
 	afterHeartbeatHooks []afterHeartbeatFunc
 
+	logRotationHooks []func()
+
 	retriesExhaustedHandlers []RetriesExhaustedFunc
+
+	cancellations jobCancellations
+
+	// the producer of the manager, built on first use so that its rate limits, stats, retries and async
+	// buffer are shared by every caller
+	producerOnce sync.Once
+	producer     *Producer
+
+	// acknowledgements and retries Redis refused, nil without Options.WriteBuffer
+	writes *writeBuffer
+
+	// cache of the classes disabled by DisableClass
+	killSwitch *killSwitch
+
+	// archiver of the records of the jobs run, nil without Options.Archive
+	archiver *archiver
+
+	// subscribers of every event type, see Subscribe
+	subscribers map[EventType][]func(Event)
+	eventsLock  sync.RWMutex
+
+	// errors receives the errors of a manager run by RunWithErrors
+	errors     chan error
+	errorsLock sync.Mutex
 }
 
 type staleMessageUpdate struct {
@@ -66,7 +113,10 @@ func (m *Manager) GetRedisClient() *redis.Client {
 	return m.opts.client
 }
 
-// NewManagerWithRedisClient creates a new manager with provide options and pre-configured Redis client
+// NewManagerWithRedisClient creates a new manager with provide options and pre-configured Redis client.
+// Managers and producers given the same client share its connection pool, each keeping its keys under
+// its own Namespace. The pool must hold a connection for the blocking fetch of every worker of every
+// manager using it, unless they have their own FetchPoolSize.
 func NewManagerWithRedisClient(options Options, client *redis.Client) (*Manager, error) {
 	options, err := processOptionsWithRedisClient(options, client)
 	if err != nil {
@@ -86,11 +136,19 @@ func newManager(processedOptions Options) (*Manager, error) {
 		logger:       processedOptions.Logger,
 		opts:         processedOptions,
 		processNonce: processNonce,
-		active:       !processedOptions.ManagerStartInactive,
+		active:       !processedOptions.ManagerStartInactive && processedOptions.Standby == nil,
+		failures:     newFailureSampler(processedOptions.FailureSample),
+		allocations:  newAllocationProfiler(processedOptions.AllocationProfile),
+		schedulerLag: &schedulerLag{},
+		writes:       newWriteBuffer(processedOptions.WriteBuffer),
+		killSwitch:   &killSwitch{},
 	}
 	if processedOptions.Heartbeat != nil && processedOptions.Heartbeat.PrioritizedManager != nil {
 		manager.addAfterHeartbeatHooks(activateManagerByPriority)
 	}
+	if processedOptions.Archive != nil {
+		manager.archiver = newArchiver(manager, *processedOptions.Archive)
+	}
 	return manager, nil
 }
 
@@ -99,13 +157,117 @@ func (m *Manager) AddWorker(queue string, concurrency int, job JobFunc, mids ...
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
+	w := newWorker(m.logger, queue, concurrency, m.buildJob(queue, job, mids))
+	m.addWorkerLocked(w)
+}
+
+// AddWorkerForQueues adds a worker whose runners share the jobs of several queues. Every fetch tries
+// the queues in a random order, a queue having twice the weight of another coming first twice as often.
+// The worker is known by the comma-separated names of its queues by descending weight.
+func (m *Manager) AddWorkerForQueues(queues map[string]int, concurrency int, job JobFunc, mids ...MiddlewareFunc) {
+	m.addWorkerForQueues(queues, false, concurrency, job, mids)
+}
+
+// AddStrictWorkerForQueues adds a worker like AddWorkerForQueues, which only runs the jobs of a queue
+// once every heavier queue is empty
+func (m *Manager) AddStrictWorkerForQueues(queues map[string]int, concurrency int, job JobFunc, mids ...MiddlewareFunc) {
+	m.addWorkerForQueues(queues, true, concurrency, job, mids)
+}
+
+func (m *Manager) addWorkerForQueues(queues map[string]int, strict bool, concurrency int, job JobFunc, mids []MiddlewareFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	sorted := sortWeightedQueues(queues)
+	if len(sorted) == 0 {
+		return
+	}
+	// every queue gets its own middleware chain, so stats and retries are kept by queue
+	jobs := map[string]JobFunc{}
+	for _, q := range sorted {
+		jobs[q.name] = m.buildJob(q.name, job, mids)
+	}
+	w := newWorker(m.logger, poolName(sorted), concurrency, func(message *Msg) error {
+		queueJob, ok := jobs[message.fetchedFrom]
+		if !ok {
+			return fmt.Errorf("no worker processes queue %s", message.fetchedFrom)
+		}
+		return queueJob(message)
+	})
+	w.queues = sorted
+	w.strictQueues = strict
+	m.addWorkerLocked(w)
+}
+
+// buildJob wraps the job of queue in its middlewares
+func (m *Manager) buildJob(queue string, job JobFunc, mids []MiddlewareFunc) JobFunc {
 	middlewareQueueName := m.opts.Namespace + queue
+	middlewares := NewMiddlewares(mids...)
 	if len(mids) == 0 {
-		job = DefaultMiddlewares().build(middlewareQueueName, m, job)
-	} else {
-		job = NewMiddlewares(mids...).build(middlewareQueueName, m, job)
+		middlewares = DefaultMiddlewares()
+	}
+	middlewares = middlewares.Prepend(expiryMiddleware)
+	if m.opts.ClassFilter != nil {
+		// refused jobs never reach the rest of the pipeline
+		middlewares = middlewares.Prepend(classFilterMiddleware(*m.opts.ClassFilter))
+	}
+	if m.opts.KillSwitch != nil {
+		middlewares = middlewares.Prepend(killSwitchMiddleware(*m.opts.KillSwitch))
+	}
+	// batches record the outcome of the handler itself, before retries handle failures
+	job = middlewares.build(middlewareQueueName, m, uniqueJobFunc(m, batchJobFunc(m, codecJobFunc(m, queue, decompressionJobFunc(checkpointJobFunc(m, cancellationJobFunc(m, jobProducerJobFunc(m, allocationJobFunc(m, eventsJobFunc(m, queue, job))))))))))
+	// hooks run even for the jobs the middlewares refuse
+	return classAliasJobFunc(m.opts, jobHooksJobFunc(m, queue, serializerJobFunc(m.opts.QueueSerializers, job)))
+}
+
+func (m *Manager) addWorkerLocked(w *worker) {
+	w.shutdownTimeout = m.opts.ShutdownTimeout
+	w.warmUp = m.opts.WarmUp
+	if warmUp, ok := m.opts.QueueWarmUp[w.queue]; ok {
+		w.warmUp = warmUp
+	}
+	m.workers = append(m.workers, w)
+	if m.startWorker != nil {
+		m.startWorker(w)
+	}
+}
+
+// RemoveWorker stops the worker of queue, or of the queues of the pool named queue, and removes it
+// from the manager. While the manager runs, it returns once the worker's jobs are done or its
+// ShutdownTimeout passed.
+func (m *Manager) RemoveWorker(queue string) error {
+	m.lock.Lock()
+	var removed *worker
+	for i, w := range m.workers {
+		if w.queue == queue {
+			removed = w
+			// copied, as the old slice may still be read by other goroutines
+			m.workers = append(m.workers[:i:i], m.workers[i+1:]...)
+			break
+		}
+	}
+	m.lock.Unlock()
+	if removed == nil {
+		return fmt.Errorf("no worker processes queue %s", queue)
+	}
+
+	removed.remove()
+	m.requeueWorkerAbandoned(removed)
+	return nil
+}
+
+// SetConcurrency changes the number of jobs of queue processed at once, without restarting the manager.
+// Lowering it lets the stopped runners finish their current job.
+func (m *Manager) SetConcurrency(queue string, concurrency int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, w := range m.workers {
+		if w.queue == queue {
+			w.setConcurrency(concurrency)
+			return nil
+		}
 	}
-	m.workers = append(m.workers, newWorker(m.logger, queue, concurrency, job))
+	return fmt.Errorf("no worker processes queue %s", queue)
 }
 
 // AddBeforeStartHooks adds functions to be executed before the manager starts
@@ -122,6 +284,13 @@ func (m *Manager) AddDuringDrainHooks(hooks ...func()) {
 	m.duringDrainHooks = append(m.duringDrainHooks, hooks...)
 }
 
+// AddAfterShutdownHooks adds functions to be executed with the shutdown report once all workers have stopped
+func (m *Manager) AddAfterShutdownHooks(hooks ...AfterShutdownFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.afterShutdownHooks = append(m.afterShutdownHooks, hooks...)
+}
+
 func (m *Manager) addAfterHeartbeatHooks(hooks ...afterHeartbeatFunc) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -157,7 +326,11 @@ func (m *Manager) Run(ctx context.Context) error {
 		m.lock.Unlock()
 		return fmt.Errorf("manager already running")
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	m.running = true
+	m.cancel = cancel
+	m.shutdownReport = nil
 	m.lock.Unlock()
 
 	defer func() {
@@ -175,29 +348,152 @@ func (m *Manager) Run(ctx context.Context) error {
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	for i := range m.workers {
-		w := m.workers[i]
+	m.lock.Lock()
+	m.startWorker = func(w *worker) {
 		g.Go(func() error {
-			fetcher := newSimpleFetcher(w.queue, *m.Opts(), m.IsActive())
+			m.lock.Lock()
+			fetching := m.fetchingLocked()
+			m.lock.Unlock()
+			fetcher := w.newFetcher(*m.Opts(), fetching, m.reportError, m.writes)
+			m.emit(Event{Type: EventWorkerStart, Queue: w.queue})
 			w.start(fetcher)
+			m.emit(Event{Type: EventWorkerStop, Queue: w.queue})
 			return nil
 		})
 	}
+	for _, w := range m.workers {
+		m.startWorker(w)
+	}
+	m.lock.Unlock()
 
 	g.Go(func() error {
 		<-ctx.Done()
-		for _, w := range m.workers {
+		m.lock.Lock()
+		m.drainStartedAt = time.Now()
+		// workers added from now on wait for the next run
+		m.startWorker = nil
+		workers := m.workers
+		m.lock.Unlock()
+		for _, w := range workers {
 			w.quit()
 		}
 		return nil
 	})
 
-	m.schedule = newScheduledWorker(m.opts)
+	m.schedule = newScheduledWorker(m.opts, m.schedulerLag)
+	m.schedule.onError = func(err error) {
+		m.reportError("", PhaseSchedule, err)
+	}
 	g.Go(func() error {
 		m.schedule.run(ctx)
 		return nil
 	})
 
+	if len(m.cronJobs) > 0 {
+		g.Go(func() error {
+			m.runCron(ctx)
+			return nil
+		})
+	}
+
+	if m.opts.Autoscale != nil {
+		g.Go(func() error {
+			m.runAutoscale(ctx, *m.opts.Autoscale)
+			return nil
+		})
+	} else {
+		if m.opts.Burst != nil {
+			g.Go(func() error {
+				m.runBurst(ctx, *m.opts.Burst)
+				return nil
+			})
+		}
+		if m.opts.Spillover != nil {
+			g.Go(func() error {
+				m.runSpillover(ctx, *m.opts.Spillover)
+				return nil
+			})
+		}
+	}
+
+	g.Go(func() error {
+		m.runJobCancellations(ctx)
+		return nil
+	})
+
+	if m.opts.Standby != nil {
+		g.Go(func() error {
+			m.runStandby(ctx, *m.opts.Standby)
+			return nil
+		})
+	}
+
+	if m.archiver != nil {
+		g.Go(func() error {
+			m.archiver.run(ctx, m)
+			return nil
+		})
+	}
+
+	if m.writes != nil {
+		g.Go(func() error {
+			m.runWriteBuffer(ctx)
+			return nil
+		})
+	}
+
+	if m.opts.LockJanitor != nil {
+		g.Go(func() error {
+			m.runLockJanitor(ctx, *m.opts.LockJanitor)
+			return nil
+		})
+	}
+
+	if m.opts.Backpressure != nil {
+		g.Go(func() error {
+			m.runBackpressure(ctx, *m.opts.Backpressure)
+			return nil
+		})
+	}
+
+	m.lock.Lock()
+	migrations := m.migrations
+	m.lock.Unlock()
+	for _, migration := range migrations {
+		migration := migration
+		g.Go(func() error {
+			m.runQueueMigration(ctx, migration)
+			return nil
+		})
+	}
+
+	if len(m.deadLetterConsumers) > 0 {
+		g.Go(func() error {
+			m.runDeadLetterConsumers(ctx)
+			return nil
+		})
+	}
+
+	if m.opts.InFlightDumpSignal != nil {
+		g.Go(func() error {
+			m.dumpInFlightOnSignal(ctx, m.opts.InFlightDumpSignal)
+			return nil
+		})
+	}
+
+	if m.opts.Outbox != nil && m.opts.Outbox.DB != nil {
+		holder, err := m.getHeartbeatID()
+		if err != nil {
+			m.logger.Println("ERR: couldn't identify the manager for the outbox lease:", err)
+		} else {
+			relay := newOutboxRelay(m.opts, holder, m.IsActive)
+			g.Go(func() error {
+				relay.run(ctx)
+				return nil
+			})
+		}
+	}
+
 	if m.opts.Heartbeat != nil {
 		g.Go(func() error {
 			m.startHeartbeat(ctx)
@@ -205,7 +501,92 @@ func (m *Manager) Run(ctx context.Context) error {
 		})
 	}
 
-	return g.Wait()
+	err := g.Wait()
+	m.finishShutdown()
+	m.removeHeartbeat()
+	return err
+}
+
+// removeHeartbeat takes the manager out of the processes listed by Sidekiq's Web UI once it stops.
+// The heartbeat of a manager which couldn't requeue abandoned jobs is left to expire, so other managers
+// requeue them.
+func (m *Manager) removeHeartbeat() {
+	if m.opts.Heartbeat == nil {
+		return
+	}
+	if report := m.ShutdownReport(); report != nil && len(report.RequeueFailed) > 0 {
+		return
+	}
+	heartbeatID, err := m.getHeartbeatID()
+	if err == nil {
+		err = m.opts.store.RemoveHeartbeat(context.Background(), heartbeatID)
+	}
+	if err != nil {
+		m.logger.Println("ERR: couldn't remove heartbeat:", err)
+	}
+}
+
+func (m *Manager) finishShutdown() {
+	m.requeueAbandoned()
+	m.drainWriteBuffer()
+	// the jobs handlers enqueued with EnqueueAsync are written before the manager reports it stopped
+	if err := m.Producer().Flush(context.Background()); err != nil {
+		m.logger.Println("ERR: couldn't flush async enqueues:", err)
+	}
+
+	m.lock.Lock()
+	report := buildShutdownReport(m.drainStartedAt, m.workers)
+	m.shutdownReport = &report
+	hooks := m.afterShutdownHooks
+	m.lock.Unlock()
+
+	m.logger.Printf("Shutdown finished in %v: %d jobs completed during drain, %d abandoned, %d of them requeued",
+		report.Duration, len(report.Completed), len(report.Abandoned), len(report.Requeued))
+	for _, h := range hooks {
+		h(report)
+	}
+}
+
+// requeueAbandoned pushes the jobs still running past the shutdown deadline back onto their queue, so
+// any manager can run them again. Jobs which finish afterwards are acknowledged all the same.
+func (m *Manager) requeueAbandoned() {
+	m.lock.Lock()
+	workers := m.workers
+	m.lock.Unlock()
+
+	for _, w := range workers {
+		m.requeueWorkerAbandoned(w)
+	}
+}
+
+func (m *Manager) requeueWorkerAbandoned(w *worker) {
+	drain := w.lastDrain()
+	if drain == nil || len(drain.abandoned) == 0 {
+		return
+	}
+	for queue, inProgressQueue := range w.inProgressQueues {
+		// the requeue waits for the buffered acknowledgements, or the jobs they completed would run again
+		if m.writes.len() > 0 && m.bufferRequeue(w, inProgressQueue, queue) {
+			continue
+		}
+		requeued, err := m.opts.store.RequeueMessagesFromInProgressQueue(context.Background(), inProgressQueue, queue)
+		w.recordRequeued(requeued)
+		if err != nil {
+			m.logger.Println("ERR: couldn't requeue the abandoned jobs of", queue, ":", err)
+			if m.bufferRequeue(w, inProgressQueue, queue) {
+				m.logger.Println("buffered the requeue of the abandoned jobs of", queue)
+			}
+			continue
+		}
+		m.logger.Println("requeued", len(requeued), "abandoned jobs of", queue)
+	}
+}
+
+// ShutdownReport returns the report of the most recent shutdown, or nil if the manager hasn't stopped yet
+func (m *Manager) ShutdownReport() *ShutdownReport {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.shutdownReport
 }
 
 // Stop all workers under this Manager and returns immediately.
@@ -216,6 +597,10 @@ func (m *Manager) Stop() {
 		return
 	}
 
+	if m.cancel != nil {
+		m.cancel()
+	}
+
 	for _, w := range m.workers {
 		w.quit()
 	}
@@ -236,14 +621,25 @@ func (m *Manager) inProgressMessages() map[string][]*Msg {
 	defer m.lock.Unlock()
 	res := map[string][]*Msg{}
 	for _, w := range m.workers {
-		res[w.queue] = append(res[w.queue], w.inProgressMessages()...)
+		for _, queue := range w.sourceQueues() {
+			if _, ok := res[queue]; !ok {
+				res[queue] = nil
+			}
+		}
+		for _, msg := range w.inProgressMessages() {
+			queue := w.messageQueue(msg)
+			res[queue] = append(res[queue], msg)
+		}
 	}
 	return res
 }
 
-// Producer creates a new work producer with configuration identical to the manager
+// Producer returns the work producer of the manager, with configuration identical to the manager
 func (m *Manager) Producer() *Producer {
-	return &Producer{opts: m.opts}
+	m.producerOnce.Do(func() {
+		m.producer = newProducer(m.opts)
+	})
+	return m.producer
 }
 
 // GetStats returns the set of stats for the manager
@@ -252,22 +648,38 @@ func (m *Manager) GetStats() (Stats, error) {
 		Jobs:     map[string][]JobStatus{},
 		Enqueued: map[string]int64{},
 		Name:     m.opts.ManagerDisplayName,
+		Tag:      m.processTag(),
+		Labels:   m.opts.Labels,
+
+		MiddlewareTimings: m.middlewareTimings(),
+		RecentFailures:    m.RecentFailures(),
+		Allocations:       m.ClassAllocations(),
+		JobDocs:           m.JobDocs(),
+		JobClasses:        m.JobClasses(),
+		SchedulerLag:      m.SchedulerLag(),
 	}
 	var q []string
 
-	inProgress := m.inProgressMessages()
 	ns := m.opts.Namespace
 
-	for queue, msgs := range inProgress {
-		var jobs []JobStatus
-		for _, m := range msgs {
-			jobs = append(jobs, JobStatus{
-				Message:   m,
-				StartedAt: m.startedAt,
-			})
+	m.lock.Lock()
+	for _, w := range m.workers {
+		for _, queue := range w.sourceQueues() {
+			if _, ok := stats.Jobs[ns+queue]; !ok {
+				stats.Jobs[ns+queue] = nil
+				q = append(q, queue)
+			}
 		}
-		stats.Jobs[ns+queue] = jobs
-		q = append(q, queue)
+	}
+	m.lock.Unlock()
+
+	for _, job := range m.InFlight() {
+		stats.Jobs[ns+job.Queue] = append(stats.Jobs[ns+job.Queue], JobStatus{
+			Message:      job.Message,
+			StartedAt:    job.StartedAt.Unix(),
+			DisplayClass: job.Message.DisplayClass(),
+			DisplayArgs:  m.displayArgs(job.Message),
+		})
 	}
 
 	storeStats, err := m.opts.store.GetAllStats(context.Background(), q)
@@ -278,6 +690,8 @@ func (m *Manager) GetStats() (Stats, error) {
 
 	stats.Processed = storeStats.Processed
 	stats.Failed = storeStats.Failed
+	stats.Duplicates = storeStats.Duplicates
+	stats.FailureCategories = storeStats.FailureCategories
 	stats.RetryCount = storeStats.RetryCount
 
 	for q, l := range storeStats.Enqueued {
@@ -307,9 +721,15 @@ func (m *Manager) GetRetries(page uint64, pageSize int64, match string) (Retries
 		retryJobs = append(retryJobs, retryJob)
 	}
 
+	display, err := m.displayJobs(context.Background(), retryJobs)
+	if err != nil {
+		return Retries{}, err
+	}
+
 	return Retries{
-		TotalRetryCount: storeRetries.TotalRetryCount,
-		RetryJobs:       retryJobs,
+		TotalRetryCount:  storeRetries.TotalRetryCount,
+		RetryJobs:        retryJobs,
+		RetryJobsDisplay: display,
 	}, nil
 }
 
@@ -325,13 +745,20 @@ func (m *Manager) startHeartbeat(ctx context.Context) {
 			heartbeatTime, err := m.opts.store.GetTime(ctx)
 			if err != nil {
 				m.logger.Println("ERR: Failed to get heartbeat time", err)
+				m.reportError("", PhaseHeartbeat, err)
 				return
 			}
 			heartbeat, err := m.sendHeartbeat(heartbeatTime)
 			if err != nil {
 				m.logger.Println("ERR: Failed to send heartbeat", err)
+				m.reportError("", PhaseHeartbeat, err)
 				return
 			}
+			m.lock.Lock()
+			m.lastHeartbeat = time.Now()
+			m.lock.Unlock()
+			m.emit(Event{Type: EventHeartbeat})
+			m.handleRemoteSignals(ctx, heartbeat.Identity)
 			expireTS := heartbeatTime.Add(-m.opts.Heartbeat.HeartbeatTTL).Unix()
 			staleMessageUpdates, err := m.handleAllExpiredHeartbeats(ctx, expireTS)
 			if err != nil {
@@ -405,9 +832,7 @@ func (m *Manager) Active(active bool) {
 	if activateManager || deactivateManager {
 		m.lock.Lock()
 		m.active = active
-		for _, worker := range m.workers {
-			worker.fetcher.SetActive(active)
-		}
+		m.updateFetchersLocked()
 		m.lock.Unlock()
 		for _, hook := range m.afterActiveChangeHooks {
 			hook(m, activateManager, deactivateManager)
@@ -0,0 +1,76 @@
+package workers
+
+import "context"
+
+// Phases of a ManagerError
+const (
+	PhaseFetch       = "fetch"
+	PhaseAcknowledge = "acknowledge"
+	PhaseSchedule    = "schedule"
+	PhaseHeartbeat   = "heartbeat"
+	PhaseRun         = "run"
+)
+
+// managerErrorsBuffer is how many errors RunWithErrors keeps while they aren't received
+const managerErrorsBuffer = 100
+
+// ManagerError is an error a running manager hit, such as a failed fetch, or the error Run returned
+type ManagerError struct {
+	// Queue whose worker hit the error, empty for the errors of the whole manager
+	Queue string
+	Phase string
+	Err   error
+}
+
+func (e *ManagerError) Error() string {
+	if e.Queue == "" {
+		return e.Phase + ": " + e.Err.Error()
+	}
+	return e.Phase + " " + e.Queue + ": " + e.Err.Error()
+}
+
+func (e *ManagerError) Unwrap() error {
+	return e.Err
+}
+
+// RunWithErrors runs the manager like Run, in the background. The returned channel receives a ManagerError
+// for every error the manager carries on after, such as failed fetches, then for the error of Run, and is
+// closed once the manager stopped. Errors are dropped while the channel is full, rather than holding the
+// manager up, but the error of Run is always sent.
+func (m *Manager) RunWithErrors(ctx context.Context) <-chan error {
+	errs := make(chan error, managerErrorsBuffer)
+	m.errorsLock.Lock()
+	m.errors = errs
+	m.errorsLock.Unlock()
+
+	go func() {
+		err := m.Run(ctx)
+		m.errorsLock.Lock()
+		if m.errors == errs {
+			m.errors = nil
+		}
+		m.errorsLock.Unlock()
+		if err != nil {
+			errs <- &ManagerError{Phase: PhaseRun, Err: err}
+		}
+		close(errs)
+	}()
+	return errs
+}
+
+// reportError sends an error to the channel of RunWithErrors, if any
+func (m *Manager) reportError(queue, phase string, err error) {
+	if phase == PhaseFetch {
+		m.emit(Event{Type: EventFetchError, Queue: queue, Err: err})
+	}
+
+	m.errorsLock.Lock()
+	defer m.errorsLock.Unlock()
+	if m.errors == nil {
+		return
+	}
+	select {
+	case m.errors <- &ManagerError{Queue: queue, Phase: phase, Err: err}:
+	default:
+	}
+}
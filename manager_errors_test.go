@@ -0,0 +1,86 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingAckStore fails every acknowledgement
+type failingAckStore struct {
+	storage.Store
+	err error
+}
+
+func (s *failingAckStore) AcknowledgeMessage(ctx context.Context, queue string, message string) error {
+	return s.err
+}
+
+func TestManagerError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := &ManagerError{Queue: "emails", Phase: PhaseFetch, Err: cause}
+	assert.Equal(t, "fetch emails: connection refused", err.Error())
+	assert.True(t, errors.Is(err, cause))
+
+	err = &ManagerError{Phase: PhaseHeartbeat, Err: cause}
+	assert.Equal(t, "heartbeat: connection refused", err.Error())
+}
+
+func TestRunWithErrors(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	cause := errors.New("connection reset")
+	mgr.opts.store = &failingAckStore{Store: opts.store, err: cause}
+
+	processed := make(chan string, 1)
+	mgr.AddWorker("errors", 1, func(m *Msg) error {
+		processed <- m.Jid()
+		return nil
+	}, NopMiddleware)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := mgr.RunWithErrors(ctx)
+
+	jid, err := mgr.Producer().Enqueue("errors", "any", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, jid, <-processed)
+
+	select {
+	case err := <-errs:
+		var managerErr *ManagerError
+		assert.True(t, errors.As(err, &managerErr))
+		assert.Equal(t, "errors", managerErr.Queue)
+		assert.Equal(t, PhaseAcknowledge, managerErr.Phase)
+		assert.Equal(t, cause, managerErr.Err)
+	case <-time.After(time.Second):
+		t.Fatal("no error reported")
+	}
+
+	cancel()
+	for range errs {
+	}
+	_, open := <-errs
+	assert.False(t, open)
+}
+
+func TestRunWithErrorsReportsRunError(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	mgr.lock.Lock()
+	mgr.running = true
+	mgr.lock.Unlock()
+
+	err = <-mgr.RunWithErrors(context.Background())
+	var managerErr *ManagerError
+	assert.True(t, errors.As(err, &managerErr))
+	assert.Equal(t, PhaseRun, managerErr.Phase)
+}
@@ -74,6 +74,34 @@ func TestNewManagerWithRedisClientNoProcessID(t *testing.T) {
 	assert.Nil(t, mgr)
 }
 
+func TestNewManagersSharingRedisClient(t *testing.T) {
+	ctx := context.Background()
+	shared, err := SetupDefaultTestOptionsWithNamespace("shared")
+	assert.NoError(t, err)
+	client := shared.client
+
+	billing, err := NewManagerWithRedisClient(testOptionsWithNamespace("billing"), client)
+	assert.NoError(t, err)
+	emails, err := NewManagerWithRedisClient(testOptionsWithNamespace("emails"), client)
+	assert.NoError(t, err)
+	assert.Same(t, billing.GetRedisClient(), emails.GetRedisClient())
+
+	_, err = billing.Producer().Enqueue("default", "Invoice", nil)
+	assert.NoError(t, err)
+	length, err := billing.opts.store.QueueLength(ctx, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+	length, err = emails.opts.store.QueueLength(ctx, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+
+	// a client serves a single database
+	opts := testOptionsWithNamespace("other")
+	opts.Database = testDatabase - 1
+	_, err = NewManagerWithRedisClient(opts, client)
+	assert.Error(t, err)
+}
+
 func TestManager_AddBeforeStartHooks(t *testing.T) {
 	namespace := "prod"
 	opts := testOptionsWithNamespace(namespace)
@@ -188,6 +216,100 @@ func TestManager_AddWorker(t *testing.T) {
 	defaultMiddlewares = baseMids
 }
 
+func TestManager_SetConcurrency(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	mgr, err := NewManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("someq", 1, func(m *Msg) error { return nil })
+
+	assert.NoError(t, mgr.SetConcurrency("someq", 4))
+	assert.Equal(t, 4, mgr.workers[0].getConcurrency())
+	assert.Error(t, mgr.SetConcurrency("otherq", 4))
+}
+
+func TestManager_AddWorkerForQueues(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	queues := make(chan string, 2)
+	mgr.AddStrictWorkerForQueues(map[string]int{"critical": 5, "low": 1}, 1, func(m *Msg) error {
+		queues <- m.fetchedFrom
+		return nil
+	}, NopMiddleware)
+	assert.Len(t, mgr.workers, 1)
+	assert.Equal(t, "critical,low", mgr.workers[0].queue)
+	assert.NoError(t, mgr.SetConcurrency("critical,low", 2))
+
+	prod := mgr.Producer()
+	_, err = prod.Enqueue("low", "any", nil)
+	assert.NoError(t, err)
+	_, err = prod.Enqueue("critical", "any", nil)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(context.Background())
+		wg.Done()
+	}()
+	assert.ElementsMatch(t, []string{"critical", "low"}, []string{<-queues, <-queues})
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Contains(t, stats.Jobs, "prod:critical")
+	assert.Contains(t, stats.Jobs, "prod:low")
+
+	mgr.Stop()
+	wg.Wait()
+
+	for _, queue := range []string{"critical", "low"} {
+		length, err := opts.store.QueueLength(context.Background(), queue+":1:inprogress")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), length, queue)
+	}
+}
+
+func TestManager_AddAndRemoveWorkersWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	prod := mgr.Producer()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(ctx)
+		wg.Done()
+	}()
+	assert.Eventually(t, func() bool { return mgr.Status().Running }, time.Second, time.Millisecond)
+
+	processed := make(chan string, 1)
+	mgr.AddWorker("plugin", 1, func(m *Msg) error {
+		processed <- m.Jid()
+		return nil
+	}, NopMiddleware)
+	jid, err := prod.Enqueue("plugin", "any", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, jid, <-processed)
+
+	assert.NoError(t, mgr.RemoveWorker("plugin"))
+	assert.Error(t, mgr.RemoveWorker("plugin"))
+	assert.Empty(t, mgr.workers)
+	_, err = prod.Enqueue("plugin", "any", nil)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	length, err := opts.store.QueueLength(ctx, "plugin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+
+	mgr.Stop()
+	wg.Wait()
+}
+
 func TestManager_Run(t *testing.T) {
 	namespace := "mgrruntest"
 	opts := testOptionsWithNamespace(namespace)
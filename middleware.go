@@ -20,8 +20,13 @@ func (m Middlewares) Prepend(mid MiddlewareFunc) Middlewares {
 }
 
 func (m Middlewares) build(queue string, mgr *Manager, final JobFunc) JobFunc {
+	timers := newMiddlewareTimers(m)
+	final = timers[len(m)].wrap(final)
 	for i := len(m) - 1; i >= 0; i-- {
-		final = m[i](queue, mgr, final)
+		final = timers[i].wrap(m[i](queue, mgr, final))
+	}
+	if mgr != nil {
+		mgr.registerMiddlewareTimers(queue, timers)
 	}
 	return final
 }
@@ -0,0 +1,99 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ClassFallback is what a manager does with jobs whose class it refuses to run
+type ClassFallback int
+
+const (
+	// ClassFallbackSkip acknowledges and drops the job
+	ClassFallbackSkip ClassFallback = iota
+	// ClassFallbackRequeue moves the job to ClassFilterOptions.FallbackQueue
+	ClassFallbackRequeue
+	// ClassFallbackDeadLetter moves the job to the dead set, or its queue's dead-letter queue
+	ClassFallbackDeadLetter
+)
+
+// ClassFilterOptions restricts the job classes a manager runs
+type ClassFilterOptions struct {
+	// Only these classes are run, unless the list is empty
+	Allow []string
+	// These classes are never run
+	Deny []string
+
+	Fallback ClassFallback
+	// Queue refused jobs are moved to by ClassFallbackRequeue
+	FallbackQueue string
+}
+
+func (o *ClassFilterOptions) validate() error {
+	if o.Fallback == ClassFallbackRequeue && o.FallbackQueue == "" {
+		return errors.New("class filter requeue fallback requires a FallbackQueue")
+	}
+	return nil
+}
+
+type classFilter struct {
+	opts  ClassFilterOptions
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+func newClassFilter(opts ClassFilterOptions) *classFilter {
+	f := &classFilter{opts: opts, allow: map[string]bool{}, deny: map[string]bool{}}
+	for _, class := range opts.Allow {
+		f.allow[class] = true
+	}
+	for _, class := range opts.Deny {
+		f.deny[class] = true
+	}
+	return f
+}
+
+func (f *classFilter) accepts(class string) bool {
+	if f.deny[class] {
+		return false
+	}
+	return len(f.allow) == 0 || f.allow[class]
+}
+
+// refuse applies the fallback to a job the manager won't run
+func (f *classFilter) refuse(queue string, mgr *Manager, message *Msg) error {
+	ctx := context.Background()
+	var err error
+
+	switch f.opts.Fallback {
+	case ClassFallbackRequeue:
+		message.Set("queue", f.opts.FallbackQueue)
+		if err = mgr.opts.store.CreateQueue(ctx, f.opts.FallbackQueue); err == nil {
+			err = mgr.opts.store.EnqueueMessageNow(ctx, f.opts.FallbackQueue, message.ToJson())
+		}
+	case ClassFallbackDeadLetter:
+		err = mgr.deadLetter(ctx, strings.TrimPrefix(queue, mgr.opts.Namespace), message)
+	default:
+		mgr.logger.Println("skipping job", message.Jid(), "of refused class", message.Class(), "on", queue)
+	}
+
+	if err != nil {
+		// keep the job in the in-progress queue rather than losing it
+		message.ack = false
+	}
+	return err
+}
+
+// classFilterMiddleware runs jobs of accepted classes and hands the others to the fallback
+func classFilterMiddleware(opts ClassFilterOptions) MiddlewareFunc {
+	filter := newClassFilter(opts)
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		return func(message *Msg) error {
+			if filter.accepts(message.Class()) {
+				return next(message)
+			}
+			return filter.refuse(queue, mgr, message)
+		}
+	}
+}
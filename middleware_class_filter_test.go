@@ -0,0 +1,80 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassFilterAccepts(t *testing.T) {
+	f := newClassFilter(ClassFilterOptions{})
+	assert.True(t, f.accepts("Any"))
+
+	f = newClassFilter(ClassFilterOptions{Allow: []string{"A", "B"}, Deny: []string{"B"}})
+	assert.True(t, f.accepts("A"))
+	assert.False(t, f.accepts("B"))
+	assert.False(t, f.accepts("C"))
+
+	f = newClassFilter(ClassFilterOptions{Deny: []string{"B"}})
+	assert.True(t, f.accepts("A"))
+	assert.False(t, f.accepts("B"))
+}
+
+func TestClassFilterMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		fallback ClassFallback
+		queued   int64
+		dead     int64
+	}{
+		{name: "skip", fallback: ClassFallbackSkip},
+		{name: "requeue", fallback: ClassFallbackRequeue, queued: 1},
+		{name: "dead letter", fallback: ClassFallbackDeadLetter, dead: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+			assert.NoError(t, err)
+			mgr := &Manager{opts: opts, logger: opts.Logger}
+			rc := opts.client
+
+			filter := ClassFilterOptions{Allow: []string{"Billing"}, Fallback: tt.fallback, FallbackQueue: "other"}
+			var ran []string
+			job := NewMiddlewares(classFilterMiddleware(filter)).build("myqueue", mgr, func(m *Msg) error {
+				ran = append(ran, m.Class())
+				return nil
+			})
+
+			allowed, _ := NewMsg(`{"jid":"1","class":"Billing","args":[]}`)
+			refused, _ := NewMsg(`{"jid":"2","class":"Reports","args":[],"queue":"myqueue"}`)
+			assert.NoError(t, job(allowed))
+			assert.NoError(t, job(refused))
+			assert.Equal(t, []string{"Billing"}, ran)
+			assert.True(t, refused.ack)
+
+			queued, _ := rc.LLen(ctx, "prod:queue:other").Result()
+			assert.Equal(t, tt.queued, queued)
+			if tt.queued > 0 {
+				moved, _ := rc.RPop(ctx, "prod:queue:other").Result()
+				msg, _ := NewMsg(moved)
+				assert.Equal(t, "other", msg.Get("queue").MustString())
+			}
+			dead, _ := rc.ZCard(ctx, "prod:"+storage.DeadKey).Result()
+			assert.Equal(t, tt.dead, dead)
+		})
+	}
+}
+
+func TestClassFilterRequiresFallbackQueue(t *testing.T) {
+	_, err := processOptions(Options{
+		ServerAddr:  "localhost:6379",
+		ProcessID:   "1",
+		ClassFilter: &ClassFilterOptions{Fallback: ClassFallbackRequeue},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+// ExactlyOnceMiddleware records a completion marker for every successfully processed JID and skips
+// jobs whose JID already has one, such as copies delivered again by the in-progress queue recovery.
+// Markers expire after ttl. A worker dying between finishing a job and writing its marker can still
+// run the job twice, so handlers with side effects outside Redis should stay idempotent.
+//
+// Suppressed duplicates are counted in the "duplicates" stat.
+func ExactlyOnceMiddleware(ttl time.Duration) MiddlewareFunc {
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		return func(message *Msg) error {
+			ctx := context.Background()
+			jid := message.Jid()
+			if jid == "" {
+				return next(message)
+			}
+
+			completed, err := mgr.opts.store.IsJobCompleted(ctx, jid)
+			if err != nil {
+				return err
+			}
+			if completed {
+				mgr.logger.Println("skipping already completed job", jid)
+				incrementStats(mgr, "duplicates")
+				return nil
+			}
+
+			if err := next(message); err != nil {
+				return err
+			}
+
+			if err := mgr.opts.store.MarkJobCompleted(ctx, jid, ttl); err != nil {
+				// the job ran, failing it now would only schedule a retry of completed work
+				mgr.logger.Println("couldn't record completion of job", jid, ":", err)
+			}
+			return nil
+		}
+	}
+}
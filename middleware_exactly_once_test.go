@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExactlyOnceMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, logger: opts.Logger}
+	rc := opts.client
+
+	runs := 0
+	fail := false
+	job := NewMiddlewares(ExactlyOnceMiddleware(time.Minute)).build("myqueue", mgr, func(m *Msg) error {
+		runs++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	// failed jobs aren't marked, so their retries still run
+	fail = true
+	message, _ := NewMsg(`{"jid":"once","class":"Add","args":[]}`)
+	assert.Error(t, job(message))
+	assert.Error(t, job(message))
+	assert.Equal(t, 2, runs)
+
+	fail = false
+	assert.NoError(t, job(message))
+	assert.NoError(t, job(message))
+	assert.Equal(t, 3, runs)
+
+	ttl, _ := rc.TTL(ctx, "prod:completed:once").Result()
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	stats, err := opts.store.GetAllStats(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Duplicates)
+
+	other, _ := NewMsg(`{"jid":"other","class":"Add","args":[]}`)
+	assert.NoError(t, job(other))
+	assert.Equal(t, 4, runs)
+}
@@ -0,0 +1,18 @@
+package workers
+
+// expiryMiddleware skips the jobs enqueued with an expires_in which weren't started within that many
+// seconds of their creation, like Sidekiq's expiring jobs
+func expiryMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		expiresIn, err := message.Get("expires_in").Float64()
+		if err != nil || expiresIn <= 0 {
+			return next(message)
+		}
+		createdAt, err := message.Get("created_at").Float64()
+		if err != nil || createdAt+expiresIn > nowToSecondsWithNanoPrecision() {
+			return next(message)
+		}
+		incrementStats(mgr, "expired")
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package workers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryMiddleware(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+
+	now := nowToSecondsWithNanoPrecision()
+	tests := []struct {
+		name    string
+		payload string
+		run     bool
+	}{
+		{name: "no expiry", payload: fmt.Sprintf(`{"jid":"1","created_at":%f}`, now-3600), run: true},
+		{name: "not expired", payload: fmt.Sprintf(`{"jid":"2","created_at":%f,"expires_in":60}`, now-30), run: true},
+		{name: "expired", payload: fmt.Sprintf(`{"jid":"3","created_at":%f,"expires_in":60}`, now-90), run: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := NewMsg(tt.payload)
+			assert.NoError(t, err)
+			ran := false
+			err = expiryMiddleware("prod:myqueue", mgr, func(*Msg) error {
+				ran = true
+				return nil
+			})(message)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.run, ran)
+		})
+	}
+}
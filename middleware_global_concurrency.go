@@ -0,0 +1,139 @@
+package workers
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGlobalConcurrencyLeaseTTL = time.Minute
+	defaultGlobalConcurrencyDefer    = 5 * time.Second
+)
+
+// GlobalConcurrencyOptions caps the jobs running at once across every manager sharing the Redis namespace.
+// Zero limits don't cap.
+type GlobalConcurrencyOptions struct {
+	// Jobs of the queue running at once, and of some of its classes
+	Queue   int
+	Classes map[string]int
+
+	// Optional time the slot of a job is kept without being renewed, such as by a manager which died,
+	// defaults to a minute. Running jobs renew their slots every third of it.
+	LeaseTTL time.Duration
+	// Optional delay of the jobs over a limit, defaults to 5 seconds
+	Defer time.Duration
+}
+
+func (o GlobalConcurrencyOptions) withDefaults() GlobalConcurrencyOptions {
+	if o.LeaseTTL <= 0 {
+		o.LeaseTTL = defaultGlobalConcurrencyLeaseTTL
+	}
+	if o.Defer <= 0 {
+		o.Defer = defaultGlobalConcurrencyDefer
+	}
+	return o
+}
+
+// globalSemaphore is a slot limit shared by every manager
+type globalSemaphore struct {
+	name  string
+	limit int
+}
+
+// semaphores returns the limits a job of class must get a slot of, the queue's first
+func (o GlobalConcurrencyOptions) semaphores(queue, class string) []globalSemaphore {
+	var res []globalSemaphore
+	if o.Queue > 0 {
+		res = append(res, globalSemaphore{name: "queue:" + queue, limit: o.Queue})
+	}
+	if limit := o.Classes[class]; limit > 0 {
+		res = append(res, globalSemaphore{name: "class:" + class, limit: limit})
+	}
+	return res
+}
+
+// GlobalConcurrencyMiddleware caps the jobs of a queue and of its classes running at once across the
+// whole fleet, such as to never run more than 5 ExportJob anywhere. Jobs over a limit are acknowledged
+// and moved to the schedule set, to be tried again after opts.Defer.
+func GlobalConcurrencyMiddleware(opts GlobalConcurrencyOptions) MiddlewareFunc {
+	opts = opts.withDefaults()
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		queue = strings.TrimPrefix(queue, mgr.opts.Namespace)
+		return func(message *Msg) error {
+			semaphores := opts.semaphores(queue, message.Class())
+			if len(semaphores) == 0 {
+				return next(message)
+			}
+
+			ctx := context.Background()
+			holder := generateJid()
+			acquired, err := acquireGlobalSlots(ctx, mgr, semaphores, holder, opts.LeaseTTL)
+			if err != nil {
+				mgr.logger.Println("ERR: couldn't check the global concurrency of", queue, ":", err)
+				return next(message)
+			}
+			if !acquired {
+				at := timeToSecondsWithNanoPrecision(time.Now().Add(opts.Defer))
+				if err := mgr.opts.store.EnqueueScheduledMessage(ctx, at, message.ToJson()); err != nil {
+					// keep the job in the in-progress queue rather than losing it
+					message.ack = false
+					return err
+				}
+				return nil
+			}
+
+			stop := renewGlobalSlots(mgr, semaphores, holder, opts.LeaseTTL)
+			defer func() {
+				stop()
+				releaseGlobalSlots(ctx, mgr, semaphores, holder)
+			}()
+			return next(message)
+		}
+	}
+}
+
+// acquireGlobalSlots takes a slot of every semaphore, or none of them
+func acquireGlobalSlots(ctx context.Context, mgr *Manager, semaphores []globalSemaphore, holder string, ttl time.Duration) (bool, error) {
+	for i, s := range semaphores {
+		acquired, err := mgr.opts.store.AcquireSemaphore(ctx, s.name, holder, s.limit, ttl)
+		if err != nil || !acquired {
+			releaseGlobalSlots(ctx, mgr, semaphores[:i], holder)
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// renewGlobalSlots keeps the slots of a running job until the returned func is called
+func renewGlobalSlots(mgr *Manager, semaphores []globalSemaphore, holder string, ttl time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, s := range semaphores {
+					renewed, err := mgr.opts.store.RenewSemaphore(context.Background(), s.name, holder, ttl)
+					if err != nil {
+						mgr.logger.Println("ERR: couldn't renew the global concurrency slot of", s.name, ":", err)
+					} else if !renewed {
+						mgr.logger.Println("WARN: the global concurrency slot of", s.name, "expired while its job ran")
+					}
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func releaseGlobalSlots(ctx context.Context, mgr *Manager, semaphores []globalSemaphore, holder string) {
+	for _, s := range semaphores {
+		if err := mgr.opts.store.ReleaseSemaphore(ctx, s.name, holder); err != nil {
+			mgr.logger.Println("ERR: couldn't release the global concurrency slot of", s.name, ":", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalConcurrencyMiddleware(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	limits := GlobalConcurrencyOptions{Classes: map[string]int{"ExportJob": 1}}
+
+	// two managers of the fleet share the limit
+	var jobs []JobFunc
+	started := make(chan bool)
+	release := make(chan bool)
+	for i := 0; i < 2; i++ {
+		mgr, err := newManager(opts)
+		assert.NoError(t, err)
+		jobs = append(jobs, NewMiddlewares(GlobalConcurrencyMiddleware(limits)).build("prod:exports", mgr, func(m *Msg) error {
+			if m.Class() == "ExportJob" {
+				started <- true
+				<-release
+			}
+			return nil
+		}))
+	}
+
+	done := make(chan error)
+	go func() {
+		message, _ := NewMsg(`{"jid":"1","class":"ExportJob","queue":"exports"}`)
+		done <- jobs[0](message)
+	}()
+	<-started
+
+	message, _ := NewMsg(`{"jid":"2","class":"ExportJob","queue":"exports"}`)
+	assert.NoError(t, jobs[1](message))
+	assert.True(t, message.ack)
+	scheduled, _ := opts.client.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Result()
+	assert.Equal(t, int64(1), scheduled)
+
+	// other classes aren't limited
+	message, _ = NewMsg(`{"jid":"3","class":"ImportJob","queue":"exports"}`)
+	assert.NoError(t, jobs[1](message))
+	scheduled, _ = opts.client.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Result()
+	assert.Equal(t, int64(1), scheduled)
+
+	// the slot is released once the job is done
+	release <- true
+	assert.NoError(t, <-done)
+	go func() {
+		message, _ := NewMsg(`{"jid":"4","class":"ExportJob","queue":"exports"}`)
+		done <- jobs[1](message)
+	}()
+	<-started
+	release <- true
+	assert.NoError(t, <-done)
+}
+
+func TestAcquireSemaphoreFreesExpiredSlots(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	// the slot of a manager which died expires
+	acquired, err := opts.store.AcquireSemaphore(ctx, "queue:exports", "dead", 1, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = opts.store.AcquireSemaphore(ctx, "queue:exports", "alive", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	time.Sleep(20 * time.Millisecond)
+	acquired, err = opts.store.AcquireSemaphore(ctx, "queue:exports", "alive", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	renewed, err := opts.store.RenewSemaphore(ctx, "queue:exports", "alive", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, renewed)
+	renewed, err = opts.store.RenewSemaphore(ctx, "queue:exports", "dead", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, renewed)
+}
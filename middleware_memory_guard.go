@@ -0,0 +1,217 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMemorySampleInterval = 250 * time.Millisecond
+
+// MemorySampler returns the current memory usage in bytes
+type MemorySampler func() (uint64, error)
+
+// heapObjectsMetric is the runtime metric of the bytes of the live and unswept objects of the Go heap
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// HeapAllocSampler samples the bytes of the objects allocated on the Go heap, as runtime.MemStats'
+// HeapAlloc, without stopping the world
+func HeapAllocSampler() (uint64, error) {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0, fmt.Errorf("unsupported runtime metric %s", heapObjectsMetric)
+	}
+	return sample[0].Value.Uint64(), nil
+}
+
+// CgroupMemorySampler samples the memory usage of the process' cgroup, from a cgroup v2 memory.current
+// file or a cgroup v1 memory.usage_in_bytes file
+func CgroupMemorySampler(path string) MemorySampler {
+	return func() (uint64, error) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	}
+}
+
+// MemoryGuardOptions configures the per-job memory guard
+type MemoryGuardOptions struct {
+	// Growth of the sampled memory usage while a job runs above which it is cancelled
+	Budget uint64
+
+	// Optional source of memory usage, defaults to HeapAllocSampler
+	Sampler MemorySampler
+	// How often memory is sampled while jobs run, defaults to 250ms
+	SampleInterval time.Duration
+
+	// Move jobs over budget to the dead set, or their queue's dead-letter queue, rather than parking them
+	// in the ParkedQueue of their class
+	DeadLetter bool
+}
+
+// MemoryBudgetError is the reason a job which used more memory than the guard allows was parked or
+// dead-lettered
+type MemoryBudgetError struct {
+	Jid    string
+	Used   uint64
+	Budget uint64
+}
+
+func (e *MemoryBudgetError) Error() string {
+	return fmt.Sprintf("job %s used %d bytes of memory, over its budget of %d", e.Jid, e.Used, e.Budget)
+}
+
+// MemoryGuardMiddleware cancels the context of jobs during which memory usage grows by more than the
+// budget, then parks them in the ParkedQueue of their class until Manager.EnableClass moves them back, or
+// kills them with DeadLetter, so that a pathological payload doesn't repeatedly exhaust memory.
+// Handlers must return once Msg.Context is done for the guard to stop them. The usage is the process'
+// (or cgroup's) and includes the growth caused by jobs running concurrently. A single goroutine samples
+// the usage for every job the default sampler watches in the process.
+func MemoryGuardMiddleware(opts MemoryGuardOptions) MiddlewareFunc {
+	if opts.SampleInterval <= 0 {
+		opts.SampleInterval = defaultMemorySampleInterval
+	}
+	monitor := heapMonitor(opts.SampleInterval)
+	if opts.Sampler != nil {
+		monitor = newMemoryMonitor(opts.Sampler, opts.SampleInterval)
+	}
+
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		queue = strings.TrimPrefix(queue, mgr.opts.Namespace)
+		return func(message *Msg) error {
+			start, err := monitor.sampler()
+			if err != nil {
+				mgr.logger.Println("ERR: couldn't sample memory usage:", err)
+				return next(message)
+			}
+
+			watch := monitor.watch(start, opts.Budget, func() {
+				mgr.logger.Println("cancelling job", message.Jid(), "over its memory budget")
+				mgr.cancellations.cancel(message.Jid())
+			})
+			err = next(message)
+			used := monitor.unwatch(watch) - start
+
+			if used <= opts.Budget {
+				return err
+			}
+			budgetErr := &MemoryBudgetError{Jid: message.Jid(), Used: used, Budget: opts.Budget}
+			ctx := context.Background()
+			if opts.DeadLetter {
+				mgr.logger.Println("moving job to the dead set:", budgetErr)
+				return mgr.deadLetter(ctx, queue, message)
+			}
+
+			mgr.logger.Println("parking job:", budgetErr)
+			if q, _ := message.Get("queue").String(); q == "" {
+				message.Set("queue", queue)
+			}
+			if err := mgr.opts.store.EnqueueMessageNow(ctx, ParkedQueue(message.Class()), message.ToJson()); err != nil {
+				// keep the job in the in-progress queue rather than losing it
+				message.ack = false
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// memoryWatch is a running job watched by a memoryMonitor
+type memoryWatch struct {
+	start    uint64
+	budget   uint64
+	peak     uint64
+	exceeded func()
+	fired    bool
+}
+
+// memoryMonitor samples memory usage from a single goroutine for every job it watches, running only
+// while it watches some
+type memoryMonitor struct {
+	sampler  MemorySampler
+	interval time.Duration
+
+	lock    sync.Mutex
+	watches map[*memoryWatch]struct{}
+	running bool
+}
+
+var heapMonitors = struct {
+	lock       sync.Mutex
+	byInterval map[time.Duration]*memoryMonitor
+}{byInterval: map[time.Duration]*memoryMonitor{}}
+
+// heapMonitor returns the monitor of the process sampling HeapAllocSampler every interval
+func heapMonitor(interval time.Duration) *memoryMonitor {
+	heapMonitors.lock.Lock()
+	defer heapMonitors.lock.Unlock()
+	monitor, ok := heapMonitors.byInterval[interval]
+	if !ok {
+		monitor = newMemoryMonitor(HeapAllocSampler, interval)
+		heapMonitors.byInterval[interval] = monitor
+	}
+	return monitor
+}
+
+func newMemoryMonitor(sampler MemorySampler, interval time.Duration) *memoryMonitor {
+	return &memoryMonitor{sampler: sampler, interval: interval, watches: map[*memoryWatch]struct{}{}}
+}
+
+// watch calls exceeded once the usage grew by more than budget since start, until unwatch is called
+func (m *memoryMonitor) watch(start, budget uint64, exceeded func()) *memoryWatch {
+	w := &memoryWatch{start: start, budget: budget, peak: start, exceeded: exceeded}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.watches[w] = struct{}{}
+	if !m.running {
+		m.running = true
+		go m.run()
+	}
+	return w
+}
+
+// unwatch stops watching a job, and returns the highest usage sampled while it ran
+func (m *memoryMonitor) unwatch(w *memoryWatch) uint64 {
+	usage, err := m.sampler()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.watches, w)
+	if err == nil && usage > w.peak {
+		w.peak = usage
+	}
+	return w.peak
+}
+
+func (m *memoryMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		usage, err := m.sampler()
+
+		m.lock.Lock()
+		if len(m.watches) == 0 {
+			m.running = false
+			m.lock.Unlock()
+			return
+		}
+		for w := range m.watches {
+			if err != nil || usage <= w.peak {
+				continue
+			}
+			w.peak = usage
+			if !w.fired && w.peak-w.start > w.budget {
+				w.fired = true
+				w.exceeded()
+			}
+		}
+		m.lock.Unlock()
+	}
+}
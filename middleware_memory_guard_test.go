@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryGuardMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	rc := opts.client
+
+	var usage uint64 = 1000
+	sampler := func() (uint64, error) {
+		return atomic.LoadUint64(&usage), nil
+	}
+	guard := MemoryGuardOptions{Budget: 500, Sampler: sampler, SampleInterval: time.Millisecond}
+
+	var growth uint64
+	jobErr := errors.New("failed")
+	var fail bool
+	handler := func(m *Msg) error {
+		// usage spikes while the job runs, then is released
+		atomic.AddUint64(&usage, growth)
+		defer atomic.AddUint64(&usage, ^(growth - 1))
+		select {
+		case <-m.Context().Done():
+			return m.Context().Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+		if growth > guard.Budget {
+			t.Error("the job over its budget wasn't cancelled")
+		}
+		if fail {
+			return jobErr
+		}
+		return nil
+	}
+	job := mgr.buildJob("myqueue", handler, []MiddlewareFunc{MemoryGuardMiddleware(guard)})
+	message := func() *Msg {
+		message, _ := NewMsg(`{"jid":"hungry","class":"Parse","args":[]}`)
+		return message
+	}
+
+	growth = 400
+	assert.NoError(t, job(message()))
+	fail = true
+	assert.Equal(t, jobErr, job(message()))
+
+	// the job is cancelled as soon as it's over budget, and parked
+	growth = 600
+	assert.NoError(t, job(message()))
+	parked, _ := rc.LRange(ctx, "prod:queue:"+ParkedQueue("Parse"), 0, -1).Result()
+	if assert.Len(t, parked, 1) {
+		msg, _ := NewMsg(parked[0])
+		assert.Equal(t, "hungry", msg.Jid())
+		assert.Equal(t, "myqueue", msg.Get("queue").MustString())
+	}
+
+	guard.DeadLetter = true
+	job = mgr.buildJob("myqueue", handler, []MiddlewareFunc{MemoryGuardMiddleware(guard)})
+	assert.NoError(t, job(message()))
+	dead, _ := rc.ZRange(ctx, "prod:"+storage.DeadKey, 0, -1).Result()
+	if assert.Len(t, dead, 1) {
+		msg, _ := NewMsg(dead[0])
+		assert.Equal(t, "hungry", msg.Jid())
+	}
+}
+
+func TestHeapAllocSampler(t *testing.T) {
+	usage, err := HeapAllocSampler()
+	assert.NoError(t, err)
+	assert.True(t, usage > 0)
+
+	// the guards of the process share the monitor of the heap
+	assert.Same(t, heapMonitor(time.Second), heapMonitor(time.Second))
+}
+
+func TestCgroupMemorySampler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.current")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("123456\n"), 0644))
+
+	usage, err := CgroupMemorySampler(path)()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(123456), usage)
+
+	_, err = CgroupMemorySampler(filepath.Join(t.TempDir(), "missing"))()
+	assert.Error(t, err)
+}
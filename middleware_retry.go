@@ -2,9 +2,12 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
+	"strings"
 	"time"
 )
 
@@ -23,29 +26,45 @@ func retryProcessError(queue string, mgr *Manager, message *Msg, err error) erro
 	if !retry(message) {
 		return err
 	}
-	if retryCount(message) < retryMax(message) {
+	if retryCount(message) < retryMax(message) && !errors.Is(err, ErrNonRetryable) {
+		if retryQueue, err := message.Get("retry_queue").String(); err == nil && retryQueue != "" {
+			queue = retryQueue
+		} else if message.workerOptions != nil && message.workerOptions.RetryQueue != "" {
+			queue = message.workerOptions.RetryQueue
+		}
 		message.Set("queue", queue)
 		message.Set("error_message", fmt.Sprintf("%v", err))
 		retryCount := incrementRetry(message)
 
-		waitDuration := durationToSecondsWithNanoPrecision(
-			time.Duration(
-				secondsToDelay(retryCount),
-			) * time.Second,
-		)
+		waitDuration := durationToSecondsWithNanoPrecision(retryDelay(message, retryCount))
 
-		err = mgr.opts.store.EnqueueRetriedMessage(context.Background(), nowToSecondsWithNanoPrecision()+waitDuration, message.ToJson())
+		at := mgr.dampenRetryStorm(context.Background(), nowToSecondsWithNanoPrecision()+waitDuration)
+		err = mgr.opts.store.EnqueueRetriedMessage(context.Background(), at, message.ToJson())
 
 		// If we can't add the job to the retry queue,
 		// then we shouldn't acknowledge the job, otherwise
-		// it'll disappear into the void.
-		if err != nil {
-			message.ack = false
+		// it'll disappear into the void. With a write buffer,
+		// the retry is written along with the acknowledgement.
+		if err == nil {
+			mgr.emit(Event{Type: EventRetryScheduled, Queue: strings.TrimPrefix(queue, mgr.opts.Namespace), Message: message, RetryAt: time.Unix(0, int64(at*NanoSecondPrecision))})
+		} else {
+			if mgr.writes != nil {
+				message.pendingRetry, message.pendingRetryAt = message.ToJson(), at
+			} else {
+				message.ack = false
+			}
 		}
 	} else {
 		for _, retriesExhaustedHandler := range mgr.retriesExhaustedHandlers {
 			retriesExhaustedHandler(queue, message, err)
 		}
+		if deadLetterQueue, ok := mgr.hasDeadLetterQueue(queue); ok {
+			message.Set("error_message", fmt.Sprintf("%v", err))
+			if dlqErr := mgr.deadLetter(context.Background(), deadLetterQueue, message); dlqErr != nil {
+				message.ack = false
+				return dlqErr
+			}
+		}
 	}
 	return err
 }
@@ -61,6 +80,8 @@ func RetryMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 				}
 
 				if err != nil {
+					// the stack of the deferred call still holds the frames which panicked
+					setErrorBacktrace(message, 3)
 					err = retryProcessError(queue, mgr, message, err)
 				}
 			}
@@ -69,6 +90,7 @@ func RetryMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 
 		err = next(message)
 		if err != nil {
+			setErrorBacktrace(message, 2)
 			err = retryProcessError(queue, mgr, message, err)
 		}
 
@@ -76,11 +98,43 @@ func RetryMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 	}
 }
 
+// setErrorBacktrace records the stack of a failed job as its error_backtrace, like Sidekiq, when its
+// backtrace field asks for it: every frame for true, or up to that many frames for a number. The frames
+// are the panic's, or the caller's of the retry middleware for returned errors, skipping skip frames.
+func setErrorBacktrace(message *Msg, skip int) {
+	limit := 0
+	if all, err := message.Get("backtrace").Bool(); err == nil && all {
+		limit = -1
+	} else if lines, err := message.Get("backtrace").Int(); err == nil && lines > 0 {
+		limit = lines
+	}
+	if limit == 0 {
+		return
+	}
+
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(skip+1, pcs)])
+	var backtrace []string
+	for limit < 0 || len(backtrace) < limit {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			backtrace = append(backtrace, fmt.Sprintf("%s:%d:in `%s'", frame.File, frame.Line, frame.Function))
+		}
+		if !more {
+			break
+		}
+	}
+	message.Set("error_backtrace", backtrace)
+}
+
 func retry(message *Msg) bool {
 	retry := false
 
 	if param, err := message.Get("retry").Bool(); err == nil {
 		retry = param
+	} else if limit, err := message.Get("retry").Int(); err == nil {
+		// Sidekiq sends the number of retries instead of true
+		retry = limit > 0
 	}
 
 	return retry
@@ -93,8 +147,13 @@ func retryCount(message *Msg) int {
 
 func retryMax(message *Msg) int {
 	max := DefaultRetryMax
+	if message.workerOptions != nil && message.workerOptions.MaxRetries > 0 {
+		max = message.workerOptions.MaxRetries
+	}
 	if messageRetryMax, err := message.Get("retry_max").Int(); err == nil && messageRetryMax >= 0 {
 		max = messageRetryMax
+	} else if limit, err := message.Get("retry").Int(); err == nil && limit > 0 {
+		max = limit
 	}
 	return max
 }
@@ -114,6 +173,14 @@ func incrementRetry(message *Msg) (retryCount int) {
 	return
 }
 
+// retryDelay returns how long a job which failed count times waits before its retry
+func retryDelay(message *Msg, count int) time.Duration {
+	if message.workerOptions != nil && message.workerOptions.RetryBackoff != nil {
+		return message.workerOptions.RetryBackoff(count)
+	}
+	return time.Duration(secondsToDelay(count)) * time.Second
+}
+
 func secondsToDelay(count int) int {
 	power := math.Pow(float64(count), 4)
 	return int(power) + 15 + (rand.Intn(30) * (count + 1))
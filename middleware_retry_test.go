@@ -55,6 +55,41 @@ func TestRetryQueue(t *testing.T) {
 	}
 }
 
+func TestRetryErrorBacktrace(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		backtrace string
+		lines     int
+	}{
+		{name: "every frame", backtrace: "true"},
+		{name: "some frames", backtrace: "2", lines: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+			assert.NoError(t, err)
+			mgr := &Manager{opts: opts}
+
+			message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"backtrace\":" + tt.backtrace + "}")
+			wares.build("myqueue", mgr, panickingFunc)(message)
+
+			retries, _ := opts.client.ZRange(ctx, retryQueue(opts.Namespace), 0, 1).Result()
+			message, _ = NewMsg(retries[0])
+			backtrace, err := message.Get("error_backtrace").StringArray()
+			assert.NoError(t, err)
+			if tt.lines > 0 {
+				assert.Len(t, backtrace, tt.lines)
+			}
+			// the first frame is the one which panicked
+			if assert.NotEmpty(t, backtrace) {
+				assert.Contains(t, backtrace[0], "middleware_retry_test.go")
+			}
+		})
+	}
+}
+
 func TestDisableRetries(t *testing.T) {
 	ctx := context.Background()
 
@@ -256,3 +291,46 @@ func TestRetryOnlyToCustomMax(t *testing.T) {
 	count, _ := opts.client.ZCard(ctx, retryQueue(opts.Namespace)).Result()
 	assert.Equal(t, int64(0), count)
 }
+
+func TestIntegerRetryLimit(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	mgr := &Manager{opts: opts}
+
+	// Sidekiq's "retry": 2 enables retries and caps them at 2
+	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":2,\"retry_count\":1}")
+	wares.build("myqueue", mgr, panickingFunc)(message)
+	count, _ := opts.client.ZCard(ctx, retryQueue(opts.Namespace)).Result()
+	assert.Equal(t, int64(1), count)
+
+	message, _ = NewMsg("{\"jid\":\"3\",\"retry\":2,\"retry_count\":2}")
+	wares.build("myqueue", mgr, panickingFunc)(message)
+	count, _ = opts.client.ZCard(ctx, retryQueue(opts.Namespace)).Result()
+	assert.Equal(t, int64(1), count)
+
+	message, _ = NewMsg("{\"jid\":\"4\",\"retry\":0}")
+	wares.build("myqueue", mgr, panickingFunc)(message)
+	count, _ = opts.client.ZCard(ctx, retryQueue(opts.Namespace)).Result()
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRetryToRetryQueue(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	mgr := &Manager{opts: opts}
+
+	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true,\"retry_queue\":\"slow\"}")
+	wares.build("myqueue", mgr, panickingFunc)(message)
+
+	retries, _ := opts.client.ZRange(ctx, retryQueue(opts.Namespace), 0, 1).Result()
+	if assert.Len(t, retries, 1) {
+		retried, _ := NewMsg(retries[0])
+		assert.Equal(t, "slow", retried.Get("queue").MustString())
+	}
+}
@@ -16,7 +16,7 @@ func StatsMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 				}
 
 				if err != nil {
-					incrementStats(mgr, "failed")
+					mgr.recordFailure(queue, message, err, true)
 				}
 			}
 
@@ -24,7 +24,7 @@ func StatsMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 
 		err = next(message)
 		if err != nil {
-			incrementStats(mgr, "failed")
+			mgr.recordFailure(queue, message, err, false)
 		} else {
 			incrementStats(mgr, "processed")
 		}
@@ -82,3 +82,32 @@ func TestFailedStats(t *testing.T) {
 	dayCountInt, _ = strconv.ParseInt(dayCount, 10, 64)
 	assert.Equal(t, int64(1), dayCountInt)
 }
+
+func TestStatsInSeparateStore(t *testing.T) {
+	ctx := context.Background()
+
+	options := testOptionsWithNamespace("prod")
+	options.StatsStore = &StatsStoreOptions{Namespace: "counters", ServerAddr: testServerAddr, Database: testDatabase - 1}
+	opts, err := processOptions(options)
+	assert.NoError(t, err)
+	rc, statsClient := opts.client, opts.statsClient
+	assert.NoError(t, rc.FlushDB(ctx).Err())
+	assert.NoError(t, statsClient.FlushDB(ctx).Err())
+
+	mgr := &Manager{opts: opts}
+	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+	NewMiddlewares(StatsMiddleware).build("myqueue", mgr, func(m *Msg) error {
+		return nil
+	})(message)
+
+	count, _ := statsClient.Get(ctx, "counters:stat:processed").Result()
+	assert.Equal(t, "1", count)
+	exists, _ := rc.Exists(ctx, "prod:stat:processed", "counters:stat:processed").Result()
+	assert.Equal(t, int64(0), exists)
+
+	assert.NoError(t, rc.LPush(ctx, "prod:queue:myqueue", "{}").Err())
+	stats, err := opts.store.GetAllStats(ctx, []string{"myqueue"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Processed)
+	assert.Equal(t, int64(1), stats.Enqueued["prod:myqueue"])
+}
@@ -0,0 +1,151 @@
+package workers
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const defaultTenantConcurrencyDefer = 5 * time.Second
+
+// TenantQuota limits the processing of a tenant's jobs. Zero values don't limit.
+type TenantQuota struct {
+	// Jobs of the tenant started per minute across every manager sharing the Redis namespace
+	JobsPerMinute int64
+
+	// Share of a queue's concurrency in a manager the tenant's jobs may use, between 0 and 1.
+	// A tenant may always run at least one job.
+	MaxConcurrencyShare float64
+}
+
+// TenantQuotaOptions configures per-tenant fair-use quotas
+type TenantQuotaOptions struct {
+	// Optional tenant of a job, defaults to its "tenant" field. Jobs without a tenant aren't limited.
+	Tenant func(message *Msg) string
+
+	// Quota of every tenant, unless overridden in Tenants
+	Quota   TenantQuota
+	Tenants map[string]TenantQuota
+
+	// Optional delay of jobs over their concurrency share, defaults to 5 seconds. Jobs over their
+	// rate are deferred to the next minute.
+	ConcurrencyDefer time.Duration
+}
+
+func (o TenantQuotaOptions) quota(tenant string) TenantQuota {
+	if quota, ok := o.Tenants[tenant]; ok {
+		return quota
+	}
+	return o.Quota
+}
+
+type tenantQuotas struct {
+	opts TenantQuotaOptions
+
+	lock    sync.Mutex
+	running map[string]map[string]int // by queue, then tenant
+}
+
+// acquire reports how long the job must be deferred, or zero once it may run. Jobs which may run
+// must be released.
+func (q *tenantQuotas) acquire(ctx context.Context, mgr *Manager, queue, tenant string) (time.Duration, error) {
+	quota := q.opts.quota(tenant)
+
+	if quota.MaxConcurrencyShare > 0 {
+		limit := int(math.Floor(quota.MaxConcurrencyShare * float64(mgr.queueConcurrency(queue))))
+		if limit < 1 {
+			limit = 1
+		}
+		q.lock.Lock()
+		if q.running[queue][tenant] >= limit {
+			q.lock.Unlock()
+			return q.opts.ConcurrencyDefer, nil
+		}
+		if q.running[queue] == nil {
+			q.running[queue] = map[string]int{}
+		}
+		q.running[queue][tenant]++
+		q.lock.Unlock()
+	}
+
+	if quota.JobsPerMinute > 0 {
+		now := time.Now()
+		window := now.Unix() / 60
+		started, err := mgr.opts.store.IncrementTenantJobs(ctx, tenant, window, 2*time.Minute)
+		if err != nil || started > quota.JobsPerMinute {
+			q.release(queue, tenant)
+			return time.Unix((window+1)*60, 0).Sub(now), err
+		}
+	}
+	return 0, nil
+}
+
+func (q *tenantQuotas) release(queue, tenant string) {
+	if q.opts.quota(tenant).MaxConcurrencyShare <= 0 {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.running[queue][tenant] > 0 {
+		q.running[queue][tenant]--
+	}
+}
+
+// queueConcurrency returns the concurrency of the worker of a namespaced queue
+func (m *Manager) queueConcurrency(queue string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, w := range m.workers {
+		for _, source := range w.sourceQueues() {
+			if m.opts.Namespace+source == queue {
+				return w.getConcurrency()
+			}
+		}
+	}
+	return 1
+}
+
+// TenantQuotaMiddleware enforces per-tenant processing quotas, so a single tenant can't monopolize
+// shared workers. Jobs over their tenant's quota are acknowledged and moved to the schedule set, to be
+// tried again once the tenant is back under its quota.
+func TenantQuotaMiddleware(opts TenantQuotaOptions) MiddlewareFunc {
+	if opts.Tenant == nil {
+		opts.Tenant = func(message *Msg) string {
+			tenant, _ := message.Get("tenant").String()
+			return tenant
+		}
+	}
+	if opts.ConcurrencyDefer <= 0 {
+		opts.ConcurrencyDefer = defaultTenantConcurrencyDefer
+	}
+	quotas := &tenantQuotas{opts: opts, running: map[string]map[string]int{}}
+
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		return func(message *Msg) error {
+			tenant := opts.Tenant(message)
+			if tenant == "" {
+				return next(message)
+			}
+
+			ctx := context.Background()
+			deferBy, err := quotas.acquire(ctx, mgr, queue, tenant)
+			if err != nil {
+				mgr.logger.Println("ERR: couldn't check the quota of tenant", tenant, ":", err)
+				return next(message)
+			}
+			if deferBy == 0 {
+				defer quotas.release(queue, tenant)
+				return next(message)
+			}
+
+			at := timeToSecondsWithNanoPrecision(time.Now().Add(deferBy))
+			if err := mgr.opts.store.EnqueueScheduledMessage(ctx, at, message.ToJson()); err != nil {
+				// keep the job in the in-progress queue rather than losing it
+				message.ack = false
+				return err
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantQuotaMiddleware_ConcurrencyShare(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("shared", 4, func(m *Msg) error { return nil })
+
+	started := make(chan bool)
+	release := make(chan bool)
+	job := NewMiddlewares(TenantQuotaMiddleware(TenantQuotaOptions{
+		Quota: TenantQuota{MaxConcurrencyShare: 0.5},
+	})).build("prod:shared", mgr, func(m *Msg) error {
+		started <- true
+		<-release
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			message, _ := NewMsg(`{"jid":"1","class":"Sync","tenant":"acme","queue":"shared"}`)
+			job(message)
+		}()
+		<-started
+	}
+
+	// acme uses its share of the 4 slots, its next job is deferred
+	message, _ := NewMsg(`{"jid":"2","class":"Sync","tenant":"acme","queue":"shared"}`)
+	assert.NoError(t, job(message))
+	assert.True(t, message.ack)
+	scheduled, _ := opts.client.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Result()
+	assert.Equal(t, int64(1), scheduled)
+
+	// other tenants and jobs without a tenant still run
+	for _, payload := range []string{`{"jid":"3","tenant":"globex"}`, `{"jid":"4"}`} {
+		wg.Add(1)
+		go func(payload string) {
+			defer wg.Done()
+			message, _ := NewMsg(payload)
+			job(message)
+		}(payload)
+		<-started
+	}
+
+	for i := 0; i < 4; i++ {
+		release <- true
+	}
+	wg.Wait()
+
+	// acme is back under its share
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		message, _ := NewMsg(`{"jid":"5","class":"Sync","tenant":"acme","queue":"shared"}`)
+		job(message)
+	}()
+	<-started
+	release <- true
+	wg.Wait()
+}
+
+func TestTenantQuotaMiddleware_JobsPerMinute(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	ran := map[string]int{}
+	job := NewMiddlewares(TenantQuotaMiddleware(TenantQuotaOptions{
+		Quota:   TenantQuota{JobsPerMinute: 2},
+		Tenants: map[string]TenantQuota{"vip": {}},
+		Tenant: func(m *Msg) string {
+			return m.Get("org").MustString()
+		},
+	})).build("prod:shared", mgr, func(m *Msg) error {
+		ran[m.Get("org").MustString()]++
+		return nil
+	})
+
+	for i := 0; i < 5; i++ {
+		for _, org := range []string{"acme", "vip"} {
+			message, _ := NewMsg(fmt.Sprintf(`{"jid":"%d","class":"Sync","org":"%s"}`, i, org))
+			assert.NoError(t, job(message))
+		}
+	}
+	assert.Equal(t, 2, ran["acme"])
+	assert.Equal(t, 5, ran["vip"])
+
+	deferred, _ := opts.client.ZRangeWithScores(ctx, "prod:"+storage.ScheduledJobsKey, 0, -1).Result()
+	assert.Len(t, deferred, 3)
+	assert.Greater(t, deferred[0].Score, nowToSecondsWithNanoPrecision())
+}
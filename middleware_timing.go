@@ -0,0 +1,113 @@
+package workers
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const handlerTimingName = "handler"
+
+// MiddlewareTiming contains the time spent in one step of a queue's processing pipeline
+type MiddlewareTiming struct {
+	Name  string `json:"name"`
+	Calls int64  `json:"calls"`
+	// Time spent in the step itself, excluding the rest of the pipeline it calls into
+	TotalMicros int64 `json:"total_us"`
+	AvgMicros   int64 `json:"avg_us"`
+}
+
+type middlewareTimer struct {
+	name  string
+	calls int64
+	// nanoseconds spent in this step and everything after it
+	inclusiveNanos int64
+}
+
+func (t *middlewareTimer) wrap(next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		start := time.Now()
+		defer func() {
+			atomic.AddInt64(&t.inclusiveNanos, int64(time.Since(start)))
+			atomic.AddInt64(&t.calls, 1)
+		}()
+		return next(message)
+	}
+}
+
+// middlewareTimers holds one timer per middleware followed by the timer of the handler
+type middlewareTimers []*middlewareTimer
+
+func newMiddlewareTimers(mids Middlewares) middlewareTimers {
+	timers := make(middlewareTimers, 0, len(mids)+1)
+	for _, mid := range mids {
+		timers = append(timers, &middlewareTimer{name: middlewareName(mid)})
+	}
+	return append(timers, &middlewareTimer{name: handlerTimingName})
+}
+
+func (timers middlewareTimers) snapshot() []MiddlewareTiming {
+	inclusive := make([]int64, len(timers)+1)
+	calls := make([]int64, len(timers))
+	for i, t := range timers {
+		inclusive[i] = atomic.LoadInt64(&t.inclusiveNanos)
+		calls[i] = atomic.LoadInt64(&t.calls)
+	}
+
+	res := make([]MiddlewareTiming, len(timers))
+	for i, t := range timers {
+		self := inclusive[i] - inclusive[i+1]
+		if self < 0 {
+			// the next step finished recording before this one did
+			self = 0
+		}
+		timing := MiddlewareTiming{
+			Name:        t.name,
+			Calls:       calls[i],
+			TotalMicros: time.Duration(self).Microseconds(),
+		}
+		if calls[i] > 0 {
+			timing.AvgMicros = timing.TotalMicros / calls[i]
+		}
+		res[i] = timing
+	}
+	return res
+}
+
+func middlewareName(mid MiddlewareFunc) string {
+	return funcName(mid)
+}
+
+// funcName returns the package-qualified name of the function fn
+func funcName(fn interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// registerMiddlewareTimers expects the caller to hold m.lock
+func (m *Manager) registerMiddlewareTimers(queue string, timers middlewareTimers) {
+	if m.middlewareTimers == nil {
+		m.middlewareTimers = map[string]middlewareTimers{}
+	}
+	m.middlewareTimers[queue] = timers
+}
+
+// middlewareTimings returns the timings of every queue's pipeline, keyed by namespaced queue
+func (m *Manager) middlewareTimings() map[string][]MiddlewareTiming {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := map[string][]MiddlewareTiming{}
+	for queue, timers := range m.middlewareTimers {
+		res[queue] = timers.snapshot()
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func slowAuditMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		time.Sleep(30 * time.Millisecond)
+		return next(message)
+	}
+}
+
+func TestMiddlewareTimings(t *testing.T) {
+	mgr := &Manager{}
+
+	job := NewMiddlewares(NopMiddleware, slowAuditMiddleware).build("prod:timed", mgr, func(m *Msg) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	msg, _ := NewMsg(`{"jid":"1"}`)
+	assert.NoError(t, job(msg))
+	assert.NoError(t, job(msg))
+
+	timings := mgr.middlewareTimings()
+	assert.Contains(t, timings, "prod:timed")
+
+	queueTimings := timings["prod:timed"]
+	assert.Len(t, queueTimings, 3)
+	assert.Equal(t, "go-workers2.NopMiddleware", queueTimings[0].Name)
+	assert.Equal(t, "go-workers2.slowAuditMiddleware", queueTimings[1].Name)
+	assert.Equal(t, handlerTimingName, queueTimings[2].Name)
+
+	for _, timing := range queueTimings {
+		assert.Equal(t, int64(2), timing.Calls)
+	}
+
+	// the audit step is charged for its own sleep only, not for the handler's
+	assert.True(t, queueTimings[1].AvgMicros >= 30000)
+	assert.True(t, queueTimings[1].AvgMicros < 40000)
+	assert.True(t, queueTimings[2].AvgMicros >= 10000)
+	assert.True(t, queueTimings[0].AvgMicros < 5000)
+}
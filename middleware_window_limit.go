@@ -0,0 +1,91 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultMaxOverrated = 20
+
+// WindowLimiter is a rolling-window rate limiter shared with Sidekiq Enterprise: at most Count jobs
+// acquire it during any Interval, across the Go managers and the Ruby processes using the limiter of the
+// same Name, such as Sidekiq::Limiter.window("stripe", 10, :second).
+type WindowLimiter struct {
+	Name     string
+	Count    int
+	Interval time.Duration
+}
+
+// WindowLimitOptions configures WindowLimitMiddleware
+type WindowLimitOptions struct {
+	// Optional limiter of every job of the queue, and limiters of some of its classes, which take
+	// precedence
+	Limiter *WindowLimiter
+	Classes map[string]WindowLimiter
+
+	// Optional number of times a job over its limit is rescheduled before it fails into the retry
+	// pipeline, defaults to 20 as in Sidekiq Enterprise
+	MaxOverrated int
+}
+
+func (o WindowLimitOptions) limiter(class string) (WindowLimiter, bool) {
+	if limiter, ok := o.Classes[class]; ok {
+		return limiter, true
+	}
+	if o.Limiter != nil {
+		return *o.Limiter, true
+	}
+	return WindowLimiter{}, false
+}
+
+// OverLimitError is returned for a job which was over its rate limit more than WindowLimitOptions.MaxOverrated times
+type OverLimitError struct {
+	Limiter   string
+	Overrated int
+}
+
+func (e *OverLimitError) Error() string {
+	return fmt.Sprintf("over the rate limit of %s %d times", e.Limiter, e.Overrated)
+}
+
+// WindowLimitMiddleware rate limits the jobs of a queue with limiters shared with Sidekiq Enterprise.
+// Jobs over their limit are acknowledged and moved to the schedule set until the window has room for
+// them, counting the reschedules in the "overrated" field of the job as Sidekiq does.
+func WindowLimitMiddleware(opts WindowLimitOptions) MiddlewareFunc {
+	if opts.MaxOverrated <= 0 {
+		opts.MaxOverrated = defaultMaxOverrated
+	}
+
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		return func(message *Msg) error {
+			limiter, ok := opts.limiter(message.Class())
+			if !ok {
+				return next(message)
+			}
+
+			ctx := context.Background()
+			wait, err := mgr.opts.store.AcquireWindowLimit(ctx, limiter.Name, limiter.Count, limiter.Interval)
+			if err != nil {
+				mgr.logger.Println("ERR: couldn't check the rate limit of", limiter.Name, ":", err)
+				return next(message)
+			}
+			if wait == 0 {
+				return next(message)
+			}
+
+			overrated, _ := message.Get("overrated").Int()
+			if overrated >= opts.MaxOverrated {
+				return &OverLimitError{Limiter: limiter.Name, Overrated: overrated}
+			}
+			message.Set("overrated", overrated+1)
+			at := timeToSecondsWithNanoPrecision(time.Now().Add(wait))
+			if err := mgr.opts.store.EnqueueScheduledMessage(ctx, at, message.ToJson()); err != nil {
+				// keep the job in the in-progress queue rather than losing it
+				message.ack = false
+				return err
+			}
+			return nil
+		}
+	}
+}
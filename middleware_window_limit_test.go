@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowLimitMiddleware(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	limits := WindowLimitOptions{
+		Classes:      map[string]WindowLimiter{"ChargeJob": {Name: "stripe", Count: 2, Interval: time.Minute}},
+		MaxOverrated: 1,
+	}
+
+	// two managers of the fleet share the limiter
+	ran := 0
+	var jobs []JobFunc
+	for i := 0; i < 2; i++ {
+		mgr, err := newManager(opts)
+		assert.NoError(t, err)
+		jobs = append(jobs, NewMiddlewares(WindowLimitMiddleware(limits)).build("prod:charges", mgr, func(m *Msg) error {
+			ran++
+			return nil
+		}))
+	}
+
+	for i, jid := range []string{"1", "2"} {
+		message, _ := NewMsg(`{"jid":"` + jid + `","class":"ChargeJob","queue":"charges"}`)
+		assert.NoError(t, jobs[i](message))
+	}
+	assert.Equal(t, 2, ran)
+	acquired, _ := opts.client.ZCard(ctx, "prod:"+storage.WindowLimiterPrefix+"stripe").Result()
+	assert.Equal(t, int64(2), acquired)
+
+	// the third is rescheduled once its window has room
+	message, _ := NewMsg(`{"jid":"3","class":"ChargeJob","queue":"charges"}`)
+	assert.NoError(t, jobs[0](message))
+	assert.Equal(t, 2, ran)
+	assert.True(t, message.ack)
+	scheduled, _ := opts.client.ZRangeWithScores(ctx, "prod:"+storage.ScheduledJobsKey, 0, -1).Result()
+	if assert.Len(t, scheduled, 1) {
+		rescheduled, _ := NewMsg(scheduled[0].Member.(string))
+		assert.Equal(t, 1, rescheduled.Get("overrated").MustInt())
+		at := time.Unix(0, int64(scheduled[0].Score*float64(time.Second)))
+		assert.True(t, at.After(time.Now().Add(50*time.Second)), "rescheduled too early")
+
+		// and fails once it was over the limit too often
+		err := jobs[1](rescheduled)
+		assert.IsType(t, &OverLimitError{}, err)
+	}
+
+	// other classes aren't limited
+	message, _ = NewMsg(`{"jid":"4","class":"RefundJob","queue":"charges"}`)
+	assert.NoError(t, jobs[1](message))
+	assert.Equal(t, 3, ran)
+}
+
+func TestWindowLimitExpires(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	store := opts.store
+	ctx := context.Background()
+
+	wait, err := store.AcquireWindowLimit(ctx, "api", 1, 200*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, err = store.AcquireWindowLimit(ctx, "api", 1, 200*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, wait > 0 && wait <= 200*time.Millisecond, wait)
+
+	time.Sleep(wait + 10*time.Millisecond)
+	wait, err = store.AcquireWindowLimit(ctx, "api", 1, 200*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+}
@@ -1,6 +1,7 @@
 package workers
 
 import (
+	"context"
 	"log"
 	"os"
 	"reflect"
@@ -22,6 +23,21 @@ type Msg struct {
 	original  string
 	ack       bool
 	startedAt int64
+
+	serializer Serializer
+	checkpoint *jobCheckpoint
+
+	// queue the message was fetched from, by the fetchers of workers of several queues
+	fetchedFrom string
+	// producer of the children of the job, see Producer
+	producer *Producer
+	// options of the worker running the job, when it was added by AddWorkerWithOptions
+	workerOptions *WorkerOptions
+	// context of the job, see Context
+	ctx context.Context
+	// retry Redis refused, written by the fetcher before acknowledging the job
+	pendingRetry   string
+	pendingRetryAt float64
 }
 
 // Args is the set of parameters for a message
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,13 +21,28 @@ const (
 
 // Options contains the set of configuration options for a manager and/or producer
 type Options struct {
-	ProcessID    string
-	Namespace    string
+	ProcessID string
+	Namespace string
+
+	// Optional environment, such as staging or production, prefixing every key ahead of Namespace:
+	// queues, stats, schedules and heartbeats of one environment are never seen by another
+	Environment string
+
+	// Optional tag and labels telling this process apart from the others in Sidekiq's Web UI and in
+	// stats. Tag defaults to the namespace, labels are shown as key=value.
+	Tag    string
+	Labels map[string]string
+
 	PollInterval time.Duration
 	Database     int
 	Password     string
 	PoolSize     int
 
+	// Optional size of a separate connection pool used only for blocking fetches, so that
+	// long BRPOPLPUSH calls never hold the connections needed for acks, stats and scheduling.
+	// Zero shares PoolSize connections between fetches and everything else.
+	FetchPoolSize int
+
 	// Provide one of ServerAddr or (SentinelAddrs + RedisMasterName)
 	ServerAddr      string
 	SentinelAddrs   string
@@ -40,17 +56,175 @@ type Options struct {
 	// Define Heartbeat to enable heartbeat
 	Heartbeat *HeartbeatOptions
 
+	// Optional active/passive mode, where only the manager holding a Redis lease fetches jobs.
+	// Standby managers start inactive, and can't also be prioritized managers.
+	Standby *StandbyOptions
+
+	// Optional upper bound on how long a stopping manager waits for in-flight jobs.
+	// Zero waits until every job has finished.
+	ShutdownTimeout time.Duration
+
+	// Optional period over which a queue's concurrency ramps up from 1 to its target
+	// every time its worker starts, with per-queue overrides in QueueWarmUp
+	WarmUp      time.Duration
+	QueueWarmUp map[string]time.Duration
+
+	// Optional burst of concurrency for the queues with a large backlog when the manager starts
+	Burst *BurstOptions
+
+	// Optional adjustment of the concurrency of every queue to its latency, replacing Burst
+	Autoscale *AutoscaleOptions
+
+	// Optional sharing of the unused runners of empty queues with the queues which have a backlog,
+	// up to a hard ceiling above the concurrency they run at
+	Spillover *SpilloverOptions
+
+	// Optional signal, such as syscall.SIGUSR1, on which a running manager logs its in-flight jobs
+	InFlightDumpSignal os.Signal
+
+	// Optional transactional outbox producers can enqueue to through a SQL transaction.
+	// Managers with an outbox DB relay committed jobs to Redis.
+	Outbox *OutboxOptions
+
+	// Optional map of old job class names to their current names, applied to the fetched jobs before
+	// any middleware, to the stored jobs moved back to a queue: scheduled jobs, retries, requeued,
+	// parked and imported jobs, and to the dead-lettered jobs handed to consumers
+	ClassAliases map[string]string
+
+	// Optional restriction of the job classes this manager runs
+	ClassFilter *ClassFilterOptions
+
+	// Optional holding back of the jobs of the classes disabled across the fleet by Manager.DisableClass
+	KillSwitch *KillSwitchOptions
+
+	// Optional lifetime of cached reads of slowly-changing metadata such as the
+	// known queues and registered processes. Zero disables the cache. The changes other
+	// processes make to them may go unseen for up to this lifetime.
+	MetadataCacheTTL time.Duration
+
+	// Optional check of enqueued payloads against the Sidekiq job format
+	PayloadValidation PayloadValidationMode
+
+	// Optional limit on how deep a producer lets a queue grow before rejecting,
+	// rerouting or holding back immediate enqueues
+	QueueDepthGuard *QueueDepthGuardOptions
+
+	// Optional publication of the backpressure state of the queues this manager works, for producers
+	Backpressure *BackpressureOptions
+	// Optional holding back or deferral by producers of the jobs of queues under backpressure
+	ProducerBackpressure *ProducerBackpressureOptions
+
+	// Optional archival of the record of every job run to a sink outliving Redis retention
+	Archive *ArchiveOptions
+
+	// Optional buffering of the acknowledgements and retries Redis refuses, such as while it's out of memory
+	WriteBuffer *WriteBufferOptions
+
+	// Optional release of the unique and dedupe locks of jobs their producer died before writing
+	LockJanitor *LockJanitorOptions
+
+	// Optional window during which producers skip enqueues duplicating the class and args
+	// of an earlier job, overridden by EnqueueOptions.DedupeFor. It doesn't apply to the jobs of
+	// cron ticks and batch callbacks.
+	DedupWindow time.Duration
+
+	// Optional generator of the JIDs of enqueued jobs, such as one embedding a tenant prefix.
+	// Defaults to 24 random hex characters, like Sidekiq's.
+	JIDGenerator func() string
+
+	// Optional serializers of the args of the jobs of some queues, JSON for the others
+	QueueSerializers map[string]Serializer
+
+	// Optional codecs applied by the store to the serialized args of the jobs of some queues, such as
+	// compression, encryption and signing, and decoding the encoded jobs of every queue.
+	// ArgsCompression doesn't apply to these queues.
+	QueueCodecs map[string]CodecChain
+
+	// Optional compression of large args by producers
+	ArgsCompression *ArgsCompressionOptions
+
+	// Optional payload fields the jobs enqueued through Msg.Producer copy from the running job,
+	// defaults to tenant, trace_id and traceparent
+	PropagatedFields []string
+
+	// Optional middleware run by producers on every job before it is written to Redis
+	ProducerMiddlewares ProducerMiddlewares
+
+	// Optional token-bucket rates of the jobs each producer enqueues, by queue and by class
+	EnqueueRateLimits *EnqueueRateLimitOptions
+
+	// Optional retry of producer writes to Redis which failed, and callback receiving the jobs
+	// which still couldn't be written
+	EnqueueRetry     *EnqueueRetryOptions
+	OnEnqueueFailure EnqueueFailureFunc
+
+	// Optional check that Redis confirms every job written by EnqueueWithReceipt, failing the enqueue
+	// with ErrEnqueueNotConfirmed otherwise
+	VerifyEnqueues bool
+
+	// Optional hook receiving a metric for every job producers write to Redis
+	OnEnqueueMetric EnqueueMetricFunc
+
+	// Optional configuration of the buffer producers write EnqueueAsync jobs from
+	AsyncBuffer *AsyncBufferOptions
+
+	// Optional redaction of the job args shown by the stats and retries APIs
+	RedactArgs RedactArgsFunc
+
+	// Optional spreading of retries due in a window already holding many others
+	RetryStorm *RetryStormOptions
+
+	// Optional dead-letter queues by queue name, keeping the jobs of the queue which ran out of
+	// retries apart from other queues
+	DeadLetterQueues map[string]DeadLetterOptions
+
+	// Optional classification of job failures, such as telling dependency errors from bugs, ahead of
+	// the built-in timeout, panic, non_retryable and error categories counted in stats
+	FailureClassifier FailureClassifierFunc
+
+	// Optional size and decay of the sample of recent failures managers keep per job class
+	FailureSample *FailureSampleOptions
+
+	// Optional sampling of the memory allocated and the GC pauses seen by jobs, by class
+	AllocationProfile *AllocationProfileOptions
+
+	// Optional namespace and Redis the processed and failed counters are kept in, apart from the jobs
+	StatsStore *StatsStoreOptions
+
+	// Optional authorization of the mutating API and gwctl operations, such as queue purges, and sink
+	// recording them along with their actor, allowed or denied
+	Authorize AuthorizeFunc
+	AuditSink AuditSinkFunc
+
 	// Log
 	Logger *log.Logger
 
-	client *redis.Client
-	store  storage.Store
+	client      *redis.Client
+	fetchClient *redis.Client
+	statsClient *redis.Client
+	store       storage.Store
 }
 
 func (o *Options) Client() *redis.Client {
 	return o.client
 }
 
+// StatsStoreOptions configures where stats counters are kept. High-churn counters can then live
+// on a cheaper Redis than the jobs, or be shared by managers working in different namespaces.
+type StatsStoreOptions struct {
+	// Optional namespace of the stats keys, defaults to the namespace of the jobs
+	Namespace string
+
+	// Optional Redis server of the stats keys, defaults to the Redis of the jobs. Client takes
+	// precedence over ServerAddr.
+	ServerAddr     string
+	Password       string
+	Database       int
+	PoolSize       int
+	RedisTLSConfig *tls.Config
+	Client         *redis.Client
+}
+
 type HeartbeatOptions struct {
 	// Optional heartbeat interval config
 	Interval time.Duration
@@ -58,9 +232,16 @@ type HeartbeatOptions struct {
 	// redis eviction ttl config
 	HeartbeatTTL time.Duration
 
+	// Optional hook returning extra fields written into the process hash on every beat.
+	// Fields already used by the heartbeat itself are ignored.
+	BeatFields HeartbeatFieldsFunc
+
 	PrioritizedManager *PrioritizedManagerOptions
 }
 
+// HeartbeatFieldsFunc returns custom fields to add to a manager's heartbeat
+type HeartbeatFieldsFunc func(manager *Manager) map[string]string
+
 type PrioritizedManagerOptions struct {
 	ManagerPriority     int
 	TotalActiveManagers int
@@ -109,19 +290,65 @@ func processOptions(options Options) (Options, error) {
 		options.Logger = log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds)
 	}
 
-	redisStore := storage.NewRedisStore(options.Namespace, options.client, options.Logger)
-	options.store = redisStore
+	options.fetchClient = newFetchClient(options, options.client)
+	options.statsClient = newStatsClient(options)
+	options.store = newStore(options)
 
-	if options.Heartbeat != nil {
-		if options.Heartbeat.Interval <= 0 {
-			options.Heartbeat.Interval = defaultHeartbeatInterval
-		}
-		if options.Heartbeat.HeartbeatTTL <= 0 {
-			options.Heartbeat.HeartbeatTTL = defaultHeartbeatTTL
-		}
+	return options, nil
+}
+
+func newStore(options Options) storage.Store {
+	return storage.NewRedisStore(options.Namespace, options.client, options.Logger,
+		storage.WithMetadataCache(options.MetadataCacheTTL),
+		storage.WithFetchClient(options.fetchClient),
+		storage.WithStatsStore(statsNamespace(options), options.statsClient),
+		storage.WithQueueCodecs(options.QueueCodecs))
+}
+
+// newStatsClient connects to the Redis of the stats keys, when it isn't the Redis of the jobs
+func newStatsClient(options Options) *redis.Client {
+	stats := options.StatsStore
+	if stats == nil {
+		return nil
 	}
+	if stats.Client != nil {
+		return stats.Client
+	}
+	if stats.ServerAddr == "" {
+		return nil
+	}
+	poolSize := stats.PoolSize
+	if poolSize == 0 {
+		poolSize = 1
+	}
+	return redis.NewClient(&redis.Options{
+		IdleTimeout: 240 * time.Second,
+		Password:    stats.Password,
+		DB:          stats.Database,
+		PoolSize:    poolSize,
+		Addr:        stats.ServerAddr,
+		TLSConfig:   stats.RedisTLSConfig,
+	})
+}
 
-	return options, nil
+func statsNamespace(options Options) string {
+	if options.StatsStore == nil || options.StatsStore.Namespace == "" {
+		return options.Namespace
+	}
+	if options.Environment != "" {
+		return options.Environment + ":" + options.StatsStore.Namespace + ":"
+	}
+	return options.StatsStore.Namespace + ":"
+}
+
+// newFetchClient creates the dedicated fetch pool, connecting the same way as client
+func newFetchClient(options Options, client *redis.Client) *redis.Client {
+	if options.FetchPoolSize <= 0 {
+		return nil
+	}
+	fetchOptions := *client.Options()
+	fetchOptions.PoolSize = options.FetchPoolSize
+	return redis.NewClient(&fetchOptions)
 }
 
 func processOptionsWithRedisClient(options Options, client *redis.Client) (Options, error) {
@@ -133,6 +360,10 @@ func processOptionsWithRedisClient(options Options, client *redis.Client) (Optio
 	if client == nil {
 		return Options{}, errors.New("redis client is nil; Redis client is not configured")
 	}
+	// a client's connections all select the same database, so managers sharing it are told apart by namespace
+	if options.Database != 0 && options.Database != client.Options().DB {
+		return Options{}, errors.New("options require database " + strconv.Itoa(options.Database) + " but the redis client uses another; use a Namespace to share a client")
+	}
 
 	options.client = client
 
@@ -140,8 +371,9 @@ func processOptionsWithRedisClient(options Options, client *redis.Client) (Optio
 		options.Logger = log.New(os.Stdout, "go-workers2: ", log.Ldate|log.Lmicroseconds)
 	}
 
-	redisStore := storage.NewRedisStore(options.Namespace, options.client, options.Logger)
-	options.store = redisStore
+	options.fetchClient = newFetchClient(options, options.client)
+	options.statsClient = newStatsClient(options)
+	options.store = newStore(options)
 
 	return options, nil
 }
@@ -155,13 +387,59 @@ func validateGeneralOptions(options Options) (Options, error) {
 		options.Namespace += ":"
 	}
 
+	if options.Environment != "" {
+		if strings.Contains(options.Environment, ":") {
+			return Options{}, errors.New("environment " + options.Environment + " can't contain ':'")
+		}
+		options.Namespace = options.Environment + ":" + options.Namespace
+	}
+
 	if options.PollInterval <= 0 {
 		options.PollInterval = 15 * time.Second
 	}
 
-	if options.Heartbeat != nil &&
-		options.Heartbeat.Interval >= options.Heartbeat.HeartbeatTTL {
-		return Options{}, errors.New("invalid heartbeat configuration, heartbeat interval longer than or equal to heartbeat tll")
+	if options.Outbox != nil {
+		outbox, err := validateOutboxOptions(*options.Outbox)
+		if err != nil {
+			return Options{}, err
+		}
+		options.Outbox = &outbox
+	}
+
+	if options.ArgsCompression != nil {
+		switch options.ArgsCompression.Algorithm {
+		case "", CompressionZlib, CompressionGzip:
+		default:
+			return Options{}, errors.New("unknown args compression algorithm " + string(options.ArgsCompression.Algorithm))
+		}
+	}
+
+	if options.ClassFilter != nil {
+		if err := options.ClassFilter.validate(); err != nil {
+			return Options{}, err
+		}
+	}
+
+	if options.Archive != nil && options.Archive.Sink == nil {
+		return Options{}, errors.New("archive requires a Sink")
+	}
+
+	if options.Standby != nil && options.Heartbeat != nil && options.Heartbeat.PrioritizedManager != nil {
+		return Options{}, errors.New("standby managers can't be prioritized managers")
+	}
+
+	if options.Heartbeat != nil {
+		heartbeat := *options.Heartbeat
+		if heartbeat.Interval <= 0 {
+			heartbeat.Interval = defaultHeartbeatInterval
+		}
+		if heartbeat.HeartbeatTTL <= 0 {
+			heartbeat.HeartbeatTTL = defaultHeartbeatTTL
+		}
+		if heartbeat.Interval >= heartbeat.HeartbeatTTL {
+			return Options{}, errors.New("invalid heartbeat configuration, heartbeat interval longer than or equal to heartbeat tll")
+		}
+		options.Heartbeat = &heartbeat
 	}
 
 	return options, nil
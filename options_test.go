@@ -1,10 +1,12 @@
 package workers
 
 import (
+	"context"
 	"crypto/tls"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,6 +30,45 @@ func TestRedisPoolConfig(t *testing.T) {
 	assert.Equal(t, 20, opts.client.Options().PoolSize)
 }
 
+func TestRedisFetchPoolConfig(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := processOptions(Options{
+		ServerAddr: "localhost:6379",
+		ProcessID:  "1",
+		PoolSize:   5,
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, opts.fetchClient)
+
+	opts, err = processOptions(Options{
+		ServerAddr:    "localhost:6379",
+		ProcessID:     "1",
+		PoolSize:      5,
+		FetchPoolSize: 3,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, opts.client.Options().PoolSize)
+	assert.Equal(t, 3, opts.fetchClient.Options().PoolSize)
+	assert.Equal(t, "localhost:6379", opts.fetchClient.Options().Addr)
+
+	// fetches go through the dedicated pool
+	opts.client.Del(ctx, "queue:fetchpool", "queue:fetchpool:inprogress")
+	opts.client.LPush(ctx, "queue:fetchpool", "message")
+	before := opts.client.PoolStats()
+	message, err := opts.store.DequeueMessage(ctx, "fetchpool", "fetchpool:inprogress", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "message", message)
+	after := opts.client.PoolStats()
+	assert.Equal(t, before.Hits+before.Misses, after.Hits+after.Misses)
+	assert.NotZero(t, opts.fetchClient.PoolStats().TotalConns)
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", PoolSize: 4})
+	opts, err = processOptionsWithRedisClient(Options{ProcessID: "1", FetchPoolSize: 2}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, opts.fetchClient.Options().PoolSize)
+}
+
 func TestRedisPoolConfigTLS(t *testing.T) {
 	opts, err := processOptions(Options{
 		ServerAddr: "localhost:6379",
@@ -99,6 +140,37 @@ func TestAddsColonToNamespace(t *testing.T) {
 	assert.Equal(t, "prod:", opts.Namespace)
 }
 
+func TestEnvironmentPrefixesNamespace(t *testing.T) {
+	opts, err := processOptions(Options{
+		ServerAddr:  "localhost:6379",
+		ProcessID:   "1",
+		Namespace:   "prod",
+		Environment: "staging",
+		StatsStore:  &StatsStoreOptions{Namespace: "stats"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "staging:prod:", opts.Namespace)
+	assert.Equal(t, "staging:stats:", statsNamespace(opts))
+
+	opts, err = processOptions(Options{
+		ServerAddr:  "localhost:6379",
+		ProcessID:   "1",
+		Environment: "staging",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "staging:", opts.Namespace)
+	assert.Equal(t, "staging:", statsNamespace(opts))
+
+	_, err = processOptions(Options{
+		ServerAddr:  "localhost:6379",
+		ProcessID:   "1",
+		Environment: "staging:eu",
+	})
+	assert.Error(t, err)
+}
+
 func TestDefaultPollIntervalConfig(t *testing.T) {
 	opts, err := processOptions(Options{
 		ServerAddr: "localhost:6379",
@@ -155,3 +227,30 @@ func TestSentinelConfigNoMaster(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestHeartbeatConfigDefaults(t *testing.T) {
+	// only the interval is set, the ttl falls back to its default
+	opts, err := processOptions(Options{
+		ServerAddr: "localhost:6379",
+		ProcessID:  "1",
+		Heartbeat:  &HeartbeatOptions{Interval: time.Second},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, opts.Heartbeat.Interval)
+	assert.Equal(t, defaultHeartbeatTTL, opts.Heartbeat.HeartbeatTTL)
+
+	opts, err = processOptionsWithRedisClient(Options{
+		ProcessID: "1",
+		Heartbeat: &HeartbeatOptions{},
+	}, redis.NewClient(&redis.Options{}))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultHeartbeatInterval, opts.Heartbeat.Interval)
+	assert.Equal(t, defaultHeartbeatTTL, opts.Heartbeat.HeartbeatTTL)
+
+	_, err = processOptions(Options{
+		ServerAddr: "localhost:6379",
+		ProcessID:  "1",
+		Heartbeat:  &HeartbeatOptions{Interval: 2 * time.Minute},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OutboxPlaceholder selects the bind parameter syntax of the outbox SQL statements
+type OutboxPlaceholder int
+
+const (
+	// OutboxPlaceholderQuestion uses ? parameters (MySQL, SQLite)
+	OutboxPlaceholderQuestion OutboxPlaceholder = iota
+	// OutboxPlaceholderDollar uses $1 parameters (PostgreSQL)
+	OutboxPlaceholderDollar
+)
+
+const (
+	defaultOutboxTable        = "go_workers_outbox"
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 100
+
+	// outboxLease elects the single relay of the managers sharing an outbox table
+	outboxLease            = "outbox"
+	minOutboxLeaseDuration = 15 * time.Second
+)
+
+// OutboxOptions configures the transactional outbox. The table is expected to look like:
+//
+//	CREATE TABLE go_workers_outbox (
+//		id      BIGSERIAL PRIMARY KEY,  -- any auto-incrementing key
+//		queue   TEXT NOT NULL,
+//		at      DOUBLE PRECISION NOT NULL,
+//		payload TEXT NOT NULL
+//	)
+type OutboxOptions struct {
+	// Database the manager's relay reads committed jobs from. Producers write through the caller's transaction.
+	DB          *sql.DB
+	Table       string
+	Placeholder OutboxPlaceholder
+
+	// How often the relay looks for committed jobs, and how many it moves per query.
+	// Default to one second and 100.
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func (o OutboxOptions) placeholder(n int) string {
+	if o.Placeholder == OutboxPlaceholderDollar {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// EnqueueToOutbox writes a job into the outbox table within tx. It is pushed to Redis by a manager's
+// relay once tx commits, and never if tx rolls back. Managers relay the outbox one at a time, under a
+// Redis lease. Outbox jobs are delivered at least once: a relay
+// stopping between pushing a job and deleting its row pushes it again on the next run.
+// Depth guards and uniqueness locks aren't applied to outbox jobs.
+func (p *Producer) EnqueueToOutbox(tx *sql.Tx, queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	if p.opts.Outbox == nil {
+		return "", errors.New("the outbox isn't configured")
+	}
+	outbox := p.opts.Outbox
+
+	data := p.newEnqueueData(queue, class, args, opts, nowToSecondsWithNanoPrecision())
+	err := p.opts.ProducerMiddlewares.run(context.Background(), &data, func(ctx context.Context, job *EnqueueData) error {
+		bytes, err := p.encode(*job)
+		if err != nil {
+			return err
+		}
+		query := fmt.Sprintf("INSERT INTO %s (queue, at, payload) VALUES (%s, %s, %s)",
+			outbox.Table, outbox.placeholder(1), outbox.placeholder(2), outbox.placeholder(3))
+		_, err = tx.ExecContext(ctx, query, job.Queue, job.At, string(bytes))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return data.Jid, nil
+}
+
+type outboxRelay struct {
+	opts   Options
+	outbox OutboxOptions
+	// holder identifies the relay in the outbox lease
+	holder string
+	// active reports whether the manager currently processes jobs
+	active func() bool
+}
+
+func newOutboxRelay(opts Options, holder string, active func() bool) *outboxRelay {
+	return &outboxRelay{opts: opts, outbox: *opts.Outbox, holder: holder, active: active}
+}
+
+// leaseTTL outlives a few polls, so the lease of a relay which died expires quickly without the
+// relay losing it between two polls
+func (r *outboxRelay) leaseTTL() time.Duration {
+	if ttl := 3 * r.outbox.PollInterval; ttl > minOutboxLeaseDuration {
+		return ttl
+	}
+	return minOutboxLeaseDuration
+}
+
+func (r *outboxRelay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.outbox.PollInterval)
+	defer ticker.Stop()
+	defer func() {
+		if err := r.opts.store.ReleaseLease(context.Background(), outboxLease, r.holder); err != nil {
+			r.opts.Logger.Println("ERR: couldn't release the outbox lease:", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r.active() {
+				r.poll(ctx)
+			}
+		}
+	}
+}
+
+// poll moves committed jobs to Redis, batch by batch, until the outbox is empty. Only the relay holding
+// the outbox lease moves jobs, so no row is pushed by several managers.
+func (r *outboxRelay) poll(ctx context.Context) {
+	for {
+		held, err := r.opts.store.AcquireLease(ctx, outboxLease, r.holder, r.leaseTTL())
+		if err != nil {
+			r.opts.Logger.Println("ERR: couldn't acquire the outbox lease:", err)
+			return
+		}
+		if !held {
+			return
+		}
+		moved, err := r.relayBatch(ctx)
+		if err != nil {
+			r.opts.Logger.Println("ERR: couldn't relay outbox jobs:", err)
+			return
+		}
+		if moved < r.outbox.BatchSize {
+			return
+		}
+	}
+}
+
+type outboxRow struct {
+	id      int64
+	queue   string
+	at      float64
+	payload string
+}
+
+func (r *outboxRelay) relayBatch(ctx context.Context) (int, error) {
+	query := fmt.Sprintf("SELECT id, queue, at, payload FROM %s ORDER BY id LIMIT %d", r.outbox.Table, r.outbox.BatchSize)
+	rows, err := r.outbox.DB.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	var batch []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		if err := rows.Scan(&row.id, &row.queue, &row.at, &row.payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	remove := fmt.Sprintf("DELETE FROM %s WHERE id = %s", r.outbox.Table, r.outbox.placeholder(1))
+	now := nowToSecondsWithNanoPrecision()
+	for i, row := range batch {
+		if err := r.push(ctx, row, now); err != nil {
+			return i, err
+		}
+		if _, err := r.outbox.DB.ExecContext(ctx, remove, row.id); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+func (r *outboxRelay) push(ctx context.Context, row outboxRow, now float64) error {
+	if now < row.at {
+		return r.opts.store.EnqueueScheduledMessage(ctx, row.at, row.payload)
+	}
+	if err := r.opts.store.CreateQueue(ctx, row.queue); err != nil {
+		return err
+	}
+	return r.opts.store.EnqueueMessageNow(ctx, row.queue, row.payload)
+}
+
+func validateOutboxOptions(outbox OutboxOptions) (OutboxOptions, error) {
+	if outbox.Table == "" {
+		outbox.Table = defaultOutboxTable
+	}
+	if strings.ContainsAny(outbox.Table, " ;'\"") {
+		return OutboxOptions{}, fmt.Errorf("invalid outbox table name %q", outbox.Table)
+	}
+	if outbox.PollInterval <= 0 {
+		outbox.PollInterval = defaultOutboxPollInterval
+	}
+	if outbox.BatchSize <= 0 {
+		outbox.BatchSize = defaultOutboxBatchSize
+	}
+	return outbox, nil
+}
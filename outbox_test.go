@@ -0,0 +1,300 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// outboxTestDriver is a database/sql driver keeping a single outbox table in memory
+type outboxTestDriver struct {
+	sync.Mutex
+	rows   [][]driver.Value
+	nextID int64
+}
+
+var testOutbox = &outboxTestDriver{}
+
+func init() {
+	sql.Register("outboxtest", testOutbox)
+}
+
+func (d *outboxTestDriver) reset() {
+	d.Lock()
+	defer d.Unlock()
+	d.rows = nil
+}
+
+func (d *outboxTestDriver) Open(string) (driver.Conn, error) {
+	return &outboxTestConn{driver: d}, nil
+}
+
+type outboxTestConn struct {
+	driver  *outboxTestDriver
+	pending [][]driver.Value
+	inTx    bool
+}
+
+func (c *outboxTestConn) Prepare(query string) (driver.Stmt, error) {
+	return &outboxTestStmt{conn: c, query: query}, nil
+}
+
+func (c *outboxTestConn) Close() error { return nil }
+
+func (c *outboxTestConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+func (c *outboxTestConn) Commit() error {
+	c.driver.Lock()
+	defer c.driver.Unlock()
+	for _, row := range c.pending {
+		c.driver.nextID++
+		c.driver.rows = append(c.driver.rows, append([]driver.Value{c.driver.nextID}, row...))
+	}
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+func (c *outboxTestConn) Rollback() error {
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+type outboxTestStmt struct {
+	conn  *outboxTestConn
+	query string
+}
+
+func (s *outboxTestStmt) Close() error  { return nil }
+func (s *outboxTestStmt) NumInput() int { return -1 }
+
+func (s *outboxTestStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.conn.driver
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO go_workers_outbox"):
+		if !s.conn.inTx {
+			return nil, errors.New("outbox inserts are expected in a transaction")
+		}
+		s.conn.pending = append(s.conn.pending, args)
+	case strings.HasPrefix(s.query, "DELETE FROM go_workers_outbox WHERE id = ?"):
+		d.Lock()
+		defer d.Unlock()
+		for i, row := range d.rows {
+			if row[0] == args[0] {
+				d.rows = append(d.rows[:i], d.rows[i+1:]...)
+				break
+			}
+		}
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *outboxTestStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "SELECT id, queue, at, payload FROM go_workers_outbox ORDER BY id LIMIT") {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	d := s.conn.driver
+	d.Lock()
+	defer d.Unlock()
+	return &outboxTestRows{rows: append([][]driver.Value{}, d.rows...)}, nil
+}
+
+type outboxTestRows struct {
+	rows [][]driver.Value
+}
+
+func (r *outboxTestRows) Columns() []string { return []string{"id", "queue", "at", "payload"} }
+func (r *outboxTestRows) Close() error      { return nil }
+
+func (r *outboxTestRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestEnqueueToOutbox(t *testing.T) {
+	ctx := context.Background()
+	testOutbox.reset()
+
+	db, err := sql.Open("outboxtest", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+	_, err = p.EnqueueToOutbox(nil, "outbox", "Add", []int{1}, EnqueueOptions{})
+	assert.Error(t, err, "the outbox isn't configured")
+
+	outbox, err := validateOutboxOptions(OutboxOptions{DB: db})
+	assert.NoError(t, err)
+	opts.Outbox = &outbox
+	p = newProducer(opts)
+
+	// rolled back jobs are never relayed
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	_, err = p.EnqueueToOutbox(tx, "outbox", "Add", []int{1}, EnqueueOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+
+	tx, err = db.Begin()
+	assert.NoError(t, err)
+	jid, err := p.EnqueueToOutbox(tx, "outbox", "Add", []int{2}, EnqueueOptions{})
+	assert.NoError(t, err)
+	scheduledJid, err := p.EnqueueToOutbox(tx, "outbox", "Add", []int{3}, EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60})
+	assert.NoError(t, err)
+
+	relay := newOutboxRelay(opts, "relay-1", func() bool { return true })
+	moved, err := relay.relayBatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, moved, "uncommitted jobs aren't visible to the relay")
+
+	assert.NoError(t, tx.Commit())
+	moved, err = relay.relayBatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, moved)
+	assert.Empty(t, testOutbox.rows)
+
+	bytes, err := rc.RPop(ctx, "prod:queue:outbox").Result()
+	assert.NoError(t, err)
+	msg, err := NewMsg(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, jid, msg.Jid())
+	assert.Equal(t, "[2]", msg.Args().ToJson())
+	assert.EqualValues(t, 0, rc.LLen(ctx, "prod:queue:outbox").Val())
+
+	scheduled, err := rc.ZRange(ctx, "prod:schedule", 0, -1).Result()
+	assert.NoError(t, err)
+	if assert.Len(t, scheduled, 1) {
+		msg, err = NewMsg(scheduled[0])
+		assert.NoError(t, err)
+		assert.Equal(t, scheduledJid, msg.Jid())
+	}
+}
+
+func TestOutboxRelayRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	testOutbox.reset()
+
+	db, err := sql.Open("outboxtest", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	outbox, err := validateOutboxOptions(OutboxOptions{DB: db, PollInterval: 10 * time.Millisecond, BatchSize: 1})
+	assert.NoError(t, err)
+	opts.Outbox = &outbox
+	p := newProducer(opts)
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = p.EnqueueToOutbox(tx, "outbox", "Add", []int{i}, EnqueueOptions{})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tx.Commit())
+
+	done := make(chan struct{})
+	relay := newOutboxRelay(opts, "relay-1", func() bool { return true })
+	go func() {
+		relay.run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return opts.client.LLen(context.Background(), "prod:queue:outbox").Val() == 3
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestOutboxRelaysShareTheTable(t *testing.T) {
+	ctx := context.Background()
+	testOutbox.reset()
+
+	db, err := sql.Open("outboxtest", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	outbox, err := validateOutboxOptions(OutboxOptions{DB: db, PollInterval: 10 * time.Millisecond, BatchSize: 1})
+	assert.NoError(t, err)
+	opts.Outbox = &outbox
+	p := newProducer(opts)
+
+	enqueue := func(n int) {
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			_, err = p.EnqueueToOutbox(tx, "outbox", "Add", []int{i}, EnqueueOptions{})
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, tx.Commit())
+	}
+	enqueue(20)
+
+	// two managers poll the same table at once, and each job is pushed once
+	relays := []*outboxRelay{
+		newOutboxRelay(opts, "relay-1", func() bool { return true }),
+		newOutboxRelay(opts, "relay-2", func() bool { return true }),
+	}
+	var wg sync.WaitGroup
+	for _, relay := range relays {
+		wg.Add(1)
+		go func(relay *outboxRelay) {
+			defer wg.Done()
+			relay.poll(ctx)
+		}(relay)
+	}
+	wg.Wait()
+	assert.EqualValues(t, 20, opts.client.LLen(ctx, "prod:queue:outbox").Val())
+	assert.Empty(t, testOutbox.rows)
+
+	// the other relay takes over once the lease holder stops
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		relays[0].run(runCtx)
+		close(done)
+	}()
+	cancel()
+	<-done
+	enqueue(1)
+	relays[1].poll(ctx)
+	assert.EqualValues(t, 21, opts.client.LLen(ctx, "prod:queue:outbox").Val())
+}
+
+func TestValidateOutboxOptions(t *testing.T) {
+	outbox, err := validateOutboxOptions(OutboxOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "go_workers_outbox", outbox.Table)
+	assert.Equal(t, time.Second, outbox.PollInterval)
+	assert.Equal(t, 100, outbox.BatchSize)
+	assert.Equal(t, "?", outbox.placeholder(1))
+
+	outbox.Placeholder = OutboxPlaceholderDollar
+	assert.Equal(t, "$2", outbox.placeholder(2))
+
+	_, err = validateOutboxOptions(OutboxOptions{Table: "jobs; DROP TABLE users"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package workers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PayloadValidationMode controls how outgoing payloads are checked against the Sidekiq job format
+type PayloadValidationMode int
+
+const (
+	// PayloadValidationOff pushes payloads without checking them
+	PayloadValidationOff PayloadValidationMode = iota
+	// PayloadValidationStrict rejects payloads with missing or mistyped standard fields
+	PayloadValidationStrict
+	// PayloadValidationFix repairs violations where possible and rejects the rest
+	PayloadValidationFix
+)
+
+// PayloadValidationError lists the Sidekiq compatibility violations found in a payload
+type PayloadValidationError struct {
+	Violations []string
+}
+
+func (e *PayloadValidationError) Error() string {
+	return "invalid sidekiq payload: " + strings.Join(e.Violations, "; ")
+}
+
+// ValidateSidekiqPayload checks that a JSON payload carries correctly typed standard Sidekiq fields
+func ValidateSidekiqPayload(payload []byte) error {
+	job, err := decodePayloadFields(payload)
+	if err != nil {
+		return err
+	}
+	if violations := payloadViolations(job); len(violations) > 0 {
+		return &PayloadValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// FixSidekiqPayload repairs the standard Sidekiq fields of a JSON payload where that can be done safely,
+// and returns an error for the violations it can't repair
+func FixSidekiqPayload(payload []byte) ([]byte, error) {
+	job, err := decodePayloadFields(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if args, ok := job["args"]; ok && args != nil {
+		if _, isArray := args.([]interface{}); !isArray {
+			// Sidekiq expects positional arguments, so a lone value becomes the first argument
+			job["args"] = []interface{}{args}
+		}
+	} else {
+		job["args"] = []interface{}{}
+	}
+
+	// the incoming jid is kept whenever it can identify the job, as callers may already know it
+	switch jid := job["jid"].(type) {
+	case string:
+		if !validJid(jid) {
+			job["jid"] = generateJid()
+		}
+	case json.Number:
+		job["jid"] = jid.String()
+	default:
+		job["jid"] = generateJid()
+	}
+
+	for _, field := range []string{"created_at", "enqueued_at"} {
+		if s, ok := job[field].(string); ok {
+			if _, err := json.Number(s).Float64(); err == nil {
+				job[field] = json.Number(s)
+			}
+		}
+	}
+	if _, ok := job["created_at"]; !ok {
+		if enqueuedAt, ok := job["enqueued_at"].(json.Number); ok {
+			job["created_at"] = enqueuedAt
+		}
+	}
+
+	if s, ok := job["retry"].(string); ok {
+		switch s {
+		case "true":
+			job["retry"] = true
+		case "false":
+			job["retry"] = false
+		}
+	}
+
+	if violations := payloadViolations(job); len(violations) > 0 {
+		return nil, &PayloadValidationError{Violations: violations}
+	}
+	return json.Marshal(job)
+}
+
+func decodePayloadFields(payload []byte) (map[string]interface{}, error) {
+	var job map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	// keep numbers intact so integer and float fields can be told apart
+	dec.UseNumber()
+	if err := dec.Decode(&job); err != nil {
+		return nil, &PayloadValidationError{Violations: []string{fmt.Sprintf("payload is not a JSON object: %v", err)}}
+	}
+	return job, nil
+}
+
+// validJid tells whether a jid is a non-empty string without spaces or control characters
+func validJid(jid string) bool {
+	if jid == "" {
+		return false
+	}
+	for _, r := range jid {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func payloadViolations(job map[string]interface{}) []string {
+	var violations []string
+
+	if class, ok := job["class"].(string); !ok || class == "" {
+		violations = append(violations, "class must be a non-empty string")
+	}
+	if jid, ok := job["jid"].(string); !ok || jid == "" {
+		violations = append(violations, "jid must be a non-empty string")
+	} else if !validJid(jid) {
+		violations = append(violations, "jid must not contain spaces or control characters")
+	}
+	if _, ok := job["args"].([]interface{}); !ok {
+		violations = append(violations, "args must be an array")
+	}
+	if queue, ok := job["queue"]; ok {
+		if _, isString := queue.(string); !isString {
+			violations = append(violations, "queue must be a string")
+		}
+	}
+
+	for _, field := range []string{"created_at", "enqueued_at"} {
+		value, ok := job[field]
+		if !ok {
+			violations = append(violations, field+" is missing")
+			continue
+		}
+		if _, isNumber := value.(json.Number); !isNumber {
+			violations = append(violations, field+" must be a float timestamp")
+		}
+	}
+
+	if retry, ok := job["retry"]; ok {
+		switch r := retry.(type) {
+		case bool:
+		case json.Number:
+			if _, err := r.Int64(); err != nil {
+				violations = append(violations, "retry must be a boolean or an integer")
+			}
+		default:
+			violations = append(violations, "retry must be a boolean or an integer")
+		}
+	}
+
+	return violations
+}
+
+func checkPayload(mode PayloadValidationMode, payload []byte) ([]byte, error) {
+	switch mode {
+	case PayloadValidationStrict:
+		return payload, ValidateSidekiqPayload(payload)
+	case PayloadValidationFix:
+		return FixSidekiqPayload(payload)
+	default:
+		return payload, nil
+	}
+}
@@ -0,0 +1,143 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSidekiqPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		violations []string
+	}{
+		{
+			name:    "valid payload",
+			payload: `{"class":"Add","args":[1,2],"jid":"abc","created_at":1.5,"enqueued_at":1.5,"retry":true}`,
+		},
+		{
+			name:    "integer retry",
+			payload: `{"class":"Add","args":[],"jid":"abc","created_at":1,"enqueued_at":1,"retry":5}`,
+		},
+		{
+			name:       "missing class and jid",
+			payload:    `{"args":[],"created_at":1,"enqueued_at":1}`,
+			violations: []string{"class must be a non-empty string", "jid must be a non-empty string"},
+		},
+		{
+			name:       "malformed jid",
+			payload:    `{"class":"Add","args":[],"jid":"a b","created_at":1,"enqueued_at":1}`,
+			violations: []string{"jid must not contain spaces or control characters"},
+		},
+		{
+			name:       "args not an array",
+			payload:    `{"class":"Add","args":{"a":1},"jid":"abc","created_at":1,"enqueued_at":1}`,
+			violations: []string{"args must be an array"},
+		},
+		{
+			name:       "string timestamps",
+			payload:    `{"class":"Add","args":[],"jid":"abc","created_at":"1","enqueued_at":1}`,
+			violations: []string{"created_at must be a float timestamp"},
+		},
+		{
+			name:       "missing created_at",
+			payload:    `{"class":"Add","args":[],"jid":"abc","enqueued_at":1}`,
+			violations: []string{"created_at is missing"},
+		},
+		{
+			name:       "fractional retry",
+			payload:    `{"class":"Add","args":[],"jid":"abc","created_at":1,"enqueued_at":1,"retry":1.5}`,
+			violations: []string{"retry must be a boolean or an integer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSidekiqPayload([]byte(tt.payload))
+			if tt.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+			validationErr, ok := err.(*PayloadValidationError)
+			assert.True(t, ok)
+			assert.Equal(t, tt.violations, validationErr.Violations)
+		})
+	}
+}
+
+func TestFixSidekiqPayload(t *testing.T) {
+	fixed, err := FixSidekiqPayload([]byte(`{"class":"Add","args":"solo","enqueued_at":"12.5","retry":"true"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, ValidateSidekiqPayload(fixed))
+
+	var job map[string]interface{}
+	assert.NoError(t, json.Unmarshal(fixed, &job))
+	assert.Equal(t, []interface{}{"solo"}, job["args"])
+	assert.Equal(t, 12.5, job["enqueued_at"])
+	assert.Equal(t, 12.5, job["created_at"])
+	assert.Equal(t, true, job["retry"])
+	assert.Len(t, job["jid"], 24)
+
+	// valid incoming jids are kept, and only missing or malformed ones are generated
+	for _, tt := range []struct {
+		jid  string
+		kept string
+	}{
+		{jid: `"abc"`, kept: "abc"},
+		{jid: `"4b3f8c1e-2d7a-4e9b-a1c6-0f5e8d2b7a93"`, kept: "4b3f8c1e-2d7a-4e9b-a1c6-0f5e8d2b7a93"},
+		{jid: `42`, kept: "42"},
+		{jid: `""`},
+		{jid: `"a b"`},
+		{jid: `"abc\n"`},
+		{jid: `null`},
+		{jid: `["abc"]`},
+	} {
+		fixed, err := FixSidekiqPayload([]byte(`{"class":"Add","args":[],"jid":` + tt.jid + `,"created_at":1,"enqueued_at":1}`))
+		if !assert.NoError(t, err, tt.jid) {
+			continue
+		}
+		assert.NoError(t, json.Unmarshal(fixed, &job))
+		if tt.kept != "" {
+			assert.Equal(t, tt.kept, job["jid"], tt.jid)
+		} else {
+			assert.Len(t, job["jid"], 24, tt.jid)
+		}
+	}
+
+	// a missing class can't be made up
+	_, err = FixSidekiqPayload([]byte(`{"args":[],"jid":"abc","created_at":1,"enqueued_at":1}`))
+	assert.Error(t, err)
+}
+
+func TestProducer_PayloadValidation(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.PayloadValidation = PayloadValidationStrict
+	p := &Producer{opts: opts}
+
+	// producer payloads are valid as built
+	_, err = p.Enqueue("validated", "Add", []int{1, 2})
+	assert.NoError(t, err)
+
+	// non-array args are rejected in strict mode
+	_, err = p.Enqueue("validated", "Add", map[string]int{"a": 1})
+	assert.IsType(t, &PayloadValidationError{}, err)
+
+	nb, _ := opts.client.LLen(ctx, "prod:queue:validated").Result()
+	assert.Equal(t, int64(1), nb)
+
+	// and wrapped in fix mode
+	p.opts.PayloadValidation = PayloadValidationFix
+	_, err = p.Enqueue("validated", "Add", map[string]int{"a": 1})
+	assert.NoError(t, err)
+
+	bytes, _ := opts.client.LPop(ctx, "prod:queue:validated").Result()
+	var job map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(bytes), &job))
+	assert.Equal(t, []interface{}{map[string]interface{}{"a": float64(1)}}, job["args"])
+}
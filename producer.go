@@ -18,7 +18,30 @@ const (
 
 // Producer is used to enqueue new work
 type Producer struct {
-	opts Options
+	opts         Options
+	depthGuard   *queueDepthGuard
+	backpressure *producerBackpressure
+	retry        *enqueueRetry
+	async        *asyncBuffer
+	stats        *producerStats
+}
+
+func newProducer(options Options) *Producer {
+	p := &Producer{
+		opts:         options,
+		depthGuard:   newQueueDepthGuard(options.QueueDepthGuard),
+		backpressure: newProducerBackpressure(options.ProducerBackpressure),
+		retry:        newEnqueueRetry(options.EnqueueRetry),
+		stats:        newProducerStats(),
+	}
+	if options.EnqueueRateLimits != nil {
+		// rate limits apply to jobs as producer middleware left them, right before they are written
+		mids := make(ProducerMiddlewares, 0, len(options.ProducerMiddlewares)+1)
+		mids = append(mids, options.ProducerMiddlewares...)
+		p.opts.ProducerMiddlewares = append(mids, rateLimitMiddleware(*options.EnqueueRateLimits))
+	}
+	p.async = newAsyncBuffer(p, options.AsyncBuffer)
+	return p
 }
 
 // EnqueueData stores data and configuration for new work
@@ -27,8 +50,65 @@ type EnqueueData struct {
 	Class      string      `json:"class"`
 	Args       interface{} `json:"args"`
 	Jid        string      `json:"jid"`
+	CreatedAt  float64     `json:"created_at"`
 	EnqueuedAt float64     `json:"enqueued_at"`
 	EnqueueOptions
+
+	// Extra holds additional top-level payload fields, such as trace IDs set by producer middleware.
+	// Standard fields take precedence over extra fields with the same name.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the job with its extra fields merged into the top-level object
+func (d EnqueueData) MarshalJSON() ([]byte, error) {
+	type plain EnqueueData
+	bytes, err := json.Marshal(plain(d))
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := d.EnqueueOptions.fields()
+	if d.UniqueFor > 0 {
+		lockFields, err := uniqueJobFields(&d)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range lockFields {
+			overrides[key] = value
+		}
+	}
+	if len(overrides) == 0 && len(d.Extra) == 0 && len(d.Custom) == 0 {
+		return bytes, nil
+	}
+
+	// raw values keep the encoding of the standard fields, large integer args included, untouched
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return nil, err
+	}
+	set := func(key string, value interface{}) error {
+		raw, err := json.Marshal(value)
+		if err == nil {
+			fields[key] = raw
+		}
+		return err
+	}
+	for key, value := range overrides {
+		if err := set(key, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, extra := range []map[string]interface{}{d.Extra, d.Custom} {
+		for key, value := range extra {
+			if _, ok := fields[key]; ok {
+				continue
+			}
+			if err := set(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return json.Marshal(fields)
 }
 
 // EnqueueOptions stores configuration for new work
@@ -37,6 +117,56 @@ type EnqueueOptions struct {
 	RetryMax   int     `json:"retry_max,omitempty"`
 	Retry      bool    `json:"retry,omitempty"`
 	At         float64 `json:"at,omitempty"`
+
+	// Optional time, in seconds like At, before which the job stays out of reach of fetchers without
+	// going through the schedule set. Honored by Enqueue and EnqueueWithOptions, for queues only fetched
+	// by go-workers2 managers.
+	NotBefore float64 `json:"-"`
+
+	// Optional number of retries, sent as an integer "retry" like Sidekiq does. Takes precedence over Retry.
+	RetryLimit int `json:"-"`
+	// Optional queue retries are pushed to instead of the job's queue
+	RetryQueue string `json:"retry_queue,omitempty"`
+	// Optional error backtrace kept with failed jobs, every frame with Backtrace or up to BacktraceLines frames
+	Backtrace      bool `json:"backtrace,omitempty"`
+	BacktraceLines int  `json:"-"`
+	// Optional tags shown by the Sidekiq Web UI
+	Tags []string `json:"tags,omitempty"`
+	// Optional time after its creation past which the job is skipped instead of started, by Go workers and
+	// Sidekiq Pro alike
+	ExpiresIn time.Duration `json:"-"`
+	// Optional custom payload fields. Standard fields take precedence over custom fields with the same name.
+	Custom map[string]interface{} `json:"-"`
+
+	// Optional lifetime of a uniqueness lock on the job's class, queue and args. While the lock
+	// is held, enqueueing an identical job is skipped and returns ErrDuplicateJob. The lock is an
+	// until_executed lock of sidekiq-unique-jobs, shared with Ruby producers and released by the worker,
+	// Go or Ruby, which runs the job successfully.
+	UniqueFor time.Duration `json:"-"`
+
+	// Optional batch the job belongs to, set by Batch.Enqueue
+	Bid string `json:"gw_bid,omitempty"`
+
+	// Optional window during which enqueueing a job with the same class and args as an earlier one
+	// enqueued with DedupeFor is skipped, returning ErrDuplicateJob. Bulk enqueues give skipped entries
+	// an empty JID, and also skip entries duplicating an earlier entry of the batch.
+	// Defaults to Options.DedupWindow, which a negative DedupeFor disables.
+	DedupeFor time.Duration `json:"-"`
+}
+
+// fields returns the payload fields which struct tags can't express
+func (o EnqueueOptions) fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if o.RetryLimit > 0 {
+		fields["retry"] = o.RetryLimit
+	}
+	if o.BacktraceLines > 0 {
+		fields["backtrace"] = o.BacktraceLines
+	}
+	if o.ExpiresIn > 0 {
+		fields["expires_in"] = o.ExpiresIn.Seconds()
+	}
+	return fields
 }
 
 // NewProducer creates a new producer with the given options
@@ -46,21 +176,18 @@ func NewProducer(options Options) (*Producer, error) {
 		return nil, err
 	}
 
-	return &Producer{
-		opts: options,
-	}, nil
+	return newProducer(options), nil
 }
 
-// NewProducerWithRedisClient creates a new producer with the given options and Redis client
+// NewProducerWithRedisClient creates a new producer with the given options and Redis client, which it may
+// share with other producers and managers, see NewManagerWithRedisClient
 func NewProducerWithRedisClient(options Options, client *redis.Client) (*Producer, error) {
 	options, err := processOptionsWithRedisClient(options, client)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Producer{
-		opts: options,
-	}, nil
+	return newProducer(options), nil
 }
 
 // GetRedisClient returns the Redis client used by the producer
@@ -91,37 +218,345 @@ func (p *Producer) EnqueueWithOptions(queue, class string, args interface{}, opt
 
 // EnqueueWithContext enqueues new work for processing with the given options and context
 func (p *Producer) EnqueueWithContext(ctx context.Context, queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	return p.enqueue(ctx, queue, class, args, opts, nil)
+}
+
+// enqueue writes a job, and fills receipt with Redis' confirmation of the write when it isn't nil
+func (p *Producer) enqueue(ctx context.Context, queue, class string, args interface{}, opts EnqueueOptions, receipt *EnqueueReceipt) (string, error) {
 	now := nowToSecondsWithNanoPrecision()
-	data := EnqueueData{
-		Queue:          queue,
-		Class:          class,
-		Args:           args,
-		Jid:            generateJid(),
-		EnqueuedAt:     now,
-		EnqueueOptions: opts,
+
+	if err := p.applyBackpressure(ctx, queue, &opts, now); err != nil {
+		return "", err
+	}
+	if now >= opts.At {
+		var err error
+		if queue, err = p.guardQueue(ctx, queue, 1); err != nil {
+			return "", err
+		}
 	}
 
-	bytes, err := json.Marshal(data)
+	data := p.newEnqueueData(queue, class, args, opts, now)
+
+	err := p.opts.ProducerMiddlewares.run(ctx, &data, func(ctx context.Context, job *EnqueueData) error {
+		bytes, err := p.encode(*job)
+		if err != nil {
+			return err
+		}
+
+		locks, err := p.acquireJobLocks(ctx, job, newBatchDedupe())
+		if err != nil {
+			return err
+		}
+		if err = p.registerBatchJob(ctx, job); err != nil {
+			p.releaseUniqueLocks(ctx, locks)
+			return err
+		}
+
+		start := time.Now()
+		err = p.retry.do(ctx, func() error {
+			if receipt != nil {
+				return p.pushConfirmed(ctx, job, now, string(bytes), receipt)
+			}
+			return p.pushNowOrLater(ctx, job, now, string(bytes))
+		})
+		p.recordWrite([]EnqueueData{*job}, []string{string(bytes)}, time.Since(start), err)
+		if err != nil {
+			p.reportEnqueueFailure(ctx, []EnqueueData{*job}, err)
+			p.unregisterBatchJob(ctx, job)
+			p.releaseUniqueLocks(ctx, locks)
+			return err
+		}
+		p.confirmUniqueLocks(ctx, locks)
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
+	return data.Jid, nil
+}
+
+// EnqueueBulk enqueues one job per entry of argsList for immediate processing, using as few
+// round trips as possible, and returns the generated JIDs in the same order
+func (p *Producer) EnqueueBulk(queue, class string, argsList [][]interface{}) ([]string, error) {
+	return p.EnqueueBulkWithContext(context.Background(), queue, class, argsList, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+}
 
-	if now < opts.At {
-		err = p.opts.store.EnqueueScheduledMessage(ctx, data.At, string(bytes))
-		return data.Jid, err
+// EnqueueBulkWithContext enqueues one job per entry of argsList with the given options and context.
+// Every payload is built and validated before anything is written to Redis, so producer middleware
+// sees each job before any of them is pushed. Jobs dropped by middleware get an empty JID, and so do
+// duplicates, which are skipped with UniqueFor or DedupeFor.
+// Jobs bound for different queues or times are written separately: when a write fails, the JIDs of the
+// jobs already written are returned with the error, and the jobs which weren't written get an empty JID.
+func (p *Producer) EnqueueBulkWithContext(ctx context.Context, queue, class string, argsList [][]interface{}, opts EnqueueOptions) ([]string, error) {
+	if len(argsList) == 0 {
+		return []string{}, nil
 	}
 
-	err = p.opts.store.CreateQueue(ctx, queue)
+	now := nowToSecondsWithNanoPrecision()
+
+	if err := p.applyBackpressure(ctx, queue, &opts, now); err != nil {
+		return nil, err
+	}
+	if now >= opts.At {
+		var err error
+		if queue, err = p.guardQueue(ctx, queue, len(argsList)); err != nil {
+			return nil, err
+		}
+	}
+
+	// middleware may reroute or reschedule jobs, so messages are grouped by destination
+	type destination struct {
+		queue string
+		at    float64
+	}
+	type collectedJob struct {
+		index   int
+		dest    destination
+		message string
+		job     EnqueueData
+	}
+	var collected []collectedJob
+
+	var current int
+	collect := p.opts.ProducerMiddlewares.build(func(ctx context.Context, job *EnqueueData) error {
+		bytes, err := p.encode(*job)
+		if err != nil {
+			return err
+		}
+		dest := destination{queue: job.Queue}
+		if now < job.At {
+			dest = destination{at: job.At}
+		}
+		collected = append(collected, collectedJob{index: current, dest: dest, message: string(bytes), job: *job})
+		return nil
+	})
+
+	jids := make([]string, len(argsList))
+	for i, args := range argsList {
+		current = i
+		data := p.newEnqueueData(queue, class, args, opts, now)
+		count := len(collected)
+		if err := collect(ctx, &data); err != nil {
+			return nil, err
+		}
+		// jobs dropped by middleware get an empty JID
+		if len(collected) > count {
+			jids[i] = data.Jid
+		}
+	}
+
+	// duplicates of unique or deduplicated jobs are skipped and get an empty JID
+	var destinations []destination
+	messages := map[destination][]string{}
+	jobs := map[destination][]EnqueueData{}
+	indexes := map[destination][]int{}
+	locks := map[destination][]string{}
+	batch := newBatchDedupe()
+	// undo releases the locks and batch registrations of the given destinations, which weren't written
+	undo := func(dests []destination) {
+		for _, dest := range dests {
+			for i := range jobs[dest] {
+				p.unregisterBatchJob(ctx, &jobs[dest][i])
+			}
+			p.releaseUniqueLocks(ctx, locks[dest])
+		}
+	}
+	for _, c := range collected {
+		acquired, err := p.acquireJobLocks(ctx, &c.job, batch)
+		if err == ErrDuplicateJob {
+			jids[c.index] = ""
+			continue
+		}
+		if err != nil {
+			undo(destinations)
+			return nil, err
+		}
+		if err := p.registerBatchJob(ctx, &c.job); err != nil {
+			p.releaseUniqueLocks(ctx, acquired)
+			undo(destinations)
+			return nil, err
+		}
+		if _, ok := messages[c.dest]; !ok {
+			destinations = append(destinations, c.dest)
+		}
+		messages[c.dest] = append(messages[c.dest], c.message)
+		jobs[c.dest] = append(jobs[c.dest], c.job)
+		indexes[c.dest] = append(indexes[c.dest], c.index)
+		locks[c.dest] = append(locks[c.dest], acquired...)
+	}
+
+	for i, dest := range destinations {
+		start := time.Now()
+		err := p.retry.do(ctx, func() error {
+			return p.pushBatch(ctx, dest.queue, dest.at, messages[dest])
+		})
+		p.recordWrite(jobs[dest], messages[dest], time.Since(start), err)
+		if err == nil {
+			p.confirmUniqueLocks(ctx, locks[dest])
+			continue
+		}
+
+		// this batch and the ones after it were not written, and get an empty JID
+		var failed []EnqueueData
+		for _, dest := range destinations[i:] {
+			failed = append(failed, jobs[dest]...)
+			for _, index := range indexes[dest] {
+				jids[index] = ""
+			}
+		}
+		p.reportEnqueueFailure(ctx, failed, err)
+		undo(destinations[i:])
+		return jids, err
+	}
+	return jids, nil
+}
+
+// EnqueueMsg pushes a prebuilt message to a queue for immediate processing, keeping its jid,
+// timestamps, retry count and custom fields. Only its queue field is set to the given queue.
+func (p *Producer) EnqueueMsg(queue string, msg *Msg) error {
+	return p.EnqueueMsgWithContext(context.Background(), queue, msg)
+}
+
+// EnqueueMsgWithContext pushes a prebuilt message to a queue with the given context. Producer
+// middleware and unique locks are skipped, since they apply to jobs built by the producer.
+func (p *Producer) EnqueueMsgWithContext(ctx context.Context, queue string, msg *Msg) error {
+	queue, err := p.guardQueue(ctx, queue, 1)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	err = p.opts.store.EnqueueMessageNow(ctx, queue, string(bytes))
+	message, err := NewMsg(msg.ToJson())
 	if err != nil {
-		return "", err
+		return err
+	}
+	message.Set("queue", queue)
+	bytes, err := message.Encode()
+	if err != nil {
+		return err
+	}
+	if bytes, err = checkPayload(p.opts.PayloadValidation, bytes); err != nil {
+		return err
 	}
 
-	return data.Jid, nil
+	job := EnqueueData{Queue: queue, Class: message.Class(), Jid: message.Jid()}
+	start := time.Now()
+	err = p.retry.do(ctx, func() error {
+		return p.pushNowOrLater(ctx, &job, 0, string(bytes))
+	})
+	p.recordWrite([]EnqueueData{job}, []string{string(bytes)}, time.Since(start), err)
+	if err != nil {
+		p.reportEnqueueFailure(ctx, []EnqueueData{job}, err)
+	}
+	return err
+}
+
+// CancelScheduled removes a job enqueued for later processing from the schedule set, and reports whether it was found.
+// The unique lock of the job is released, and the job is done for its batch.
+func (p *Producer) CancelScheduled(jid string) (bool, error) {
+	ctx := context.Background()
+	removed, err := p.opts.store.RemoveScheduledMessage(ctx, jid)
+	p.releaseCancelled(ctx, removed)
+	return len(removed) > 0, err
+}
+
+// CancelRetry removes a failed job waiting for its next attempt from the retry set, and reports whether it was found.
+// The unique lock of the job is released, and the job is done for its batch.
+func (p *Producer) CancelRetry(jid string) (bool, error) {
+	ctx := context.Background()
+	removed, err := p.opts.store.RemoveRetriedMessage(ctx, jid)
+	p.releaseCancelled(ctx, removed)
+	return len(removed) > 0, err
+}
+
+// releaseCancelled releases the unique locks the removed jobs held, and unregisters them from their batch
+func (p *Producer) releaseCancelled(ctx context.Context, messages []string) {
+	for _, message := range messages {
+		msg, err := NewMsg(message)
+		if err != nil {
+			continue
+		}
+		if digest, _ := msg.Get("lock_digest").String(); digest != "" {
+			if err := p.opts.store.ReleaseUniqueJobLock(ctx, digest, msg.Jid()); err != nil && p.opts.Logger != nil {
+				p.opts.Logger.Println("couldn't release unique lock", digest, "of job", msg.Jid(), ":", err)
+			}
+		}
+		bid, _ := msg.Get("gw_bid").String()
+		p.unregisterBatchJob(ctx, &EnqueueData{Jid: msg.Jid(), EnqueueOptions: EnqueueOptions{Bid: bid}})
+	}
+}
+
+func (p *Producer) pushBatch(ctx context.Context, queue string, at float64, messages []string) error {
+	if at > 0 {
+		return p.opts.store.EnqueueScheduledMessages(ctx, at, messages)
+	}
+
+	if err := p.opts.store.CreateQueue(ctx, queue); err != nil {
+		return err
+	}
+	if err := p.opts.store.EnqueueMessagesNow(ctx, queue, messages); err != nil {
+		return err
+	}
+	p.depthGuard.added(queue, len(messages))
+	return nil
+}
+
+func (p *Producer) pushNowOrLater(ctx context.Context, job *EnqueueData, now float64, message string) error {
+	if now < job.At {
+		return p.opts.store.EnqueueScheduledMessage(ctx, job.At, message)
+	}
+
+	err := p.opts.store.CreateQueue(ctx, job.Queue)
+	if err != nil {
+		return err
+	}
+
+	if now < job.NotBefore {
+		return p.opts.store.EnqueueDelayedMessage(ctx, job.Queue, job.NotBefore, message)
+	}
+
+	err = p.opts.store.EnqueueMessageNow(ctx, job.Queue, message)
+	if err != nil {
+		return err
+	}
+	p.depthGuard.added(job.Queue, 1)
+	return nil
+}
+
+func (p *Producer) newEnqueueData(queue, class string, args interface{}, opts EnqueueOptions, now float64) EnqueueData {
+	if opts.DedupeFor == 0 {
+		opts.DedupeFor = p.opts.DedupWindow
+	}
+	return EnqueueData{
+		Queue:          queue,
+		Class:          class,
+		Args:           args,
+		Jid:            p.generateJid(),
+		CreatedAt:      now,
+		EnqueuedAt:     now,
+		EnqueueOptions: opts,
+	}
+}
+
+// encode serializes a job into the payload pushed to Redis
+func (p *Producer) encode(data EnqueueData) ([]byte, error) {
+	data, err := p.serializeArgs(data)
+	if err != nil {
+		return nil, err
+	}
+	// queues with codecs compress args through their chain
+	if len(p.opts.QueueCodecs[data.Queue]) > 0 {
+		data, err = p.encodeArgs(data)
+	} else {
+		data, err = p.compressArgs(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return checkPayload(p.opts.PayloadValidation, bytes)
 }
 
 func timeToSecondsWithNanoPrecision(t time.Time) float64 {
@@ -136,6 +571,14 @@ func nowToSecondsWithNanoPrecision() float64 {
 	return timeToSecondsWithNanoPrecision(time.Now())
 }
 
+// generateJid returns the JID of a new job, from Options.JIDGenerator when set
+func (p *Producer) generateJid() string {
+	if p.opts.JIDGenerator != nil {
+		return p.opts.JIDGenerator()
+	}
+	return generateJid()
+}
+
 func generateJid() string {
 	// Return 12 random bytes as 24 character hex
 	b := make([]byte, 12)
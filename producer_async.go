@@ -0,0 +1,222 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAsyncBufferSize    = 10000
+	defaultAsyncFlushSize     = 100
+	defaultAsyncFlushInterval = 100 * time.Millisecond
+)
+
+// ErrAsyncBufferFull is returned by EnqueueAsync when the producer's buffer holds as many jobs as it may
+var ErrAsyncBufferFull = errors.New("async enqueue buffer is full")
+
+// AsyncBufferOptions configures the buffer of jobs enqueued with EnqueueAsync
+type AsyncBufferOptions struct {
+	// Maximum number of jobs waiting to be written, defaults to 10000
+	Size int
+
+	// Buffered jobs are written once FlushSize of them are waiting, or FlushInterval after
+	// the first one was buffered. Default to 100 and 100ms.
+	FlushSize     int
+	FlushInterval time.Duration
+}
+
+type bufferedJob struct {
+	job     EnqueueData
+	message string
+}
+
+type asyncBuffer struct {
+	producer *Producer
+	opts     AsyncBufferOptions
+
+	lock  sync.Mutex
+	jobs  []bufferedJob
+	timer *time.Timer
+
+	// serializes flushes so batches are written in the order they were buffered
+	flushLock sync.Mutex
+
+	// wakes the background flusher, started on first use; a single pending wake-up covers every job
+	// buffered until the flusher takes them
+	wake        chan struct{}
+	flusherOnce sync.Once
+}
+
+func newAsyncBuffer(producer *Producer, opts *AsyncBufferOptions) *asyncBuffer {
+	var bufferOpts AsyncBufferOptions
+	if opts != nil {
+		bufferOpts = *opts
+	}
+	if bufferOpts.Size <= 0 {
+		bufferOpts.Size = defaultAsyncBufferSize
+	}
+	if bufferOpts.FlushSize <= 0 || bufferOpts.FlushSize > bufferOpts.Size {
+		bufferOpts.FlushSize = defaultAsyncFlushSize
+		if bufferOpts.FlushSize > bufferOpts.Size {
+			bufferOpts.FlushSize = bufferOpts.Size
+		}
+	}
+	if bufferOpts.FlushInterval <= 0 {
+		bufferOpts.FlushInterval = defaultAsyncFlushInterval
+	}
+	return &asyncBuffer{producer: producer, opts: bufferOpts, wake: make(chan struct{}, 1)}
+}
+
+func (b *asyncBuffer) add(job bufferedJob) error {
+	if b == nil {
+		return errors.New("async enqueues require a producer created by NewProducer")
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.jobs) >= b.opts.Size {
+		return ErrAsyncBufferFull
+	}
+	b.jobs = append(b.jobs, job)
+
+	if len(b.jobs) >= b.opts.FlushSize {
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+		b.notify()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.opts.FlushInterval, b.notify)
+	}
+	return nil
+}
+
+// notify wakes the background flusher, without waiting when it was already woken
+func (b *asyncBuffer) notify() {
+	b.flusherOnce.Do(func() {
+		go b.runFlusher()
+	})
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *asyncBuffer) runFlusher() {
+	for range b.wake {
+		b.flushInBackground()
+	}
+}
+
+func (b *asyncBuffer) take() []bufferedJob {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	jobs := b.jobs
+	b.jobs = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return jobs
+}
+
+func (b *asyncBuffer) flushInBackground() {
+	if err := b.flush(context.Background()); err != nil {
+		b.producer.opts.Logger.Println("ERR: couldn't flush async enqueues:", err)
+	}
+}
+
+// flush writes every buffered job, grouped by destination. Jobs which couldn't be written are
+// handed to OnEnqueueFailure.
+func (b *asyncBuffer) flush(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.flushLock.Lock()
+	defer b.flushLock.Unlock()
+
+	jobs := b.take()
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	type destination struct {
+		queue string
+		at    float64
+	}
+	var destinations []destination
+	grouped := map[destination][]bufferedJob{}
+	now := nowToSecondsWithNanoPrecision()
+	for _, job := range jobs {
+		dest := destination{queue: job.job.Queue}
+		if now < job.job.At {
+			dest = destination{at: job.job.At}
+		}
+		if _, ok := grouped[dest]; !ok {
+			destinations = append(destinations, dest)
+		}
+		grouped[dest] = append(grouped[dest], job)
+	}
+
+	p := b.producer
+	var firstErr error
+	for _, dest := range destinations {
+		messages := make([]string, len(grouped[dest]))
+		written := make([]EnqueueData, len(grouped[dest]))
+		for i, job := range grouped[dest] {
+			messages[i] = job.message
+			written[i] = job.job
+		}
+		start := time.Now()
+		err := p.retry.do(ctx, func() error {
+			return p.pushBatch(ctx, dest.queue, dest.at, messages)
+		})
+		p.recordWrite(written, messages, time.Since(start), err)
+		if err != nil {
+			p.reportEnqueueFailure(ctx, written, err)
+			for i := range written {
+				p.unregisterBatchJob(ctx, &written[i])
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// EnqueueAsync buffers new work and returns without waiting for Redis. Buffered jobs are written
+// in batches in the background; call Flush before shutting down to write the remaining ones.
+// Jobs which can't be written are only reported through OnEnqueueFailure.
+// Depth guards and uniqueness locks aren't applied to async enqueues.
+func (p *Producer) EnqueueAsync(queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	ctx := context.Background()
+	data := p.newEnqueueData(queue, class, args, opts, nowToSecondsWithNanoPrecision())
+
+	err := p.opts.ProducerMiddlewares.run(ctx, &data, func(ctx context.Context, job *EnqueueData) error {
+		bytes, err := p.encode(*job)
+		if err != nil {
+			return err
+		}
+		if err := p.registerBatchJob(ctx, job); err != nil {
+			return err
+		}
+		if err := p.async.add(bufferedJob{job: *job, message: string(bytes)}); err != nil {
+			p.unregisterBatchJob(ctx, job)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return data.Jid, nil
+}
+
+// Flush writes every job buffered by EnqueueAsync, and returns the first error met doing so
+func (p *Producer) Flush(ctx context.Context) error {
+	return p.async.flush(ctx)
+}
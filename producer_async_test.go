@@ -0,0 +1,112 @@
+package workers
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducerEnqueueAsync(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	opts.AsyncBuffer = &AsyncBufferOptions{Size: 3, FlushInterval: time.Hour}
+	p := newProducer(opts)
+	assert.Equal(t, 3, p.async.opts.FlushSize)
+
+	jid, err := p.EnqueueAsync("async", "Add", []int{1}, EnqueueOptions{})
+	assert.NoError(t, err)
+	_, err = p.EnqueueAsync("async", "Add", []int{2}, EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, rc.LLen(ctx, "prod:queue:async").Val())
+
+	assert.NoError(t, p.Flush(ctx))
+	bytes, _ := rc.RPop(ctx, "prod:queue:async").Result()
+	msg, _ := NewMsg(bytes)
+	assert.Equal(t, jid, msg.Jid())
+	assert.EqualValues(t, 1, rc.ZCard(ctx, "prod:schedule").Val())
+
+	// the buffer is written in the background once FlushSize jobs wait
+	for i := 0; i < 3; i++ {
+		_, err = p.EnqueueAsync("async", "Add", []int{i}, EnqueueOptions{})
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		return rc.LLen(ctx, "prod:queue:async").Val() == 3
+	}, time.Second, 10*time.Millisecond)
+	messages, _ := rc.LRange(ctx, "prod:queue:async", 0, -1).Result()
+	first, _ := NewMsg(messages[len(messages)-1])
+	assert.Equal(t, "[0]", first.Args().ToJson())
+}
+
+func TestProducerEnqueueAsyncFlushInterval(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.AsyncBuffer = &AsyncBufferOptions{FlushInterval: 10 * time.Millisecond}
+	p := newProducer(opts)
+
+	_, err = p.EnqueueAsync("async", "Add", []int{1}, EnqueueOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return opts.client.LLen(ctx, "prod:queue:async").Val() == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestProducerEnqueueAsyncBufferFull(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	store := &flakyStore{Store: opts.store, failures: 1}
+	opts.store = store
+	failed := make(chan string, 2)
+	opts.OnEnqueueFailure = func(ctx context.Context, job *EnqueueData, err error) {
+		assert.Equal(t, errRedisDown, err)
+		failed <- job.Jid
+	}
+	opts.AsyncBuffer = &AsyncBufferOptions{Size: 2, FlushSize: 5, FlushInterval: time.Hour}
+	p := newProducer(opts)
+	assert.Equal(t, 2, p.async.opts.FlushSize)
+
+	// hold back the background flush while the buffer is full
+	p.async.flushLock.Lock()
+	var jids []string
+	for i := 0; i < 2; i++ {
+		jid, err := p.EnqueueAsync("async", "Add", []int{i}, EnqueueOptions{})
+		assert.NoError(t, err)
+		jids = append(jids, jid)
+	}
+	_, err = p.EnqueueAsync("async", "Add", []int{3}, EnqueueOptions{})
+	assert.Equal(t, ErrAsyncBufferFull, err)
+	p.async.flushLock.Unlock()
+
+	// the failed batch is reported job by job
+	assert.ElementsMatch(t, jids, []string{<-failed, <-failed})
+	assert.NoError(t, p.Flush(context.Background()))
+}
+
+func TestProducerEnqueueAsyncSingleFlusher(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.AsyncBuffer = &AsyncBufferOptions{FlushSize: 1, FlushInterval: time.Hour}
+	p := newProducer(opts)
+
+	// every job is a full batch, yet they are all written by one goroutine
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 500; i++ {
+		_, err = p.EnqueueAsync("async", "Add", []int{i}, EnqueueOptions{})
+		assert.NoError(t, err)
+		assert.True(t, runtime.NumGoroutine() <= goroutines+3)
+	}
+	assert.Eventually(t, func() bool {
+		return opts.client.LLen(ctx, "prod:queue:async").Val() == 500
+	}, time.Second, 10*time.Millisecond)
+}
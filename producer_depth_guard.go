@@ -0,0 +1,182 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QueueDepthAction is what a producer does when a queue is deeper than its guard allows
+type QueueDepthAction int
+
+const (
+	// QueueDepthReject fails the enqueue with a *QueueFullError
+	QueueDepthReject QueueDepthAction = iota
+	// QueueDepthReroute pushes the job onto the overflow queue instead
+	QueueDepthReroute
+	// QueueDepthBackpressure blocks the enqueue until the queue drains below the limit
+	QueueDepthBackpressure
+)
+
+const (
+	defaultQueueDepthCacheTTL  = time.Second
+	defaultQueueDepthBackoff   = 100 * time.Millisecond
+	defaultQueueDepthMaxWait   = 10 * time.Second
+	defaultOverflowQueueSuffix = "_overflow"
+)
+
+// QueueDepthGuardOptions configures the producer-side guard against unbounded queue growth
+type QueueDepthGuardOptions struct {
+	// Depth above which the guard kicks in, for every queue without an entry in QueueMaxDepth
+	MaxDepth int64
+	// Optional per-queue limits which override MaxDepth
+	QueueMaxDepth map[string]int64
+
+	Action QueueDepthAction
+
+	// Queue used by QueueDepthReroute, defaults to the queue name followed by "_overflow"
+	OverflowQueue string
+
+	// How long a queue depth read from Redis is trusted, defaults to one second
+	CacheTTL time.Duration
+
+	// How long QueueDepthBackpressure waits between depth checks and in total before rejecting,
+	// default to 100ms and 10s
+	Backoff time.Duration
+	MaxWait time.Duration
+}
+
+// QueueFullError is returned when the depth guard rejects an enqueue
+type QueueFullError struct {
+	Queue    string
+	Depth    int64
+	MaxDepth int64
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("queue %s is full: depth %d exceeds %d", e.Queue, e.Depth, e.MaxDepth)
+}
+
+type cachedQueueDepth struct {
+	depth     int64
+	fetchedAt time.Time
+}
+
+type queueDepthGuard struct {
+	opts QueueDepthGuardOptions
+
+	lock   sync.Mutex
+	depths map[string]*cachedQueueDepth
+}
+
+func newQueueDepthGuard(opts *QueueDepthGuardOptions) *queueDepthGuard {
+	if opts == nil {
+		return nil
+	}
+	guardOpts := *opts
+	if guardOpts.CacheTTL <= 0 {
+		guardOpts.CacheTTL = defaultQueueDepthCacheTTL
+	}
+	if guardOpts.Backoff <= 0 {
+		guardOpts.Backoff = defaultQueueDepthBackoff
+	}
+	if guardOpts.MaxWait <= 0 {
+		guardOpts.MaxWait = defaultQueueDepthMaxWait
+	}
+	return &queueDepthGuard{
+		opts:   guardOpts,
+		depths: map[string]*cachedQueueDepth{},
+	}
+}
+
+func (g *queueDepthGuard) maxDepth(queue string) int64 {
+	if max, ok := g.opts.QueueMaxDepth[queue]; ok {
+		return max
+	}
+	return g.opts.MaxDepth
+}
+
+func (g *queueDepthGuard) overflowQueue(queue string) string {
+	if g.opts.OverflowQueue != "" {
+		return g.opts.OverflowQueue
+	}
+	return queue + defaultOverflowQueueSuffix
+}
+
+// depth returns the cached depth of a queue, reading it from Redis when the cache is stale or refresh is set
+func (g *queueDepthGuard) depth(ctx context.Context, p *Producer, queue string, refresh bool) (int64, error) {
+	g.lock.Lock()
+	cached, ok := g.depths[queue]
+	g.lock.Unlock()
+	if ok && !refresh && time.Since(cached.fetchedAt) < g.opts.CacheTTL {
+		return cached.depth, nil
+	}
+
+	depth, err := p.opts.store.QueueLength(ctx, queue)
+	if err != nil {
+		return 0, err
+	}
+	g.lock.Lock()
+	g.depths[queue] = &cachedQueueDepth{depth: depth, fetchedAt: time.Now()}
+	g.lock.Unlock()
+	return depth, nil
+}
+
+// added accounts for jobs this producer pushed since the depth was last read
+func (g *queueDepthGuard) added(queue string, n int) {
+	if g == nil {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if cached, ok := g.depths[queue]; ok {
+		cached.depth += int64(n)
+	}
+}
+
+// admit decides which queue n new jobs for queue go to, or returns an error if they can't be enqueued
+func (g *queueDepthGuard) admit(ctx context.Context, p *Producer, queue string, n int) (string, error) {
+	max := g.maxDepth(queue)
+	if max <= 0 {
+		return queue, nil
+	}
+
+	depth, err := g.depth(ctx, p, queue, false)
+	if err != nil {
+		return "", err
+	}
+	if depth+int64(n) <= max {
+		return queue, nil
+	}
+
+	switch g.opts.Action {
+	case QueueDepthReroute:
+		return g.overflowQueue(queue), nil
+	case QueueDepthBackpressure:
+		deadline := time.Now().Add(g.opts.MaxWait)
+		for time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(g.opts.Backoff):
+			}
+			depth, err = g.depth(ctx, p, queue, true)
+			if err != nil {
+				return "", err
+			}
+			if depth+int64(n) <= max {
+				return queue, nil
+			}
+		}
+	}
+	return "", &QueueFullError{Queue: queue, Depth: depth, MaxDepth: max}
+}
+
+// guardQueue returns the queue n immediate jobs should be pushed to, applying the depth guard if one is configured
+func (p *Producer) guardQueue(ctx context.Context, queue string, n int) (string, error) {
+	if p.depthGuard == nil {
+		return queue, nil
+	}
+	return p.depthGuard.admit(ctx, p, queue, n)
+}
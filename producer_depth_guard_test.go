@@ -0,0 +1,114 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueDepthGuardReject(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.QueueDepthGuard = &QueueDepthGuardOptions{
+		MaxDepth:      2,
+		QueueMaxDepth: map[string]int64{"unbounded": 0},
+		CacheTTL:      time.Minute,
+	}
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	for i := 0; i < 2; i++ {
+		_, err = p.Enqueue("guarded", "Add", []int{1, 2})
+		assert.NoError(t, err)
+	}
+
+	_, err = p.Enqueue("guarded", "Add", []int{1, 2})
+	if assert.IsType(t, &QueueFullError{}, err) {
+		assert.Equal(t, "guarded", err.(*QueueFullError).Queue)
+		assert.Equal(t, int64(2), err.(*QueueFullError).Depth)
+	}
+
+	_, err = p.EnqueueBulk("guarded", "Add", [][]interface{}{{1}})
+	assert.IsType(t, &QueueFullError{}, err)
+
+	nb, _ := rc.LLen(ctx, "prod:queue:guarded").Result()
+	assert.Equal(t, int64(2), nb)
+
+	// scheduled jobs and queues without a limit aren't guarded
+	_, err = p.EnqueueIn("guarded", "Add", 60, []int{1, 2})
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = p.Enqueue("unbounded", "Add", []int{1, 2})
+		assert.NoError(t, err)
+	}
+}
+
+func TestQueueDepthGuardReroute(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.QueueDepthGuard = &QueueDepthGuardOptions{
+		MaxDepth: 1,
+		Action:   QueueDepthReroute,
+	}
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("guarded", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	jid, err := p.Enqueue("guarded", "Add", []int{1, 2})
+	assert.NoError(t, err)
+
+	nb, _ := rc.LLen(ctx, "prod:queue:guarded").Result()
+	assert.Equal(t, int64(1), nb)
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:guarded_overflow").Result()
+	var data EnqueueData
+	assert.NoError(t, json.Unmarshal([]byte(bytes), &data))
+	assert.Equal(t, jid, data.Jid)
+	assert.Equal(t, "guarded_overflow", data.Queue)
+}
+
+func TestQueueDepthGuardBackpressure(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.QueueDepthGuard = &QueueDepthGuardOptions{
+		MaxDepth: 1,
+		Action:   QueueDepthBackpressure,
+		Backoff:  10 * time.Millisecond,
+		MaxWait:  time.Second,
+	}
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("guarded", "Add", []int{1, 2})
+	assert.NoError(t, err)
+
+	// a consumer drains the queue while the producer waits
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		rc.RPop(ctx, "prod:queue:guarded")
+	}()
+	_, err = p.Enqueue("guarded", "Add", []int{1, 2})
+	assert.NoError(t, err)
+
+	nb, _ := rc.LLen(ctx, "prod:queue:guarded").Result()
+	assert.Equal(t, int64(1), nb)
+
+	// and gives up once the context is done
+	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	_, err = p.EnqueueWithContext(timeoutCtx, "guarded", "Add", []int{1, 2}, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,70 @@
+package workers
+
+import "context"
+
+// FeatureGateAction is what happens to a job whose feature flag is disabled
+type FeatureGateAction int
+
+const (
+	// FeatureGateDrop skips the job, whose enqueue returns ErrJobDropped
+	FeatureGateDrop FeatureGateAction = iota
+	// FeatureGateDivert enqueues the job to FeatureGateOptions.DivertQueue instead of its queue
+	FeatureGateDivert
+)
+
+// FeatureGateDecision is what a feature gate did with a job behind a flag
+type FeatureGateDecision struct {
+	Flag    string
+	Enabled bool
+	// Action applied to a job of a disabled feature
+	Action FeatureGateAction
+	// Err is the provider's error, in which case the job is enqueued as if the flag was enabled
+	Err error
+}
+
+// FeatureGateOptions configures the gating of enqueues by feature flags
+type FeatureGateOptions struct {
+	// Optional flag a job is behind, or "" for jobs which aren't gated. Defaults to the job's class.
+	Flag func(job *EnqueueData) string
+	// Enabled consults the feature-flag provider
+	Enabled func(ctx context.Context, flag string) (bool, error)
+
+	Action FeatureGateAction
+	// Queue of the jobs diverted by FeatureGateDivert
+	DivertQueue string
+
+	// Optional hook recording the decision of every gated job
+	OnDecision func(ctx context.Context, job *EnqueueData, decision FeatureGateDecision)
+}
+
+// FeatureGateMiddleware drops or diverts the jobs of disabled features before they are written, so a
+// rollout can stop creating jobs without changing the code enqueueing them
+func FeatureGateMiddleware(opts FeatureGateOptions) ProducerMiddlewareFunc {
+	if opts.Flag == nil {
+		opts.Flag = func(job *EnqueueData) string { return job.Class }
+	}
+
+	return func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			flag := opts.Flag(job)
+			if flag == "" {
+				return next(ctx, job)
+			}
+
+			enabled, err := opts.Enabled(ctx, flag)
+			decision := FeatureGateDecision{Flag: flag, Enabled: enabled || err != nil, Action: opts.Action, Err: err}
+			if opts.OnDecision != nil {
+				opts.OnDecision(ctx, job, decision)
+			}
+			if decision.Enabled {
+				return next(ctx, job)
+			}
+
+			if opts.Action == FeatureGateDivert {
+				job.Queue = opts.DivertQueue
+				return next(ctx, job)
+			}
+			return nil
+		}
+	}
+}
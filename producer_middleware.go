@@ -0,0 +1,83 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrJobDropped is returned, with an empty JID, when producer middleware drops a job by returning nil without calling next
+var ErrJobDropped = errors.New("the job was dropped by producer middleware")
+
+// EnqueueFunc pushes a job to Redis
+type EnqueueFunc func(ctx context.Context, job *EnqueueData) error
+
+// ProducerMiddlewareFunc is an extra function on the enqueue pipeline. It may modify the job before
+// calling next, reject it by returning an error, or drop it by returning nil without calling next, in which case
+// the enqueue returns ErrJobDropped.
+type ProducerMiddlewareFunc func(next EnqueueFunc) EnqueueFunc
+
+// ProducerMiddlewares contains the list of middleware functions run on every enqueued job
+type ProducerMiddlewares []ProducerMiddlewareFunc
+
+// Append adds middleware to the end of the enqueue pipeline
+func (m ProducerMiddlewares) Append(mid ProducerMiddlewareFunc) ProducerMiddlewares {
+	return append(m, mid)
+}
+
+// Prepend adds middleware to the front of the enqueue pipeline
+func (m ProducerMiddlewares) Prepend(mid ProducerMiddlewareFunc) ProducerMiddlewares {
+	return append(ProducerMiddlewares{mid}, m...)
+}
+
+func (m ProducerMiddlewares) build(final EnqueueFunc) EnqueueFunc {
+	for i := len(m) - 1; i >= 0; i-- {
+		final = m[i](final)
+	}
+	return final
+}
+
+// run pushes job through the pipeline to final, and returns ErrJobDropped if middleware never called it
+func (m ProducerMiddlewares) run(ctx context.Context, job *EnqueueData, final EnqueueFunc) error {
+	reached := false
+	err := m.build(func(ctx context.Context, job *EnqueueData) error {
+		reached = true
+		return final(ctx, job)
+	})(ctx, job)
+	if err == nil && !reached {
+		return ErrJobDropped
+	}
+	return err
+}
+
+// NewProducerMiddlewares creates the enqueue pipeline given the list of middleware funcs
+func NewProducerMiddlewares(mids ...ProducerMiddlewareFunc) ProducerMiddlewares {
+	return ProducerMiddlewares(mids)
+}
+
+// PayloadSizeError is returned when an enqueued payload is larger than the configured limit
+type PayloadSizeError struct {
+	Class string
+	Size  int
+	Limit int
+}
+
+func (e *PayloadSizeError) Error() string {
+	return fmt.Sprintf("payload of %s is %d bytes, over the %d byte limit", e.Class, e.Size, e.Limit)
+}
+
+// PayloadSizeLimitMiddleware rejects jobs whose encoded payload is larger than limit bytes
+func PayloadSizeLimitMiddleware(limit int) ProducerMiddlewareFunc {
+	return func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			bytes, err := job.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			if len(bytes) > limit {
+				return &PayloadSizeError{Class: job.Class, Size: len(bytes), Limit: limit}
+			}
+			return next(ctx, job)
+		}
+	}
+}
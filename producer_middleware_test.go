@@ -0,0 +1,200 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func traceMiddleware(next EnqueueFunc) EnqueueFunc {
+	return func(ctx context.Context, job *EnqueueData) error {
+		if job.Extra == nil {
+			job.Extra = map[string]interface{}{}
+		}
+		job.Extra["trace_id"] = "trace-" + job.Class
+		// standard fields can't be overwritten through Extra
+		job.Extra["class"] = "Other"
+		return next(ctx, job)
+	}
+}
+
+func TestProducerMiddlewares(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	var order []string
+	orderMid := func(name string) ProducerMiddlewareFunc {
+		return func(next EnqueueFunc) EnqueueFunc {
+			return func(ctx context.Context, job *EnqueueData) error {
+				order = append(order, name)
+				return next(ctx, job)
+			}
+		}
+	}
+	opts.ProducerMiddlewares = NewProducerMiddlewares(orderMid("b"), traceMiddleware).Prepend(orderMid("a"))
+	p := newProducer(opts)
+
+	jid, err := p.Enqueue("mids", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, order)
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:mids").Result()
+	msg, err := NewMsg(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, jid, msg.Jid())
+	assert.Equal(t, "Add", msg.Class())
+	assert.Equal(t, "trace-Add", msg.Get("trace_id").MustString())
+
+	// bulk enqueues run the chain once per job
+	order = nil
+	_, err = p.EnqueueBulk("mids", "Add", [][]interface{}{{1}, {2}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "a", "b"}, order)
+}
+
+func TestProducerMiddlewaresRejectAndReroute(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	errRejected := errors.New("rejected")
+	opts.ProducerMiddlewares = NewProducerMiddlewares(func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			args := job.Args.([]interface{})
+			switch args[0] {
+			case "reject":
+				return errRejected
+			case "reroute":
+				job.Queue = "other"
+			case "drop":
+				return nil
+			}
+			return next(ctx, job)
+		}
+	})
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("mids", "Add", []interface{}{"reject"})
+	assert.Equal(t, errRejected, err)
+
+	_, err = p.EnqueueBulk("mids", "Add", [][]interface{}{{"keep"}, {"reject"}})
+	assert.Equal(t, errRejected, err)
+	nb, _ := rc.LLen(ctx, "prod:queue:mids").Result()
+	assert.Equal(t, int64(0), nb)
+
+	_, err = p.EnqueueBulk("mids", "Add", [][]interface{}{{"keep"}, {"reroute"}, {"keep"}})
+	assert.NoError(t, err)
+	nb, _ = rc.LLen(ctx, "prod:queue:mids").Result()
+	assert.Equal(t, int64(2), nb)
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:other").Result()
+	var data EnqueueData
+	assert.NoError(t, json.Unmarshal([]byte(bytes), &data))
+	assert.Equal(t, "other", data.Queue)
+	found, _ := rc.SIsMember(ctx, "prod:queues", "other").Result()
+	assert.True(t, found)
+
+	// dropped jobs get no JID
+	jid, err := p.Enqueue("mids", "Add", []interface{}{"drop"})
+	assert.Equal(t, ErrJobDropped, err)
+	assert.Empty(t, jid)
+	jid, err = p.EnqueueAsync("mids", "Add", []interface{}{"drop"}, EnqueueOptions{})
+	assert.Equal(t, ErrJobDropped, err)
+	assert.Empty(t, jid)
+
+	rc.Del(ctx, "prod:queue:mids")
+	jids, err := p.EnqueueBulk("mids", "Add", [][]interface{}{{"keep"}, {"drop"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jids[0])
+	assert.Empty(t, jids[1])
+	nb, _ = rc.LLen(ctx, "prod:queue:mids").Result()
+	assert.Equal(t, int64(1), nb)
+}
+
+func TestPayloadSizeLimitMiddleware(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ProducerMiddlewares = NewProducerMiddlewares(PayloadSizeLimitMiddleware(200))
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("mids", "Add", []int{1, 2})
+	assert.NoError(t, err)
+
+	big := make([]int, 100)
+	_, err = p.Enqueue("mids", "Add", big)
+	if assert.IsType(t, &PayloadSizeError{}, err) {
+		assert.Equal(t, 200, err.(*PayloadSizeError).Limit)
+	}
+}
+
+func TestFeatureGateMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	flags := map[string]bool{"Enabled": true, "Dropped": false}
+	var decisions []FeatureGateDecision
+	gate := FeatureGateOptions{
+		Enabled: func(ctx context.Context, flag string) (bool, error) {
+			enabled, ok := flags[flag]
+			if !ok {
+				return false, errors.New("unknown flag")
+			}
+			return enabled, nil
+		},
+		OnDecision: func(ctx context.Context, job *EnqueueData, decision FeatureGateDecision) {
+			decisions = append(decisions, decision)
+		},
+	}
+	opts.ProducerMiddlewares = NewProducerMiddlewares(FeatureGateMiddleware(gate))
+	p := newProducer(opts)
+
+	for _, class := range []string{"Enabled", "Dropped", "Unknown"} {
+		jid, err := p.Enqueue("gated", class, []int{1})
+		if class == "Dropped" {
+			assert.Equal(t, ErrJobDropped, err)
+			assert.Empty(t, jid)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	nb, _ := rc.LLen(ctx, "prod:queue:gated").Result()
+	assert.Equal(t, int64(2), nb)
+	assert.Len(t, decisions, 3)
+	assert.True(t, decisions[0].Enabled)
+	assert.False(t, decisions[1].Enabled)
+	assert.Equal(t, FeatureGateDrop, decisions[1].Action)
+	// provider errors fail open
+	assert.True(t, decisions[2].Enabled)
+	assert.Error(t, decisions[2].Err)
+
+	gate.Action = FeatureGateDivert
+	gate.DivertQueue = "parked"
+	gate.Flag = func(job *EnqueueData) string {
+		if job.Queue == "gated" {
+			return "Dropped"
+		}
+		return ""
+	}
+	opts.ProducerMiddlewares = NewProducerMiddlewares(FeatureGateMiddleware(gate))
+	p = newProducer(opts)
+	_, err = p.Enqueue("gated", "Add", []int{1})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("ungated", "Add", []int{1})
+	assert.NoError(t, err)
+
+	nb, _ = rc.LLen(ctx, "prod:queue:parked").Result()
+	assert.Equal(t, int64(1), nb)
+	nb, _ = rc.LLen(ctx, "prod:queue:ungated").Result()
+	assert.Equal(t, int64(1), nb)
+}
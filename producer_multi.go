@@ -0,0 +1,120 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// JobSpec describes one of the jobs enqueued together by EnqueueMulti
+type JobSpec struct {
+	Queue   string
+	Class   string
+	Args    interface{}
+	Options EnqueueOptions
+
+	// Jid is set to the JID of the job once it is enqueued
+	Jid string
+}
+
+// EnqueueMulti enqueues jobs to several queues in a single Redis transaction, so that either all of
+// them are enqueued or none is. Jobs without Options.At are enqueued for immediate processing.
+func (p *Producer) EnqueueMulti(jobs []JobSpec) error {
+	return p.EnqueueMultiWithContext(context.Background(), jobs)
+}
+
+// EnqueueMultiWithContext enqueues jobs to several queues in a single Redis transaction with the given
+// context. Every payload is built before anything is written to Redis, and the whole set is rejected
+// with ErrDuplicateJob if any job is a duplicate of a unique or deduplicated job.
+func (p *Producer) EnqueueMultiWithContext(ctx context.Context, jobs []JobSpec) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	now := nowToSecondsWithNanoPrecision()
+
+	var messages []storage.QueuedMessage
+	var collected []EnqueueData
+	var indexes []int
+	var current int
+	collect := p.opts.ProducerMiddlewares.build(func(ctx context.Context, job *EnqueueData) error {
+		bytes, err := p.encode(*job)
+		if err != nil {
+			return err
+		}
+		message := storage.QueuedMessage{Queue: job.Queue, Message: string(bytes)}
+		if now < job.At {
+			message.At = job.At
+		}
+		messages = append(messages, message)
+		collected = append(collected, *job)
+		indexes = append(indexes, current)
+		return nil
+	})
+
+	for i, spec := range jobs {
+		current = i
+		opts := spec.Options
+		if opts.At == 0 {
+			opts.At = now
+		}
+		queue := spec.Queue
+		if now >= opts.At {
+			var err error
+			if queue, err = p.guardQueue(ctx, queue, 1); err != nil {
+				return err
+			}
+		}
+		data := p.newEnqueueData(queue, spec.Class, spec.Args, opts, now)
+		if err := collect(ctx, &data); err != nil {
+			return err
+		}
+	}
+
+	var locks []string
+	batch := newBatchDedupe()
+	for i := range collected {
+		acquired, err := p.acquireJobLocks(ctx, &collected[i], batch)
+		if err == nil {
+			locks = append(locks, acquired...)
+			err = p.registerBatchJob(ctx, &collected[i])
+		}
+		if err != nil {
+			for j := 0; j < i; j++ {
+				p.unregisterBatchJob(ctx, &collected[j])
+			}
+			p.releaseUniqueLocks(ctx, locks)
+			return err
+		}
+	}
+
+	written := make([]string, len(messages))
+	for i, message := range messages {
+		written[i] = message.Message
+	}
+	start := time.Now()
+	err := p.retry.do(ctx, func() error {
+		return p.opts.store.EnqueueMessagesAtomically(ctx, messages)
+	})
+	p.recordWrite(collected, written, time.Since(start), err)
+	if err != nil {
+		p.reportEnqueueFailure(ctx, collected, err)
+		for i := range collected {
+			p.unregisterBatchJob(ctx, &collected[i])
+		}
+		p.releaseUniqueLocks(ctx, locks)
+		return err
+	}
+	p.confirmUniqueLocks(ctx, locks)
+
+	for _, message := range messages {
+		if message.At == 0 {
+			p.depthGuard.added(message.Queue, 1)
+		}
+	}
+	for i, index := range indexes {
+		jobs[index].Jid = collected[i].Jid
+	}
+	return nil
+}
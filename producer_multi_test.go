@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducer_EnqueueMulti(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	p := newProducer(opts)
+
+	assert.NoError(t, p.EnqueueMulti(nil))
+
+	jobs := []JobSpec{
+		{Queue: "index", Class: "IndexDocument", Args: []int{1}},
+		{Queue: "notify", Class: "NotifyUser", Args: []int{2}},
+		{Queue: "notify", Class: "Remind", Args: []int{3}, Options: EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60}},
+	}
+	assert.NoError(t, p.EnqueueMulti(jobs))
+	for _, job := range jobs {
+		assert.NotEmpty(t, job.Jid)
+	}
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:index").Result()
+	msg, _ := NewMsg(bytes)
+	assert.Equal(t, jobs[0].Jid, msg.Jid())
+	assert.Equal(t, "IndexDocument", msg.Class())
+	bytes, _ = rc.RPop(ctx, "prod:queue:notify").Result()
+	msg, _ = NewMsg(bytes)
+	assert.Equal(t, jobs[1].Jid, msg.Jid())
+
+	queues, _ := rc.SMembers(ctx, "prod:queues").Result()
+	assert.ElementsMatch(t, []string{"index", "notify"}, queues)
+	scheduled, _ := rc.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Result()
+	assert.Equal(t, int64(1), scheduled)
+}
+
+func TestProducer_EnqueueMultiAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	store := &flakyStore{Store: opts.store, failures: 1}
+	opts.store = store
+	var failed []string
+	opts.OnEnqueueFailure = func(ctx context.Context, job *EnqueueData, err error) {
+		failed = append(failed, job.Class)
+	}
+	p := newProducer(opts)
+
+	jobs := []JobSpec{
+		{Queue: "index", Class: "IndexDocument", Args: []int{1}},
+		{Queue: "notify", Class: "NotifyUser", Args: []int{1}, Options: EnqueueOptions{DedupeFor: time.Minute}},
+	}
+	assert.Equal(t, errRedisDown, p.EnqueueMulti(jobs))
+	assert.Equal(t, []string{"IndexDocument", "NotifyUser"}, failed)
+	assert.Empty(t, jobs[0].Jid)
+
+	// the dedupe lock was released along with the failed write
+	assert.NoError(t, p.EnqueueMulti(jobs))
+	index, _ := rc.LLen(ctx, "prod:queue:index").Result()
+	notify, _ := rc.LLen(ctx, "prod:queue:notify").Result()
+	assert.Equal(t, int64(1), index)
+	assert.Equal(t, int64(1), notify)
+
+	// a duplicate rejects every job
+	again := []JobSpec{
+		{Queue: "index", Class: "IndexDocument", Args: []int{2}},
+		{Queue: "notify", Class: "NotifyUser", Args: []int{1}, Options: EnqueueOptions{DedupeFor: time.Minute}},
+	}
+	assert.Equal(t, ErrDuplicateJob, p.EnqueueMulti(again))
+	index, _ = rc.LLen(ctx, "prod:queue:index").Result()
+	assert.Equal(t, int64(1), index)
+}
@@ -0,0 +1,148 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitMode is what a producer does with jobs enqueued over their rate
+type RateLimitMode int
+
+const (
+	// RateLimitBlock waits until the job fits in the rate
+	RateLimitBlock RateLimitMode = iota
+	// RateLimitDrop rejects the job with ErrEnqueueRateLimited
+	RateLimitDrop
+	// RateLimitDefer schedules the job for when it fits in the rate
+	RateLimitDefer
+)
+
+// ErrEnqueueRateLimited is returned for jobs dropped by an enqueue rate limit
+var ErrEnqueueRateLimited = errors.New("enqueue rate limit exceeded")
+
+// Rate is a token bucket limiting how fast jobs are enqueued
+type Rate struct {
+	// Jobs enqueued per second
+	PerSecond float64
+	// Optional number of jobs which can be enqueued at once, defaults to PerSecond rounded up
+	Burst int
+	Mode  RateLimitMode
+}
+
+// EnqueueRateLimitOptions configures the token-bucket rates of enqueued jobs. The jobs of a queue and
+// class with both a rate take a token from each bucket.
+type EnqueueRateLimitOptions struct {
+	Queues  map[string]Rate
+	Classes map[string]Rate
+}
+
+type tokenBucket struct {
+	rate  Rate
+	burst float64
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate Rate) *tokenBucket {
+	burst := float64(rate.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(rate.PerSecond))
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// take takes a token, and returns how long to wait for it. Without wait, no token is taken if none
+// is available, and take reports false.
+func (b *tokenBucket) take(now time.Time, wait bool) (time.Duration, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate.PerSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if !wait || b.rate.PerSecond <= 0 {
+		return 0, false
+	}
+	// the token is taken in advance, so jobs waiting for one are spread over time
+	b.tokens--
+	return time.Duration(-b.tokens / b.rate.PerSecond * float64(time.Second)), true
+}
+
+// refund gives back a token taken for a job which wasn't enqueued
+func (b *tokenBucket) refund() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+func newTokenBuckets(rates map[string]Rate) map[string]*tokenBucket {
+	buckets := make(map[string]*tokenBucket, len(rates))
+	for key, rate := range rates {
+		buckets[key] = newTokenBucket(rate)
+	}
+	return buckets
+}
+
+// rateLimitMiddleware applies the rates of the queue and class of immediate jobs. Jobs scheduled for
+// later aren't limited.
+func rateLimitMiddleware(opts EnqueueRateLimitOptions) ProducerMiddlewareFunc {
+	queues := newTokenBuckets(opts.Queues)
+	classes := newTokenBuckets(opts.Classes)
+
+	return func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			now := time.Now()
+			if job.At > timeToSecondsWithNanoPrecision(now) {
+				return next(ctx, job)
+			}
+
+			var delay time.Duration
+			mode := RateLimitBlock
+			var taken []*tokenBucket
+			refund := func() {
+				for _, bucket := range taken {
+					bucket.refund()
+				}
+			}
+			for _, bucket := range []*tokenBucket{queues[job.Queue], classes[job.Class]} {
+				if bucket == nil {
+					continue
+				}
+				wait, ok := bucket.take(now, bucket.rate.Mode != RateLimitDrop)
+				if !ok {
+					refund()
+					return ErrEnqueueRateLimited
+				}
+				taken = append(taken, bucket)
+				if wait > delay {
+					delay, mode = wait, bucket.rate.Mode
+				}
+			}
+			if delay == 0 {
+				return next(ctx, job)
+			}
+
+			if mode == RateLimitDefer {
+				job.At = timeToSecondsWithNanoPrecision(now.Add(delay))
+				return next(ctx, job)
+			}
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				refund()
+				return ctx.Err()
+			}
+			return next(ctx, job)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(Rate{PerSecond: 10, Burst: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		wait, ok := b.take(now, false)
+		assert.True(t, ok)
+		assert.Zero(t, wait)
+	}
+	_, ok := b.take(now, false)
+	assert.False(t, ok)
+
+	wait, ok := b.take(now, true)
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, wait)
+	wait, _ = b.take(now, true)
+	assert.Equal(t, 200*time.Millisecond, wait)
+
+	// tokens refill at the rate, up to the burst
+	wait, ok = b.take(now.Add(time.Hour), false)
+	assert.True(t, ok)
+	assert.Zero(t, wait)
+}
+
+func TestProducer_EnqueueRateLimits(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	opts.EnqueueRateLimits = &EnqueueRateLimitOptions{
+		Queues: map[string]Rate{
+			"dropped":  {PerSecond: 1, Burst: 2, Mode: RateLimitDrop},
+			"deferred": {PerSecond: 1, Burst: 1, Mode: RateLimitDefer},
+		},
+		Classes: map[string]Rate{"Blocked": {PerSecond: 50, Burst: 1}},
+	}
+	p := newProducer(opts)
+
+	for i := 0; i < 2; i++ {
+		_, err = p.Enqueue("dropped", "Add", []int{i})
+		assert.NoError(t, err)
+	}
+	_, err = p.Enqueue("dropped", "Add", []int{3})
+	assert.Equal(t, ErrEnqueueRateLimited, err)
+	nb, _ := rc.LLen(ctx, "prod:queue:dropped").Result()
+	assert.Equal(t, int64(2), nb)
+
+	jids, err := p.EnqueueBulk("deferred", "Add", [][]interface{}{{1}, {2}, {3}})
+	assert.NoError(t, err)
+	assert.Len(t, jids, 3)
+	nb, _ = rc.LLen(ctx, "prod:queue:deferred").Result()
+	assert.Equal(t, int64(1), nb)
+	scheduled, _ := rc.ZRangeWithScores(ctx, "prod:"+storage.ScheduledJobsKey, 0, -1).Result()
+	assert.Len(t, scheduled, 2)
+	now := nowToSecondsWithNanoPrecision()
+	assert.InDelta(t, now+1, scheduled[0].Score, 0.5)
+	assert.InDelta(t, now+2, scheduled[1].Score, 0.5)
+
+	// class limits block until a token is available
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = p.Enqueue("other", "Blocked", []int{i})
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+	nb, _ = rc.LLen(ctx, "prod:queue:other").Result()
+	assert.Equal(t, int64(3), nb)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = p.EnqueueWithContext(cancelled, "other", "Blocked", []int{4}, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+	assert.Equal(t, context.Canceled, err)
+
+	// jobs scheduled for later aren't limited
+	_, err = p.EnqueueIn("dropped", "Add", 60, []int{5})
+	assert.NoError(t, err)
+}
+
+func TestProducer_EnqueueRateLimitsByQueueAndClass(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.EnqueueRateLimits = &EnqueueRateLimitOptions{
+		Queues:  map[string]Rate{"reports": {PerSecond: 0.001, Burst: 2, Mode: RateLimitDrop}},
+		Classes: map[string]Rate{"Report": {PerSecond: 0.001, Burst: 1, Mode: RateLimitDrop}},
+	}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	// the manager's callers share its buckets
+	assert.Same(t, mgr.Producer(), mgr.Producer())
+	_, err = mgr.Producer().Enqueue("reports", "Report", nil)
+	assert.NoError(t, err)
+	_, err = mgr.Producer().Enqueue("reports", "Report", nil)
+	assert.Equal(t, ErrEnqueueRateLimited, err)
+
+	// the queue got its token back when the class refused the job
+	_, err = mgr.Producer().Enqueue("reports", "Summary", nil)
+	assert.NoError(t, err)
+	_, err = mgr.Producer().Enqueue("reports", "Summary", nil)
+	assert.Equal(t, ErrEnqueueRateLimited, err)
+
+	// a queue named like a limited class isn't limited
+	for i := 0; i < 2; i++ {
+		_, err = mgr.Producer().Enqueue("Report", "Summary", nil)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrEnqueueNotConfirmed is returned when Redis' reply shows a job wasn't written
+var ErrEnqueueNotConfirmed = errors.New("redis didn't confirm the enqueue")
+
+// EnqueueReceipt records a job as Redis confirmed its write, for callers keeping an audit trail
+type EnqueueReceipt struct {
+	Jid   string `json:"jid"`
+	Queue string `json:"queue"`
+	// Time the job is scheduled for, or zero for a job queued right away
+	At time.Time `json:"at,omitempty"`
+
+	// Time of the Redis server when the job was written, or zero for a job dropped by producer middleware
+	WrittenAt time.Time `json:"written_at"`
+	// Size of the payload in bytes
+	PayloadSize int `json:"payload_size"`
+	// Length of the queue after the push, or number of jobs added to the schedule
+	Count int64 `json:"count"`
+}
+
+// EnqueueWithReceipt enqueues new work like EnqueueWithContext, and returns Redis' confirmation of the write
+func (p *Producer) EnqueueWithReceipt(ctx context.Context, queue, class string, args interface{}, opts EnqueueOptions) (EnqueueReceipt, error) {
+	receipt := EnqueueReceipt{Queue: queue}
+	jid, err := p.enqueue(ctx, queue, class, args, opts, &receipt)
+	if err != nil {
+		return EnqueueReceipt{}, err
+	}
+	receipt.Jid = jid
+	return receipt, nil
+}
+
+// pushConfirmed writes a job like pushNowOrLater, and records Redis' reply in receipt
+func (p *Producer) pushConfirmed(ctx context.Context, job *EnqueueData, now float64, message string, receipt *EnqueueReceipt) error {
+	var at float64
+	if now < job.At {
+		at = job.At
+	} else if err := p.opts.store.CreateQueue(ctx, job.Queue); err != nil {
+		return err
+	}
+
+	confirmation, err := p.opts.store.EnqueueConfirmedMessage(ctx, job.Queue, at, message)
+	if err != nil {
+		return err
+	}
+	if p.opts.VerifyEnqueues && confirmation.Count < 1 {
+		return ErrEnqueueNotConfirmed
+	}
+	if at == 0 {
+		p.depthGuard.added(job.Queue, 1)
+	} else {
+		receipt.At = time.Unix(0, int64(at*NanoSecondPrecision))
+	}
+
+	receipt.Queue = job.Queue
+	receipt.WrittenAt = confirmation.Time
+	receipt.PayloadSize = len(message)
+	receipt.Count = confirmation.Count
+	return nil
+}
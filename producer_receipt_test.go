@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducer_EnqueueWithReceipt(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.VerifyEnqueues = true
+	p := newProducer(opts)
+
+	receipt, err := p.EnqueueWithReceipt(ctx, "receipts", "Audit", []string{"a"}, EnqueueOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, receipt.Jid, 24)
+	assert.Equal(t, "receipts", receipt.Queue)
+	assert.True(t, receipt.At.IsZero())
+	assert.False(t, receipt.WrittenAt.IsZero())
+	assert.Equal(t, int64(1), receipt.Count)
+
+	messages, err := opts.client.LRange(ctx, "prod:queue:receipts", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, len(messages[0]), receipt.PayloadSize)
+
+	receipt, err = p.EnqueueWithReceipt(ctx, "receipts", "Audit", []string{"b"}, EnqueueOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), receipt.Count)
+}
+
+func TestProducer_EnqueueWithReceiptScheduled(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	p := newProducer(opts)
+
+	at := time.Now().Add(time.Hour)
+	receipt, err := p.EnqueueWithReceipt(ctx, "receipts", "Audit", []string{"a"}, EnqueueOptions{At: timeToSecondsWithNanoPrecision(at)})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, at, receipt.At, time.Millisecond)
+	assert.Equal(t, int64(1), receipt.Count)
+
+	scheduled, err := opts.client.ZCard(ctx, "prod:schedule").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), scheduled)
+}
@@ -0,0 +1,104 @@
+package workers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultEnqueueRetryAttempts   = 3
+	defaultEnqueueRetryBackoff    = 100 * time.Millisecond
+	defaultEnqueueRetryMaxBackoff = 2 * time.Second
+)
+
+// EnqueueRetryOptions configures how producers retry writes to Redis which failed.
+// A write which timed out may have reached Redis, so a retried job can be enqueued twice.
+type EnqueueRetryOptions struct {
+	// Number of attempts, the first one included. Defaults to 3.
+	Attempts int
+
+	// Delay before the second attempt, doubled after every failure up to MaxBackoff.
+	// Default to 100ms and 2s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Optional fraction of every delay, between 0 and 1, which is randomized
+	Jitter float64
+}
+
+// EnqueueFailureFunc is called with every job a producer failed to write to Redis, once retries are exhausted
+type EnqueueFailureFunc func(ctx context.Context, job *EnqueueData, err error)
+
+type enqueueRetry struct {
+	opts EnqueueRetryOptions
+}
+
+func newEnqueueRetry(opts *EnqueueRetryOptions) *enqueueRetry {
+	if opts == nil {
+		return nil
+	}
+	retryOpts := *opts
+	if retryOpts.Attempts <= 0 {
+		retryOpts.Attempts = defaultEnqueueRetryAttempts
+	}
+	if retryOpts.Backoff <= 0 {
+		retryOpts.Backoff = defaultEnqueueRetryBackoff
+	}
+	if retryOpts.MaxBackoff < retryOpts.Backoff {
+		retryOpts.MaxBackoff = defaultEnqueueRetryMaxBackoff
+		if retryOpts.MaxBackoff < retryOpts.Backoff {
+			retryOpts.MaxBackoff = retryOpts.Backoff
+		}
+	}
+	if retryOpts.Jitter < 0 {
+		retryOpts.Jitter = 0
+	} else if retryOpts.Jitter > 1 {
+		retryOpts.Jitter = 1
+	}
+	return &enqueueRetry{opts: retryOpts}
+}
+
+// delay returns how long to wait after the given failed attempt, counted from zero
+func (r *enqueueRetry) delay(attempt int) time.Duration {
+	delay := r.opts.Backoff
+	for i := 0; i < attempt && delay < r.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.opts.MaxBackoff {
+		delay = r.opts.MaxBackoff
+	}
+	if r.opts.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * r.opts.Jitter * rand.Float64())
+	}
+	return delay
+}
+
+// do runs write until it succeeds, the attempts are exhausted or ctx is done. A nil retry runs it once.
+func (r *enqueueRetry) do(ctx context.Context, write func() error) error {
+	err := write()
+	if r == nil {
+		return err
+	}
+	for attempt := 1; err != nil && attempt < r.opts.Attempts; attempt++ {
+		timer := time.NewTimer(r.delay(attempt - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = write()
+	}
+	return err
+}
+
+// reportEnqueueFailure hands the jobs which couldn't be written to OnEnqueueFailure
+func (p *Producer) reportEnqueueFailure(ctx context.Context, jobs []EnqueueData, err error) {
+	if p.opts.OnEnqueueFailure == nil {
+		return
+	}
+	for i := range jobs {
+		p.opts.OnEnqueueFailure(ctx, &jobs[i], err)
+	}
+}
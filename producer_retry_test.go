@@ -0,0 +1,135 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStore fails the given number of immediate enqueues before handing them to the real store
+type flakyStore struct {
+	storage.Store
+	failures int
+	attempts int
+}
+
+var errRedisDown = errors.New("redis is down")
+
+func (s *flakyStore) EnqueueMessageNow(ctx context.Context, queue string, message string) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errRedisDown
+	}
+	return s.Store.EnqueueMessageNow(ctx, queue, message)
+}
+
+func (s *flakyStore) EnqueueMessagesNow(ctx context.Context, queue string, messages []string) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errRedisDown
+	}
+	return s.Store.EnqueueMessagesNow(ctx, queue, messages)
+}
+
+func (s *flakyStore) EnqueueMessagesAtomically(ctx context.Context, messages []storage.QueuedMessage) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errRedisDown
+	}
+	return s.Store.EnqueueMessagesAtomically(ctx, messages)
+}
+
+func TestProducerEnqueueRetry(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	store := &flakyStore{Store: opts.store, failures: 2}
+	opts.store = store
+	opts.EnqueueRetry = &EnqueueRetryOptions{Attempts: 3, Backoff: time.Millisecond}
+	var failed []string
+	opts.OnEnqueueFailure = func(ctx context.Context, job *EnqueueData, err error) {
+		assert.Equal(t, errRedisDown, err)
+		failed = append(failed, job.Jid)
+	}
+	p := newProducer(opts)
+
+	jid, err := p.Enqueue("flaky", "Add", []int{1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, store.attempts)
+	assert.Empty(t, failed)
+	bytes, _ := rc.RPop(ctx, "prod:queue:flaky").Result()
+	msg, _ := NewMsg(bytes)
+	assert.Equal(t, jid, msg.Jid())
+
+	// the failure callback gets the job once the attempts are exhausted
+	store.attempts, store.failures = 0, 3
+	_, err = p.Enqueue("flaky", "Add", []int{2})
+	assert.Equal(t, errRedisDown, err)
+	assert.Equal(t, 3, store.attempts)
+	assert.Len(t, failed, 1)
+
+	// bulk enqueues retry whole batches and report every job of the failed ones
+	failed = nil
+	store.attempts, store.failures = 0, 1
+	jids, err := p.EnqueueBulk("flaky", "Add", [][]interface{}{{1}, {2}})
+	assert.NoError(t, err)
+	assert.Len(t, jids, 2)
+	assert.EqualValues(t, 2, rc.LLen(ctx, "prod:queue:flaky").Val())
+
+	store.attempts, store.failures = 0, 3
+	_, err = p.EnqueueBulk("flaky", "Add", [][]interface{}{{1}, {2}})
+	assert.Equal(t, errRedisDown, err)
+	assert.Len(t, failed, 2)
+}
+
+func TestProducerWithoutEnqueueRetry(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	store := &flakyStore{Store: opts.store, failures: 1}
+	opts.store = store
+	calls := 0
+	opts.OnEnqueueFailure = func(ctx context.Context, job *EnqueueData, err error) {
+		calls++
+	}
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("flaky", "Add", []int{1})
+	assert.Equal(t, errRedisDown, err)
+	assert.Equal(t, 1, store.attempts)
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnqueueRetryDelay(t *testing.T) {
+	r := newEnqueueRetry(&EnqueueRetryOptions{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond})
+	assert.Equal(t, 3, r.opts.Attempts)
+	assert.Equal(t, 100*time.Millisecond, r.delay(0))
+	assert.Equal(t, 200*time.Millisecond, r.delay(1))
+	assert.Equal(t, 300*time.Millisecond, r.delay(2))
+	assert.Equal(t, 300*time.Millisecond, r.delay(10))
+
+	r = newEnqueueRetry(&EnqueueRetryOptions{Backoff: 100 * time.Millisecond, Jitter: 0.5})
+	for i := 0; i < 20; i++ {
+		delay := r.delay(0)
+		assert.True(t, delay > 50*time.Millisecond && delay <= 100*time.Millisecond, delay)
+	}
+
+	// a cancelled context stops retrying
+	r = newEnqueueRetry(&EnqueueRetryOptions{Attempts: 5, Backoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts := 0
+	err := r.do(ctx, func() error {
+		attempts++
+		return errRedisDown
+	})
+	assert.Equal(t, errRedisDown, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,116 @@
+package workers
+
+import (
+	"sync"
+	"time"
+)
+
+// ProducerStats contains the counters of a producer's writes to Redis
+type ProducerStats struct {
+	// Jobs written and jobs which couldn't be, by queue then class
+	Enqueued map[string]map[string]int64 `json:"enqueued"`
+	Failed   map[string]map[string]int64 `json:"failed"`
+
+	BytesWritten int64 `json:"bytes_written"`
+
+	// Writes to Redis, each of a job or a batch of jobs, and their latency retries included
+	Writes      int64 `json:"writes"`
+	TotalMicros int64 `json:"total_us"`
+	AvgMicros   int64 `json:"avg_us"`
+	MaxMicros   int64 `json:"max_us"`
+}
+
+// EnqueueMetric describes a job a producer wrote to Redis, or failed to
+type EnqueueMetric struct {
+	Queue string
+	Class string
+	Bytes int
+	// Duration of the write the job was part of
+	Latency time.Duration
+	Err     error
+}
+
+// EnqueueMetricFunc receives a metric for every job a producer writes, to forward it to a metrics sink
+type EnqueueMetricFunc func(metric EnqueueMetric)
+
+type producerStats struct {
+	lock  sync.Mutex
+	stats ProducerStats
+}
+
+func newProducerStats() *producerStats {
+	return &producerStats{stats: ProducerStats{
+		Enqueued: map[string]map[string]int64{},
+		Failed:   map[string]map[string]int64{},
+	}}
+}
+
+func countJob(counts map[string]map[string]int64, queue, class string) {
+	if counts[queue] == nil {
+		counts[queue] = map[string]int64{}
+	}
+	counts[queue][class]++
+}
+
+// recordWrite counts a write of jobs, whose payloads are messages, to Redis
+func (p *Producer) recordWrite(jobs []EnqueueData, messages []string, latency time.Duration, err error) {
+	// producers built as struct literals don't count their writes
+	if s := p.stats; s != nil {
+		s.lock.Lock()
+		s.stats.Writes++
+		s.stats.TotalMicros += latency.Microseconds()
+		if latency.Microseconds() > s.stats.MaxMicros {
+			s.stats.MaxMicros = latency.Microseconds()
+		}
+		for i, job := range jobs {
+			if err != nil {
+				countJob(s.stats.Failed, job.Queue, job.Class)
+			} else {
+				countJob(s.stats.Enqueued, job.Queue, job.Class)
+				s.stats.BytesWritten += int64(len(messages[i]))
+			}
+		}
+		s.lock.Unlock()
+	}
+
+	if p.opts.OnEnqueueMetric != nil {
+		for i, job := range jobs {
+			p.opts.OnEnqueueMetric(EnqueueMetric{
+				Queue:   job.Queue,
+				Class:   job.Class,
+				Bytes:   len(messages[i]),
+				Latency: latency,
+				Err:     err,
+			})
+		}
+	}
+}
+
+// Stats returns a snapshot of the producer's counters
+func (p *Producer) Stats() ProducerStats {
+	s := p.stats
+	if s == nil {
+		s = newProducerStats()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snapshot := s.stats
+	snapshot.Enqueued = copyJobCounts(s.stats.Enqueued)
+	snapshot.Failed = copyJobCounts(s.stats.Failed)
+	if snapshot.Writes > 0 {
+		snapshot.AvgMicros = snapshot.TotalMicros / snapshot.Writes
+	}
+	return snapshot
+}
+
+func copyJobCounts(counts map[string]map[string]int64) map[string]map[string]int64 {
+	res := make(map[string]map[string]int64, len(counts))
+	for queue, classes := range counts {
+		res[queue] = make(map[string]int64, len(classes))
+		for class, count := range classes {
+			res[queue][class] = count
+		}
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducerStats(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	store := &flakyStore{Store: opts.store}
+	opts.store = store
+	var metrics []EnqueueMetric
+	opts.OnEnqueueMetric = func(metric EnqueueMetric) {
+		metrics = append(metrics, metric)
+	}
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("metrics", "Add", []int{1})
+	assert.NoError(t, err)
+	_, err = p.EnqueueBulk("metrics", "Sub", [][]interface{}{{1}, {2}})
+	assert.NoError(t, err)
+	_, err = p.EnqueueIn("metrics", "Add", 60, []int{1})
+	assert.NoError(t, err)
+
+	store.failures, store.attempts = 1, 0
+	_, err = p.Enqueue("metrics", "Add", []int{2})
+	assert.Equal(t, errRedisDown, err)
+
+	stats := p.Stats()
+	assert.Equal(t, map[string]map[string]int64{"metrics": {"Add": 2, "Sub": 2}}, stats.Enqueued)
+	assert.Equal(t, map[string]map[string]int64{"metrics": {"Add": 1}}, stats.Failed)
+	assert.Equal(t, int64(4), stats.Writes)
+	assert.True(t, stats.BytesWritten > 0)
+	assert.True(t, stats.MaxMicros >= stats.AvgMicros)
+
+	if assert.Len(t, metrics, 5) {
+		assert.Equal(t, "Sub", metrics[1].Class)
+		assert.True(t, metrics[1].Bytes > 0)
+		assert.NoError(t, metrics[3].Err)
+		assert.Equal(t, errRedisDown, metrics[4].Err)
+	}
+
+	// snapshots aren't affected by later writes
+	_, err = p.EnqueueAsync("metrics", "Add", []int{3}, EnqueueOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, p.Flush(context.Background()))
+	assert.Equal(t, int64(2), stats.Enqueued["metrics"]["Add"])
+	assert.Equal(t, int64(3), p.Stats().Enqueued["metrics"]["Add"])
+}
+
+func TestProducerStatsWithoutConstructor(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	p := &Producer{opts: opts}
+
+	_, err = p.Enqueue("metrics", "Add", []int{1})
+	assert.NoError(t, err)
+	assert.Empty(t, p.Stats().Enqueued)
+
+	_, err = p.EnqueueAsync("metrics", "Add", []int{1}, EnqueueOptions{})
+	assert.Error(t, err)
+	assert.NoError(t, p.Flush(context.Background()))
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/digitalocean/go-workers2/storage"
 	"github.com/go-redis/redis/v8"
@@ -214,3 +216,351 @@ func TestNewProducerWithRedisClientNoProcessID(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, mgr)
 }
+
+func TestProducer_EnqueueWithMetadataCache(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.MetadataCacheTTL = time.Minute
+	opts.store = newStore(opts)
+	rc := opts.client
+
+	p := &Producer{opts: opts}
+
+	_, err = p.Enqueue("cached1", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	found, _ := rc.SIsMember(ctx, "prod:queues", "cached1").Result()
+	assert.True(t, found)
+
+	// a known queue is registered again even while the cache is fresh, in case it was removed
+	rc.Del(ctx, "prod:queues")
+	_, err = p.Enqueue("cached1", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	found, _ = rc.SIsMember(ctx, "prod:queues", "cached1").Result()
+	assert.True(t, found)
+
+	// new queues are still written and show up in the cached listing
+	_, err = p.Enqueue("cached2", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	found, _ = rc.SIsMember(ctx, "prod:queues", "cached2").Result()
+	assert.True(t, found)
+
+	queues, err := opts.store.ListQueues(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cached1", "cached2"}, queues)
+}
+
+func TestProducer_EnqueueBulk(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := &Producer{opts: opts}
+
+	// spans several batches
+	var argsList [][]interface{}
+	for i := 0; i < 2500; i++ {
+		argsList = append(argsList, []interface{}{i, "x"})
+	}
+
+	jids, err := p.EnqueueBulk("bulk", "Add", argsList)
+	assert.NoError(t, err)
+	assert.Len(t, jids, 2500)
+
+	found, _ := rc.SIsMember(ctx, "prod:queues", "bulk").Result()
+	assert.True(t, found)
+
+	nb, _ := rc.LLen(ctx, "prod:queue:bulk").Result()
+	assert.Equal(t, int64(2500), nb)
+
+	// jobs are consumed in the order they were given
+	for i := 0; i < 3; i++ {
+		bytes, _ := rc.RPop(ctx, "prod:queue:bulk").Result()
+		var data EnqueueData
+		assert.NoError(t, json.Unmarshal([]byte(bytes), &data))
+		assert.Equal(t, jids[i], data.Jid)
+		assert.Equal(t, "Add", data.Class)
+		assert.Equal(t, []interface{}{float64(i), "x"}, data.Args)
+	}
+
+	// scheduled bulk enqueues go to the schedule set
+	jids, err = p.EnqueueBulkWithContext(ctx, "bulk", "Add", argsList[:10], EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60})
+	assert.NoError(t, err)
+	assert.Len(t, jids, 10)
+	scheduled, _ := rc.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Result()
+	assert.Equal(t, int64(10), scheduled)
+
+	jids, err = p.EnqueueBulk("bulk", "Add", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, jids)
+}
+
+// partialFailureStore fails the writes to one queue, and the unique locks acquired after the given number
+type partialFailureStore struct {
+	storage.Store
+	failQueue string
+	locks     int
+	maxLocks  int
+}
+
+func (s *partialFailureStore) EnqueueMessagesNow(ctx context.Context, queue string, messages []string) error {
+	if queue == s.failQueue {
+		return errRedisDown
+	}
+	return s.Store.EnqueueMessagesNow(ctx, queue, messages)
+}
+
+func (s *partialFailureStore) AcquireUniqueJobLock(ctx context.Context, digest string, jid string, ttl time.Duration) (bool, error) {
+	s.locks++
+	if s.maxLocks > 0 && s.locks > s.maxLocks {
+		return false, errRedisDown
+	}
+	return s.Store.AcquireUniqueJobLock(ctx, digest, jid, ttl)
+}
+
+func TestProducer_EnqueueBulkPartialFailure(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	store := &partialFailureStore{Store: opts.store, failQueue: "other"}
+	opts.store = store
+	opts.ProducerMiddlewares = NewProducerMiddlewares(func(next EnqueueFunc) EnqueueFunc {
+		return func(ctx context.Context, job *EnqueueData) error {
+			if job.Args.([]interface{})[0] == "reroute" {
+				job.Queue = "other"
+			}
+			return next(ctx, job)
+		}
+	})
+	p := newProducer(opts)
+	b := p.NewBatch("import")
+	assert.NoError(t, store.OpenBatch(ctx, b.ID, map[string]interface{}{}, "open", batchTTL))
+	unique := EnqueueOptions{At: nowToSecondsWithNanoPrecision(), UniqueFor: time.Minute, Bid: b.ID}
+
+	// the jobs written before the failing destination keep their JID and lock
+	argsList := [][]interface{}{{"keep"}, {"reroute"}}
+	jids, err := p.EnqueueBulkWithContext(ctx, "bulk", "Add", argsList, unique)
+	assert.Equal(t, errRedisDown, err)
+	if assert.Len(t, jids, 2) {
+		assert.NotEmpty(t, jids[0])
+		assert.Empty(t, jids[1])
+	}
+	assert.EqualValues(t, 1, rc.LLen(ctx, "prod:queue:bulk").Val())
+	status, err := p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), status.Pending, "the open token and the written job")
+
+	store.failQueue = ""
+	jids, err = p.EnqueueBulkWithContext(ctx, "bulk", "Add", argsList, unique)
+	assert.NoError(t, err)
+	assert.Equal(t, "", jids[0], "the written job is still locked")
+	assert.NotEmpty(t, jids[1], "the job which wasn't written was unlocked")
+
+	// jobs registered in their batch before a lock failed are taken out of it
+	store.locks, store.maxLocks = 0, 1
+	_, err = p.EnqueueBulkWithContext(ctx, "bulk", "Add", [][]interface{}{{1}, {2}}, unique)
+	assert.Equal(t, errRedisDown, err)
+	status, err = p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), status.Pending)
+}
+
+func TestProducer_CancelScheduled(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	jid, err := p.EnqueueIn("reminders", "Remind", 60, []int{1})
+	assert.NoError(t, err)
+	other, err := p.EnqueueIn("reminders", "Remind", 60, []int{2})
+	assert.NoError(t, err)
+
+	cancelled, err := p.CancelScheduled(jid)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+
+	cancelled, err = p.CancelScheduled(jid)
+	assert.NoError(t, err)
+	assert.False(t, cancelled)
+
+	// JIDs only match whole values
+	cancelled, err = p.CancelScheduled(other[:10])
+	assert.NoError(t, err)
+	assert.False(t, cancelled)
+
+	scheduled, _ := rc.ZRange(ctx, "prod:"+storage.ScheduledJobsKey, 0, -1).Result()
+	if assert.Len(t, scheduled, 1) {
+		assert.Contains(t, scheduled[0], other)
+	}
+}
+
+func TestProducer_CancelScheduledReleasesJob(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	p := newProducer(opts)
+
+	unique := EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60, UniqueFor: time.Hour}
+	jid, err := p.EnqueueWithOptions("reminders", "Remind", []int{1}, unique)
+	assert.NoError(t, err)
+	_, err = p.EnqueueWithOptions("reminders", "Remind", []int{1}, unique)
+	assert.Equal(t, ErrDuplicateJob, err)
+
+	// the cancelled job gives its lock up
+	cancelled, err := p.CancelScheduled(jid)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+	_, err = p.EnqueueWithOptions("reminders", "Remind", []int{1}, unique)
+	assert.NoError(t, err)
+
+	// and is done for its batch
+	b := p.NewBatch("")
+	b.OnComplete(BatchCallback{Queue: "callbacks", Class: "RemindersDone"})
+	var batched string
+	assert.NoError(t, b.Jobs(func() (err error) {
+		batched, err = b.EnqueueWithOptions("reminders", "Remind", []int{2}, EnqueueOptions{At: nowToSecondsWithNanoPrecision() + 60})
+		return err
+	}))
+	cancelled, err = p.CancelScheduled(batched)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+	status, err := p.BatchStatus(b.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), status.Pending)
+	assert.Equal(t, int64(1), opts.client.LLen(context.Background(), "prod:queue:callbacks").Val())
+}
+
+func TestProducer_CancelRetry(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, 10, `{"jid":"retried*","class":"Remind","args":[]}`))
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, 20, `{"jid":"retried","class":"Remind","args":[]}`))
+
+	cancelled, err := p.CancelRetry("retried*")
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+
+	retries, _ := rc.ZRange(ctx, "prod:"+storage.RetryKey, 0, -1).Result()
+	assert.Equal(t, []string{`{"jid":"retried","class":"Remind","args":[]}`}, retries)
+}
+
+func TestProducer_EnqueueWithSidekiqOptions(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	_, err = p.EnqueueWithOptions("opts", "Report", []interface{}{int64(1) << 60}, EnqueueOptions{
+		Retry:          true,
+		RetryLimit:     3,
+		RetryQueue:     "slow",
+		BacktraceLines: 10,
+		Tags:           []string{"billing"},
+		ExpiresIn:      time.Hour,
+		Custom:         map[string]interface{}{"tenant": "acme", "class": "Other"},
+	})
+	assert.NoError(t, err)
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:opts").Result()
+	msg, err := NewMsg(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "Report", msg.Class())
+	assert.Equal(t, 3, msg.Get("retry").MustInt())
+	assert.Equal(t, "slow", msg.Get("retry_queue").MustString())
+	assert.Equal(t, 10, msg.Get("backtrace").MustInt())
+	assert.Equal(t, []string{"billing"}, msg.Get("tags").MustStringArray())
+	assert.Equal(t, float64(3600), msg.Get("expires_in").MustFloat64())
+	assert.Equal(t, "acme", msg.Get("tenant").MustString())
+	assert.Contains(t, bytes, `"args":[1152921504606846976]`)
+
+	_, err = p.EnqueueWithOptions("opts", "Report", []int{1}, EnqueueOptions{Retry: true, Backtrace: true})
+	assert.NoError(t, err)
+	bytes, _ = rc.RPop(ctx, "prod:queue:opts").Result()
+	msg, _ = NewMsg(bytes)
+	assert.True(t, msg.Get("retry").MustBool())
+	assert.True(t, msg.Get("backtrace").MustBool())
+	_, hasExpiry := msg.CheckGet("expires_in")
+	assert.False(t, hasExpiry)
+}
+
+func TestProducer_JIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+	jid, err := p.Enqueue("jids", "Add", []int{1})
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9a-f]{24}$", jid)
+
+	n := 0
+	opts.JIDGenerator = func() string {
+		n++
+		return fmt.Sprintf("shard-7-%d", n)
+	}
+	p = newProducer(opts)
+
+	jid, err = p.Enqueue("jids", "Add", []int{1})
+	assert.NoError(t, err)
+	assert.Equal(t, "shard-7-1", jid)
+
+	jids, err := p.EnqueueBulk("jids", "Add", [][]interface{}{{2}, {3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shard-7-2", "shard-7-3"}, jids)
+
+	rc.RPop(ctx, "prod:queue:jids")
+	bytes, _ := rc.RPop(ctx, "prod:queue:jids").Result()
+	msg, err := NewMsg(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "shard-7-1", msg.Jid())
+}
+
+func TestProducer_EnqueueMsg(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	p := newProducer(opts)
+
+	dead := `{"jid":"abc","class":"Charge","queue":"billing","args":[1],"retry":true,"retry_count":4,` +
+		`"enqueued_at":1700000000.5,"created_at":1699999999.5,"tenant":"acme"}`
+	msg, err := NewMsg(dead)
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.EnqueueMsg("billing_replay", msg))
+	assert.Equal(t, "billing", msg.Get("queue").MustString())
+
+	found, _ := rc.SIsMember(ctx, "prod:queues", "billing_replay").Result()
+	assert.True(t, found)
+	bytes, _ := rc.RPop(ctx, "prod:queue:billing_replay").Result()
+	pushed, err := NewMsg(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", pushed.Jid())
+	assert.Equal(t, "billing_replay", pushed.Get("queue").MustString())
+	assert.Equal(t, 4, pushed.Get("retry_count").MustInt())
+	assert.Equal(t, 1700000000.5, pushed.Get("enqueued_at").MustFloat64())
+	assert.Equal(t, "acme", pushed.Get("tenant").MustString())
+
+	opts.PayloadValidation = PayloadValidationStrict
+	p = newProducer(opts)
+	msg, _ = NewMsg(`{"class":"Charge","args":[1]}`)
+	assert.Error(t, p.EnqueueMsg("billing_replay", msg))
+}
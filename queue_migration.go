@@ -0,0 +1,177 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultQueueMigrationQuiet         = time.Minute
+	defaultQueueMigrationCheckInterval = 10 * time.Second
+)
+
+// QueueMigration configures the renaming of a queue without downtime, see AddMigratingWorker
+type QueueMigration struct {
+	From string
+	To   string
+	// Optional time From must stay drained for before the migration is done, so the jobs of producers
+	// still being switched over are caught, defaults to a minute
+	Quiet time.Duration
+	// Optional interval between checks of From, defaults to 10s
+	CheckInterval time.Duration
+	// Optional callback once the migration is done
+	OnDrained func(from, to string)
+}
+
+func (o QueueMigration) withDefaults() QueueMigration {
+	if o.Quiet <= 0 {
+		o.Quiet = defaultQueueMigrationQuiet
+	}
+	if o.CheckInterval <= 0 {
+		o.CheckInterval = defaultQueueMigrationCheckInterval
+	}
+	return o
+}
+
+// QueueMigrationStatus is the progress of a migration added by AddMigratingWorker
+type QueueMigrationStatus struct {
+	From string
+	To   string
+	// Zero until the migration is done
+	DrainedAt time.Time
+}
+
+type queueMigration struct {
+	QueueMigration
+	emptySince time.Time
+	drainedAt  time.Time
+}
+
+// AddMigratingWorker adds a worker running the jobs of both migration.From and migration.To, those of
+// From first, while producers are switched over to To. Jobs of From are retried on To. Once From stays
+// drained for Quiet the migration is logged as done, and From can be dropped from the worker's queues.
+func (m *Manager) AddMigratingWorker(migration QueueMigration, concurrency int, job JobFunc, mids ...MiddlewareFunc) {
+	migration = migration.withDefaults()
+	m.AddStrictWorkerForQueues(map[string]int{migration.From: 2, migration.To: 1}, concurrency, func(message *Msg) error {
+		if message.fetchedFrom == migration.From {
+			// the job's view of its queue and its retries move over with it
+			message.Set("queue", migration.To)
+			options := WorkerOptions{}
+			if message.workerOptions != nil {
+				options = *message.workerOptions
+			}
+			if options.RetryQueue == "" {
+				options.RetryQueue = migration.To
+			}
+			message.workerOptions = &options
+		}
+		return job(message)
+	}, mids...)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.migrations = append(m.migrations, &queueMigration{QueueMigration: migration})
+}
+
+// QueueMigrations returns the progress of the migrations added by AddMigratingWorker
+func (m *Manager) QueueMigrations() []QueueMigrationStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := make([]QueueMigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		res[i] = QueueMigrationStatus{From: migration.From, To: migration.To, DrainedAt: migration.drainedAt}
+	}
+	return res
+}
+
+// QueueDrained tells whether queue has no job waiting, scheduled or retried on it, and none in progress
+// in this manager
+func (m *Manager) QueueDrained(ctx context.Context, queue string) (bool, error) {
+	length, err := m.opts.store.QueueLength(ctx, queue)
+	if err != nil || length > 0 {
+		return false, err
+	}
+
+	for _, set := range []JobSet{ScheduledJobs, RetryJobs} {
+		messages, err := m.opts.store.ListSetMessages(ctx, string(set))
+		if err != nil {
+			return false, err
+		}
+		for _, scored := range messages {
+			message, err := NewMsg(scored.Message)
+			if err != nil {
+				continue
+			}
+			// retries carry the namespaced queue
+			if q, _ := message.Get("queue").String(); q == queue || q == m.opts.Namespace+queue {
+				return false, nil
+			}
+		}
+	}
+
+	m.lock.Lock()
+	workers := m.workers
+	m.lock.Unlock()
+	for _, w := range workers {
+		for _, message := range w.inProgressMessages() {
+			if message.fetchedFrom == queue || (message.fetchedFrom == "" && w.queue == queue) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// checkQueueMigration records when the From queue of migration was first seen drained, and completes
+// the migration once it stayed drained for Quiet
+func (m *Manager) checkQueueMigration(ctx context.Context, migration *queueMigration) {
+	drained, err := m.QueueDrained(ctx, migration.From)
+	if err != nil {
+		if ctx.Err() == nil {
+			m.logger.Println("ERR: couldn't check the migration of", migration.From, "to", migration.To, ":", err)
+		}
+		return
+	}
+
+	m.lock.Lock()
+	if !drained {
+		migration.emptySince = time.Time{}
+		m.lock.Unlock()
+		return
+	}
+	if migration.emptySince.IsZero() {
+		migration.emptySince = time.Now()
+	}
+	done := migration.drainedAt.IsZero() && time.Since(migration.emptySince) >= migration.Quiet
+	if done {
+		migration.drainedAt = time.Now()
+	}
+	m.lock.Unlock()
+
+	if done {
+		m.logger.Println("queue", migration.From, "is drained, its migration to", migration.To, "is done")
+		if migration.OnDrained != nil {
+			migration.OnDrained(migration.From, migration.To)
+		}
+	}
+}
+
+// runQueueMigration checks migration every CheckInterval until it is done or ctx is done
+func (m *Manager) runQueueMigration(ctx context.Context, migration *queueMigration) {
+	ticker := time.NewTicker(migration.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkQueueMigration(ctx, migration)
+			m.lock.Lock()
+			done := !migration.drainedAt.IsZero()
+			m.lock.Unlock()
+			if done {
+				return
+			}
+		}
+	}
+}
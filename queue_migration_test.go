@@ -0,0 +1,66 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_AddMigratingWorker(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	var drained []string
+	mgr.AddMigratingWorker(QueueMigration{
+		From:  "mailer",
+		To:    "mail",
+		Quiet: time.Nanosecond,
+		OnDrained: func(from, to string) {
+			drained = append(drained, from, to)
+		},
+	}, 1, func(m *Msg) error {
+		return errors.New("failed")
+	})
+	if assert.Len(t, mgr.workers, 1) {
+		assert.Equal(t, []string{"mailer", "mail"}, mgr.workers[0].sourceQueues())
+	}
+
+	p := mgr.Producer()
+	_, err = p.EnqueueIn("mailer", "Mail", 60, nil)
+	assert.NoError(t, err)
+	ok, err := mgr.QueueDrained(ctx, "mailer")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	opts.client.Del(ctx, "prod:"+string(ScheduledJobs))
+	retried, _ := NewMsg(`{"jid":"0","class":"Mail","queue":"prod:mailer"}`)
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, nowToSecondsWithNanoPrecision()+60, retried.ToJson()))
+	ok, err = mgr.QueueDrained(ctx, "mailer")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	opts.client.Del(ctx, "prod:"+string(RetryJobs))
+
+	// jobs of the old queue are retried on the new one
+	message, _ := NewMsg(`{"jid":"1","class":"Mail","queue":"mailer","retry":true}`)
+	message.fetchedFrom = "mailer"
+	assert.NoError(t, mgr.workers[0].handler(message))
+	retries, _ := opts.client.ZRange(ctx, "prod:"+string(RetryJobs), 0, -1).Result()
+	if assert.Len(t, retries, 1) {
+		retried, _ := NewMsg(retries[0])
+		assert.Equal(t, "mail", retried.Get("queue").MustString())
+	}
+
+	mgr.checkQueueMigration(ctx, mgr.migrations[0])
+	assert.Equal(t, []string{"mailer", "mail"}, drained)
+	if statuses := mgr.QueueMigrations(); assert.Len(t, statuses, 1) {
+		assert.False(t, statuses[0].DrainedAt.IsZero())
+	}
+	// the migration is only reported once
+	mgr.checkQueueMigration(ctx, mgr.migrations[0])
+	assert.Len(t, drained, 2)
+}
@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// ReplayOptions configures the replay of a job by ReplayJob
+type ReplayOptions struct {
+	// Optional queue the job is replayed on, defaults to the job's queue
+	Queue string
+	// Optional middlewares run around the handler, such as the ones of the job's worker. None by default.
+	Middlewares []MiddlewareFunc
+	// Optional destination of the trace of the replay, defaults to the manager's logger
+	Trace io.Writer
+}
+
+// ReplayResult is the outcome of a replayed job
+type ReplayResult struct {
+	Err      error
+	Duration time.Duration
+	// Trace lists every middleware and the handler as they were entered and left
+	Trace []string
+}
+
+type replayContextKey struct{}
+
+// IsReplay tells whether ctx is the context of a job replayed by ReplayJob. The contexts of replayed
+// jobs are also flagged as shadow jobs, see IsShadow.
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayContextKey{}).(bool)
+	return replay
+}
+
+// LoadDeadJob returns the payload of the job of the dead set with the given JID, or storage.NoMessage
+func (m *Manager) LoadDeadJob(ctx context.Context, jid string) (string, error) {
+	messages, err := m.opts.store.ListSetMessages(ctx, string(DeadJobs))
+	if err != nil {
+		return "", err
+	}
+	for _, scored := range messages {
+		if message, err := NewMsg(scored.Message); err == nil && message.Jid() == jid {
+			return scored.Message, nil
+		}
+	}
+	return "", storage.NoMessage
+}
+
+// ReplayJob runs a job from its payload, such as a dead job loaded by LoadDeadJob, through the given
+// middlewares and job, tracing every step. The job isn't retried and its context is flagged, see
+// IsReplay, so handlers can skip their side effects. Panics are recovered as the replay's error.
+func (m *Manager) ReplayJob(ctx context.Context, payload string, job JobFunc, opts ReplayOptions) (*ReplayResult, error) {
+	message, err := NewMsg(payload)
+	if err != nil {
+		return nil, err
+	}
+	queue := opts.Queue
+	if queue == "" {
+		// retried and dead jobs carry the namespaced queue
+		queue, _ = message.Get("queue").String()
+		queue = strings.TrimPrefix(queue, m.opts.Namespace)
+	}
+	// failures stay local instead of being written to the retry or dead set
+	message.Set("retry", false)
+	ctx = context.WithValue(ctx, replayContextKey{}, true)
+	message.ctx = context.WithValue(ctx, shadowContextKey{}, true)
+
+	result := &ReplayResult{}
+	trace := func(format string, args ...interface{}) {
+		line := fmt.Sprintf(format, args...)
+		result.Trace = append(result.Trace, line)
+		if opts.Trace != nil {
+			fmt.Fprintln(opts.Trace, line)
+		} else {
+			m.logger.Println("replay", message.Jid()+":", line)
+		}
+	}
+
+	// only the decoding steps of buildJob run, batches, checkpoints, hooks and events are left alone
+	// and the middlewares aren't timed, not to skew the timings of the queue
+	pipeline := codecJobFunc(m, queue, decompressionJobFunc(traceJobFunc("handler", job, trace)))
+	for i := len(opts.Middlewares) - 1; i >= 0; i-- {
+		mid := opts.Middlewares[i]
+		pipeline = traceJobFunc(middlewareName(mid), mid(m.opts.Namespace+queue, m, pipeline), trace)
+	}
+	pipeline = serializerJobFunc(m.opts.QueueSerializers, pipeline)
+
+	trace("replaying %s of %s on queue %s: %v", message.Jid(), message.Class(), queue, m.displayArgs(message))
+	start := time.Now()
+	result.Err = func() (err error) {
+		defer func() {
+			if e := recover(); e != nil {
+				trace("panic: %v\n%s", e, debug.Stack())
+				err = fmt.Errorf("panic: %v", e)
+			}
+		}()
+		return pipeline(message)
+	}()
+	result.Duration = time.Since(start)
+	trace("done in %v, error: %v", result.Duration, result.Err)
+	return result, nil
+}
+
+// traceJobFunc traces the job entering and leaving next
+func traceJobFunc(name string, next JobFunc, trace func(string, ...interface{})) JobFunc {
+	return func(message *Msg) error {
+		trace("-> %s", name)
+		start := time.Now()
+		err := next(message)
+		trace("<- %s in %v, error: %v", name, time.Since(start), err)
+		return err
+	}
+}
@@ -0,0 +1,109 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_ReplayJob(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	dead, _ := NewMsg(`{"jid":"dead","class":"Charge","queue":"billing","args":[42],"retry":true,"retry_count":25}`)
+	assert.NoError(t, opts.store.AddSetMessages(ctx, string(DeadJobs), []storage.ScoredMessage{{Message: dead.ToJson(), Score: 1}}))
+
+	_, err = mgr.LoadDeadJob(ctx, "other")
+	assert.Equal(t, storage.NoMessage, err)
+	payload, err := mgr.LoadDeadJob(ctx, "dead")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	var replayed, shadow bool
+	result, err := mgr.ReplayJob(ctx, payload, func(m *Msg) error {
+		replayed = IsReplay(m.Context())
+		shadow = IsShadow(m.Context())
+		assert.Equal(t, int64(42), m.Args().GetIndex(0).MustInt64())
+		return errors.New("declined")
+	}, ReplayOptions{Middlewares: []MiddlewareFunc{RetryMiddleware}, Trace: &out})
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.True(t, shadow)
+	assert.EqualError(t, result.Err, "declined")
+	assert.Contains(t, out.String(), "-> handler")
+	assert.Contains(t, result.Trace, "-> go-workers2.RetryMiddleware")
+	assert.Contains(t, out.String(), "on queue billing")
+
+	// a replay leaves the retry and dead sets alone
+	assert.Equal(t, int64(0), opts.client.ZCard(ctx, "prod:"+string(RetryJobs)).Val())
+	assert.Equal(t, int64(1), opts.client.ZCard(ctx, "prod:"+string(DeadJobs)).Val())
+
+	result, err = mgr.ReplayJob(ctx, payload, func(m *Msg) error {
+		panic("boom")
+	}, ReplayOptions{Trace: &out})
+	assert.NoError(t, err)
+	assert.EqualError(t, result.Err, "panic: boom")
+
+	_, err = mgr.ReplayJob(ctx, "not json", func(m *Msg) error { return nil }, ReplayOptions{})
+	assert.Error(t, err)
+}
+
+func TestManager_ReplayRetriedDeadJob(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	// the job fails into the retry set, then goes over its memory budget once retried and dies
+	var usage uint64
+	guard := MemoryGuardMiddleware(MemoryGuardOptions{
+		Budget:     1,
+		Sampler:    func() (uint64, error) { return atomic.LoadUint64(&usage), nil },
+		DeadLetter: true,
+	})
+	job := mgr.buildJob("billing", func(m *Msg) error {
+		if _, err := m.Get("retry_count").Int(); err == nil {
+			atomic.AddUint64(&usage, 1<<20)
+			return nil
+		}
+		return errors.New("card declined")
+	}, []MiddlewareFunc{RetryMiddleware, guard})
+
+	jid, err := mgr.Producer().EnqueueWithOptions("billing", "Charge", []int{42}, EnqueueOptions{Retry: true})
+	assert.NoError(t, err)
+	payload, _ := opts.client.LPop(ctx, "prod:queue:billing").Result()
+	message, _ := NewMsg(payload)
+	assert.NoError(t, job(message))
+	retries, _ := opts.client.ZRange(ctx, "prod:"+string(RetryJobs), 0, -1).Result()
+	if !assert.Len(t, retries, 1) {
+		return
+	}
+	opts.client.Del(ctx, "prod:"+string(RetryJobs))
+	retried, _ := NewMsg(retries[0])
+	assert.NoError(t, job(retried))
+
+	payload, err = mgr.LoadDeadJob(ctx, jid)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var queues []string
+	result, err := mgr.ReplayJob(ctx, payload, func(m *Msg) error {
+		return nil
+	}, ReplayOptions{Middlewares: []MiddlewareFunc{func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		queues = append(queues, queue)
+		return next
+	}}, Trace: &bytes.Buffer{}})
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err)
+	assert.Contains(t, result.Trace, "replaying "+jid+" of Charge on queue billing: [42]")
+	assert.Equal(t, []string{"prod:billing"}, queues)
+}
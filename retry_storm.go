@@ -0,0 +1,58 @@
+package workers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryStormWindow     = 10 * time.Second
+	defaultRetryStormThreshold  = 1000
+	defaultRetryStormSpreadOver = 5 * time.Minute
+)
+
+// RetryStormOptions configures how retries are spread when many jobs fail at once, such as when a
+// dependency goes down, so their retries don't all hit Redis and the dependency at the same time
+type RetryStormOptions struct {
+	// Optional width of the window around a retry's due time in which other retries are counted,
+	// defaults to 10 seconds
+	Window time.Duration
+	// Optional number of retries in the window from which new retries are spread, defaults to 1000
+	Threshold int64
+	// Optional interval after their due time new retries are spread over, defaults to 5 minutes
+	SpreadOver time.Duration
+}
+
+func (o RetryStormOptions) withDefaults() RetryStormOptions {
+	if o.Window <= 0 {
+		o.Window = defaultRetryStormWindow
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = defaultRetryStormThreshold
+	}
+	if o.SpreadOver <= 0 {
+		o.SpreadOver = defaultRetryStormSpreadOver
+	}
+	return o
+}
+
+// dampenRetryStorm returns when a retry due at is scheduled, delaying it by a random part of SpreadOver
+// when the retries due around that time reach the threshold
+func (m *Manager) dampenRetryStorm(ctx context.Context, at float64) float64 {
+	if m.opts.RetryStorm == nil {
+		return at
+	}
+	opts := m.opts.RetryStorm.withDefaults()
+
+	halfWindow := durationToSecondsWithNanoPrecision(opts.Window) / 2
+	count, err := m.opts.store.CountRetriedMessages(ctx, at-halfWindow, at+halfWindow)
+	if err != nil {
+		m.logger.Println("ERR: couldn't count the retries due around", at, ":", err)
+		return at
+	}
+	if count < opts.Threshold {
+		return at
+	}
+	return at + rand.Float64()*durationToSecondsWithNanoPrecision(opts.SpreadOver)
+}
@@ -0,0 +1,39 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDampenRetryStorm(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	at := nowToSecondsWithNanoPrecision() + 60
+	assert.Equal(t, at, mgr.dampenRetryStorm(ctx, at))
+
+	mgr.opts.RetryStorm = &RetryStormOptions{Window: 10 * time.Second, Threshold: 3, SpreadOver: time.Minute}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, at+float64(i), fmt.Sprintf(`{"jid":"%d"}`, i)))
+	}
+	// retries outside the window aren't counted
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, at+30, `{"jid":"late"}`))
+	assert.Equal(t, at, mgr.dampenRetryStorm(ctx, at))
+
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, at-2, `{"jid":"2"}`))
+	spread := false
+	for i := 0; i < 10; i++ {
+		dampened := mgr.dampenRetryStorm(ctx, at)
+		assert.GreaterOrEqual(t, dampened, at)
+		assert.Less(t, dampened, at+60)
+		spread = spread || dampened > at
+	}
+	assert.True(t, spread)
+}
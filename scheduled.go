@@ -4,10 +4,15 @@ import (
 	"context"
 	"strings"
 	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
 )
 
 type scheduledWorker struct {
 	opts Options
+	lag  *schedulerLag
+	// onError receives the Redis errors the scheduler carries on after
+	onError func(err error)
 }
 
 func (s *scheduledWorker) run(ctx context.Context) {
@@ -28,38 +33,100 @@ func (s *scheduledWorker) poll(ctx context.Context) {
 	now := nowToSecondsWithNanoPrecision()
 
 	for {
-		rawMessage, err := s.opts.store.DequeueScheduledMessage(ctx, now)
+		scored, err := s.opts.store.DequeueScheduledMessage(ctx, now)
 
 		if err != nil {
+			s.reportError(err)
 			break
 		}
 
+		rawMessage := scored.Message
 		message, _ := NewMsg(rawMessage)
 		queue, _ := message.Get("queue").String()
 		queue = strings.TrimPrefix(queue, s.opts.Namespace)
 		message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
+		s.opts.renameClass(message)
+
+		if _, ok := message.CheckGet(scheduledBatchField); ok {
+			pushed, err := s.enqueueBatch(ctx, queue, message)
+			if err != nil {
+				// the members are left in place, and collected on the next poll
+				s.opts.Logger.Println("ERR: couldn't collect scheduled batch", message.Jid(), ":", err)
+				s.opts.store.EnqueueScheduledMessage(ctx, now+s.opts.PollInterval.Seconds(), rawMessage)
+			}
+			if pushed {
+				s.lag.recordScheduled(scored.Score, nowToSecondsWithNanoPrecision())
+			}
+			continue
+		}
 
+		s.lag.recordScheduled(scored.Score, nowToSecondsWithNanoPrecision())
 		s.opts.store.EnqueueMessageNow(ctx, queue, message.ToJson())
 	}
 
 	for {
-		rawMessage, err := s.opts.store.DequeueRetriedMessage(ctx, now)
+		scored, err := s.opts.store.DequeueRetriedMessage(ctx, now)
 
 		if err != nil {
+			s.reportError(err)
 			break
 		}
 
-		message, _ := NewMsg(rawMessage)
+		message, _ := NewMsg(scored.Message)
 		queue, _ := message.Get("queue").String()
 		queue = strings.TrimPrefix(queue, s.opts.Namespace)
 		message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
+		s.opts.renameClass(message)
 
+		s.lag.recordRetry(scored.Score, nowToSecondsWithNanoPrecision())
 		s.opts.store.EnqueueMessageNow(ctx, queue, message.ToJson())
 	}
 }
 
-func newScheduledWorker(opts Options) *scheduledWorker {
+// reportError hands errors other than an empty set to onError
+func (s *scheduledWorker) reportError(err error) {
+	if err != storage.NoMessage && s.onError != nil {
+		s.onError(err)
+	}
+}
+
+// renameClass replaces a class listed in ClassAliases with its current name. Every path moving stored jobs
+// back to a queue goes through it, so renamed classes never come back.
+func (o *Options) renameClass(message *Msg) bool {
+	class, ok := o.ClassAliases[message.Class()]
+	if ok {
+		message.Set("class", class)
+	}
+	return ok
+}
+
+// renamePayload returns payload with its class renamed by ClassAliases, untouched if it isn't renamed
+func (o *Options) renamePayload(payload string) string {
+	if len(o.ClassAliases) == 0 {
+		return payload
+	}
+	message, err := NewMsg(payload)
+	if err != nil || !o.renameClass(message) {
+		return payload
+	}
+	return message.ToJson()
+}
+
+// classAliasJobFunc renames the class of the fetched jobs listed in ClassAliases before any middleware
+// sees them, so that the jobs still enqueued under a former name run as their current class
+func classAliasJobFunc(opts Options, next JobFunc) JobFunc {
+	if len(opts.ClassAliases) == 0 {
+		return next
+	}
+	return func(message *Msg) error {
+		opts.renameClass(message)
+		return next(message)
+	}
+}
+
+func newScheduledWorker(opts Options, lag *schedulerLag) *scheduledWorker {
 	return &scheduledWorker{
 		opts: opts,
+		lag:  lag,
 	}
 }
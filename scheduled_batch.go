@@ -0,0 +1,77 @@
+package workers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scheduledBatchTTL is how long the members of a batch are kept past its wake time
+const scheduledBatchTTL = 24 * time.Hour
+
+// scheduledBatchField names the batch of a wake message, internal to go-workers so that the jobs carrying
+// a field of their own named like it aren't mistaken for batches
+const scheduledBatchField = "gw_scheduled_batch"
+
+// scheduledBatchArgs stands for the args of a wake message, replaced with its members' as it's pushed
+const scheduledBatchArgs = "gw_scheduled_batch_args"
+
+// EnqueueBatchedAt adds args to the batch of jobs named batch waking up at the given time. The batch is
+// a single entry of the schedule set, which runs class once on queue with every member's args, in the
+// order they were added, as its own args. Members added after the batch woke up start a new batch due
+// right away. The returned JID is the batch's, which can be passed to CancelScheduled.
+// Producer middleware, uniqueness and depth guards aren't applied to batch members.
+func (p *Producer) EnqueueBatchedAt(queue, class string, at time.Time, batch string, args interface{}) (string, error) {
+	ctx := context.Background()
+
+	member, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+
+	wakeAt := timeToSecondsWithNanoPrecision(at)
+	jid := scheduledBatchJid(queue, class, batch, wakeAt)
+	// the wake message only depends on the batch, so every member schedules the same entry
+	bytes, err := p.encode(EnqueueData{
+		Queue:          queue,
+		Class:          class,
+		Args:           []interface{}{},
+		Jid:            jid,
+		CreatedAt:      wakeAt,
+		EnqueuedAt:     wakeAt,
+		EnqueueOptions: EnqueueOptions{At: wakeAt},
+		Extra:          map[string]interface{}{scheduledBatchField: batch},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ttl := time.Until(at) + scheduledBatchTTL
+	if err := p.opts.store.AddScheduledBatchMember(ctx, jid, wakeAt, string(bytes), string(member), ttl); err != nil {
+		return "", err
+	}
+	return jid, nil
+}
+
+// scheduledBatchJid identifies a batch by its queue, class, name and wake time
+func scheduledBatchJid(queue, class, batch string, at float64) string {
+	bytes, _ := json.Marshal([]interface{}{queue, class, batch, at})
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:12])
+}
+
+// enqueueBatch pushes a batch's wake message to queue with the args of its members, taken from Redis in
+// the same step, and reports whether the batch has any
+func (s *scheduledWorker) enqueueBatch(ctx context.Context, queue string, message *Msg) (bool, error) {
+	message.Set("args", scheduledBatchArgs)
+	placeholder := `"` + scheduledBatchArgs + `"`
+	parts := strings.SplitN(message.ToJson(), placeholder, 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("couldn't find the args of scheduled batch %s", message.Jid())
+	}
+	return s.opts.store.EnqueueScheduledBatch(ctx, message.Jid(), queue, parts[0], parts[1])
+}
@@ -0,0 +1,107 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueBatchedAt(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	p := newProducer(opts)
+	scheduled := newScheduledWorker(opts, nil)
+
+	due := time.Now().Add(-time.Second)
+	var jids []string
+	for _, args := range []interface{}{[]int{1}, []int{2}, map[string]int64{"id": 9007199254740993}} {
+		jid, err := p.EnqueueBatchedAt("digest", "Digest", due, "9am", args)
+		assert.NoError(t, err)
+		jids = append(jids, jid)
+	}
+	later, err := p.EnqueueBatchedAt("digest", "Digest", time.Now().Add(time.Hour), "9am", []int{4})
+	assert.NoError(t, err)
+
+	assert.Equal(t, jids[0], jids[1])
+	assert.Equal(t, jids[0], jids[2])
+	assert.NotEqual(t, jids[0], later)
+	assert.EqualValues(t, 2, rc.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Val())
+
+	scheduled.poll(ctx)
+
+	messages, _ := rc.LRange(ctx, "prod:queue:digest", 0, -1).Result()
+	if assert.Len(t, messages, 1) {
+		msg, err := NewMsg(messages[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "Digest", msg.Class())
+		assert.Equal(t, jids[0], msg.Jid())
+		assert.Equal(t, "9am", msg.Get(scheduledBatchField).MustString())
+		assert.Equal(t, `[[1],[2],{"id":9007199254740993}]`, msg.Args().ToJson())
+	}
+	assert.EqualValues(t, 1, rc.ZCard(ctx, "prod:"+storage.ScheduledJobsKey).Val())
+
+	// a member added once the batch woke up starts a new one
+	_, err = p.EnqueueBatchedAt("digest", "Digest", due, "9am", []int{5})
+	assert.NoError(t, err)
+	scheduled.poll(ctx)
+	messages, _ = rc.LRange(ctx, "prod:queue:digest", 0, -1).Result()
+	if assert.Len(t, messages, 2) {
+		msg, _ := NewMsg(messages[0])
+		assert.Equal(t, `[[5]]`, msg.Args().ToJson())
+	}
+
+	cancelled, err := p.CancelScheduled(later)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+}
+
+type failingScheduledBatchStore struct {
+	storage.Store
+	failures int
+}
+
+func (s *failingScheduledBatchStore) EnqueueScheduledBatch(ctx context.Context, batch string, queue string, prefix, suffix string) (bool, error) {
+	if s.failures > 0 {
+		s.failures--
+		return false, errRedisDown
+	}
+	return s.Store.EnqueueScheduledBatch(ctx, batch, queue, prefix, suffix)
+}
+
+func TestScheduledBatchKeepsMembersOnFailure(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.PollInterval = time.Millisecond
+	rc := opts.client
+	p := newProducer(opts)
+	opts.store = &failingScheduledBatchStore{Store: opts.store, failures: 1}
+	scheduled := newScheduledWorker(opts, nil)
+
+	jid, err := p.EnqueueBatchedAt("digest", "Digest", time.Now().Add(-time.Second), "9am", []int{2})
+	assert.NoError(t, err)
+	// an ordinary job with a field named batch isn't a scheduled batch
+	assert.NoError(t, opts.store.EnqueueScheduledMessage(ctx, nowToSecondsWithNanoPrecision()-1, `{"jid":"plain","class":"Digest","queue":"digest","args":[3],"batch":"mine"}`))
+
+	// the failed collection leaves the members to the next poll
+	scheduled.poll(ctx)
+	messages, _ := rc.LRange(ctx, "prod:queue:digest", 0, -1).Result()
+	if assert.Len(t, messages, 1) {
+		assert.Contains(t, messages[0], `"jid":"plain"`)
+	}
+	time.Sleep(5 * time.Millisecond)
+	scheduled.poll(ctx)
+	messages, _ = rc.LRange(ctx, "prod:queue:digest", 0, -1).Result()
+	if assert.Len(t, messages, 2) {
+		msg, _ := NewMsg(messages[0])
+		assert.Equal(t, jid, msg.Jid())
+		assert.Equal(t, `[[2]]`, msg.Args().ToJson())
+	}
+}
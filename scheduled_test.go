@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/digitalocean/go-workers2/storage"
@@ -15,7 +16,7 @@ func TestScheduled(t *testing.T) {
 	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
 	assert.NoError(t, err)
 
-	scheduled := newScheduledWorker(opts)
+	scheduled := newScheduledWorker(opts, nil)
 
 	rc := opts.client
 
@@ -40,6 +41,91 @@ func TestScheduled(t *testing.T) {
 	assert.Equal(t, int64(1), pending)
 }
 
+func TestScheduledClassAliases(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ClassAliases = map[string]string{"Legacy::Mailer": "Mailer"}
+
+	scheduled := newScheduledWorker(opts, nil)
+
+	rc := opts.client
+
+	now := nowToSecondsWithNanoPrecision()
+
+	retried, _ := NewMsg("{\"queue\":\"default\",\"class\":\"Legacy::Mailer\"}")
+	later, _ := NewMsg("{\"queue\":\"default\",\"class\":\"Legacy::Mailer\",\"at\":1}")
+	other, _ := NewMsg("{\"queue\":\"default\",\"class\":\"Other\"}")
+
+	rc.ZAdd(ctx, retryQueue(opts.Namespace), &redis.Z{Score: now - 60.0, Member: retried.ToJson()}).Result()
+	rc.ZAdd(ctx, "prod:"+storage.ScheduledJobsKey, &redis.Z{Score: now - 30.0, Member: later.ToJson()}).Result()
+	rc.ZAdd(ctx, retryQueue(opts.Namespace), &redis.Z{Score: now - 10.0, Member: other.ToJson()}).Result()
+
+	scheduled.poll(ctx)
+
+	messages, _ := rc.LRange(ctx, "prod:queue:default", 0, -1).Result()
+	var classes []string
+	for _, m := range messages {
+		msg, _ := NewMsg(m)
+		classes = append(classes, msg.Class())
+	}
+	assert.ElementsMatch(t, []string{"Mailer", "Mailer", "Other"}, classes)
+}
+
+func TestClassAliasesOnRequeue(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ClassAliases = map[string]string{"Legacy::Mailer": "Mailer"}
+	opts.DeadLetterQueues = map[string]DeadLetterOptions{"lettered": {}}
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	rc := opts.client
+
+	classes := func(queue string) []string {
+		jobs, err := mgr.PeekQueue(ctx, queue, 10)
+		assert.NoError(t, err)
+		var res []string
+		for _, job := range jobs {
+			res = append(res, job.Class())
+		}
+		return res
+	}
+
+	// requeued by hand, such as from the console
+	dead, _ := NewMsg(`{"jid":"dead","queue":"requeued","class":"Legacy::Mailer"}`)
+	assert.NoError(t, opts.store.EnqueueDeadMessage(ctx, nowToSecondsWithNanoPrecision(), dead.ToJson()))
+	requeued, err := mgr.RequeueJob(ctx, DeadJobs, "dead")
+	assert.NoError(t, err)
+	assert.True(t, requeued)
+	assert.Equal(t, []string{"Mailer"}, classes("requeued"))
+
+	// restored from a snapshot
+	imported, err := mgr.ImportQueue(ctx, strings.NewReader(`{"job":{"jid":"1","queue":"restored","class":"Legacy::Mailer"}}`+"\n"), "restored")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, imported)
+	assert.Equal(t, []string{"Mailer"}, classes("restored"))
+
+	// parked while the class was disabled
+	rc.LPush(ctx, "prod:queue:"+ParkedQueue("Legacy::Mailer"), `{"jid":"2","queue":"parked","class":"Legacy::Mailer"}`)
+	moved, err := mgr.EnableClass(ctx, "Legacy::Mailer")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, []string{"Mailer"}, classes("parked"))
+
+	// handed to dead-letter consumers
+	lettered, _ := NewMsg(`{"jid":"3","queue":"lettered","class":"Legacy::Mailer"}`)
+	assert.NoError(t, mgr.deadLetter(ctx, "lettered", lettered))
+	var consumed []string
+	_, err = mgr.DrainDeadLetterQueue(ctx, "lettered", func(msg *Msg) error {
+		consumed = append(consumed, msg.Class())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Mailer"}, consumed)
+}
+
 func retryQueue(namespace string) string {
 	return namespace + storage.RetryKey
 }
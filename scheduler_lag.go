@@ -0,0 +1,97 @@
+package workers
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// schedulerLagSamples is the number of recent lags the percentiles are computed over
+const schedulerLagSamples = 1000
+
+// SchedulerLag is how late the jobs of a sorted set were moved to their queue after their target time
+type SchedulerLag struct {
+	// Jobs moved since the manager started
+	Count int64 `json:"count"`
+
+	// Percentiles and maximum of the recent lags
+	P50Millis int64 `json:"p50_ms"`
+	P95Millis int64 `json:"p95_ms"`
+	MaxMillis int64 `json:"max_ms"`
+}
+
+type lagTracker struct {
+	lock    sync.Mutex
+	count   int64
+	samples []time.Duration
+	next    int
+}
+
+func (t *lagTracker) record(lag time.Duration) {
+	if lag < 0 {
+		lag = 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.count++
+	if len(t.samples) < schedulerLagSamples {
+		t.samples = append(t.samples, lag)
+		return
+	}
+	t.samples[t.next] = lag
+	t.next = (t.next + 1) % schedulerLagSamples
+}
+
+func (t *lagTracker) snapshot() SchedulerLag {
+	t.lock.Lock()
+	lag := SchedulerLag{Count: t.count}
+	samples := append([]time.Duration(nil), t.samples...)
+	t.lock.Unlock()
+
+	if len(samples) == 0 {
+		return lag
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	percentile := func(p float64) int64 {
+		return samples[int(p*float64(len(samples)-1))].Milliseconds()
+	}
+	lag.P50Millis = percentile(0.5)
+	lag.P95Millis = percentile(0.95)
+	lag.MaxMillis = samples[len(samples)-1].Milliseconds()
+	return lag
+}
+
+// schedulerLag tracks the lag of the scheduled and retried jobs moved by a manager's scheduler
+type schedulerLag struct {
+	scheduled lagTracker
+	retries   lagTracker
+}
+
+// recordScheduled tracks a scheduled job with the given target time moved at now, both in seconds
+func (l *schedulerLag) recordScheduled(target, now float64) {
+	if l != nil {
+		l.scheduled.record(secondsToDuration(now - target))
+	}
+}
+
+// recordRetry tracks a retried job with the given target time moved at now, both in seconds
+func (l *schedulerLag) recordRetry(target, now float64) {
+	if l != nil {
+		l.retries.record(secondsToDuration(now - target))
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// SchedulerLag returns how late the manager moved scheduled and retried jobs to their queue, by set
+func (m *Manager) SchedulerLag() map[string]SchedulerLag {
+	if m.schedulerLag == nil {
+		return map[string]SchedulerLag{}
+	}
+	return map[string]SchedulerLag{
+		"scheduled": m.schedulerLag.scheduled.snapshot(),
+		"retry":     m.schedulerLag.retries.snapshot(),
+	}
+}
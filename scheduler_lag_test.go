@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLagTracker(t *testing.T) {
+	tracker := &lagTracker{}
+	assert.Equal(t, SchedulerLag{}, tracker.snapshot())
+
+	for i := 1; i <= 100; i++ {
+		tracker.record(time.Duration(i) * time.Millisecond)
+	}
+	tracker.record(-time.Second)
+	assert.Equal(t, SchedulerLag{Count: 101, P50Millis: 50, P95Millis: 95, MaxMillis: 100}, tracker.snapshot())
+
+	// only the recent lags are kept
+	for i := 0; i < schedulerLagSamples; i++ {
+		tracker.record(2 * time.Second)
+	}
+	assert.Equal(t, SchedulerLag{Count: 1101, P50Millis: 2000, P95Millis: 2000, MaxMillis: 2000}, tracker.snapshot())
+}
+
+func TestScheduledRecordsLag(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	scheduled := newScheduledWorker(opts, mgr.schedulerLag)
+
+	now := nowToSecondsWithNanoPrecision()
+	opts.client.ZAdd(ctx, retryQueue(opts.Namespace), &redis.Z{Score: now - 30, Member: `{"queue":"default","jid":"1"}`})
+	opts.client.ZAdd(ctx, "prod:schedule", &redis.Z{Score: now - 5, Member: `{"queue":"default","jid":"2"}`})
+	opts.client.ZAdd(ctx, "prod:schedule", &redis.Z{Score: now - 1, Member: `{"queue":"default","jid":"3"}`})
+	scheduled.poll(ctx)
+
+	lag := mgr.SchedulerLag()
+	assert.Equal(t, int64(2), lag["scheduled"].Count)
+	assert.True(t, lag["scheduled"].MaxMillis >= 5000 && lag["scheduled"].MaxMillis < 6000)
+	assert.True(t, lag["scheduled"].P50Millis >= 1000 && lag["scheduled"].P50Millis < 2000)
+	assert.Equal(t, int64(1), lag["retry"].Count)
+	assert.True(t, lag["retry"].P95Millis >= 30000)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, lag, stats.SchedulerLag)
+}
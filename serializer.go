@@ -0,0 +1,96 @@
+package workers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Serializer encodes the args of the jobs of a queue. Jobs keep a JSON envelope, so retries, scheduling
+// and the stats API work whatever the serializer; the args encoded by serializers other than JSON are
+// stored as a single base64 string, which only Go consumers with the same serializer can decode.
+type Serializer interface {
+	// Name identifies the serializer in the payloads it encoded
+	Name() string
+	Marshal(args interface{}) ([]byte, error)
+	Unmarshal(data []byte, args interface{}) error
+}
+
+// JSONSerializer keeps args as plain JSON, readable by Sidekiq
+type JSONSerializer struct{}
+
+// Name returns "json"
+func (JSONSerializer) Name() string { return "json" }
+
+// Marshal encodes args as JSON
+func (JSONSerializer) Marshal(args interface{}) ([]byte, error) { return json.Marshal(args) }
+
+// Unmarshal decodes JSON args
+func (JSONSerializer) Unmarshal(data []byte, args interface{}) error { return json.Unmarshal(data, args) }
+
+func isJSONSerializer(s Serializer) bool {
+	return s == nil || s.Name() == JSONSerializer{}.Name()
+}
+
+// serializeArgs encodes the job's args with its queue's serializer
+func (p *Producer) serializeArgs(data EnqueueData) (EnqueueData, error) {
+	serializer := p.opts.QueueSerializers[data.Queue]
+	if isJSONSerializer(serializer) {
+		return data, nil
+	}
+
+	encoded, err := serializer.Marshal(data.Args)
+	if err != nil {
+		return data, err
+	}
+	data.Args = []interface{}{base64.StdEncoding.EncodeToString(encoded)}
+
+	extra := map[string]interface{}{}
+	for key, value := range data.Extra {
+		extra[key] = value
+	}
+	extra["serializer"] = serializer.Name()
+	data.Extra = extra
+	return data, nil
+}
+
+// DecodeArgs decodes the job's args into target, with the serializer they were encoded with
+func (m *Msg) DecodeArgs(target interface{}) error {
+	name, _ := m.Get("serializer").String()
+	if name == "" || name == (JSONSerializer{}).Name() {
+		return json.Unmarshal([]byte(m.Args().ToJson()), target)
+	}
+	if m.serializer == nil || m.serializer.Name() != name {
+		return fmt.Errorf("no %s serializer to decode job %s", name, m.Jid())
+	}
+
+	encoded, err := m.Args().GetIndex(0).String()
+	if err != nil {
+		return err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	return m.serializer.Unmarshal(data, target)
+}
+
+// serializerJobFunc gives jobs the serializer their args were encoded with, out of the manager's serializers
+func serializerJobFunc(serializers map[string]Serializer, next JobFunc) JobFunc {
+	byName := map[string]Serializer{}
+	for _, serializer := range serializers {
+		if !isJSONSerializer(serializer) {
+			byName[serializer.Name()] = serializer
+		}
+	}
+	if len(byName) == 0 {
+		return next
+	}
+
+	return func(message *Msg) error {
+		if name, err := message.Get("serializer").String(); err == nil {
+			message.serializer = byName[name]
+		}
+		return next(message)
+	}
+}
@@ -0,0 +1,66 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type gobSerializer struct{}
+
+func (gobSerializer) Name() string { return "gob" }
+
+func (gobSerializer) Marshal(args interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(args)
+	return buf.Bytes(), err
+}
+
+func (gobSerializer) Unmarshal(data []byte, args interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(args)
+}
+
+type resizeArgs struct {
+	Image string
+	Width int
+}
+
+func TestQueueSerializers(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	opts.QueueSerializers = map[string]Serializer{"internal": gobSerializer{}, "shared": JSONSerializer{}}
+	p := newProducer(opts)
+
+	_, err = p.Enqueue("internal", "Resize", resizeArgs{Image: "cat.png", Width: 64})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("shared", "Resize", []interface{}{"cat.png", 64})
+	assert.NoError(t, err)
+
+	raw, _ := rc.RPop(ctx, "prod:queue:shared").Result()
+	shared, _ := NewMsg(raw)
+	assert.Equal(t, `["cat.png",64]`, shared.Args().ToJson())
+	var sharedArgs []interface{}
+	assert.NoError(t, shared.DecodeArgs(&sharedArgs))
+	assert.Len(t, sharedArgs, 2)
+
+	raw, _ = rc.RPop(ctx, "prod:queue:internal").Result()
+	internal, _ := NewMsg(raw)
+	assert.Equal(t, "gob", internal.Get("serializer").MustString())
+	assert.Equal(t, "Resize", internal.Class())
+
+	// decoding requires a manager with the serializer
+	var decoded resizeArgs
+	assert.Error(t, internal.DecodeArgs(&decoded))
+
+	job := serializerJobFunc(opts.QueueSerializers, func(m *Msg) error {
+		return m.DecodeArgs(&decoded)
+	})
+	assert.NoError(t, job(internal))
+	assert.Equal(t, resizeArgs{Image: "cat.png", Width: 64}, decoded)
+}
@@ -0,0 +1,74 @@
+package workers
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+)
+
+// ShardedQueue is a queue split into Shards queues named Name_0 to Name_<Shards-1>. The jobs of a key
+// always go to the same shard, so a worker added by AddShardedWorker runs them in the order they were
+// enqueued while the shards are processed in parallel.
+type ShardedQueue struct {
+	Name   string
+	Shards int
+}
+
+// Queue returns the shard of key. Keys are spread by jump consistent hashing, so changing the number
+// of shards only moves the keys it has to.
+func (q ShardedQueue) Queue(key string) string {
+	return q.shard(jumpHash(key, q.shardCount()))
+}
+
+// Queues returns every shard
+func (q ShardedQueue) Queues() []string {
+	queues := make([]string, q.shardCount())
+	for i := range queues {
+		queues[i] = q.shard(i)
+	}
+	return queues
+}
+
+func (q ShardedQueue) shard(i int) string {
+	return q.Name + "_" + strconv.Itoa(i)
+}
+
+func (q ShardedQueue) shardCount() int {
+	if q.Shards <= 0 {
+		return 1
+	}
+	return q.Shards
+}
+
+// jumpHash maps key to one of buckets buckets, see "A Fast, Minimal Memory, Consistent Hash Algorithm"
+func jumpHash(key string, buckets int) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	k := h.Sum64()
+
+	b, j := int64(-1), int64(0)
+	for j < int64(buckets) {
+		b = j
+		k = k*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((k>>33)+1)))
+	}
+	return int(b)
+}
+
+// EnqueueSharded enqueues a job to the shard of key for immediate processing
+func (p *Producer) EnqueueSharded(queue ShardedQueue, key, class string, args interface{}) (string, error) {
+	return p.EnqueueShardedWithContext(context.Background(), queue, key, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+}
+
+// EnqueueShardedWithContext enqueues a job to the shard of key with the given options and context
+func (p *Producer) EnqueueShardedWithContext(ctx context.Context, queue ShardedQueue, key, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	return p.EnqueueWithContext(ctx, queue.Queue(key), class, args, opts)
+}
+
+// AddShardedWorker adds a worker running the jobs of every shard of queue, one at a time by shard so
+// the jobs of a key run in order. Jobs that fail are retried after the ones enqueued after them.
+func (m *Manager) AddShardedWorker(queue ShardedQueue, job JobFunc, mids ...MiddlewareFunc) {
+	for _, shard := range queue.Queues() {
+		m.AddWorker(shard, 1, job, mids...)
+	}
+}
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedQueue(t *testing.T) {
+	orders := ShardedQueue{Name: "orders", Shards: 8}
+	assert.Equal(t, []string{"orders_0", "orders_1", "orders_2", "orders_3", "orders_4", "orders_5", "orders_6", "orders_7"}, orders.Queues())
+	assert.Equal(t, orders.Queue("order-42"), orders.Queue("order-42"))
+	assert.Equal(t, []string{"orders_0"}, ShardedQueue{Name: "orders"}.Queues())
+
+	grown := ShardedQueue{Name: "orders", Shards: 9}
+	used := map[string]bool{}
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		used[orders.Queue(key)] = true
+		if before, after := orders.Queue(key), grown.Queue(key); before != after {
+			// keys only move to the new shard
+			assert.Equal(t, "orders_8", after)
+			moved++
+		}
+	}
+	assert.Len(t, used, 8)
+	assert.InDelta(t, 1000/9, moved, 40)
+}
+
+func TestEnqueueSharded(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	orders := ShardedQueue{Name: "orders", Shards: 4}
+	for i := 0; i < 3; i++ {
+		_, err := mgr.Producer().EnqueueSharded(orders, "order-42", "Ship", []int{i})
+		assert.NoError(t, err)
+	}
+	length, err := opts.store.QueueLength(ctx, orders.Queue("order-42"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), length)
+
+	mgr.AddShardedWorker(orders, func(m *Msg) error { return nil })
+	assert.Len(t, mgr.workers, 4)
+	for i, w := range mgr.workers {
+		assert.Equal(t, orders.Queues()[i], w.queue)
+		assert.Equal(t, 1, w.getConcurrency())
+	}
+}
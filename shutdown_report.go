@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"time"
+)
+
+// AfterShutdownFunc is executed with the report of a manager's drain once all of its workers have stopped
+type AfterShutdownFunc func(report ShutdownReport)
+
+// ShutdownReport summarizes what happened to in-flight work while a manager was draining
+type ShutdownReport struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+
+	// Completed jobs finished while the manager was draining
+	Completed []ShutdownJob `json:"completed"`
+
+	// Abandoned jobs were still running when the shutdown deadline passed
+	Abandoned []ShutdownJob `json:"abandoned"`
+
+	// Requeued abandoned jobs were pushed back onto their queue
+	Requeued []ShutdownJob `json:"requeued"`
+
+	// RequeueFailed abandoned jobs couldn't be pushed back and remain in the in-progress queue, where
+	// they are picked up again on the next start, or by another manager once the heartbeat expired
+	RequeueFailed []ShutdownJob `json:"requeue_failed"`
+}
+
+// ShutdownJob identifies a job that was in flight during a drain
+type ShutdownJob struct {
+	Queue string `json:"queue"`
+	Jid   string `json:"jid"`
+	Class string `json:"class"`
+}
+
+// Clean reports whether every in-flight job finished before the manager stopped
+func (r ShutdownReport) Clean() bool {
+	return len(r.Abandoned) == 0
+}
+
+type workerDrain struct {
+	completed []ShutdownJob
+	abandoned []ShutdownJob
+	// requeued holds the jids of the abandoned jobs pushed back onto their queue
+	requeued map[string]bool
+}
+
+func newShutdownJob(queue string, msg *Msg) ShutdownJob {
+	return ShutdownJob{
+		Queue: queue,
+		Jid:   msg.Jid(),
+		Class: msg.Class(),
+	}
+}
+
+func buildShutdownReport(startedAt time.Time, workers []*worker) ShutdownReport {
+	report := ShutdownReport{
+		StartedAt:     startedAt,
+		Duration:      time.Since(startedAt),
+		Completed:     []ShutdownJob{},
+		Abandoned:     []ShutdownJob{},
+		Requeued:      []ShutdownJob{},
+		RequeueFailed: []ShutdownJob{},
+	}
+	for _, w := range workers {
+		drain := w.lastDrain()
+		if drain == nil {
+			continue
+		}
+		report.Completed = append(report.Completed, drain.completed...)
+		report.Abandoned = append(report.Abandoned, drain.abandoned...)
+		for _, job := range drain.abandoned {
+			if drain.requeued[job.Jid] {
+				report.Requeued = append(report.Requeued, job)
+			} else {
+				report.RequeueFailed = append(report.RequeueFailed, job)
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,143 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_ShutdownReport(t *testing.T) {
+	namespace := "mgrshutdowntest"
+	opts := testOptionsWithNamespace(namespace)
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	prod := mgr.Producer()
+
+	cc := NewCallCounter()
+	mgr.AddWorker("shutdown_queue", 1, cc.F, NopMiddleware)
+
+	var reported []ShutdownReport
+	mgr.AddAfterShutdownHooks(func(report ShutdownReport) {
+		reported = append(reported, report)
+	})
+	assert.Nil(t, mgr.ShutdownReport())
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(ctx)
+		wg.Done()
+	}()
+
+	jid, err := prod.Enqueue("shutdown_queue", "Drained", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	// the job finishes after the drain has started
+	mgr.Stop()
+	time.Sleep(100 * time.Millisecond)
+	cc.ackSyncCh <- true
+	wg.Wait()
+
+	report := mgr.ShutdownReport()
+	assert.NotNil(t, report)
+	assert.True(t, report.Clean())
+	assert.Equal(t, []ShutdownJob{{Queue: "shutdown_queue", Jid: jid, Class: "Drained"}}, report.Completed)
+	assert.Empty(t, report.Abandoned)
+	assert.True(t, report.Duration >= 100*time.Millisecond)
+	assert.Len(t, reported, 1)
+}
+
+func TestManager_ShutdownReportAbandonsAfterTimeout(t *testing.T) {
+	// a stopped fetcher may still be blocked on a dequeue, and would take
+	// a job enqueued by the next test in the same namespace
+	namespace := "mgrshutdowntimeouttest"
+	opts := testOptionsWithNamespace(namespace)
+	opts.PollInterval = time.Second
+	opts.ShutdownTimeout = 200 * time.Millisecond
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	prod := mgr.Producer()
+
+	cc := NewCallCounter()
+	mgr.AddWorker("shutdown_queue", 1, cc.F, NopMiddleware)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(ctx)
+		wg.Done()
+	}()
+
+	jid, err := prod.Enqueue("shutdown_queue", "Stuck", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	mgr.Stop()
+	wg.Wait()
+
+	report := mgr.ShutdownReport()
+	assert.NotNil(t, report)
+	assert.False(t, report.Clean())
+	assert.Empty(t, report.Completed)
+	assert.Equal(t, []ShutdownJob{{Queue: "shutdown_queue", Jid: jid, Class: "Stuck"}}, report.Abandoned)
+	assert.Equal(t, report.Abandoned, report.Requeued)
+	assert.Empty(t, report.RequeueFailed)
+
+	// the abandoned job is back on its queue
+	queued, err := mgr.opts.store.ListMessages(ctx, "shutdown_queue")
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+	inProgress, err := mgr.opts.store.ListMessages(ctx, mgr.workers[0].inProgressQueue)
+	assert.NoError(t, err)
+	assert.Empty(t, inProgress)
+
+	// release the abandoned runner
+	cc.ackSyncCh <- true
+}
+
+func TestManager_ShutdownReportRequeueFailed(t *testing.T) {
+	namespace := "mgrshutdownrequeuetest"
+	opts := testOptionsWithNamespace(namespace)
+	opts.PollInterval = time.Second
+	opts.ShutdownTimeout = 200 * time.Millisecond
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	prod := mgr.Producer()
+	mgr.opts.store = &shutdownRefusingStore{Store: mgr.opts.store, refusals: 1}
+
+	cc := NewCallCounter()
+	mgr.AddWorker("shutdown_queue", 1, cc.F, NopMiddleware)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(ctx)
+		wg.Done()
+	}()
+
+	jid, err := prod.Enqueue("shutdown_queue", "Stuck", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	// Redis refuses the requeue, and there is no write buffer to retry it
+	mgr.Stop()
+	wg.Wait()
+
+	report := mgr.ShutdownReport()
+	assert.Empty(t, report.Requeued)
+	assert.Equal(t, []ShutdownJob{{Queue: "shutdown_queue", Jid: jid, Class: "Stuck"}}, report.RequeueFailed)
+	inProgress, err := mgr.opts.store.ListMessages(ctx, mgr.workers[0].inProgressQueue)
+	assert.NoError(t, err)
+	assert.Len(t, inProgress, 1)
+
+	// release the abandoned runner
+	cc.ackSyncCh <- true
+}
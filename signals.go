@@ -0,0 +1,83 @@
+package workers
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// defaultSignalShutdownTimeout is how long RunWithSignals waits for in-flight jobs by default, like Sidekiq
+const defaultSignalShutdownTimeout = 25 * time.Second
+
+// signalAction is what RunWithSignals does on a signal
+type signalAction int
+
+const (
+	signalQuiet signalAction = iota
+	signalShutdown
+	signalLogRotation
+)
+
+// AddLogRotationHooks adds functions executed when RunWithSignals gets USR2, such as to reopen log files
+func (m *Manager) AddLogRotationHooks(hooks ...func()) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.logRotationHooks = append(m.logRotationHooks, hooks...)
+}
+
+// RunWithSignals runs the manager like Run, handling signals the way Sidekiq does: TSTP quiets the
+// manager, TERM and INT stop it once its in-flight jobs are done or ShutdownTimeout passed, and USR2 runs
+// the log rotation hooks. Managers without a ShutdownTimeout get Sidekiq's 25 seconds.
+func (m *Manager) RunWithSignals(ctx context.Context) error {
+	m.lock.Lock()
+	if m.opts.ShutdownTimeout <= 0 {
+		m.opts.ShutdownTimeout = defaultSignalShutdownTimeout
+		for _, w := range m.workers {
+			w.shutdownTimeout = defaultSignalShutdownTimeout
+		}
+	}
+	m.lock.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	for sig := range sidekiqSignals {
+		signal.Notify(signals, sig)
+	}
+	defer signal.Stop(signals)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				m.handleSignal(sig, cancel)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return m.Run(ctx)
+}
+
+func (m *Manager) handleSignal(sig os.Signal, shutdown context.CancelFunc) {
+	action, ok := sidekiqSignals[sig]
+	if !ok {
+		return
+	}
+	m.logger.Println("got signal", sig)
+	switch action {
+	case signalQuiet:
+		m.Quiet()
+	case signalShutdown:
+		shutdown()
+	case signalLogRotation:
+		m.lock.Lock()
+		hooks := m.logRotationHooks
+		m.lock.Unlock()
+		for _, hook := range hooks {
+			hook()
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package workers
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_RunWithSignals(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("signals", 1, func(m *Msg) error { return nil })
+
+	rotated := make(chan bool, 1)
+	mgr.AddLogRotationHooks(func() { rotated <- true })
+
+	done := make(chan error)
+	go func() {
+		done <- mgr.RunWithSignals(context.Background())
+	}()
+	assert.Eventually(t, func() bool {
+		mgr.lock.Lock()
+		defer mgr.lock.Unlock()
+		return mgr.running
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, defaultSignalShutdownTimeout, mgr.workers[0].shutdownTimeout)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	select {
+	case <-rotated:
+	case <-time.After(time.Second):
+		t.Fatal("log rotation hooks not run")
+	}
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTSTP))
+	assert.Eventually(t, mgr.IsQuiet, time.Second, time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("manager not stopped")
+	}
+}
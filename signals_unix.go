@@ -0,0 +1,16 @@
+//go:build !windows
+
+package workers
+
+import (
+	"os"
+	"syscall"
+)
+
+// sidekiqSignals maps the signals handled by RunWithSignals to their action
+var sidekiqSignals = map[os.Signal]signalAction{
+	syscall.SIGTSTP: signalQuiet,
+	syscall.SIGTERM: signalShutdown,
+	syscall.SIGINT:  signalShutdown,
+	syscall.SIGUSR2: signalLogRotation,
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package workers
+
+import (
+	"os"
+	"syscall"
+)
+
+// sidekiqSignals maps the signals handled by RunWithSignals to their action. Windows has no TSTP or USR2.
+var sidekiqSignals = map[os.Signal]signalAction{
+	syscall.SIGTERM: signalShutdown,
+	os.Interrupt:    signalShutdown,
+}
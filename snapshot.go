@@ -0,0 +1,171 @@
+package workers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// JobSet names a sorted set of jobs
+type JobSet string
+
+const (
+	// ScheduledJobs holds the jobs enqueued for later processing
+	ScheduledJobs JobSet = storage.ScheduledJobsKey
+	// RetryJobs holds the failed jobs waiting for their next attempt
+	RetryJobs JobSet = storage.RetryKey
+	// DeadJobs holds the jobs which ran out of retries or were dead-lettered
+	DeadJobs JobSet = storage.DeadKey
+)
+
+// ParseJobSet returns the job set with the given name: schedule, retry or dead
+func ParseJobSet(name string) (JobSet, error) {
+	switch name {
+	case "schedule", "scheduled":
+		return ScheduledJobs, nil
+	case "retry", "retries":
+		return RetryJobs, nil
+	case "dead":
+		return DeadJobs, nil
+	}
+	return "", fmt.Errorf("unknown job set %q", name)
+}
+
+// snapshotImportBatch is how many entries are read before being written to Redis
+const snapshotImportBatch = 1000
+
+// snapshotExportPage is how many jobs are read from Redis at once while exporting
+const snapshotExportPage = 1000
+
+// SnapshotEntry is a line of a queue or job set snapshot
+type SnapshotEntry struct {
+	// Score of the job in its set, such as the time it is scheduled at. Unset for queue snapshots.
+	Score *float64        `json:"score,omitempty"`
+	Job   json.RawMessage `json:"job"`
+}
+
+// ExportQueue writes the jobs of a queue to w as JSON lines, oldest first, and returns how many it wrote.
+// The queue is read a page at a time, so the jobs fetched while it's exported shift the pages, and
+// some jobs may then be missed or written twice.
+func (m *Manager) ExportQueue(ctx context.Context, w io.Writer, queue string) (int, error) {
+	enc := json.NewEncoder(w)
+	written := 0
+	// jobs are pushed to the head of the list and fetched from its tail
+	for stop := int64(-1); ; stop -= snapshotExportPage {
+		messages, err := m.opts.store.ListMessagesRange(ctx, queue, stop-snapshotExportPage+1, stop)
+		if err != nil {
+			return written, err
+		}
+		for i := len(messages) - 1; i >= 0; i-- {
+			if err := enc.Encode(SnapshotEntry{Job: json.RawMessage(messages[i])}); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if len(messages) < snapshotExportPage {
+			return written, nil
+		}
+	}
+}
+
+// ImportQueue enqueues the jobs of a snapshot written by ExportQueue, in order, and returns how many it enqueued
+func (m *Manager) ImportQueue(ctx context.Context, r io.Reader, queue string) (int, error) {
+	if err := m.opts.store.CreateQueue(ctx, queue); err != nil {
+		return 0, err
+	}
+	return readSnapshot(r, func(entries []SnapshotEntry) error {
+		messages := make([]string, len(entries))
+		for i, entry := range entries {
+			messages[i] = m.opts.renamePayload(string(entry.Job))
+		}
+		return m.opts.store.EnqueueMessagesNow(ctx, queue, messages)
+	})
+}
+
+// ExportSet writes the jobs of a job set to w as JSON lines, lowest score first, and returns how many it
+// wrote. The set is read a page at a time, as ExportQueue reads queues.
+func (m *Manager) ExportSet(ctx context.Context, w io.Writer, set JobSet) (int, error) {
+	enc := json.NewEncoder(w)
+	written := 0
+	for start := int64(0); ; start += snapshotExportPage {
+		messages, err := m.opts.store.ListSetMessagesRange(ctx, string(set), start, start+snapshotExportPage-1)
+		if err != nil {
+			return written, err
+		}
+		for _, message := range messages {
+			score := message.Score
+			if err := enc.Encode(SnapshotEntry{Score: &score, Job: json.RawMessage(message.Message)}); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if len(messages) < snapshotExportPage {
+			return written, nil
+		}
+	}
+}
+
+// ImportSet adds the jobs of a snapshot written by ExportSet to a job set, and returns how many it added.
+// Entries without a score are added with the current time.
+func (m *Manager) ImportSet(ctx context.Context, r io.Reader, set JobSet) (int, error) {
+	return readSnapshot(r, func(entries []SnapshotEntry) error {
+		now := nowToSecondsWithNanoPrecision()
+		messages := make([]storage.ScoredMessage, len(entries))
+		for i, entry := range entries {
+			messages[i] = storage.ScoredMessage{Score: now, Message: m.opts.renamePayload(string(entry.Job))}
+			if entry.Score != nil {
+				messages[i].Score = *entry.Score
+			}
+		}
+		return m.opts.store.AddSetMessages(ctx, string(set), messages)
+	})
+}
+
+// readSnapshot hands the entries of a snapshot to write in batches, and returns how many were written
+func readSnapshot(r io.Reader, write func(entries []SnapshotEntry) error) (int, error) {
+	reader := bufio.NewReader(r)
+	var batch []SnapshotEntry
+	written := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := write(batch); err != nil {
+			return err
+		}
+		written += len(batch)
+		batch = nil
+		return nil
+	}
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var entry SnapshotEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return written, fmt.Errorf("snapshot line %d: %v", line, err)
+			}
+			if len(entry.Job) == 0 {
+				return written, fmt.Errorf("snapshot line %d: missing job", line)
+			}
+			batch = append(batch, entry)
+			if len(batch) >= snapshotImportBatch {
+				if err := flush(); err != nil {
+					return written, err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, flush()
+}
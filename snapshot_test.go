@@ -0,0 +1,131 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := newProducer(opts)
+
+	for i := 0; i < 3; i++ {
+		_, err := p.Enqueue("snap", "Add", []int{i})
+		assert.NoError(t, err)
+	}
+
+	var snapshot bytes.Buffer
+	exported, err := mgr.ExportQueue(ctx, &snapshot, "snap")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, exported)
+	lines := strings.Split(strings.TrimSpace(snapshot.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"args":[0]`, "the oldest job comes first")
+
+	imported, err := mgr.ImportQueue(ctx, bytes.NewReader(snapshot.Bytes()), "restored")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, imported)
+
+	// restored jobs are fetched in their original order
+	for i := 0; i < 3; i++ {
+		bytes, err := opts.client.RPop(ctx, "prod:queue:restored").Result()
+		assert.NoError(t, err)
+		msg, _ := NewMsg(bytes)
+		assert.Equal(t, i, msg.Args().GetIndex(0).MustInt())
+	}
+	queues, _ := opts.store.ListQueues(ctx)
+	assert.Contains(t, queues, "restored")
+}
+
+func TestSetSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := newProducer(opts)
+
+	jid, err := p.EnqueueIn("snap", "Add", 60, []int{1})
+	assert.NoError(t, err)
+
+	var snapshot bytes.Buffer
+	exported, err := mgr.ExportSet(ctx, &snapshot, ScheduledJobs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, exported)
+
+	opts.client.FlushDB(ctx)
+	imported, err := mgr.ImportSet(ctx, &snapshot, ScheduledJobs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, imported)
+
+	scheduled, err := opts.client.ZRangeWithScores(ctx, "prod:schedule", 0, -1).Result()
+	assert.NoError(t, err)
+	if assert.Len(t, scheduled, 1) {
+		msg, _ := NewMsg(scheduled[0].Member.(string))
+		assert.Equal(t, jid, msg.Jid())
+		assert.InDelta(t, msg.Get("at").MustFloat64(), scheduled[0].Score, 0.001)
+	}
+
+	// entries without a score are added with the current time
+	imported, err = mgr.ImportSet(ctx, strings.NewReader("\n{\"job\":{\"jid\":\"dead\"}}\n"), DeadJobs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, imported)
+	assert.EqualValues(t, 1, opts.client.ZCard(ctx, "prod:dead").Val())
+
+	_, err = mgr.ImportSet(ctx, strings.NewReader("{\"job\":{}}\nnot json\n"), DeadJobs)
+	assert.EqualError(t, err, "snapshot line 2: invalid character 'o' in literal null (expecting 'u')")
+
+	_, err = ParseJobSet("unknown")
+	assert.Error(t, err)
+	set, err := ParseJobSet("retry")
+	assert.NoError(t, err)
+	assert.Equal(t, RetryJobs, set)
+}
+
+func TestSnapshotExportPages(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := newProducer(opts)
+
+	// more jobs than fit in a page, read oldest or lowest score first across the pages
+	count := 2*snapshotExportPage + 1
+	args := make([][]interface{}, count)
+	for i := range args {
+		args[i] = []interface{}{i}
+	}
+	_, err = p.EnqueueBulk("paged", "Add", args)
+	assert.NoError(t, err)
+	for i := 0; i < count; i++ {
+		_, err := p.EnqueueAt("paged", "Add", time.Unix(int64(1900000000+i), 0), []int{i})
+		assert.NoError(t, err)
+	}
+
+	for _, export := range []func(w *bytes.Buffer) (int, error){
+		func(w *bytes.Buffer) (int, error) { return mgr.ExportQueue(ctx, w, "paged") },
+		func(w *bytes.Buffer) (int, error) { return mgr.ExportSet(ctx, w, ScheduledJobs) },
+	} {
+		var snapshot bytes.Buffer
+		exported, err := export(&snapshot)
+		assert.NoError(t, err)
+		assert.Equal(t, count, exported)
+		lines := strings.Split(strings.TrimSpace(snapshot.String()), "\n")
+		if assert.Len(t, lines, count) {
+			for _, i := range []int{0, snapshotExportPage - 1, snapshotExportPage, count - 1} {
+				assert.Contains(t, lines[i], fmt.Sprintf(`"args":[%d]`, i))
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const defaultSpilloverCheckInterval = 2 * time.Second
+
+// SpilloverOptions configures the sharing of the spare capacity of idle queues: the concurrency a queue
+// runs at is its soft limit, which it runs past, up to a hard ceiling, while other queues are empty and
+// leave runners unused. Queues settle back to their soft limit once the idle queues get jobs again. A
+// concurrency set meanwhile, by SetConcurrency or a burst, becomes the new soft limit of its queue.
+// Managers with an autoscaler don't spill over.
+type SpilloverOptions struct {
+	// Hard ceilings of the concurrency of every queue, with per-queue overrides in QueueMaxConcurrency.
+	// Queues whose ceiling isn't above their soft limit don't spill over.
+	MaxConcurrency      int
+	QueueMaxConcurrency map[string]int
+
+	// Optional interval between checks, defaults to 2 seconds
+	CheckInterval time.Duration
+}
+
+func (o SpilloverOptions) ceiling(queue string) int {
+	if ceiling, ok := o.QueueMaxConcurrency[queue]; ok {
+		return ceiling
+	}
+	return o.MaxConcurrency
+}
+
+// spilloverLoan is the runners lent to a worker, and the concurrency they brought it to
+type spilloverLoan struct {
+	extra       int
+	concurrency int
+}
+
+// workerLoad is the backlog and busy runners of a worker
+type workerLoad struct {
+	w       *worker
+	current int
+	soft    int
+	backlog int64
+	busy    int
+}
+
+// spillover gives the unused runners of the empty queues to the queues with a backlog, largest backlog
+// first, and returns the other queues to their soft limit. The workers are read on every check, and
+// loans records the runners lent to each of them, forgotten once their concurrency was changed by others.
+func (m *Manager) spillover(ctx context.Context, opts SpilloverOptions, loans map[*worker]spilloverLoan) {
+	m.lock.Lock()
+	workers := append([]*worker(nil), m.workers...)
+	m.lock.Unlock()
+
+	var loads []workerLoad
+	spare := 0
+	running := map[*worker]bool{}
+	for _, w := range workers {
+		running[w] = true
+		current := w.getConcurrency()
+		limit := current
+		if loan, ok := loans[w]; ok {
+			if loan.concurrency == limit {
+				limit -= loan.extra
+			} else {
+				delete(loans, w)
+			}
+		}
+		backlog, err := m.workerBacklog(ctx, w)
+		if err != nil {
+			m.logger.Println("ERR: couldn't read the backlog of", w.queue, ":", err)
+			continue
+		}
+		load := workerLoad{w: w, current: current, soft: limit, backlog: backlog, busy: len(w.inProgressMessages())}
+		if backlog == 0 && load.busy < limit {
+			spare += limit - load.busy
+		}
+		loads = append(loads, load)
+	}
+	for w := range loans {
+		if !running[w] {
+			delete(loans, w)
+		}
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		if loads[i].backlog != loads[j].backlog {
+			return loads[i].backlog > loads[j].backlog
+		}
+		return loads[i].w.queue < loads[j].w.queue
+	})
+
+	for _, load := range loads {
+		next := load.soft
+		if extra := opts.ceiling(load.w.queue) - load.soft; load.backlog > 0 && extra > 0 && spare > 0 {
+			if extra > spare {
+				extra = spare
+			}
+			spare -= extra
+			next += extra
+		}
+		delete(loans, load.w)
+		// left alone when its concurrency was changed since it was read
+		if next != load.current && !load.w.swapConcurrency(load.current, next) {
+			continue
+		}
+		if next > load.soft {
+			loans[load.w] = spilloverLoan{extra: next - load.soft, concurrency: next}
+		}
+		if next == load.current {
+			continue
+		}
+		if next == load.soft {
+			m.logger.Println("settling", load.w.queue, "back to", next, "runners")
+		} else {
+			m.logger.Println("spilling over", load.w.queue, "from", load.current, "to", next, "runners for a backlog of", load.backlog, "jobs")
+		}
+	}
+}
+
+// runSpillover shares the spare capacity of idle queues until ctx is done, then takes back the runners
+// still lent
+func (m *Manager) runSpillover(ctx context.Context, opts SpilloverOptions) {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultSpilloverCheckInterval
+	}
+
+	loans := map[*worker]spilloverLoan{}
+	defer func() {
+		for w, loan := range loans {
+			w.swapConcurrency(loan.concurrency, loan.concurrency-loan.extra)
+		}
+	}()
+
+	ticker := time.NewTicker(opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.spillover(ctx, opts, loans)
+		}
+	}
+}
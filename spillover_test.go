@@ -0,0 +1,116 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Spillover(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("reports", 2, func(m *Msg) error { return nil })
+	mgr.AddWorker("mailers", 3, func(m *Msg) error { return nil })
+	mgr.AddWorker("critical", 1, func(m *Msg) error { return nil })
+	reports, mailers, critical := mgr.workers[0], mgr.workers[1], mgr.workers[2]
+
+	for i := 0; i < 5; i++ {
+		opts.client.LPush(ctx, "prod:queue:reports", `{"jid":"1"}`)
+	}
+	spillover := SpilloverOptions{MaxConcurrency: 8, QueueMaxConcurrency: map[string]int{"critical": 1}}
+	loans := map[*worker]spilloverLoan{}
+
+	// the 4 runners of the empty queues are lent to reports
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 6, reports.getConcurrency())
+	assert.Equal(t, 3, mailers.getConcurrency())
+	assert.Equal(t, 1, critical.getConcurrency())
+
+	// mailers gets jobs, only critical is left to lend its runner, and mailers runs at its soft limit
+	opts.client.LPush(ctx, "prod:queue:mailers", `{"jid":"2"}`)
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 3, reports.getConcurrency())
+	assert.Equal(t, 3, mailers.getConcurrency())
+
+	// critical never spills over, its ceiling being its soft limit
+	opts.client.Del(ctx, "prod:queue:reports", "prod:queue:mailers")
+	opts.client.LPush(ctx, "prod:queue:critical", `{"jid":"3"}`)
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 2, reports.getConcurrency())
+	assert.Equal(t, 3, mailers.getConcurrency())
+	assert.Equal(t, 1, critical.getConcurrency())
+
+	// the spare capacity goes to the largest backlog first
+	opts.client.Del(ctx, "prod:queue:critical")
+	opts.client.LPush(ctx, "prod:queue:reports", `{"jid":"4"}`)
+	opts.client.LPush(ctx, "prod:queue:mailers", `{"jid":"5"}`, `{"jid":"6"}`)
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 2, reports.getConcurrency())
+	assert.Equal(t, 4, mailers.getConcurrency())
+}
+
+func TestManager_SpilloverFollowsChanges(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("reports", 2, func(m *Msg) error { return nil })
+	mgr.AddWorker("mailers", 3, func(m *Msg) error { return nil })
+	reports, mailers := mgr.workers[0], mgr.workers[1]
+
+	opts.client.LPush(ctx, "prod:queue:reports", `{"jid":"1"}`)
+	spillover := SpilloverOptions{MaxConcurrency: 10}
+	loans := map[*worker]spilloverLoan{}
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 5, reports.getConcurrency())
+
+	// a concurrency set meanwhile is the new soft limit, and isn't reverted
+	assert.NoError(t, mgr.SetConcurrency("reports", 4))
+	assert.NoError(t, mgr.SetConcurrency("mailers", 1))
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 5, reports.getConcurrency())
+	opts.client.Del(ctx, "prod:queue:reports")
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 4, reports.getConcurrency())
+	assert.Equal(t, 1, mailers.getConcurrency())
+
+	// workers added once spillover runs share their spare runners too
+	mgr.AddWorker("exports", 2, func(m *Msg) error { return nil })
+	opts.client.LPush(ctx, "prod:queue:mailers", `{"jid":"2"}`)
+	mgr.spillover(ctx, spillover, loans)
+	assert.Equal(t, 7, mailers.getConcurrency())
+}
+
+func TestManager_RunSpilloverTakesBackLoans(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("reports", 2, func(m *Msg) error { return nil })
+	mgr.AddWorker("mailers", 3, func(m *Msg) error { return nil })
+	mgr.AddWorker("exports", 1, func(m *Msg) error { return nil })
+	reports, mailers := mgr.workers[0], mgr.workers[1]
+	opts.client.LPush(ctx, "prod:queue:reports", `{"jid":"1"}`)
+	opts.client.LPush(ctx, "prod:queue:mailers", `{"jid":"2"}`)
+
+	done := make(chan struct{})
+	go func() {
+		mgr.runSpillover(ctx, SpilloverOptions{MaxConcurrency: 10, CheckInterval: 5 * time.Millisecond})
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return mailers.getConcurrency() == 4 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, mgr.SetConcurrency("reports", 6))
+	cancel()
+	<-done
+
+	// the runners lent are taken back, the concurrency set meanwhile is kept
+	assert.Equal(t, 3, mailers.getConcurrency())
+	assert.Equal(t, 6, reports.getConcurrency())
+}
@@ -0,0 +1,85 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultStandbyLease    = "leader"
+	defaultStandbyLeaseTTL = 15 * time.Second
+)
+
+// StandbyOptions configures active/passive managers: the managers sharing a lease start inactive, and
+// only the one holding the lease fetches jobs. A stopping leader releases the lease; the lease of a
+// leader which died expires after LeaseTTL, and Heartbeat should be set for its in-progress jobs to
+// be requeued.
+type StandbyOptions struct {
+	// Optional name of the lease, defaults to "leader"
+	Lease string
+	// Optional time a leader keeps the lease without renewing it, defaults to 15 seconds
+	LeaseTTL time.Duration
+	// Optional interval between renewals by the leader and attempts by standby managers, defaults to
+	// a third of LeaseTTL
+	RenewInterval time.Duration
+}
+
+func (o StandbyOptions) withDefaults() StandbyOptions {
+	if o.Lease == "" {
+		o.Lease = defaultStandbyLease
+	}
+	if o.LeaseTTL <= 0 {
+		o.LeaseTTL = defaultStandbyLeaseTTL
+	}
+	if o.RenewInterval <= 0 || o.RenewInterval >= o.LeaseTTL {
+		o.RenewInterval = o.LeaseTTL / 3
+	}
+	return o
+}
+
+// runStandby keeps the manager active while it holds the lease, until ctx is done
+func (m *Manager) runStandby(ctx context.Context, opts StandbyOptions) {
+	opts = opts.withDefaults()
+	holder, err := m.getHeartbeatID()
+	if err != nil {
+		m.logger.Println("ERR: couldn't identify the manager for the standby lease:", err)
+		return
+	}
+	defer func() {
+		if m.IsActive() {
+			m.Active(false)
+		}
+		// released even by inactive managers, which may be stopping in the middle of renewing it
+		if err := m.opts.store.ReleaseLease(context.Background(), opts.Lease, holder); err != nil {
+			m.logger.Println("ERR: couldn't release the standby lease:", err)
+		}
+	}()
+
+	ticker := time.NewTicker(opts.RenewInterval)
+	defer ticker.Stop()
+	for {
+		held, err := m.opts.store.AcquireLease(ctx, opts.Lease, holder, opts.LeaseTTL)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			m.logger.Println("ERR: couldn't renew the standby lease:", err)
+		}
+		// a leader which can't renew may already have lost the lease
+		held = held && err == nil
+		if held != m.IsActive() {
+			if held {
+				m.logger.Println("acquired the standby lease", opts.Lease)
+			} else {
+				m.logger.Println("lost the standby lease", opts.Lease)
+			}
+			m.Active(held)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
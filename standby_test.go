@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_StandbyFailover(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	standby := StandbyOptions{LeaseTTL: time.Minute, RenewInterval: 10 * time.Millisecond}
+	opts.Standby = &standby
+
+	leader, err := newManager(opts)
+	assert.NoError(t, err)
+	follower, err := newManager(opts)
+	assert.NoError(t, err)
+	assert.False(t, leader.IsActive())
+	assert.False(t, follower.IsActive())
+
+	var wg sync.WaitGroup
+	run := func(m *Manager, ctx context.Context) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.runStandby(ctx, standby)
+		}()
+	}
+
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	run(leader, leaderCtx)
+	assert.Eventually(t, leader.IsActive, time.Second, time.Millisecond)
+
+	followerCtx, stopFollower := context.WithCancel(context.Background())
+	run(follower, followerCtx)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, follower.IsActive())
+
+	// a stopping leader hands the lease over right away
+	stopLeader()
+	assert.Eventually(t, follower.IsActive, time.Second, time.Millisecond)
+	assert.False(t, leader.IsActive())
+
+	stopFollower()
+	wg.Wait()
+	assert.False(t, follower.IsActive())
+}
+
+func TestStandbyOptions(t *testing.T) {
+	opts := StandbyOptions{LeaseTTL: 30 * time.Second}.withDefaults()
+	assert.Equal(t, "leader", opts.Lease)
+	assert.Equal(t, 10*time.Second, opts.RenewInterval)
+
+	_, err := processOptions(Options{
+		ServerAddr: testServerAddr,
+		ProcessID:  "1",
+		Standby:    &StandbyOptions{},
+		Heartbeat:  &HeartbeatOptions{PrioritizedManager: &PrioritizedManagerOptions{TotalActiveManagers: 1}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// RedisStoreOption configures optional behavior of the Redis store
+type RedisStoreOption func(r *redisStore)
+
+// WithMetadataCache caches slowly-changing set reads (known queues, registered processes) for ttl.
+//
+// go-redis v8 speaks RESP2 only, so server-assisted invalidation (CLIENT TRACKING) isn't available:
+// writes go through to Redis and update or drop the entry of this store once done, and entries
+// otherwise expire after ttl. A queue or process another process removed or added may therefore be
+// listed, or missed, for up to ttl.
+func WithMetadataCache(ttl time.Duration) RedisStoreOption {
+	return func(r *redisStore) {
+		if ttl > 0 {
+			r.cache = newMetadataCache(ttl)
+		}
+	}
+}
+
+type metadataCacheEntry struct {
+	members map[string]bool
+	expires time.Time
+}
+
+// metadataCache is safe to use as a nil pointer, in which case every lookup misses
+type metadataCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]*metadataCacheEntry
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{
+		ttl:     ttl,
+		entries: map[string]*metadataCacheEntry{},
+	}
+}
+
+func (c *metadataCache) entry(key string) *metadataCacheEntry {
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (c *metadataCache) members(key string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := c.entry(key)
+	if e == nil {
+		return nil, false
+	}
+	members := make([]string, 0, len(e.members))
+	for m := range e.members {
+		members = append(members, m)
+	}
+	return members, true
+}
+
+func (c *metadataCache) set(key string, members []string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := &metadataCacheEntry{
+		members: make(map[string]bool, len(members)),
+		expires: time.Now().Add(c.ttl),
+	}
+	for _, m := range members {
+		e.members[m] = true
+	}
+	c.entries[key] = e
+}
+
+// add records a member this store just wrote into an already cached entry, without extending its lifetime
+func (c *metadataCache) add(key, member string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e := c.entry(key); e != nil {
+		e.members[member] = true
+	}
+}
+
+func (c *metadataCache) invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, key)
+}
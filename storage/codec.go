@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrInvalidSignature is returned for the args which don't match their signature, were encoded by
+// unknown codecs, or weren't authenticated by the codecs their queue requires
+var ErrInvalidSignature = errors.New("invalid args signature")
+
+// Codec is a reversible transform of the encoded args of a job, such as compression or encryption
+type Codec interface {
+	// Name identifies the codec in the payloads it encoded
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+// Authenticator is implemented by the codecs which authenticate the args they decode, such as signatures
+// and authenticated encryption. The jobs of a queue whose chain has one are only decoded once they were
+// encoded by it.
+type Authenticator interface {
+	Authenticates() bool
+}
+
+// CodecChain is the ordered list of codecs applied to the args of the jobs of a queue, such as compress,
+// then encrypt, then sign
+type CodecChain []Codec
+
+func (c CodecChain) names() []string {
+	names := make([]string, len(c))
+	for i, codec := range c {
+		names[i] = codec.Name()
+	}
+	return names
+}
+
+// find returns the codec of the chain with the given name
+func (c CodecChain) find(name string) Codec {
+	for _, codec := range c {
+		if codec.Name() == name {
+			return codec
+		}
+	}
+	return nil
+}
+
+// encode applies the codecs in order
+func (c CodecChain) encode(data []byte) ([]byte, []string, error) {
+	for _, codec := range c {
+		var err error
+		if data, err = codec.Encode(data); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", codec.Name(), err)
+		}
+	}
+	return data, c.names(), nil
+}
+
+// WithQueueCodecs encodes the args of the jobs of some queues through their codec chain. The codecs of
+// every chain decode the jobs of any queue, as jobs keep their encoding when moved to other queues.
+func WithQueueCodecs(chains map[string]CodecChain) RedisStoreOption {
+	return func(r *redisStore) {
+		r.codecs = chains
+		r.codecsByName = map[string]Codec{}
+		queues := make([]string, 0, len(chains))
+		for queue := range chains {
+			queues = append(queues, queue)
+		}
+		sort.Strings(queues)
+		for _, queue := range queues {
+			for _, codec := range chains[queue] {
+				if _, ok := r.codecsByName[codec.Name()]; !ok {
+					r.codecsByName[codec.Name()] = codec
+				}
+			}
+		}
+	}
+}
+
+func (r *redisStore) EncodeArgs(queue string, args []byte) ([]byte, []string, error) {
+	chain := r.codecs[queue]
+	if len(chain) == 0 {
+		return args, nil, nil
+	}
+	return chain.encode(args)
+}
+
+func (r *redisStore) DecodeArgs(queue string, codecs []string, args []byte) ([]byte, error) {
+	chain := r.codecs[queue]
+	for _, codec := range chain {
+		if authenticator, ok := codec.(Authenticator); ok && authenticator.Authenticates() && !contains(codecs, codec.Name()) {
+			return nil, fmt.Errorf("%w: queue %s requires args encoded by %s, not %v", ErrInvalidSignature, queue, codec.Name(), codecs)
+		}
+	}
+
+	// the codecs of the queue take precedence over the codecs of the same name of other queues
+	decoders := make([]Codec, len(codecs))
+	for i, name := range codecs {
+		if decoders[i] = chain.find(name); decoders[i] == nil {
+			decoders[i] = r.codecsByName[name]
+		}
+		if decoders[i] == nil {
+			return nil, fmt.Errorf("%w: unknown codec %s", ErrInvalidSignature, name)
+		}
+	}
+	for i := len(decoders) - 1; i >= 0; i-- {
+		var err error
+		if args, err = decoders[i].Decode(args); err != nil {
+			return nil, fmt.Errorf("%s: %w", decoders[i].Name(), err)
+		}
+	}
+	return args, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,11 @@ func GetManagerKey(namespace, heartbeatID string) string {
 	return namespace + heartbeatID
 }
 
+// GetSignalsKey gets redis key of the signals sent to a manager, such as by Sidekiq's Web UI
+func GetSignalsKey(namespace, heartbeatID string) string {
+	return namespace + heartbeatID + "-signals"
+}
+
 // GetWorkersKey gets redis key for manager's workers' heartbeat
 func GetWorkersKey(managerKey string) string {
 	return managerKey + heartbeatWorkKey
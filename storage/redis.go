@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -15,23 +17,80 @@ type redisStore struct {
 	namespace string
 
 	client *redis.Client
-	logger *log.Logger
+	// fetchClient serves blocking dequeues, defaults to client
+	fetchClient *redis.Client
+	logger      *log.Logger
+	cache       *metadataCache
+
+	// statsNamespace and statsClient hold the stats counters, default to namespace and client
+	statsNamespace string
+	statsClient    *redis.Client
+
+	// codecs encode the args of the jobs of some queues, and codecsByName decode the jobs of any queue
+	codecs       map[string]CodecChain
+	codecsByName map[string]Codec
 }
 
 // Compile-time check to ensure that Redis store does in fact implement the Store interface
 var _ Store = &redisStore{}
 
 // NewRedisStore returns a new Redis store with the given namespace and preconfigured client
-func NewRedisStore(namespace string, client *redis.Client, logger *log.Logger) Store {
-	return &redisStore{
+func NewRedisStore(namespace string, client *redis.Client, logger *log.Logger, opts ...RedisStoreOption) Store {
+	r := &redisStore{
 		namespace: namespace,
 		client:    client,
 		logger:    logger,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.fetchClient == nil {
+		r.fetchClient = client
+	}
+	if r.statsClient == nil {
+		r.statsClient = client
+	}
+	if r.statsNamespace == "" {
+		r.statsNamespace = namespace
+	}
+	return r
+}
+
+// WithFetchClient serves blocking dequeues from a separate client, so they don't compete with other commands for connections
+func WithFetchClient(client *redis.Client) RedisStoreOption {
+	return func(r *redisStore) {
+		if client != nil {
+			r.fetchClient = client
+		}
+	}
+}
+
+// WithStatsStore keeps the stats counters under the given namespace, and in the Redis of the given
+// client when it isn't nil
+func WithStatsStore(namespace string, client *redis.Client) RedisStoreOption {
+	return func(r *redisStore) {
+		r.statsNamespace = namespace
+		if client != nil {
+			r.statsClient = client
+		}
+	}
+}
+
+// cachedSetMembers returns the members of a set, served from the metadata cache when enabled
+func (r *redisStore) cachedSetMembers(ctx context.Context, key string) ([]string, error) {
+	if members, ok := r.cache.members(key); ok {
+		return members, nil
+	}
+	members, err := r.client.SMembers(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	r.cache.set(key, members)
+	return members, nil
 }
 
 func (r *redisStore) DequeueMessage(ctx context.Context, queue string, inprogressQueue string, timeout time.Duration) (string, error) {
-	message, err := r.client.BRPopLPush(ctx, r.getQueueName(queue), r.getQueueName(inprogressQueue), timeout).Result()
+	message, err := r.fetchClient.BRPopLPush(ctx, r.getQueueName(queue), r.getQueueName(inprogressQueue), timeout).Result()
 
 	if err != nil {
 		// If redis returns null, the queue is empty.
@@ -50,6 +109,34 @@ func (r *redisStore) DequeueMessage(ctx context.Context, queue string, inprogres
 	return message, nil
 }
 
+func (r *redisStore) EnqueueDelayedMessage(ctx context.Context, queue string, notBefore float64, message string) error {
+	return r.client.ZAdd(ctx, r.namespace+"delayed:"+queue, &redis.Z{Score: notBefore, Member: message}).Err()
+}
+
+// promoteDelayedMessagesScript moves the due messages of a delayed set to the end of their queue
+// fetched next, the earliest due fetched first
+var promoteDelayedMessagesScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for i = #due, 1, -1 do
+	redis.call("ZREM", KEYS[1], due[i])
+	redis.call("RPUSH", KEYS[2], due[i])
+end
+return #due
+`)
+
+func (r *redisStore) PromoteDelayedMessages(ctx context.Context, queue string, now float64, count int) (int64, error) {
+	keys := []string{r.namespace + "delayed:" + queue, r.getQueueName(queue)}
+	return promoteDelayedMessagesScript.Run(ctx, r.client, keys, now, count).Int64()
+}
+
+func (r *redisStore) DequeueMessageNow(ctx context.Context, queue string, inprogressQueue string) (string, error) {
+	message, err := r.client.RPopLPush(ctx, r.getQueueName(queue), r.getQueueName(inprogressQueue)).Result()
+	if err == redis.Nil {
+		return "", NoMessage
+	}
+	return message, err
+}
+
 func (r *redisStore) CheckRtt(ctx context.Context) int64 {
 	start := time.Now()
 	r.client.Ping(ctx)
@@ -59,7 +146,7 @@ func (r *redisStore) CheckRtt(ctx context.Context) int64 {
 }
 
 func (r *redisStore) getHeartbeat(ctx context.Context, heartbeatID string) (*Heartbeat, error) {
-	heartbeatProperties := []string{"beat", "quiet", "busy", "rtt_us", "rss", "info", "manager_priority", "active_manager", "worker_heartbeats"}
+	heartbeatProperties := HeartbeatFields
 	booleanProperties := []string{"quiet", "active_manager"}
 	managerKey := GetManagerKey(r.namespace, heartbeatID)
 	heartbeatPropertyValues, err := r.client.HMGet(ctx, managerKey, heartbeatProperties...).Result()
@@ -77,13 +164,19 @@ func (r *redisStore) getHeartbeat(ctx context.Context, heartbeatID string) (*Hea
 	}
 
 	for _, booleanProperty := range booleanProperties {
-		if heartbeatMap[booleanProperty] == "1" {
+		// quiet is written the way Sidekiq's Web UI reads it
+		if value := heartbeatMap[booleanProperty]; value == "1" || value == "true" {
 			heartbeatMap[booleanProperty] = "true"
 		} else {
 			heartbeatMap[booleanProperty] = "false"
 		}
 	}
 
+	// the processes set may still list a heartbeat removed since
+	if !hasPropertyValue {
+		return nil, nil
+	}
+
 	workerHeartbeats := []WorkerHeartbeat{}
 	err = json.Unmarshal([]byte(fmt.Sprintf("%v", heartbeatMap["worker_heartbeats"])), &workerHeartbeats)
 	if err != nil {
@@ -91,10 +184,6 @@ func (r *redisStore) getHeartbeat(ctx context.Context, heartbeatID string) (*Hea
 	}
 	delete(heartbeatMap, "worker_heartbeats")
 
-	if !hasPropertyValue {
-		return nil, nil
-	}
-
 	heartbeatJson, err := json.Marshal(heartbeatMap)
 	if err != nil {
 		return nil, err
@@ -130,11 +219,7 @@ func (r *redisStore) GetAllHeartbeats(ctx context.Context) ([]*Heartbeat, error)
 }
 
 func (r *redisStore) getHeartbeatIDs(ctx context.Context) ([]string, error) {
-	heartbeatIDs, err := r.client.SMembers(ctx, GetProcessesKey(r.namespace)).Result()
-	if err != nil && err != redis.Nil {
-		return nil, err
-	}
-	return heartbeatIDs, nil
+	return r.cachedSetMembers(ctx, GetProcessesKey(r.namespace))
 }
 
 func (r *redisStore) SendHeartbeat(ctx context.Context, heartbeat *Heartbeat) error {
@@ -149,9 +234,17 @@ func (r *redisStore) SendHeartbeat(ctx context.Context, heartbeat *Heartbeat) er
 		return err
 	}
 
+	if len(heartbeat.Extra) > 0 {
+		extra := make([]interface{}, 0, len(heartbeat.Extra)*2)
+		for field, value := range heartbeat.Extra {
+			extra = append(extra, field, value)
+		}
+		pipe.HMSet(ctx, managerKey, extra...)
+	}
+
 	pipe.HMSet(ctx, managerKey,
 		"beat", heartbeat.Beat,
-		"quiet", heartbeat.Quiet,
+		"quiet", strconv.FormatBool(heartbeat.Quiet),
 		"busy", heartbeat.Busy,
 		"rtt_us", rtt,
 		"rss", heartbeat.RSS,
@@ -160,14 +253,75 @@ func (r *redisStore) SendHeartbeat(ctx context.Context, heartbeat *Heartbeat) er
 		"active_manager", heartbeat.ActiveManager,
 		"worker_heartbeats", workerHeartbeats)
 
+	workKey := GetWorkersKey(managerKey)
+	pipe.Del(ctx, workKey)
+	if len(heartbeat.Work) > 0 {
+		work := make([]interface{}, 0, len(heartbeat.Work)*2)
+		for tid, job := range heartbeat.Work {
+			work = append(work, tid, job)
+		}
+		pipe.HSet(ctx, workKey, work...)
+	}
+
+	// the process hash itself never expires: it lists the in-progress queues the other managers
+	// requeue once the process stops beating, and they remove it afterwards
+	if heartbeat.Ttl > 0 {
+		pipe.Expire(ctx, workKey, 2*heartbeat.Ttl)
+	}
+
 	_, err = pipe.Exec(ctx)
 	if err != nil && err != redis.Nil {
 		return err
 	}
+	r.cache.add(GetProcessesKey(r.namespace), heartbeat.Identity)
 
 	return nil
 }
 
+func (r *redisStore) PopSignal(ctx context.Context, heartbeatID string) (string, error) {
+	signal, err := r.client.RPop(ctx, GetSignalsKey(r.namespace, heartbeatID)).Result()
+	if err == redis.Nil {
+		return "", NoMessage
+	}
+	return signal, err
+}
+
+func (r *redisStore) PublishCancellation(ctx context.Context, jid string) error {
+	return r.client.Publish(ctx, r.namespace+"cancellations", jid).Err()
+}
+
+func (r *redisStore) SubscribeCancellations(ctx context.Context) (<-chan string, error) {
+	sub := r.client.Subscribe(ctx, r.namespace+"cancellations")
+	// wait for the subscription, so no cancellation published afterwards is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	jids := make(chan string)
+	go func() {
+		defer close(jids)
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case jids <- message.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return jids, nil
+}
+
 func (r *redisStore) getTaskRunnerID(pid int, tid string) string {
 	return fmt.Sprintf("%d-%s", pid, tid)
 }
@@ -200,6 +354,8 @@ func (r *redisStore) RemoveHeartbeat(ctx context.Context, heartbeatID string) er
 	pipe.SRem(ctx, GetProcessesKey(r.namespace), heartbeatID)
 
 	_, err := pipe.Exec(ctx)
+	// invalidated once written, so that no read made meanwhile caches the removed process again
+	r.cache.invalidate(GetProcessesKey(r.namespace))
 	if err != nil && err != redis.Nil {
 		return err
 	}
@@ -225,10 +381,23 @@ func (r *redisStore) EnqueueScheduledMessage(ctx context.Context, priority float
 	return err
 }
 
-func (r *redisStore) DequeueScheduledMessage(ctx context.Context, priority float64) (string, error) {
+func (r *redisStore) EnqueueScheduledMessages(ctx context.Context, priority float64, messages []string) error {
+	pipe := r.client.Pipeline()
+	forEachBatch(messages, func(batch []string) {
+		members := make([]*redis.Z, len(batch))
+		for i, message := range batch {
+			members[i] = &redis.Z{Score: priority, Member: message}
+		}
+		pipe.ZAdd(ctx, r.namespace+ScheduledJobsKey, members...)
+	})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisStore) DequeueScheduledMessage(ctx context.Context, priority float64) (ScoredMessage, error) {
 	key := r.namespace + ScheduledJobsKey
 
-	messages, err := r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+	messages, err := r.client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
 		Min:    "-inf",
 		Max:    strconv.FormatFloat(priority, 'f', -1, 64),
 		Offset: 0,
@@ -236,25 +405,138 @@ func (r *redisStore) DequeueScheduledMessage(ctx context.Context, priority float
 	}).Result()
 
 	if err != nil {
-		return "", err
+		return ScoredMessage{}, err
 	}
 
 	if len(messages) == 0 {
-		return "", NoMessage
+		return ScoredMessage{}, NoMessage
 	}
 
-	removed, err := r.client.ZRem(ctx, key, messages[0]).Result()
+	message := ScoredMessage{Score: messages[0].Score, Message: messages[0].Member.(string)}
+	removed, err := r.client.ZRem(ctx, key, message.Message).Result()
 	if err != nil {
-		return "", err
+		return ScoredMessage{}, err
 	}
 
 	if removed == 0 {
-		return "", NoMessage
+		return ScoredMessage{}, NoMessage
+	}
+
+	return message, nil
+}
+
+func (r *redisStore) RemoveScheduledMessage(ctx context.Context, jid string) ([]string, error) {
+	return r.removeSortedSetMessage(ctx, r.namespace+ScheduledJobsKey, jid)
+}
+
+func (r *redisStore) ListSetMessages(ctx context.Context, set string) ([]ScoredMessage, error) {
+	return r.ListSetMessagesRange(ctx, set, 0, -1)
+}
+
+func (r *redisStore) CountSetMessages(ctx context.Context, set string) (int64, error) {
+	return r.client.ZCard(ctx, r.namespace+set).Result()
+}
+
+func (r *redisStore) ListSetMessagesRange(ctx context.Context, set string, start, stop int64) ([]ScoredMessage, error) {
+	members, err := r.client.ZRangeWithScores(ctx, r.namespace+set, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]ScoredMessage, len(members))
+	for i, member := range members {
+		messages[i] = ScoredMessage{Score: member.Score, Message: member.Member.(string)}
 	}
+	return messages, nil
+}
+
+func (r *redisStore) RemoveSetMessage(ctx context.Context, set string, message string) (bool, error) {
+	removed, err := r.client.ZRem(ctx, r.namespace+set, message).Result()
+	return removed > 0, err
+}
+
+func (r *redisStore) AddSetMessages(ctx context.Context, set string, messages []ScoredMessage) error {
+	pipe := r.client.Pipeline()
+	for start := 0; start < len(messages); start += bulkBatchSize {
+		end := start + bulkBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+		members := make([]*redis.Z, 0, end-start)
+		for _, message := range messages[start:end] {
+			members = append(members, &redis.Z{Score: message.Score, Member: message.Message})
+		}
+		pipe.ZAdd(ctx, r.namespace+set, members...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddScheduledBatchMember appends member to a batch, and schedules the batch's wake message unless it already is
+func (r *redisStore) AddScheduledBatchMember(ctx context.Context, batch string, priority float64, message string, member string, ttl time.Duration) error {
+	key := r.namespace + "batch:" + batch
+	pipe := r.client.TxPipeline()
+	pipe.RPush(ctx, key, member)
+	pipe.Expire(ctx, key, ttl)
+	pipe.ZAddNX(ctx, r.namespace+ScheduledJobsKey, &redis.Z{
+		Score:  priority,
+		Member: message,
+	})
+	_, err := pipe.Exec(ctx)
+	return err
+}
 
-	return messages[0], nil
+var enqueueScheduledBatchScript = redis.NewScript(`
+local members = redis.call("LRANGE", KEYS[1], 0, -1)
+if #members == 0 then
+	return 0
+end
+redis.call("LPUSH", KEYS[2], ARGV[1] .. "[" .. table.concat(members, ",") .. "]" .. ARGV[2])
+redis.call("DEL", KEYS[1])
+return 1
+`)
+
+func (r *redisStore) EnqueueScheduledBatch(ctx context.Context, batch string, queue string, prefix, suffix string) (bool, error) {
+	keys := []string{r.namespace + "batch:" + batch, r.getQueueName(queue)}
+	pushed, err := enqueueScheduledBatchScript.Run(ctx, r.client, keys, prefix, suffix).Int()
+	return pushed == 1, err
+}
+
+// removeSortedSetMessage scans a job set for the messages with the given JID and removes them
+func (r *redisStore) removeSortedSetMessage(ctx context.Context, key string, jid string) ([]string, error) {
+	match := "*" + globEscaper.Replace(jid) + "*"
+	var cursor uint64
+	var removed []string
+	for {
+		entries, next, err := r.client.ZScan(ctx, key, cursor, match, 100).Result()
+		if err != nil {
+			return removed, err
+		}
+		// entries alternate between members and scores
+		for i := 0; i < len(entries); i += 2 {
+			var job struct {
+				Jid string `json:"jid"`
+			}
+			if err := json.Unmarshal([]byte(entries[i]), &job); err != nil || job.Jid != jid {
+				continue
+			}
+			n, err := r.client.ZRem(ctx, key, entries[i]).Result()
+			if err != nil {
+				return removed, err
+			}
+			if n > 0 {
+				removed = append(removed, entries[i])
+			}
+		}
+		if next == 0 {
+			return removed, nil
+		}
+		cursor = next
+	}
 }
 
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
 func (r *redisStore) EnqueueRetriedMessage(ctx context.Context, priority float64, message string) error {
 	_, err := r.client.ZAdd(ctx, r.namespace+RetryKey, &redis.Z{
 		Score:  priority,
@@ -264,10 +546,14 @@ func (r *redisStore) EnqueueRetriedMessage(ctx context.Context, priority float64
 	return err
 }
 
-func (r *redisStore) DequeueRetriedMessage(ctx context.Context, priority float64) (string, error) {
+func (r *redisStore) CountRetriedMessages(ctx context.Context, min, max float64) (int64, error) {
+	return r.client.ZCount(ctx, r.namespace+RetryKey, strconv.FormatFloat(min, 'f', -1, 64), strconv.FormatFloat(max, 'f', -1, 64)).Result()
+}
+
+func (r *redisStore) DequeueRetriedMessage(ctx context.Context, priority float64) (ScoredMessage, error) {
 	key := r.namespace + RetryKey
 
-	messages, err := r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+	messages, err := r.client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
 		Min:    "-inf",
 		Max:    strconv.FormatFloat(priority, 'f', -1, 64),
 		Offset: 0,
@@ -275,23 +561,78 @@ func (r *redisStore) DequeueRetriedMessage(ctx context.Context, priority float64
 	}).Result()
 
 	if err != nil {
-		return "", err
+		return ScoredMessage{}, err
 	}
 
 	if len(messages) == 0 {
-		return "", NoMessage
+		return ScoredMessage{}, NoMessage
 	}
 
-	removed, err := r.client.ZRem(ctx, key, messages[0]).Result()
+	message := ScoredMessage{Score: messages[0].Score, Message: messages[0].Member.(string)}
+	removed, err := r.client.ZRem(ctx, key, message.Message).Result()
 	if err != nil {
-		return "", err
+		return ScoredMessage{}, err
 	}
 
 	if removed == 0 {
+		return ScoredMessage{}, NoMessage
+	}
+
+	return message, nil
+}
+
+func (r *redisStore) RemoveRetriedMessage(ctx context.Context, jid string) ([]string, error) {
+	return r.removeSortedSetMessage(ctx, r.namespace+RetryKey, jid)
+}
+
+func (r *redisStore) EnqueueDeadMessage(ctx context.Context, priority float64, message string) error {
+	_, err := r.client.ZAdd(ctx, r.namespace+DeadKey, &redis.Z{
+		Score:  priority,
+		Member: message,
+	}).Result()
+
+	return err
+}
+
+func (r *redisStore) EnqueueDeadLetterMessage(ctx context.Context, queue string, priority float64, message string, retention time.Duration, maxJobs int64) error {
+	key := r.namespace + DeadLetterKey(queue)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: priority, Member: message})
+		expired := priority - retention.Seconds()
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatFloat(expired, 'f', -1, 64))
+		pipe.ZRemRangeByRank(ctx, key, 0, -maxJobs-1)
+		return nil
+	})
+	return err
+}
+
+func (r *redisStore) DequeueDeadLetterMessage(ctx context.Context, queue string) (string, error) {
+	members, err := r.client.ZPopMin(ctx, r.namespace+DeadLetterKey(queue)).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
 		return "", NoMessage
 	}
+	return members[0].Member.(string), nil
+}
 
-	return messages[0], nil
+func (r *redisStore) EnqueueConfirmedMessage(ctx context.Context, queue string, at float64, message string) (EnqueueConfirmation, error) {
+	var count *redis.IntCmd
+	var now *redis.TimeCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if at > 0 {
+			count = pipe.ZAdd(ctx, r.namespace+ScheduledJobsKey, &redis.Z{Score: at, Member: message})
+		} else {
+			count = pipe.LPush(ctx, r.getQueueName(queue), message)
+		}
+		now = pipe.Time(ctx)
+		return nil
+	})
+	if err != nil {
+		return EnqueueConfirmation{}, err
+	}
+	return EnqueueConfirmation{Count: count.Val(), Time: now.Val()}, nil
 }
 
 func (r *redisStore) EnqueueMessageNow(ctx context.Context, queue string, message string) error {
@@ -300,6 +641,54 @@ func (r *redisStore) EnqueueMessageNow(ctx context.Context, queue string, messag
 	return err
 }
 
+func (r *redisStore) EnqueueMessagesNow(ctx context.Context, queue string, messages []string) error {
+	pipe := r.client.Pipeline()
+	forEachBatch(messages, func(batch []string) {
+		values := make([]interface{}, len(batch))
+		for i, message := range batch {
+			values[i] = message
+		}
+		pipe.LPush(ctx, r.getQueueName(queue), values...)
+	})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisStore) EnqueueMessagesAtomically(ctx context.Context, messages []QueuedMessage) error {
+	var queues []string
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, message := range messages {
+			if message.At > 0 {
+				pipe.ZAdd(ctx, r.namespace+ScheduledJobsKey, &redis.Z{Score: message.At, Member: message.Message})
+				continue
+			}
+			pipe.SAdd(ctx, r.namespace+"queues", message.Queue)
+			pipe.LPush(ctx, r.getQueueName(message.Queue), message.Message)
+			queues = append(queues, message.Queue)
+		}
+		return nil
+	})
+	if err == nil {
+		for _, queue := range queues {
+			r.cache.add(r.namespace+"queues", queue)
+		}
+	}
+	return err
+}
+
+// bulkBatchSize caps the number of values sent in a single variadic command
+const bulkBatchSize = 1000
+
+func forEachBatch(messages []string, f func(batch []string)) {
+	for start := 0; start < len(messages); start += bulkBatchSize {
+		end := start + bulkBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+		f(messages[start:end])
+	}
+}
+
 func (r *redisStore) GetAllRetries(ctx context.Context) (*Retries, error) {
 	pipe := r.client.Pipeline()
 
@@ -318,10 +707,16 @@ func (r *redisStore) GetAllRetries(ctx context.Context) (*Retries, error) {
 }
 
 func (r *redisStore) GetAllStats(ctx context.Context, queues []string) (*Stats, error) {
-	pipe := r.client.Pipeline()
+	statsPipe := r.statsClient.Pipeline()
+	pGet := statsPipe.Get(ctx, r.statsNamespace+"stat:processed")
+	fGet := statsPipe.Get(ctx, r.statsNamespace+"stat:failed")
+	dGet := statsPipe.Get(ctx, r.statsNamespace+"stat:duplicates")
+	cGet := statsPipe.HGetAll(ctx, r.statsNamespace+"stat:failure_categories")
+	if _, err := statsPipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
 
-	pGet := pipe.Get(ctx, r.namespace+"stat:processed")
-	fGet := pipe.Get(ctx, r.namespace+"stat:failed")
+	pipe := r.client.Pipeline()
 	rGet := pipe.ZCard(ctx, r.namespace+RetryKey)
 	qLen := map[string]*redis.IntCmd{}
 
@@ -335,11 +730,16 @@ func (r *redisStore) GetAllStats(ctx context.Context, queues []string) (*Stats,
 	}
 
 	stats := &Stats{
-		Enqueued: make(map[string]int64),
+		Enqueued:          make(map[string]int64),
+		FailureCategories: make(map[string]int64),
+	}
+	for category, count := range cGet.Val() {
+		stats.FailureCategories[category], _ = strconv.ParseInt(count, 10, 64)
 	}
 
 	stats.Processed, _ = strconv.ParseInt(pGet.Val(), 10, 64)
 	stats.Failed, _ = strconv.ParseInt(fGet.Val(), 10, 64)
+	stats.Duplicates, _ = strconv.ParseInt(dGet.Val(), 10, 64)
 	stats.RetryCount = rGet.Val()
 
 	for q, l := range qLen {
@@ -356,12 +756,25 @@ func (r *redisStore) AcknowledgeMessage(ctx context.Context, queue string, messa
 }
 
 func (r *redisStore) CreateQueue(ctx context.Context, queue string) error {
-	_, err := r.client.SAdd(ctx, r.namespace+"queues", queue).Result()
+	key := r.namespace + "queues"
+	// written through even when cached, as other processes may have removed the queue since
+	_, err := r.client.SAdd(ctx, key, queue).Result()
+	if err == nil {
+		r.cache.add(key, queue)
+	}
 	return err
 }
 
+func (r *redisStore) ListQueues(ctx context.Context) ([]string, error) {
+	return r.cachedSetMembers(ctx, r.namespace+"queues")
+}
+
 func (r *redisStore) ListMessages(ctx context.Context, queue string) ([]string, error) {
-	messages, err := r.client.LRange(ctx, r.getQueueName(queue), 0, -1).Result()
+	return r.ListMessagesRange(ctx, queue, 0, -1)
+}
+
+func (r *redisStore) ListMessagesRange(ctx context.Context, queue string, start, stop int64) ([]string, error) {
+	messages, err := r.client.LRange(ctx, r.getQueueName(queue), start, stop).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -369,14 +782,39 @@ func (r *redisStore) ListMessages(ctx context.Context, queue string) ([]string,
 	return messages, nil
 }
 
+func (r *redisStore) QueueLength(ctx context.Context, queue string) (int64, error) {
+	return r.client.LLen(ctx, r.getQueueName(queue)).Result()
+}
+
+func (r *redisStore) PurgeQueue(ctx context.Context, queue string) (int64, error) {
+	var length *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		length = pipe.LLen(ctx, r.getQueueName(queue))
+		pipe.Del(ctx, r.getQueueName(queue))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return length.Val(), nil
+}
+
+func (r *redisStore) OldestMessage(ctx context.Context, queue string) (string, error) {
+	message, err := r.client.LIndex(ctx, r.getQueueName(queue), -1).Result()
+	if err == redis.Nil {
+		return "", NoMessage
+	}
+	return message, err
+}
+
 func (r *redisStore) IncrementStats(ctx context.Context, metric string) error {
-	rc := r.client
+	rc := r.statsClient
 
 	today := time.Now().UTC().Format("2006-01-02")
 
 	pipe := rc.Pipeline()
-	pipe.Incr(ctx, r.namespace+"stat:"+metric)
-	pipe.Incr(ctx, r.namespace+"stat:"+metric+":"+today)
+	pipe.Incr(ctx, r.statsNamespace+"stat:"+metric)
+	pipe.Incr(ctx, r.statsNamespace+"stat:"+metric+":"+today)
 
 	if _, err := pipe.Exec(ctx); err != nil {
 		return err
@@ -385,6 +823,439 @@ func (r *redisStore) IncrementStats(ctx context.Context, metric string) error {
 	return nil
 }
 
+func (r *redisStore) IncrementFailureCategory(ctx context.Context, category string) error {
+	return r.statsClient.HIncrBy(ctx, r.statsNamespace+"stat:failure_categories", category, 1).Err()
+}
+
+// acquireWindowLimitScript forgets the acquisitions which left the window, then counts one unless the
+// window is full, returning the milliseconds until its oldest acquisition leaves it
+var acquireWindowLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - interval)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+	local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+	return math.max(1, math.ceil((tonumber(oldest[2]) + interval - now) * 1000))
+end
+redis.call("ZADD", KEYS[1], now, ARGV[4])
+redis.call("EXPIRE", KEYS[1], math.ceil(interval))
+return 0
+`)
+
+func (r *redisStore) AcquireWindowLimit(ctx context.Context, name string, count int, interval time.Duration) (time.Duration, error) {
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	member := strconv.FormatFloat(now, 'f', 6, 64) + "-" + strconv.FormatInt(rand.Int63(), 36)
+	keys := []string{r.namespace + WindowLimiterPrefix + name}
+	wait, err := acquireWindowLimitScript.Run(ctx, r.client, keys, now, interval.Seconds(), count, member).Int64()
+	return time.Duration(wait) * time.Millisecond, err
+}
+
+func (r *redisStore) DisableClass(ctx context.Context, class string) error {
+	return r.client.SAdd(ctx, r.namespace+"disabled_classes", class).Err()
+}
+
+func (r *redisStore) EnableClass(ctx context.Context, class string) error {
+	return r.client.SRem(ctx, r.namespace+"disabled_classes", class).Err()
+}
+
+func (r *redisStore) GetDisabledClasses(ctx context.Context) ([]string, error) {
+	return r.client.SMembers(ctx, r.namespace+"disabled_classes").Result()
+}
+
+func (r *redisStore) SetBackpressure(ctx context.Context, queue string, state string) error {
+	return r.client.HSet(ctx, r.namespace+"backpressure", queue, state).Err()
+}
+
+func (r *redisStore) GetBackpressure(ctx context.Context) (map[string]string, error) {
+	return r.client.HGetAll(ctx, r.namespace+"backpressure").Result()
+}
+
+// acquireUniqueLockScript takes the lock unless it is held, and marks it pending since the given time
+var acquireUniqueLockScript = redis.NewScript(`
+if not redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[4])
+return 1
+`)
+
+func (r *redisStore) AcquireUniqueLock(ctx context.Context, digest string, jid string, ttl time.Duration) (bool, error) {
+	keys := []string{r.namespace + "unique:" + digest, r.namespace + "unique-pending"}
+	acquired, err := acquireUniqueLockScript.Run(ctx, r.client, keys, jid, ttl.Milliseconds(), time.Now().Unix(), digest).Int()
+	return acquired == 1, err
+}
+
+func (r *redisStore) ConfirmUniqueLocks(ctx context.Context, digests []string) error {
+	if len(digests) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(digests))
+	for i, digest := range digests {
+		members[i] = digest
+	}
+	return r.client.ZRem(ctx, r.namespace+"unique-pending", members...).Err()
+}
+
+func (r *redisStore) ReleaseUniqueLock(ctx context.Context, digest string) error {
+	pipe := r.client.TxPipeline()
+	if strings.HasPrefix(digest, UniqueJobsPrefix) {
+		pipe.Del(ctx, r.namespace+digest+":LOCKED")
+		pipe.ZRem(ctx, r.namespace+UniqueJobsPrefix+"digests", digest)
+	} else {
+		pipe.Del(ctx, r.namespace+"unique:"+digest)
+	}
+	pipe.ZRem(ctx, r.namespace+"unique-pending", digest)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// acquireUniqueJobLockScript takes the lock of a digest unless another JID holds it, like the lock script
+// of sidekiq-unique-jobs, and marks it pending since the given time
+var acquireUniqueJobLockScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 and redis.call("HLEN", KEYS[1]) > 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[4])
+redis.call("ZADD", KEYS[3], ARGV[3], ARGV[4])
+return 1
+`)
+
+func (r *redisStore) AcquireUniqueJobLock(ctx context.Context, digest string, jid string, ttl time.Duration) (bool, error) {
+	keys := []string{r.namespace + digest + ":LOCKED", r.namespace + UniqueJobsPrefix + "digests", r.namespace + "unique-pending"}
+	acquired, err := acquireUniqueJobLockScript.Run(ctx, r.client, keys, jid, ttl.Milliseconds(), time.Now().Unix(), digest).Int()
+	return acquired == 1, err
+}
+
+// releaseUniqueJobLockScript frees the lock of a digest held by a JID, like the unlock script of
+// sidekiq-unique-jobs
+var releaseUniqueJobLockScript = redis.NewScript(`
+if redis.call("HDEL", KEYS[1], ARGV[1]) == 1 and redis.call("HLEN", KEYS[1]) == 0 then
+	redis.call("DEL", KEYS[1])
+	redis.call("ZREM", KEYS[2], ARGV[2])
+end
+redis.call("ZREM", KEYS[3], ARGV[2])
+return 1
+`)
+
+func (r *redisStore) ReleaseUniqueJobLock(ctx context.Context, digest string, jid string) error {
+	keys := []string{r.namespace + digest + ":LOCKED", r.namespace + UniqueJobsPrefix + "digests", r.namespace + "unique-pending"}
+	return releaseUniqueJobLockScript.Run(ctx, r.client, keys, jid, digest).Err()
+}
+
+// releaseStaleUniqueLocksScript frees the locks pending since before the given time
+var releaseStaleUniqueLocksScript = redis.NewScript(`
+local stale = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[1])
+for _, digest in ipairs(stale) do
+	if string.sub(digest, 1, string.len(ARGV[3])) == ARGV[3] then
+		redis.call("DEL", ARGV[4] .. digest .. ":LOCKED")
+		redis.call("ZREM", ARGV[4] .. ARGV[3] .. "digests", digest)
+	else
+		redis.call("DEL", ARGV[2] .. digest)
+	end
+	redis.call("ZREM", KEYS[1], digest)
+end
+return stale
+`)
+
+func (r *redisStore) ReleaseStaleUniqueLocks(ctx context.Context, acquiredBefore time.Time) ([]string, error) {
+	keys := []string{r.namespace + "unique-pending"}
+	return releaseStaleUniqueLocksScript.Run(ctx, r.client, keys, acquiredBefore.Unix(), r.namespace+"unique:", UniqueJobsPrefix, r.namespace).StringSlice()
+}
+
+func (r *redisStore) batchKeys(bid string) []string {
+	key := r.namespace + BatchPrefix + bid
+	return []string{key, key + "-jids", key + "-failed"}
+}
+
+// OpenBatch creates a batch unless it exists, and adds token to its pending jobs until it is reported done
+func (r *redisStore) OpenBatch(ctx context.Context, bid string, fields map[string]interface{}, token string, ttl time.Duration) error {
+	keys := r.batchKeys(bid)
+	pipe := r.client.TxPipeline()
+	for field, value := range fields {
+		pipe.HSetNX(ctx, keys[0], field, value)
+	}
+	pipe.HIncrBy(ctx, keys[0], "pending", 1)
+	pipe.SAdd(ctx, keys[1], token)
+	for _, key := range keys {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisStore) AddBatchJob(ctx context.Context, bid string, jid string, ttl time.Duration) error {
+	keys := r.batchKeys(bid)
+	pipe := r.client.TxPipeline()
+	pipe.HIncrBy(ctx, keys[0], "total", 1)
+	pipe.HIncrBy(ctx, keys[0], "pending", 1)
+	pipe.SAdd(ctx, keys[1], jid)
+	for _, key := range keys {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// batchJobDoneScript records the outcome of a batch job and returns the events due which didn't fire yet:
+// complete when every pending job failed at least once, success when no job is pending
+var batchJobDoneScript = redis.NewScript(`
+if ARGV[2] == "1" then
+	if redis.call("SREM", KEYS[2], ARGV[1]) == 1 then
+		redis.call("HINCRBY", KEYS[1], "pending", -1)
+	end
+	redis.call("SREM", KEYS[3], ARGV[1])
+elseif redis.call("SISMEMBER", KEYS[2], ARGV[1]) == 1 then
+	redis.call("SADD", KEYS[3], ARGV[1])
+end
+
+local pending = tonumber(redis.call("HGET", KEYS[1], "pending") or "0")
+local failed = redis.call("SCARD", KEYS[3])
+local due = {}
+if pending == failed and redis.call("HEXISTS", KEYS[1], "complete_at") == 0 then
+	table.insert(due, "complete")
+end
+if pending == 0 and redis.call("HEXISTS", KEYS[1], "success_at") == 0 then
+	table.insert(due, "success")
+end
+return due
+`)
+
+func (r *redisStore) BatchJobDone(ctx context.Context, bid string, jid string, succeeded bool) ([]string, error) {
+	success := "0"
+	if succeeded {
+		success = "1"
+	}
+	result, err := batchJobDoneScript.Run(ctx, r.client, r.batchKeys(bid), jid, success).StringSlice()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return result, err
+}
+
+// fireBatchEventScript sets the marker of a batch event, and pushes the messages of its callbacks
+// (queue, time and message triples) only if it set it
+var fireBatchEventScript = redis.NewScript(`
+if redis.call("HSETNX", KEYS[1], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+for i = 4, #ARGV, 3 do
+	local queue, at, message = ARGV[i], tonumber(ARGV[i + 1]), ARGV[i + 2]
+	if at > 0 then
+		redis.call("ZADD", KEYS[2], at, message)
+	else
+		redis.call("SADD", KEYS[3], queue)
+		redis.call("LPUSH", ARGV[3] .. queue, message)
+	end
+end
+return 1
+`)
+
+func (r *redisStore) FireBatchEvent(ctx context.Context, bid string, event string, now float64, messages []QueuedMessage) (bool, error) {
+	keys := []string{r.batchKeys(bid)[0], r.namespace + ScheduledJobsKey, r.namespace + "queues"}
+	args := []interface{}{event + "_at", strconv.FormatFloat(now, 'f', -1, 64), r.namespace + "queue:"}
+	for _, message := range messages {
+		args = append(args, message.Queue, strconv.FormatFloat(message.At, 'f', -1, 64), message.Message)
+	}
+	fired, err := fireBatchEventScript.Run(ctx, r.client, keys, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	if fired == 1 {
+		for _, message := range messages {
+			if message.At == 0 {
+				r.cache.add(r.namespace+"queues", message.Queue)
+			}
+		}
+	}
+	return fired == 1, nil
+}
+
+func (r *redisStore) GetBatch(ctx context.Context, bid string) (*Batch, error) {
+	keys := r.batchKeys(bid)
+	pipe := r.client.Pipeline()
+	fields := pipe.HGetAll(ctx, keys[0])
+	failed := pipe.SCard(ctx, keys[2])
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if len(fields.Val()) == 0 {
+		return nil, NoBatch
+	}
+	return &Batch{Fields: fields.Val(), Failed: failed.Val()}, nil
+}
+
+func (r *redisStore) AddJobNote(ctx context.Context, jid string, note string, ttl time.Duration) error {
+	key := r.namespace + "notes:" + jid
+	pipe := r.client.TxPipeline()
+	pipe.RPush(ctx, key, note)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisStore) GetJobNotes(ctx context.Context, jids []string) (map[string][]string, error) {
+	notes := map[string][]string{}
+	if len(jids) == 0 {
+		return notes, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(jids))
+	for i, jid := range jids {
+		cmds[i] = pipe.LRange(ctx, r.namespace+"notes:"+jid, 0, -1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i, jid := range jids {
+		if len(cmds[i].Val()) > 0 {
+			notes[jid] = cmds[i].Val()
+		}
+	}
+	return notes, nil
+}
+
+func (r *redisStore) RemoveJobNotes(ctx context.Context, jid string) error {
+	return r.client.Del(ctx, r.namespace+"notes:"+jid).Err()
+}
+
+// claimCronTickScript records the tick as the last one of the cron job, unless it already is or a later one is
+var claimCronTickScript = redis.NewScript(`
+local previous = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if previous >= tonumber(ARGV[2]) then
+	return {previous, 0}
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+return {previous, 1}
+`)
+
+func (r *redisStore) ClaimCronTick(ctx context.Context, name string, tick int64) (int64, bool, error) {
+	result, err := claimCronTickScript.Run(ctx, r.client, []string{r.namespace + "cron-ticks"}, name, tick).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return result[0], result[1] == 1, nil
+}
+
+func (r *redisStore) GetCronTicks(ctx context.Context) (map[string]int64, error) {
+	fields, err := r.client.HGetAll(ctx, r.namespace+"cron-ticks").Result()
+	if err != nil {
+		return nil, err
+	}
+	ticks := make(map[string]int64, len(fields))
+	for name, value := range fields {
+		ticks[name], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return ticks, nil
+}
+
+// acquireLeaseScript extends the lease of its holder, or takes a lease nobody holds
+var acquireLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+func (r *redisStore) AcquireLease(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLeaseScript.Run(ctx, r.client, []string{r.namespace + "lease:" + name}, holder, ttl.Milliseconds()).Int()
+	return acquired == 1, err
+}
+
+// releaseLeaseScript frees the lease if it is still held by the given holder
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *redisStore) ReleaseLease(ctx context.Context, name string, holder string) error {
+	return releaseLeaseScript.Run(ctx, r.client, []string{r.namespace + "lease:" + name}, holder).Err()
+}
+
+// acquireSemaphoreScript frees the expired slots, then takes a slot unless all of them are held
+var acquireSemaphoreScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[4])
+redis.call("PEXPIREAT", KEYS[1], ARGV[2])
+return 1
+`)
+
+func (r *redisStore) AcquireSemaphore(ctx context.Context, name string, holder string, limit int, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	keys := []string{r.namespace + "semaphore:" + name}
+	acquired, err := acquireSemaphoreScript.Run(ctx, r.client, keys, now.UnixMilli(), now.Add(ttl).UnixMilli(), limit, holder).Int()
+	return acquired == 1, err
+}
+
+// renewSemaphoreScript extends a slot which is still held
+var renewSemaphoreScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call("PEXPIREAT", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+func (r *redisStore) RenewSemaphore(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	keys := []string{r.namespace + "semaphore:" + name}
+	renewed, err := renewSemaphoreScript.Run(ctx, r.client, keys, time.Now().Add(ttl).UnixMilli(), holder, ttl.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+func (r *redisStore) ReleaseSemaphore(ctx context.Context, name string, holder string) error {
+	return r.client.ZRem(ctx, r.namespace+"semaphore:"+name, holder).Err()
+}
+
+func (r *redisStore) IncrementTenantJobs(ctx context.Context, tenant string, window int64, ttl time.Duration) (int64, error) {
+	key := r.namespace + "tenant-jobs:" + tenant + ":" + strconv.FormatInt(window, 10)
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *redisStore) SetCheckpoint(ctx context.Context, jid string, checkpoint string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.namespace+"checkpoint:"+jid, checkpoint, ttl).Err()
+}
+
+func (r *redisStore) GetCheckpoint(ctx context.Context, jid string) (string, error) {
+	checkpoint, err := r.client.Get(ctx, r.namespace+"checkpoint:"+jid).Result()
+	if err == redis.Nil {
+		return "", NoCheckpoint
+	}
+	return checkpoint, err
+}
+
+func (r *redisStore) DeleteCheckpoint(ctx context.Context, jid string) error {
+	return r.client.Del(ctx, r.namespace+"checkpoint:"+jid).Err()
+}
+
+func (r *redisStore) MarkJobCompleted(ctx context.Context, jid string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.namespace+"completed:"+jid, 1, ttl).Err()
+}
+
+func (r *redisStore) IsJobCompleted(ctx context.Context, jid string) (bool, error) {
+	n, err := r.client.Exists(ctx, r.namespace+"completed:"+jid).Result()
+	return n > 0, err
+}
+
 func (r *redisStore) getQueueName(queue string) string {
 	return r.namespace + "queue:" + queue
 }
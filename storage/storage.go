@@ -9,8 +9,26 @@ import (
 const (
 	RetryKey         = "goretry"
 	ScheduledJobsKey = "schedule"
+	DeadKey          = "dead"
 )
 
+// DeadLetterKey is the sorted set holding the dead-lettered jobs of a queue
+func DeadLetterKey(queue string) string {
+	return DeadKey + ":" + queue
+}
+
+// UniqueJobsPrefix starts the digests of the locks shared with sidekiq-unique-jobs, whose holders are kept
+// in a <digest>:LOCKED hash and which are listed in the uniquejobs:digests sorted set, as Ruby keeps them
+const UniqueJobsPrefix = "uniquejobs:"
+
+// BatchPrefix starts the keys of batches: a hash of their fields, and the sets of their pending and failed
+// jobs. They are distinct from the keys of Sidekiq Pro's batches, whose layout isn't public.
+const BatchPrefix = "gw-batch-"
+
+// WindowLimiterPrefix starts the keys of the rolling-window limiters shared with Sidekiq Enterprise, sorted
+// sets of the acquisitions of the window scored by their time in seconds
+const WindowLimiterPrefix = "lmtr-w-"
+
 // StorageError is used to return errors from the storage layer
 type StorageError string
 
@@ -18,15 +36,20 @@ func (e StorageError) Error() string { return string(e) }
 
 // list of known errors
 const (
-	NoMessage = StorageError("no message")
+	NoMessage    = StorageError("no message")
+	NoBatch      = StorageError("no batch")
+	NoCheckpoint = StorageError("no checkpoint")
 )
 
 // Stats has all the stats related to a manager
 type Stats struct {
 	Processed  int64
 	Failed     int64
+	Duplicates int64
 	RetryCount int64
 	Enqueued   map[string]int64
+	// Failures by category
+	FailureCategories map[string]int64
 }
 
 // Retries has the list of messages in the retry queue
@@ -35,6 +58,34 @@ type Retries struct {
 	RetryJobs       []string
 }
 
+// ScoredMessage is a message of a sorted job set, such as the schedule, along with its score
+type ScoredMessage struct {
+	Score   float64
+	Message string
+}
+
+// EnqueueConfirmation is Redis' reply to a message written by EnqueueConfirmedMessage
+type EnqueueConfirmation struct {
+	// Length of the queue after the push, or number of messages added to the schedule
+	Count int64
+	// Time of the Redis server when the message was written
+	Time time.Time
+}
+
+// QueuedMessage is a message bound for a queue, or for the schedule when At is set
+type QueuedMessage struct {
+	Queue   string
+	At      float64
+	Message string
+}
+
+// Batch is the state of a batch of jobs
+type Batch struct {
+	Fields map[string]string
+	// Number of pending jobs which failed at least once
+	Failed int64
+}
+
 // Heartbeat is used for the ruby sidekiq web ui
 type Heartbeat struct {
 	Identity string `json:"identity"`
@@ -51,9 +102,18 @@ type Heartbeat struct {
 
 	Ttl time.Duration
 
+	// Extra holds custom fields written into the process hash alongside the standard ones
+	Extra map[string]string `json:"-"`
+
 	WorkerHeartbeats []WorkerHeartbeat `json:"-"`
+
+	// Work holds the jobs in flight by thread ID, written into the process' work hash
+	Work map[string]string `json:"-"`
 }
 
+// HeartbeatFields are the process hash fields written by the heartbeat itself
+var HeartbeatFields = []string{"beat", "quiet", "busy", "rtt_us", "rss", "info", "manager_priority", "active_manager", "worker_heartbeats"}
+
 type WorkerHeartbeat struct {
 	Pid             int    `json:"pid,string"`
 	Tid             string `json:"tid,string"`
@@ -66,28 +126,154 @@ type Store interface {
 
 	// General queue operations
 	CreateQueue(ctx context.Context, queue string) error
+	ListQueues(ctx context.Context) ([]string, error)
 	ListMessages(ctx context.Context, queue string) ([]string, error)
+	// ListMessagesRange returns the messages of queue from index start to stop included, counted from the
+	// head of the list; negative indexes count from its tail, as in LRANGE
+	ListMessagesRange(ctx context.Context, queue string, start, stop int64) ([]string, error)
+	QueueLength(ctx context.Context, queue string) (int64, error)
+	// PurgeQueue removes every message of queue, returning how many were removed
+	PurgeQueue(ctx context.Context, queue string) (int64, error)
+	// OldestMessage returns the next message to be fetched from queue, or NoMessage
+	OldestMessage(ctx context.Context, queue string) (string, error)
 	AcknowledgeMessage(ctx context.Context, queue string, message string) error
 	EnqueueMessage(ctx context.Context, queue string, priority float64, message string) error
 	EnqueueMessageNow(ctx context.Context, queue string, message string) error
+	EnqueueMessagesNow(ctx context.Context, queue string, messages []string) error
+	EnqueueMessagesAtomically(ctx context.Context, messages []QueuedMessage) error
+	// EnqueueConfirmedMessage pushes message onto queue, or to the schedule when at isn't zero
+	EnqueueConfirmedMessage(ctx context.Context, queue string, at float64, message string) (EnqueueConfirmation, error)
 	DequeueMessage(ctx context.Context, queue string, inprogressQueue string, timeout time.Duration) (string, error)
+
+	// Queued messages fetched from their queue once their not-before time is due
+	EnqueueDelayedMessage(ctx context.Context, queue string, notBefore float64, message string) error
+	// PromoteDelayedMessages moves up to count due messages of a queue to the end it's fetched from
+	PromoteDelayedMessages(ctx context.Context, queue string, now float64, count int) (int64, error)
+	// DequeueMessageNow moves a message to inprogressQueue without waiting for one, NoMessage when queue is empty
+	DequeueMessageNow(ctx context.Context, queue string, inprogressQueue string) (string, error)
 	RequeueMessagesFromInProgressQueue(ctx context.Context, inprogressQueue, queue string) ([]string, error)
 
 	// Special purpose queue operations
 	EnqueueScheduledMessage(ctx context.Context, priority float64, message string) error
-	DequeueScheduledMessage(ctx context.Context, priority float64) (string, error)
+	EnqueueScheduledMessages(ctx context.Context, priority float64, messages []string) error
+	DequeueScheduledMessage(ctx context.Context, priority float64) (ScoredMessage, error)
+	// RemoveScheduledMessage removes the messages of the job jid from the schedule set, returning them
+	RemoveScheduledMessage(ctx context.Context, jid string) ([]string, error)
+	AddScheduledBatchMember(ctx context.Context, batch string, priority float64, message string, member string, ttl time.Duration) error
+	// EnqueueScheduledBatch pushes to queue the message made of prefix, the members of batch as a JSON
+	// array, then suffix, and removes the members at once. It reports false, pushing nothing, when the batch
+	// has no members.
+	EnqueueScheduledBatch(ctx context.Context, batch string, queue string, prefix, suffix string) (bool, error)
 
 	EnqueueRetriedMessage(ctx context.Context, priority float64, message string) error
-	DequeueRetriedMessage(ctx context.Context, priority float64) (string, error)
+	DequeueRetriedMessage(ctx context.Context, priority float64) (ScoredMessage, error)
+	// RemoveRetriedMessage removes the messages of the job jid from the retry set, returning them
+	RemoveRetriedMessage(ctx context.Context, jid string) ([]string, error)
+	// CountRetriedMessages counts the retries due between min and max
+	CountRetriedMessages(ctx context.Context, min, max float64) (int64, error)
+
+	EnqueueDeadMessage(ctx context.Context, priority float64, message string) error
+	// Per-queue dead-letter sets keep the jobs dead-lettered within retention, up to maxJobs
+	EnqueueDeadLetterMessage(ctx context.Context, queue string, priority float64, message string, retention time.Duration, maxJobs int64) error
+	DequeueDeadLetterMessage(ctx context.Context, queue string) (string, error)
+
+	// Sorted job sets, named by their key such as ScheduledJobsKey
+	ListSetMessages(ctx context.Context, set string) ([]ScoredMessage, error)
+	// ListSetMessagesRange returns the messages of a job set from rank start to stop included, lowest
+	// score first, as in ZRANGE
+	ListSetMessagesRange(ctx context.Context, set string, start, stop int64) ([]ScoredMessage, error)
+	CountSetMessages(ctx context.Context, set string) (int64, error)
+	AddSetMessages(ctx context.Context, set string, messages []ScoredMessage) error
+	// RemoveSetMessage removes message from a job set, returning false when it wasn't there anymore
+	RemoveSetMessage(ctx context.Context, set string, message string) (bool, error)
 
 	// Stats
 	IncrementStats(ctx context.Context, metric string) error
+	IncrementFailureCategory(ctx context.Context, category string) error
 	GetAllStats(ctx context.Context, queues []string) (*Stats, error)
 
+	// Unique job locks. Acquired locks are pending until confirmed once their job is written, so
+	// ReleaseStaleUniqueLocks can free the ones whose producer never wrote or released them.
+	AcquireUniqueLock(ctx context.Context, digest string, jid string, ttl time.Duration) (bool, error)
+	ConfirmUniqueLocks(ctx context.Context, digests []string) error
+	ReleaseUniqueLock(ctx context.Context, digest string) error
+	// AcquireUniqueJobLock takes the sidekiq-unique-jobs lock of digest for jid, unless another job holds it
+	AcquireUniqueJobLock(ctx context.Context, digest string, jid string, ttl time.Duration) (bool, error)
+	// ReleaseUniqueJobLock frees the sidekiq-unique-jobs lock of digest if jid holds it
+	ReleaseUniqueJobLock(ctx context.Context, digest string, jid string) error
+	// ReleaseStaleUniqueLocks frees the locks pending since before acquiredBefore and returns their digests
+	ReleaseStaleUniqueLocks(ctx context.Context, acquiredBefore time.Time) ([]string, error)
+
+	// Batches. BatchJobDone returns the events the outcome of the job made due which haven't fired yet,
+	// and FireBatchEvent marks an event fired at now while writing the messages of its callbacks, unless
+	// it already fired.
+	OpenBatch(ctx context.Context, bid string, fields map[string]interface{}, token string, ttl time.Duration) error
+	AddBatchJob(ctx context.Context, bid string, jid string, ttl time.Duration) error
+	BatchJobDone(ctx context.Context, bid string, jid string, succeeded bool) ([]string, error)
+	FireBatchEvent(ctx context.Context, bid string, event string, now float64, messages []QueuedMessage) (bool, error)
+	GetBatch(ctx context.Context, bid string) (*Batch, error)
+
+	// Notes on jobs
+	AddJobNote(ctx context.Context, jid string, note string, ttl time.Duration) error
+	GetJobNotes(ctx context.Context, jids []string) (map[string][]string, error)
+	RemoveJobNotes(ctx context.Context, jid string) error
+
+	// Cron jobs
+	ClaimCronTick(ctx context.Context, name string, tick int64) (previous int64, claimed bool, err error)
+	GetCronTicks(ctx context.Context) (map[string]int64, error)
+
+	// Leadership leases. AcquireLease takes the lease or extends it when holder already has it.
+	AcquireLease(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name string, holder string) error
+
+	// Distributed semaphores, whose slots are held until released or ttl after their last renewal
+	AcquireSemaphore(ctx context.Context, name string, holder string, limit int, ttl time.Duration) (bool, error)
+	RenewSemaphore(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	ReleaseSemaphore(ctx context.Context, name string, holder string) error
+
+	// Codec chains of the args of the jobs of some queues. EncodeArgs returns the args of the other queues
+	// as they are. DecodeArgs decodes args with the named codecs of any chain, failing with
+	// ErrInvalidSignature for unknown codecs and for args of a queue which weren't encoded by the
+	// authenticating codecs of its chain.
+	EncodeArgs(queue string, args []byte) (encoded []byte, codecs []string, err error)
+	DecodeArgs(queue string, codecs []string, args []byte) ([]byte, error)
+
+	// Rolling-window rate limiters, shared with Sidekiq Enterprise's window limiters. AcquireWindowLimit
+	// returns zero once it counted an acquisition, or how long until one of the count acquisitions of the
+	// last interval leaves the window.
+	AcquireWindowLimit(ctx context.Context, name string, count int, interval time.Duration) (time.Duration, error)
+
+	// Job classes disabled across the fleet
+	DisableClass(ctx context.Context, class string) error
+	EnableClass(ctx context.Context, class string) error
+	GetDisabledClasses(ctx context.Context) ([]string, error)
+
+	// Backpressure states published by managers for producers, by queue
+	SetBackpressure(ctx context.Context, queue string, state string) error
+	GetBackpressure(ctx context.Context) (map[string]string, error)
+
+	// Tenant quotas
+	IncrementTenantJobs(ctx context.Context, tenant string, window int64, ttl time.Duration) (int64, error)
+
+	// Checkpoints of long jobs
+	SetCheckpoint(ctx context.Context, jid string, checkpoint string, ttl time.Duration) error
+	GetCheckpoint(ctx context.Context, jid string) (string, error)
+	DeleteCheckpoint(ctx context.Context, jid string) error
+
+	// Completion markers
+	MarkJobCompleted(ctx context.Context, jid string, ttl time.Duration) error
+	IsJobCompleted(ctx context.Context, jid string) (bool, error)
+
 	// Heartbeat
 	GetAllHeartbeats(ctx context.Context) ([]*Heartbeat, error)
 	SendHeartbeat(ctx context.Context, heartbeat *Heartbeat) error
 	RemoveHeartbeat(ctx context.Context, heartbeatID string) error
+	// PopSignal returns the oldest signal sent to a manager, or NoMessage
+	PopSignal(ctx context.Context, heartbeatID string) (string, error)
+
+	// Job cancellations, delivered to every subscribed manager until ctx is done
+	PublishCancellation(ctx context.Context, jid string) error
+	SubscribeCancellations(ctx context.Context) (<-chan string, error)
 
 	// Retries
 	GetAllRetries(ctx context.Context) (*Retries, error)
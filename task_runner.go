@@ -9,12 +9,16 @@ import (
 )
 
 type taskRunner struct {
-	stop       chan bool
-	handler    JobFunc
-	currentMsg *Msg
-	lock       sync.RWMutex
-	logger     *log.Logger
-	tid        string
+	stop         chan bool
+	handler      JobFunc
+	currentMsg   *Msg
+	currentStart time.Time
+	lock         sync.RWMutex
+	logger       *log.Logger
+	tid          string
+
+	// retired runners were stopped by a lower concurrency, guarded by the worker's runners lock
+	retired bool
 }
 
 func (w *taskRunner) quit() {
@@ -43,6 +47,7 @@ func (w *taskRunner) work(messages <-chan *Msg, done chan<- *Msg, ready chan<- b
 
 			w.lock.Lock()
 			w.currentMsg = msg
+			w.currentStart = time.Now()
 			w.lock.Unlock()
 
 			if err := w.process(msg); err != nil {
@@ -83,6 +88,13 @@ func (w *taskRunner) inProgressMessage() *Msg {
 	return w.currentMsg
 }
 
+// inFlight returns the message being processed, if any, and when its processing started
+func (w *taskRunner) inFlight() (*Msg, time.Time) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.currentMsg, w.currentStart
+}
+
 func newTaskRunner(logger *log.Logger, handler JobFunc) *taskRunner {
 	return &taskRunner{
 		handler: handler,
@@ -0,0 +1,16 @@
+package workers
+
+// EnqueueTyped enqueues new work for immediate processing, serializing args with EncodeSidekiqArgs
+// so that the consumer can decode the same struct with DecodeSidekiqArgs
+func EnqueueTyped[T any](p *Producer, queue, class string, args T) (string, error) {
+	return EnqueueTypedWithOptions(p, queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+}
+
+// EnqueueTypedWithOptions enqueues new work built from a typed args struct with the given options
+func EnqueueTypedWithOptions[T any](p *Producer, queue, class string, args T, opts EnqueueOptions) (string, error) {
+	positional, err := EncodeSidekiqArgs(args)
+	if err != nil {
+		return "", err
+	}
+	return p.EnqueueWithOptions(queue, class, positional, opts)
+}
@@ -0,0 +1,48 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedEnqueueArgs struct {
+	Name    string
+	skipped string
+	Count   int
+	Tags    []string
+}
+
+func TestEnqueueTyped(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	sent := typedEnqueueArgs{Name: "a", skipped: "x", Count: 3, Tags: []string{"t"}}
+	jid, err := EnqueueTyped(p, "typed", "Add", sent)
+	assert.NoError(t, err)
+
+	bytes, _ := rc.RPop(ctx, "prod:queue:typed").Result()
+	msg, err := NewMsg(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, jid, msg.Jid())
+	assert.Equal(t, `["a",3,["t"]]`, msg.Args().ToJson())
+
+	// the consumer decodes the same struct definition
+	var received typedEnqueueArgs
+	assert.NoError(t, DecodeSidekiqArgs(msg.Args().Json, &received))
+	assert.Equal(t, typedEnqueueArgs{Name: "a", Count: 3, Tags: []string{"t"}}, received)
+
+	_, err = EnqueueTyped(p, "typed", "Add", &sent)
+	assert.NoError(t, err)
+
+	_, err = EnqueueTyped(p, "typed", "Add", []int{1})
+	assert.Error(t, err)
+	_, err = EnqueueTyped[*typedEnqueueArgs](p, "typed", "Add", nil)
+	assert.Error(t, err)
+}
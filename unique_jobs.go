@@ -0,0 +1,183 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+// ErrDuplicateJob is returned when an enqueue with UniqueFor is skipped because an identical job holds the lock
+var ErrDuplicateJob = errors.New("an identical job is already enqueued")
+
+// Lock types of sidekiq-unique-jobs whose locks workers release
+const (
+	uniqueUntilExecuted  = "until_executed"
+	uniqueUntilExecuting = "until_executing"
+)
+
+// dedupeDigest identifies bulk enqueued jobs by class and args, whatever their queue
+func dedupeDigest(job *EnqueueData) (string, error) {
+	bytes, err := json.Marshal([]interface{}{job.Class, job.Args})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return "dedupe:" + hex.EncodeToString(sum[:]), nil
+}
+
+// batchDedupe remembers the digests already seen in a bulk enqueue
+type batchDedupe map[string]bool
+
+func newBatchDedupe() batchDedupe {
+	return batchDedupe{}
+}
+
+// acquireJobLocks returns the digests locked for a job, or ErrDuplicateJob if it duplicates
+// an earlier entry of the same bulk enqueue or a recently enqueued job
+func (p *Producer) acquireJobLocks(ctx context.Context, job *EnqueueData, batch batchDedupe) ([]string, error) {
+	var locks []string
+
+	if job.DedupeFor > 0 {
+		digest, err := dedupeDigest(job)
+		if err != nil {
+			return nil, err
+		}
+		if batch[digest] {
+			return nil, ErrDuplicateJob
+		}
+		batch[digest] = true
+
+		acquired, err := p.opts.store.AcquireUniqueLock(ctx, digest, job.Jid, job.DedupeFor)
+		if err != nil {
+			return nil, err
+		}
+		if !acquired {
+			return nil, ErrDuplicateJob
+		}
+		locks = append(locks, digest)
+	}
+
+	lock, err := p.acquireUniqueLock(ctx, job)
+	if err != nil {
+		p.releaseUniqueLocks(ctx, locks)
+		return nil, err
+	}
+	if lock != "" {
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// uniqueDigest identifies jobs by class, queue and args as sidekiq-unique-jobs does: the MD5 of the JSON of
+// their class, queue and lock_args, which go-workers2 sets to the args, behind the uniquejobs lock prefix
+func uniqueDigest(job *EnqueueData) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Ruby's JSON.generate leaves <, > and & as they are
+	enc.SetEscapeHTML(false)
+	digestable := struct {
+		Class    string      `json:"class"`
+		Queue    string      `json:"queue"`
+		LockArgs interface{} `json:"lock_args"`
+	}{job.Class, job.Queue, job.Args}
+	if err := enc.Encode(digestable); err != nil {
+		return "", err
+	}
+	sum := md5.Sum(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return storage.UniqueJobsPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+// uniqueJobFields are the payload fields sidekiq-unique-jobs reads to release the lock of a job it runs
+func uniqueJobFields(job *EnqueueData) (map[string]interface{}, error) {
+	digest, err := uniqueDigest(job)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"lock":        uniqueUntilExecuted,
+		"lock_digest": digest,
+		"lock_args":   job.Args,
+		"lock_ttl":    int64(job.UniqueFor.Seconds()),
+	}, nil
+}
+
+// acquireUniqueLock returns the digest locked for the job, or an empty digest if the job isn't unique
+func (p *Producer) acquireUniqueLock(ctx context.Context, job *EnqueueData) (string, error) {
+	if job.UniqueFor <= 0 {
+		return "", nil
+	}
+
+	digest, err := uniqueDigest(job)
+	if err != nil {
+		return "", err
+	}
+	acquired, err := p.opts.store.AcquireUniqueJobLock(ctx, digest, job.Jid, job.UniqueFor)
+	if err != nil {
+		return "", err
+	}
+	if !acquired {
+		return "", ErrDuplicateJob
+	}
+	return digest, nil
+}
+
+// releaseUniqueLock frees the lock of a job that couldn't be pushed after all
+func (p *Producer) releaseUniqueLock(ctx context.Context, digest string) {
+	if digest == "" {
+		return
+	}
+	if err := p.opts.store.ReleaseUniqueLock(ctx, digest); err != nil && p.opts.Logger != nil {
+		p.opts.Logger.Println("couldn't release unique lock", digest, ":", err)
+	}
+}
+
+func (p *Producer) releaseUniqueLocks(ctx context.Context, digests []string) {
+	for _, digest := range digests {
+		p.releaseUniqueLock(ctx, digest)
+	}
+}
+
+// confirmUniqueLocks tells the stale lock janitor the locks of written jobs are legitimately held
+func (p *Producer) confirmUniqueLocks(ctx context.Context, digests []string) {
+	if len(digests) == 0 {
+		return
+	}
+	if err := p.opts.store.ConfirmUniqueLocks(ctx, digests); err != nil && p.opts.Logger != nil {
+		p.opts.Logger.Println("couldn't confirm unique locks", digests, ":", err)
+	}
+}
+
+// uniqueJobFunc releases the sidekiq-unique-jobs lock of a job, whoever enqueued it: until_executed locks
+// once the job succeeded, and until_executing ones before it runs. Failed jobs keep their lock while they
+// are retried, until it expires.
+func uniqueJobFunc(m *Manager, next JobFunc) JobFunc {
+	return func(message *Msg) error {
+		lock, _ := message.Get("lock").String()
+		digest, _ := message.Get("lock_digest").String()
+		if digest == "" || (lock != uniqueUntilExecuted && lock != uniqueUntilExecuting) {
+			return next(message)
+		}
+		release := func() {
+			// the context of the job is done once it returns
+			if err := m.opts.store.ReleaseUniqueJobLock(context.Background(), digest, message.Jid()); err != nil {
+				m.logger.Println("ERR: couldn't release unique lock", digest, "of job", message.Jid(), ":", err)
+			}
+		}
+
+		if lock == uniqueUntilExecuting {
+			release()
+			return next(message)
+		}
+		err := next(message)
+		if err == nil {
+			release()
+		}
+		return err
+	}
+}
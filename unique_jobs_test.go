@@ -0,0 +1,206 @@
+package workers
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducer_EnqueueUnique(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+	unique := EnqueueOptions{UniqueFor: 10 * time.Minute}
+
+	jid, err := p.EnqueueWithOptions("unique", "Add", []int{1, 2}, unique)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jid)
+
+	_, err = p.EnqueueWithOptions("unique", "Add", []int{1, 2}, unique)
+	assert.Equal(t, ErrDuplicateJob, err)
+
+	// other args, classes and non-unique enqueues aren't affected
+	_, err = p.EnqueueWithOptions("unique", "Add", []int{2, 3}, unique)
+	assert.NoError(t, err)
+	_, err = p.EnqueueWithOptions("unique", "Sub", []int{1, 2}, unique)
+	assert.NoError(t, err)
+	_, err = p.Enqueue("unique", "Add", []int{1, 2})
+	assert.NoError(t, err)
+
+	nb, _ := rc.LLen(ctx, "prod:queue:unique").Result()
+	assert.Equal(t, int64(4), nb)
+
+	// the digest and keys of sidekiq-unique-jobs
+	sum := md5.Sum([]byte(`{"class":"Add","queue":"unique","lock_args":[1,2]}`))
+	digest := "uniquejobs:" + hex.EncodeToString(sum[:])
+	assert.True(t, rc.HExists(ctx, "prod:"+digest+":LOCKED", jid).Val())
+	ttl, _ := rc.TTL(ctx, "prod:"+digest+":LOCKED").Result()
+	assert.True(t, ttl > 9*time.Minute)
+	assert.NotZero(t, rc.ZScore(ctx, "prod:uniquejobs:digests", digest).Val())
+
+	payload, _ := rc.LIndex(ctx, "prod:queue:unique", -1).Result()
+	msg, err := NewMsg(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "until_executed", msg.Get("lock").MustString())
+	assert.Equal(t, digest, msg.Get("lock_digest").MustString())
+	assert.Equal(t, []interface{}{json.Number("1"), json.Number("2")}, msg.Get("lock_args").MustArray())
+	assert.EqualValues(t, 600, msg.Get("lock_ttl").MustInt())
+}
+
+func TestUniqueJobLockReleasedOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+	p := mgr.Producer()
+	unique := EnqueueOptions{UniqueFor: 10 * time.Minute}
+
+	jid, err := p.EnqueueWithOptions("unique", "Add", []int{1, 2}, unique)
+	assert.NoError(t, err)
+	digest, _ := uniqueDigest(&EnqueueData{Queue: "unique", Class: "Add", Args: []int{1, 2}})
+	payload, _ := rc.LPop(ctx, "prod:queue:unique").Result()
+
+	failing := true
+	job := mgr.buildJob("unique", func(m *Msg) error {
+		if failing {
+			return errors.New("failed")
+		}
+		return nil
+	}, []MiddlewareFunc{NopMiddleware})
+
+	// a failed job keeps its lock for its retries
+	msg, _ := NewMsg(payload)
+	assert.Error(t, job(msg))
+	_, err = p.EnqueueWithOptions("unique", "Add", []int{1, 2}, unique)
+	assert.Equal(t, ErrDuplicateJob, err)
+
+	failing = false
+	msg, _ = NewMsg(payload)
+	assert.NoError(t, job(msg))
+	assert.Zero(t, rc.Exists(ctx, "prod:"+digest+":LOCKED").Val())
+	assert.Zero(t, rc.ZScore(ctx, "prod:uniquejobs:digests", digest).Val())
+	next, err := p.EnqueueWithOptions("unique", "Add", []int{1, 2}, unique)
+	assert.NoError(t, err)
+	assert.NotEqual(t, jid, next)
+
+	// locks taken by Ruby are released the same way, but only by their holder
+	rc.HSet(ctx, "prod:uniquejobs:ruby:LOCKED", "rubyjid", "1")
+	msg, _ = NewMsg(`{"jid":"otherjid","class":"Add","lock":"until_executed","lock_digest":"uniquejobs:ruby"}`)
+	assert.NoError(t, job(msg))
+	assert.True(t, rc.HExists(ctx, "prod:uniquejobs:ruby:LOCKED", "rubyjid").Val())
+	msg, _ = NewMsg(`{"jid":"rubyjid","class":"Add","lock":"until_executed","lock_digest":"uniquejobs:ruby"}`)
+	assert.NoError(t, job(msg))
+	assert.Zero(t, rc.Exists(ctx, "prod:uniquejobs:ruby:LOCKED").Val())
+}
+
+func TestProducer_EnqueueBulkUnique(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+
+	_, err = p.EnqueueWithOptions("unique", "Add", []interface{}{1}, EnqueueOptions{UniqueFor: time.Minute})
+	assert.NoError(t, err)
+
+	jids, err := p.EnqueueBulkWithContext(ctx, "unique", "Add", [][]interface{}{{1}, {2}, {2}}, EnqueueOptions{UniqueFor: time.Minute})
+	assert.NoError(t, err)
+	assert.Len(t, jids, 3)
+	assert.Empty(t, jids[0])
+	assert.NotEmpty(t, jids[1])
+	assert.Empty(t, jids[2])
+
+	nb, _ := rc.LLen(ctx, "prod:queue:unique").Result()
+	assert.Equal(t, int64(2), nb)
+}
+
+func TestProducer_EnqueueBulkDedupe(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	p := newProducer(opts)
+	dedupe := EnqueueOptions{DedupeFor: time.Minute}
+
+	jids, err := p.EnqueueBulkWithContext(ctx, "events", "Fanout", [][]interface{}{{1}, {2}, {1}, {3}, {2}}, dedupe)
+	assert.NoError(t, err)
+	assert.Len(t, jids, 5)
+	assert.NotEmpty(t, jids[0])
+	assert.NotEmpty(t, jids[1])
+	assert.Empty(t, jids[2])
+	assert.NotEmpty(t, jids[3])
+	assert.Empty(t, jids[4])
+
+	// the next burst is checked against the digests of the previous one, whatever the queue
+	jids, err = p.EnqueueBulkWithContext(ctx, "other", "Fanout", [][]interface{}{{1}, {4}}, dedupe)
+	assert.NoError(t, err)
+	assert.Empty(t, jids[0])
+	assert.NotEmpty(t, jids[1])
+
+	// without DedupeFor nothing is dropped
+	jids, err = p.EnqueueBulk("events", "Fanout", [][]interface{}{{1}, {1}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jids[0])
+	assert.NotEmpty(t, jids[1])
+
+	nb, _ := rc.LLen(ctx, "prod:queue:events").Result()
+	assert.Equal(t, int64(5), nb)
+	nb, _ = rc.LLen(ctx, "prod:queue:other").Result()
+	assert.Equal(t, int64(1), nb)
+}
+
+func TestProducer_EnqueueDedupWindow(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+	opts.DedupWindow = 30 * time.Second
+	p := newProducer(opts)
+
+	jid, err := p.Enqueue("webhooks", "Deliver", []string{"evt_1"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jid)
+
+	// duplicates are skipped whatever their queue, until the window is over
+	_, err = p.Enqueue("webhooks", "Deliver", []string{"evt_1"})
+	assert.Equal(t, ErrDuplicateJob, err)
+	_, err = p.Enqueue("other", "Deliver", []string{"evt_1"})
+	assert.Equal(t, ErrDuplicateJob, err)
+	jids, err := p.EnqueueBulk("webhooks", "Deliver", [][]interface{}{{"evt_1"}, {"evt_2"}})
+	assert.NoError(t, err)
+	assert.Empty(t, jids[0])
+	assert.NotEmpty(t, jids[1])
+
+	_, err = p.Enqueue("webhooks", "Deliver", []string{"evt_3"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, rc.LLen(ctx, "prod:queue:webhooks").Val())
+
+	digest, err := dedupeDigest(&EnqueueData{Class: "Deliver", Args: []string{"evt_1"}})
+	assert.NoError(t, err)
+	ttl := rc.TTL(ctx, "prod:unique:"+digest).Val()
+	assert.True(t, ttl > 0 && ttl <= 30*time.Second)
+
+	// a shorter per-enqueue window overrides the producer's
+	rc.Del(ctx, "prod:unique:"+digest)
+	_, err = p.EnqueueWithOptions("webhooks", "Deliver", []string{"evt_1"}, EnqueueOptions{DedupeFor: time.Second})
+	assert.NoError(t, err)
+	ttl = rc.TTL(ctx, "prod:unique:"+digest).Val()
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+}
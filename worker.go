@@ -3,19 +3,40 @@ package workers
 import (
 	"log"
 	"sync"
+	"time"
 )
 
 type worker struct {
 	queue           string
 	inProgressQueue string
-	handler         JobFunc
-	concurrency     int
-	runners         []*taskRunner
-	runnersLock     sync.Mutex
-	stop            chan bool
-	running         bool
-	fetcher         Fetcher
-	logger          *log.Logger
+
+	// queues fetched by a worker added by AddWorkerForQueues, whose queue is their pool name, and
+	// their in-progress queues
+	queues           []weightedQueue
+	strictQueues     bool
+	inProgressQueues map[string]string
+
+	handler     JobFunc
+	concurrency int
+	runners     []*taskRunner
+	runnersLock sync.Mutex
+	stop        chan bool
+	running     bool
+	fetcher     Fetcher
+	logger      *log.Logger
+
+	// exited is closed once the current run is over; removed workers never start again
+	exited  chan struct{}
+	removed bool
+
+	shutdownTimeout time.Duration
+	warmUp          time.Duration
+	drain           *workerDrain
+
+	// runnersWG and done are shared by the runners started while running, including the ones added by
+	// setConcurrency
+	runnersWG *sync.WaitGroup
+	done      chan *Msg
 }
 
 func newWorker(logger *log.Logger, queue string, concurrency int, handler JobFunc) *worker {
@@ -26,7 +47,7 @@ func newWorker(logger *log.Logger, queue string, concurrency int, handler JobFun
 		queue:       queue,
 		handler:     handler,
 		concurrency: concurrency,
-		stop:        make(chan bool),
+		stop:        make(chan bool, 1),
 		logger:      logger,
 	}
 	return w
@@ -34,33 +55,44 @@ func newWorker(logger *log.Logger, queue string, concurrency int, handler JobFun
 
 func (w *worker) start(fetcher Fetcher) {
 	w.runnersLock.Lock()
-	if w.running {
+	if w.running || w.removed {
 		w.runnersLock.Unlock()
 		return
 	}
 	w.running = true
+	exited := make(chan struct{})
+	w.exited = exited
 	w.fetcher = fetcher
 	w.inProgressQueue = fetcher.InProgressQueue()
+	if multi, ok := fetcher.(*multiQueueFetcher); ok {
+		w.inProgressQueues = multi.inProgressQueues()
+	} else {
+		w.inProgressQueues = map[string]string{w.queue: w.inProgressQueue}
+	}
+	w.drain = nil
+	// discard a stop request left over from a previous run
+	select {
+	case <-w.stop:
+	default:
+	}
 	defer func() {
 		w.runnersLock.Lock()
 		w.running = false
 		w.runnersLock.Unlock()
+		close(exited)
 	}()
 
-	var wg sync.WaitGroup
-	wg.Add(w.concurrency)
-
 	go fetcher.Fetch()
 
 	done := make(chan *Msg)
+	w.done = done
+	wg := &sync.WaitGroup{}
+	w.runnersWG = wg
 	w.runners = make([]*taskRunner, w.concurrency)
 	for i := 0; i < w.concurrency; i++ {
 		r := newTaskRunner(w.logger, w.handler)
 		w.runners[i] = r
-		go func() {
-			r.work(fetcher.Messages(), done, fetcher.Ready())
-			wg.Done()
-		}()
+		w.startRunner(r, w.startDelay(i))
 	}
 	exit := make(chan bool)
 	go func() {
@@ -71,35 +103,263 @@ func (w *worker) start(fetcher Fetcher) {
 	// Now that we're all set up, unlock so that stats can check.
 	w.runnersLock.Unlock()
 
+	var deadline <-chan time.Time
 	for {
 		select {
 		case msg := <-done:
 			if msg.ack {
 				fetcher.Acknowledge(msg)
 			}
+			w.recordDrainCompletion(msg)
 		case <-w.stop:
 			if !fetcher.Closed() {
+				w.beginDrain()
+				if w.shutdownTimeout > 0 {
+					deadline = time.After(w.shutdownTimeout)
+				}
+
 				fetcher.Close()
 
 				// we need to relock the runners so we can shut this down
 				w.runnersLock.Lock()
 				for _, r := range w.runners {
-					r.quit()
+					if !r.retired {
+						r.quit()
+					}
 				}
 				w.runnersLock.Unlock()
 			}
+		case <-deadline:
+			w.abandonInProgress()
+			// keep acknowledging jobs that finish after the deadline so their
+			// runners aren't left blocked on the done channel
+			go func() {
+				for {
+					select {
+					case msg := <-done:
+						if msg.ack {
+							fetcher.Acknowledge(msg)
+						}
+					case <-exit:
+						return
+					}
+				}
+			}()
+			return
 		case <-exit:
 			return
 		}
 	}
 }
 
+// newFetcher creates the fetcher of the worker's queues, reporting the Redis errors it carries on after to onError
+// and buffering the acknowledgements Redis refuses in writes
+func (w *worker) newFetcher(opts Options, isActive bool, onError fetchErrorFunc, writes *writeBuffer) Fetcher {
+	if len(w.queues) > 0 {
+		fetcher := newMultiQueueFetcher(w.queues, w.strictQueues, opts, isActive)
+		fetcher.onError = onError
+		fetcher.writes = writes
+		return fetcher
+	}
+	fetcher := newSimpleFetcher(w.queue, opts, isActive)
+	fetcher.onError = onError
+	fetcher.writes = writes
+	return fetcher
+}
+
+// sourceQueues returns the queues the worker fetches from
+func (w *worker) sourceQueues() []string {
+	if len(w.queues) == 0 {
+		return []string{w.queue}
+	}
+	res := make([]string, len(w.queues))
+	for i, q := range w.queues {
+		res[i] = q.name
+	}
+	return res
+}
+
+// queueInProgressQueue returns the in-progress queue of one of the worker's queues
+func (w *worker) queueInProgressQueue(queue string) string {
+	if len(w.queues) == 0 {
+		return w.inProgressQueue
+	}
+	return w.inProgressQueues[queue]
+}
+
+// messageQueue returns the queue msg was fetched from
+func (w *worker) messageQueue(msg *Msg) string {
+	if msg.fetchedFrom != "" {
+		return msg.fetchedFrom
+	}
+	return w.queue
+}
+
+// startRunner runs r after delay, until it's stopped. It must be called with the runners lock held.
+func (w *worker) startRunner(r *taskRunner, delay time.Duration) {
+	wg, fetcher, done := w.runnersWG, w.fetcher, w.done
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer w.removeRetiredRunner(r)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.stop:
+				return
+			}
+		}
+		r.work(fetcher.Messages(), done, fetcher.Ready())
+	}()
+}
+
+// setConcurrency changes the number of runners. While the worker is running, runners are started
+// right away, or stopped once they finish their current job.
+func (w *worker) setConcurrency(concurrency int) {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	w.setConcurrencyLocked(concurrency)
+}
+
+// swapConcurrency sets the concurrency unless it's no longer old, such as once SetConcurrency changed it,
+// and reports whether it did
+func (w *worker) swapConcurrency(old, concurrency int) bool {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	if w.concurrency != old {
+		return false
+	}
+	w.setConcurrencyLocked(concurrency)
+	return true
+}
+
+func (w *worker) setConcurrencyLocked(concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	w.concurrency = concurrency
+	if !w.running || w.drain != nil {
+		// applied when the worker starts again
+		return
+	}
+
+	var active []*taskRunner
+	for _, r := range w.runners {
+		if !r.retired {
+			active = append(active, r)
+		}
+	}
+	for i := len(active); i < concurrency; i++ {
+		r := newTaskRunner(w.logger, w.handler)
+		w.runners = append(w.runners, r)
+		w.startRunner(r, 0)
+	}
+	for i := len(active) - 1; i >= concurrency; i-- {
+		active[i].retired = true
+		active[i].quit()
+	}
+}
+
+// removeRetiredRunner forgets a runner stopped by setConcurrency once its last job is done
+func (w *worker) removeRetiredRunner(r *taskRunner) {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	if !r.retired {
+		return
+	}
+	for i, runner := range w.runners {
+		if runner == r {
+			w.runners = append(w.runners[:i], w.runners[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *worker) getConcurrency() int {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	return w.concurrency
+}
+
+// startDelay spreads the start of the runners evenly over the warm-up period, the first one starting right away
+func (w *worker) startDelay(runner int) time.Duration {
+	if w.warmUp <= 0 {
+		return 0
+	}
+	return w.warmUp * time.Duration(runner) / time.Duration(w.concurrency)
+}
+
 func (w *worker) quit() {
 	w.runnersLock.Lock()
 	defer w.runnersLock.Unlock()
 	if w.running {
-		w.stop <- true
+		select {
+		case w.stop <- true:
+		default:
+			// a stop request is already pending
+		}
+	}
+}
+
+// remove stops the worker for good, waiting for its current run to be over
+func (w *worker) remove() {
+	w.runnersLock.Lock()
+	w.removed = true
+	running, exited := w.running, w.exited
+	w.runnersLock.Unlock()
+	if !running {
+		return
 	}
+	w.quit()
+	<-exited
+}
+
+func (w *worker) beginDrain() {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	w.drain = &workerDrain{}
+}
+
+func (w *worker) recordDrainCompletion(msg *Msg) {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	if w.drain != nil {
+		w.drain.completed = append(w.drain.completed, newShutdownJob(w.messageQueue(msg), msg))
+	}
+}
+
+func (w *worker) abandonInProgress() {
+	msgs := w.inProgressMessages()
+
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	for _, msg := range msgs {
+		w.drain.abandoned = append(w.drain.abandoned, newShutdownJob(w.messageQueue(msg), msg))
+	}
+	w.logger.Println("WARN:", w.queue, "shutdown timeout reached with", len(msgs), "jobs still running")
+}
+
+// recordRequeued marks the abandoned jobs of the given messages as pushed back onto their queue
+func (w *worker) recordRequeued(messages []string) {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	if w.drain == nil {
+		return
+	}
+	if w.drain.requeued == nil {
+		w.drain.requeued = map[string]bool{}
+	}
+	for _, message := range messages {
+		if msg, err := NewMsg(message); err == nil {
+			w.drain.requeued[msg.Jid()] = true
+		}
+	}
+}
+
+func (w *worker) lastDrain() *workerDrain {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	return w.drain
 }
 
 func (w *worker) inProgressMessages() []*Msg {
@@ -113,3 +373,23 @@ func (w *worker) inProgressMessages() []*Msg {
 	}
 	return res
 }
+
+func (w *worker) inFlightJobs() []InFlightJob {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	var res []InFlightJob
+	for slot, r := range w.runners {
+		if m, startedAt := r.inFlight(); m != nil {
+			res = append(res, InFlightJob{
+				Queue:     w.messageQueue(m),
+				Class:     m.Class(),
+				Jid:       m.Jid(),
+				StartedAt: startedAt,
+				Slot:      slot,
+				Tid:       r.tid,
+				Message:   m,
+			})
+		}
+	}
+	return res
+}
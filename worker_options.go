@@ -0,0 +1,79 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrJobTimeout is returned for jobs which failed after running past their worker's JobTimeout
+var ErrJobTimeout = errors.New("job timed out")
+
+// WorkerOptions configures the policy of the worker of a queue added by AddWorkerWithOptions
+type WorkerOptions struct {
+	Concurrency int
+
+	// Optional number of retries of the failed jobs which don't set their own, defaults to DefaultRetryMax
+	MaxRetries int
+	// Optional delay before the retry of a job which failed count times, defaults to Sidekiq's backoff
+	RetryBackoff func(count int) time.Duration
+	// Optional queue retries are pushed to, for the jobs which don't set their own retry_queue
+	RetryQueue string
+
+	// Optional time after which Msg.Context is cancelled. Jobs can't be interrupted: handlers should
+	// return once the context is done, and their error is then reported as ErrJobTimeout.
+	JobTimeout time.Duration
+
+	// Optional cap on the jobs of the queue running at once across every manager, run ahead of Middlewares
+	GlobalConcurrency *GlobalConcurrencyOptions
+
+	// Optional middlewares, defaults to DefaultMiddlewares
+	Middlewares []MiddlewareFunc
+}
+
+// AddWorkerWithOptions adds a new job processing worker with its own retry and timeout policy
+func (m *Manager) AddWorkerWithOptions(queue string, opts WorkerOptions, job JobFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	mids := opts.Middlewares
+	if opts.GlobalConcurrency != nil {
+		if len(mids) == 0 {
+			mids = DefaultMiddlewares()
+		}
+		mids = NewMiddlewares(mids...).Prepend(GlobalConcurrencyMiddleware(*opts.GlobalConcurrency))
+	}
+	job = m.buildJob(queue, timeoutJobFunc(opts.JobTimeout, job), mids)
+	w := newWorker(m.logger, queue, opts.Concurrency, func(message *Msg) error {
+		message.workerOptions = &opts
+		return job(message)
+	})
+	m.addWorkerLocked(w)
+}
+
+// Context returns the context of the job, cancelled once it runs past its worker's JobTimeout
+func (m *Msg) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// timeoutJobFunc cancels the context of jobs running past timeout
+func timeoutJobFunc(timeout time.Duration, next JobFunc) JobFunc {
+	if timeout <= 0 {
+		return next
+	}
+	return func(message *Msg) error {
+		ctx, cancel := context.WithTimeout(message.Context(), timeout)
+		defer cancel()
+		message.ctx = ctx
+
+		err := next(message)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w after %v: %v", ErrJobTimeout, timeout, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,68 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddWorkerWithOptionsRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	mgr.AddWorkerWithOptions("myqueue", WorkerOptions{
+		Concurrency:  3,
+		MaxRetries:   1,
+		RetryBackoff: func(count int) time.Duration { return time.Hour },
+		RetryQueue:   "slow",
+		Middlewares:  []MiddlewareFunc{RetryMiddleware},
+	}, func(m *Msg) error {
+		return errors.New("ERROR")
+	})
+	w := mgr.workers[0]
+	assert.Equal(t, 3, w.getConcurrency())
+
+	message, _ := NewMsg(`{"jid":"2","retry":true}`)
+	assert.NoError(t, w.handler(message))
+
+	retries, err := opts.client.ZRangeWithScores(ctx, retryQueue(opts.Namespace), 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Len(t, retries, 1)
+	assert.InDelta(t, timeToSecondsWithNanoPrecision(time.Now().Add(time.Hour)), retries[0].Score, 5)
+	retried, _ := NewMsg(retries[0].Member.(string))
+	queue, _ := retried.Get("queue").String()
+	assert.Equal(t, "slow", queue)
+
+	// the retries are used up
+	opts.client.Del(ctx, retryQueue(opts.Namespace))
+	exhausted, _ := NewMsg(`{"jid":"2","retry":true,"retry_count":1}`)
+	assert.Error(t, w.handler(exhausted))
+	count, err := opts.client.ZCard(ctx, retryQueue(opts.Namespace)).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestAddWorkerWithOptionsJobTimeout(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	mgr.AddWorkerWithOptions("myqueue", WorkerOptions{
+		JobTimeout:  10 * time.Millisecond,
+		Middlewares: []MiddlewareFunc{NopMiddleware},
+	}, func(m *Msg) error {
+		<-m.Context().Done()
+		return m.Context().Err()
+	})
+
+	message, _ := NewMsg(`{"jid":"2"}`)
+	err = mgr.workers[0].handler(message)
+	assert.True(t, errors.Is(err, ErrJobTimeout))
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -216,3 +217,162 @@ func TestWorkerProcessesAndAcksMessages(t *testing.T) {
 	w.quit()
 	wg.Wait()
 }
+
+func TestWorkerWarmUp(t *testing.T) {
+	w := newWorker(nil, "q", 4, nil)
+	assert.Equal(t, time.Duration(0), w.startDelay(3))
+
+	w.warmUp = 400 * time.Millisecond
+	assert.Equal(t, time.Duration(0), w.startDelay(0))
+	assert.Equal(t, 100*time.Millisecond, w.startDelay(1))
+	assert.Equal(t, 300*time.Millisecond, w.startDelay(3))
+}
+
+func TestWorkerRampsUpConcurrency(t *testing.T) {
+	testLogger := log.New(os.Stdout, "test-go-workers2: ", log.Ldate|log.Lmicroseconds)
+	readyCh := make(chan bool)
+	msgCh := make(chan *Msg)
+	closeCh := make(chan bool)
+
+	df := dummyFetcher{
+		queue:           func() string { return "q" },
+		inProgressQueue: func() string { return "inprog-q" },
+		fetch:           func() { <-closeCh },
+		acknowledge:     func(m *Msg) {},
+		ready:           func() chan bool { return readyCh },
+		messages:        func() chan *Msg { return msgCh },
+		close:           func() { close(closeCh) },
+		closed: func() bool {
+			select {
+			case <-closeCh:
+				return true
+			default:
+				return false
+			}
+		},
+	}
+
+	release := make(chan bool)
+	w := newWorker(testLogger, "q", 2, func(m *Msg) error {
+		<-release
+		return nil
+	})
+	w.warmUp = 400 * time.Millisecond
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		w.start(&df)
+		wg.Done()
+	}()
+
+	send := func(timeout time.Duration) bool {
+		msg, _ := NewMsg(`{"jid":"1"}`)
+		select {
+		case msgCh <- msg:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+
+	// only the first runner takes work right away
+	assert.True(t, send(100*time.Millisecond))
+	assert.False(t, send(50*time.Millisecond))
+	// the second one joins halfway through the warm-up
+	assert.True(t, send(time.Second))
+
+	close(release)
+	w.quit()
+	wg.Wait()
+}
+
+func TestWorkerSetConcurrency(t *testing.T) {
+	testLogger := log.New(os.Stdout, "test-go-workers2: ", log.Ldate|log.Lmicroseconds)
+	readyCh := make(chan bool)
+	msgCh := make(chan *Msg)
+	ackCh := make(chan *Msg, 10)
+	closeCh := make(chan bool)
+
+	df := dummyFetcher{
+		queue:           func() string { return "q" },
+		inProgressQueue: func() string { return "inprog-q" },
+		fetch:           func() { <-closeCh },
+		acknowledge:     func(m *Msg) { ackCh <- m },
+		ready:           func() chan bool { return readyCh },
+		messages:        func() chan *Msg { return msgCh },
+		close:           func() { close(closeCh) },
+		closed: func() bool {
+			select {
+			case <-closeCh:
+				return true
+			default:
+				return false
+			}
+		},
+	}
+
+	release := make(chan bool)
+	w := newWorker(testLogger, "q", 1, func(m *Msg) error {
+		<-release
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		w.start(&df)
+		wg.Done()
+	}()
+
+	send := func(timeout time.Duration) bool {
+		msg, _ := NewMsg(`{"jid":"1"}`)
+		select {
+		case msgCh <- msg:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+
+	assert.True(t, send(100*time.Millisecond))
+	assert.False(t, send(50*time.Millisecond))
+
+	// new runners take work right away
+	w.setConcurrency(3)
+	assert.True(t, send(100*time.Millisecond))
+	assert.True(t, send(100*time.Millisecond))
+	assert.False(t, send(50*time.Millisecond))
+	assert.Len(t, w.inProgressMessages(), 3)
+
+	// stopped runners finish their job first
+	w.setConcurrency(1)
+	assert.Equal(t, 1, w.getConcurrency())
+	assert.Len(t, w.inProgressMessages(), 3)
+	for i := 0; i < 3; i++ {
+		release <- true
+		assert.True(t, (<-ackCh).ack)
+	}
+	assert.Eventually(t, func() bool {
+		w.runnersLock.Lock()
+		defer w.runnersLock.Unlock()
+		return len(w.runners) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.True(t, send(100*time.Millisecond))
+	assert.False(t, send(50*time.Millisecond))
+
+	close(release)
+	w.quit()
+	wg.Wait()
+}
+
+func TestWorkerSetConcurrencyWhileStopped(t *testing.T) {
+	w := newWorker(nil, "q", 2, nil)
+	w.setConcurrency(5)
+	assert.Equal(t, 5, w.concurrency)
+	assert.Empty(t, w.runners)
+
+	w.setConcurrency(0)
+	assert.Equal(t, 1, w.concurrency)
+}
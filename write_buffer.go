@@ -0,0 +1,212 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+)
+
+const (
+	defaultWriteBufferMaxBytes      = 16 << 20
+	defaultWriteBufferRetryInterval = time.Second
+)
+
+// ErrWriteBufferFull is reported for the acknowledgements which were neither written to Redis nor
+// buffered, leaving their job in progress until it is requeued
+var ErrWriteBufferFull = errors.New("write buffer full")
+
+// WriteBufferOptions configures the buffering of the acknowledgements and retries Redis refused, such as
+// while it is out of memory or read-only, and of the requeues of the jobs abandoned at shutdown. Buffered
+// writes are retried in order until Redis takes them, and their jobs aren't fetched again from their
+// in-progress queue meanwhile. A stopping manager keeps retrying them once its workers stopped, for up to
+// its ShutdownTimeout, or once without one. Writes still buffered when the process dies are lost, and
+// their jobs run again once requeued like any other job left in progress.
+type WriteBufferOptions struct {
+	// Optional cap on the size of the buffered payloads, defaults to 16MB
+	MaxBytes int
+	// Optional interval between attempts at writing the buffer, defaults to a second
+	RetryInterval time.Duration
+}
+
+func (o WriteBufferOptions) withDefaults() WriteBufferOptions {
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = defaultWriteBufferMaxBytes
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = defaultWriteBufferRetryInterval
+	}
+	return o
+}
+
+// bufferedWrite acknowledges a job, after writing its retry when it has one, or requeues the jobs left in
+// an in-progress queue
+type bufferedWrite struct {
+	inProgressQueue string
+	message         string
+	retryAt         float64
+	retry           string
+	requeue         string
+	// requeued is told about the messages the requeue pushed back
+	requeued func(messages []string)
+}
+
+func (w *bufferedWrite) size() int {
+	return len(w.inProgressQueue) + len(w.message) + len(w.retry) + len(w.requeue)
+}
+
+// apply writes the retry, once, then removes the message from its in-progress queue
+func (w *bufferedWrite) apply(ctx context.Context, store storage.Store) error {
+	if w.requeue != "" {
+		messages, err := store.RequeueMessagesFromInProgressQueue(ctx, w.inProgressQueue, w.requeue)
+		if w.requeued != nil {
+			w.requeued(messages)
+		}
+		return err
+	}
+	if w.retry != "" {
+		if err := store.EnqueueRetriedMessage(ctx, w.retryAt, w.retry); err != nil {
+			return err
+		}
+		w.retry = ""
+	}
+	return store.AcknowledgeMessage(ctx, w.inProgressQueue, w.message)
+}
+
+type writeBuffer struct {
+	opts   WriteBufferOptions
+	lock   sync.Mutex
+	writes []*bufferedWrite
+	bytes  int
+}
+
+func newWriteBuffer(opts *WriteBufferOptions) *writeBuffer {
+	if opts == nil {
+		return nil
+	}
+	return &writeBuffer{opts: opts.withDefaults()}
+}
+
+// add buffers w, returning false when it doesn't fit
+func (b *writeBuffer) add(w *bufferedWrite) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.bytes+w.size() > b.opts.MaxBytes {
+		return false
+	}
+	b.writes = append(b.writes, w)
+	b.bytes += w.size()
+	return true
+}
+
+// has tells whether the acknowledgement of message from inProgressQueue is buffered
+func (b *writeBuffer) has(inProgressQueue, message string) bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, w := range b.writes {
+		if w.inProgressQueue == inProgressQueue && w.message == message {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *writeBuffer) len() int {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.writes)
+}
+
+// flush applies the buffered writes in order, stopping at the first one Redis refuses. It returns how
+// many were written.
+func (b *writeBuffer) flush(ctx context.Context, store storage.Store) (int, error) {
+	written := 0
+	for {
+		b.lock.Lock()
+		if len(b.writes) == 0 {
+			b.lock.Unlock()
+			return written, nil
+		}
+		// the size as buffered, before apply drops the written retry
+		w, size := b.writes[0], b.writes[0].size()
+		b.lock.Unlock()
+
+		if err := w.apply(ctx, store); err != nil {
+			return written, err
+		}
+
+		b.lock.Lock()
+		b.writes = b.writes[1:]
+		b.bytes -= size
+		b.lock.Unlock()
+		written++
+	}
+}
+
+// BufferedWrites returns the number of acknowledgements waiting for Redis to take writes again
+func (m *Manager) BufferedWrites() int {
+	return m.writes.len()
+}
+
+// runWriteBuffer retries the buffered writes every interval until ctx is done. The writes of the jobs
+// still draining are left to drainWriteBuffer.
+func (m *Manager) runWriteBuffer(ctx context.Context) {
+	ticker := time.NewTicker(m.writes.opts.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.flushWriteBuffer(ctx)
+		}
+	}
+}
+
+// drainWriteBuffer retries the buffered writes of a stopped manager every interval until Redis took them
+// all or the shutdown timeout passed. An attempt started before the timeout runs to its end.
+func (m *Manager) drainWriteBuffer() {
+	if m.writes == nil {
+		return
+	}
+	deadline := time.Now().Add(m.opts.ShutdownTimeout)
+	for {
+		m.flushWriteBuffer(context.Background())
+		pending := m.writes.len()
+		if pending == 0 {
+			return
+		}
+		if time.Now().Add(m.writes.opts.RetryInterval).After(deadline) {
+			m.logger.Println("ERR: stopping with", pending, "buffered writes, their jobs stay in progress")
+			return
+		}
+		time.Sleep(m.writes.opts.RetryInterval)
+	}
+}
+
+// bufferRequeue buffers the requeue of the jobs w left in inProgressQueue, returning false when it doesn't fit
+func (m *Manager) bufferRequeue(w *worker, inProgressQueue, queue string) bool {
+	return m.writes != nil && m.writes.add(&bufferedWrite{inProgressQueue: inProgressQueue, requeue: queue, requeued: w.recordRequeued})
+}
+
+func (m *Manager) flushWriteBuffer(ctx context.Context) {
+	if m.writes.len() == 0 {
+		return
+	}
+	written, err := m.writes.flush(ctx, m.opts.store)
+	if written > 0 {
+		m.logger.Println("wrote", written, "buffered writes")
+	}
+	if err != nil && ctx.Err() == nil {
+		m.logger.Println("ERR: couldn't write", m.writes.len(), "buffered writes:", err)
+		m.reportError("", PhaseAcknowledge, err)
+	}
+}
@@ -0,0 +1,216 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/go-workers2/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var errOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'")
+
+// refusingStore refuses acknowledgements and retries while refuse is set
+type refusingStore struct {
+	storage.Store
+	refuse bool
+}
+
+func (s *refusingStore) AcknowledgeMessage(ctx context.Context, queue string, message string) error {
+	if s.refuse {
+		return errOOM
+	}
+	return s.Store.AcknowledgeMessage(ctx, queue, message)
+}
+
+func (s *refusingStore) EnqueueRetriedMessage(ctx context.Context, priority float64, message string) error {
+	if s.refuse {
+		return errOOM
+	}
+	return s.Store.EnqueueRetriedMessage(ctx, priority, message)
+}
+
+// shutdownRefusingStore refuses the first acknowledgements and requeues
+type shutdownRefusingStore struct {
+	storage.Store
+	refusals int32
+}
+
+func (s *shutdownRefusingStore) refused() bool {
+	return atomic.AddInt32(&s.refusals, -1) >= 0
+}
+
+func (s *shutdownRefusingStore) AcknowledgeMessage(ctx context.Context, queue string, message string) error {
+	if s.refused() {
+		return errOOM
+	}
+	return s.Store.AcknowledgeMessage(ctx, queue, message)
+}
+
+func (s *shutdownRefusingStore) RequeueMessagesFromInProgressQueue(ctx context.Context, inprogressQueue, queue string) ([]string, error) {
+	if s.refused() {
+		return nil, errOOM
+	}
+	return s.Store.RequeueMessagesFromInProgressQueue(ctx, inprogressQueue, queue)
+}
+
+func TestWriteBufferAcknowledgements(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	store := &refusingStore{Store: opts.store, refuse: true}
+	opts.store = store
+
+	fetcher := newSimpleFetcher("buffered", opts, true)
+	fetcher.writes = newWriteBuffer(&WriteBufferOptions{})
+	var reported []error
+	fetcher.onError = func(queue, phase string, err error) {
+		reported = append(reported, err)
+	}
+
+	message, _ := NewMsg(`{"jid":"1","class":"any"}`)
+	opts.client.LPush(ctx, "prod:queue:"+fetcher.InProgressQueue(), message.OriginalJson())
+
+	fetcher.Acknowledge(message)
+	assert.Equal(t, []error{errOOM}, reported)
+	assert.Equal(t, 1, fetcher.writes.len())
+	assert.True(t, fetcher.writes.has(fetcher.InProgressQueue(), message.OriginalJson()))
+
+	written, err := fetcher.writes.flush(ctx, store)
+	assert.Equal(t, errOOM, err)
+	assert.Equal(t, 0, written)
+
+	store.refuse = false
+	written, err = fetcher.writes.flush(ctx, store)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, written)
+	assert.Equal(t, 0, fetcher.writes.len())
+	assert.Equal(t, int64(0), opts.client.LLen(ctx, "prod:queue:"+fetcher.InProgressQueue()).Val())
+}
+
+func TestWriteBufferRetries(t *testing.T) {
+	ctx := context.Background()
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.WriteBuffer = &WriteBufferOptions{}
+	store := &refusingStore{Store: opts.store, refuse: true}
+	opts.store = store
+	mgr, err := newManager(opts)
+	assert.NoError(t, err)
+
+	message, _ := NewMsg(`{"jid":"1","class":"any","retry":true}`)
+	err = retryProcessError("buffered", mgr, message, errors.New("failed"))
+	assert.Equal(t, errOOM, err)
+	assert.True(t, message.ack)
+
+	fetcher := newSimpleFetcher("buffered", opts, true)
+	fetcher.writes = mgr.writes
+	opts.client.LPush(ctx, "prod:queue:"+fetcher.InProgressQueue(), message.OriginalJson())
+	fetcher.Acknowledge(message)
+	assert.Equal(t, 1, mgr.BufferedWrites())
+
+	store.refuse = false
+	mgr.flushWriteBuffer(ctx)
+	assert.Equal(t, 0, mgr.BufferedWrites())
+	assert.Equal(t, int64(1), opts.client.ZCard(ctx, "prod:"+storage.RetryKey).Val())
+	assert.Equal(t, int64(0), opts.client.LLen(ctx, "prod:queue:"+fetcher.InProgressQueue()).Val())
+}
+
+func TestWriteBufferFull(t *testing.T) {
+	opts, err := SetupDefaultTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.store = &refusingStore{Store: opts.store, refuse: true}
+
+	fetcher := newSimpleFetcher("buffered", opts, true)
+	fetcher.writes = newWriteBuffer(&WriteBufferOptions{MaxBytes: 10})
+	var reported []error
+	fetcher.onError = func(queue, phase string, err error) {
+		reported = append(reported, err)
+	}
+
+	message, _ := NewMsg(`{"jid":"1","class":"any"}`)
+	fetcher.Acknowledge(message)
+	assert.Equal(t, []error{errOOM, ErrWriteBufferFull}, reported)
+	assert.Equal(t, 0, fetcher.writes.len())
+}
+
+func TestWriteBufferFlushedAfterDrain(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptionsWithNamespace("wbdraintest")
+	opts.PollInterval = time.Second
+	opts.ShutdownTimeout = 2 * time.Second
+	opts.WriteBuffer = &WriteBufferOptions{RetryInterval: 50 * time.Millisecond}
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	mgr.opts.store = &shutdownRefusingStore{Store: mgr.opts.store, refusals: 3}
+
+	cc := NewCallCounter()
+	mgr.AddWorker("drain_queue", 1, cc.F, NopMiddleware)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(ctx)
+		wg.Done()
+	}()
+
+	_, err = mgr.Producer().Enqueue("drain_queue", "Drained", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	// the job completes during the drain, while Redis refuses its acknowledgement
+	mgr.Stop()
+	time.Sleep(50 * time.Millisecond)
+	cc.ackSyncCh <- true
+	wg.Wait()
+
+	assert.Equal(t, 0, mgr.BufferedWrites())
+	inProgress, err := mgr.opts.store.ListMessages(ctx, mgr.workers[0].inProgressQueue)
+	assert.NoError(t, err)
+	assert.Empty(t, inProgress)
+}
+
+func TestWriteBufferRequeuesAbandoned(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptionsWithNamespace("wbabandontest")
+	opts.PollInterval = time.Second
+	opts.ShutdownTimeout = 200 * time.Millisecond
+	opts.WriteBuffer = &WriteBufferOptions{RetryInterval: 20 * time.Millisecond}
+	mgr, err := newTestManager(opts, true)
+	assert.NoError(t, err)
+	mgr.opts.store = &shutdownRefusingStore{Store: mgr.opts.store, refusals: 2}
+
+	cc := NewCallCounter()
+	mgr.AddWorker("abandon_queue", 1, cc.F, NopMiddleware)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run(ctx)
+		wg.Done()
+	}()
+
+	jid, err := mgr.Producer().Enqueue("abandon_queue", "Stuck", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	// Redis refuses the requeue of the abandoned job at first
+	mgr.Stop()
+	wg.Wait()
+
+	assert.Equal(t, 0, mgr.BufferedWrites())
+	report := mgr.ShutdownReport()
+	assert.Equal(t, []ShutdownJob{{Queue: "abandon_queue", Jid: jid, Class: "Stuck"}}, report.Requeued)
+	assert.Empty(t, report.RequeueFailed)
+	queued, err := mgr.opts.store.ListMessages(ctx, "abandon_queue")
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+	inProgress, err := mgr.opts.store.ListMessages(ctx, mgr.workers[0].inProgressQueue)
+	assert.NoError(t, err)
+	assert.Empty(t, inProgress)
+
+	// release the abandoned runner
+	cc.ackSyncCh <- true
+}